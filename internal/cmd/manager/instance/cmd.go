@@ -25,11 +25,14 @@ import (
 
 	"github.com/cloudnative-pg/cloudnative-pg/internal/cmd/manager/instance/initdb"
 	"github.com/cloudnative-pg/cloudnative-pg/internal/cmd/manager/instance/join"
+	"github.com/cloudnative-pg/cloudnative-pg/internal/cmd/manager/instance/listbackups"
 	"github.com/cloudnative-pg/cloudnative-pg/internal/cmd/manager/instance/pgbasebackup"
+	"github.com/cloudnative-pg/cloudnative-pg/internal/cmd/manager/instance/pgrewind"
 	"github.com/cloudnative-pg/cloudnative-pg/internal/cmd/manager/instance/restore"
 	"github.com/cloudnative-pg/cloudnative-pg/internal/cmd/manager/instance/restoresnapshot"
 	"github.com/cloudnative-pg/cloudnative-pg/internal/cmd/manager/instance/run"
 	"github.com/cloudnative-pg/cloudnative-pg/internal/cmd/manager/instance/status"
+	"github.com/cloudnative-pg/cloudnative-pg/internal/cmd/manager/instance/verifyconfig"
 	"github.com/cloudnative-pg/cloudnative-pg/pkg/postgres"
 )
 
@@ -51,8 +54,11 @@ func NewCmd() *cobra.Command {
 	cmd.AddCommand(run.NewCmd())
 	cmd.AddCommand(status.NewCmd())
 	cmd.AddCommand(pgbasebackup.NewCmd())
+	cmd.AddCommand(pgrewind.NewCmd())
 	cmd.AddCommand(restore.NewCmd())
 	cmd.AddCommand(restoresnapshot.NewCmd())
+	cmd.AddCommand(verifyconfig.NewCmd())
+	cmd.AddCommand(listbackups.NewCmd())
 
 	return cmd
 }
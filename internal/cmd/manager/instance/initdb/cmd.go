@@ -20,6 +20,8 @@ package initdb
 import (
 	"context"
 	"os"
+	"sort"
+	"time"
 
 	"github.com/cloudnative-pg/machinery/pkg/log"
 	"github.com/kballard/go-shellquote"
@@ -36,8 +38,12 @@ import (
 func NewCmd() *cobra.Command {
 	var appDBName string
 	var appUser string
+	var appPasswordFile string
+	var passwordEncryption string
 	var clusterName string
 	var initDBFlagsString string
+	var textSearchConfig string
+	var allowGroupAccess bool
 	var namespace string
 	var parentNode string
 	var pgData string
@@ -49,6 +55,64 @@ func NewCmd() *cobra.Command {
 	var postInitSQLRefsFolder string
 	var postInitApplicationSQLRefsFolder string
 	var postInitTemplateSQLRefsFolder string
+	var socketDirectory string
+	var systemIdentifierFile string
+	var connectTimeout time.Duration
+	var applicationName string
+	var statementTimeout time.Duration
+	var initialDumpFile string
+	var initialDumpNoOwner bool
+	var initialDumpRole string
+	var initialTransactionID uint32
+	var initDBSettings map[string]string
+	var healthCheckAddress string
+	var sslCertFile string
+	var sslKeyFile string
+	var sslCAFile string
+	var maxConnections int
+	var superuserReservedConnections int
+	var idleSessionTimeout string
+	var sharedBuffers string
+	var effectiveCacheSize string
+	var workMem string
+	var checkpointCompletionTarget float64
+	var checkpointTimeout string
+	var preallocateWAL bool
+	var maxWalSize string
+	var minWalSize string
+	var hugePages string
+	var synchronousCommit string
+	var autovacuumMaxWorkers int
+	var autovacuumNaptime string
+	var autovacuumVacuumScaleFactor float64
+	var authLocal string
+	var authHost string
+	var trackCommitTimestamp bool
+	var rowSecurity bool
+	var enforceRowSecurity bool
+	var logLinePrefix string
+	var timezone string
+	var dateStyle string
+	var tablespaces map[string]string
+	var readOnlyRoles map[string]string
+	var appRoleMemberships []string
+	var generateAppPassword bool
+	var generatedAppPasswordFile string
+	var defaultTablespace string
+	var tempTablespaces []string
+	var initDBGracePeriod time.Duration
+	var hbaRulesFiles []string
+	var fsync bool
+	var jit bool
+	var maxParallelWorkersPerGather int
+	var effectiveIOConcurrency int
+	var detectStorage bool
+	var skipApplicationSetup bool
+	var recreateApplicationDatabase bool
+	var verifyArchiving bool
+	var initOnly bool
+	var additionalDatabases []string
+	var databaseCreationConcurrency int
 
 	cmd := &cobra.Command{
 		Use: "init [options]",
@@ -87,23 +151,97 @@ func NewCmd() *cobra.Command {
 			}
 
 			info := postgres.InitInfo{
-				ApplicationDatabase:    appDBName,
-				ApplicationUser:        appUser,
-				ClusterName:            clusterName,
-				InitDBOptions:          initDBFlags,
-				Namespace:              namespace,
-				ParentNode:             parentNode,
-				PgData:                 pgData,
-				PgWal:                  pgWal,
-				PodName:                podName,
-				PostInitSQL:            postInitSQL,
-				PostInitApplicationSQL: postInitApplicationSQL,
-				PostInitTemplateSQL:    postInitTemplateSQL,
+				ApplicationDatabase:     appDBName,
+				ApplicationUser:         appUser,
+				ApplicationPasswordFile: appPasswordFile,
+				PasswordEncryption:      passwordEncryption,
+				ClusterName:             clusterName,
+				InitDBOptions:           initDBFlags,
+				TextSearchConfig:        textSearchConfig,
+				AllowGroupAccess:        allowGroupAccess,
+				Namespace:               namespace,
+				ParentNode:              parentNode,
+				PgData:                  pgData,
+				PgWal:                   pgWal,
+				PodName:                 podName,
+				PostInitSQL:             postInitSQL,
+				PostInitApplicationSQL:  postInitApplicationSQL,
+				PostInitTemplateSQL:     postInitTemplateSQL,
 				// If the value for an SQLRefsFolder is empty,
 				// bootstrap will do nothing for that specific PostInit option.
 				PostInitApplicationSQLRefsFolder: postInitApplicationSQLRefsFolder,
 				PostInitTemplateSQLRefsFolder:    postInitTemplateSQLRefsFolder,
 				PostInitSQLRefsFolder:            postInitSQLRefsFolder,
+				SocketDirectory:                  socketDirectory,
+				SystemIdentifierFile:             systemIdentifierFile,
+				ConnectTimeout:                   connectTimeout,
+				ApplicationName:                  applicationName,
+				StatementTimeout:                 statementTimeout,
+				InitialDumpFile:                  initialDumpFile,
+				InitialDumpNoOwner:               initialDumpNoOwner,
+				InitialDumpRole:                  initialDumpRole,
+				InitialTransactionID:             initialTransactionID,
+				InitDBSettings:                   initDBSettings,
+				HealthCheckAddress:               healthCheckAddress,
+				SSLCertFile:                      sslCertFile,
+				SSLKeyFile:                       sslKeyFile,
+				SSLCAFile:                        sslCAFile,
+				MaxConnections:                   maxConnections,
+				SuperuserReservedConnections:     superuserReservedConnections,
+				IdleSessionTimeout:               idleSessionTimeout,
+				SharedBuffers:                    sharedBuffers,
+				EffectiveCacheSize:               effectiveCacheSize,
+				WorkMem:                          workMem,
+				CheckpointTimeout:                checkpointTimeout,
+				PreallocateWAL:                   preallocateWAL,
+				MaxWalSize:                       maxWalSize,
+				MinWalSize:                       minWalSize,
+				HugePages:                        hugePages,
+				SynchronousCommit:                synchronousCommit,
+				AutovacuumMaxWorkers:             autovacuumMaxWorkers,
+				AutovacuumNaptime:                autovacuumNaptime,
+				AuthLocal:                        authLocal,
+				AuthHost:                         authHost,
+				TrackCommitTimestamp:             trackCommitTimestamp,
+				RowSecurity:                      rowSecurity,
+				EnforceRowSecurity:               enforceRowSecurity,
+				LogLinePrefix:                    logLinePrefix,
+				Timezone:                         timezone,
+				DateStyle:                        dateStyle,
+				Tablespaces:                      parseTablespaces(tablespaces),
+				DefaultTablespace:                defaultTablespace,
+				TempTablespaces:                  tempTablespaces,
+				ReadOnlyRoles:                    parseReadOnlyRoles(readOnlyRoles),
+				ApplicationRoleMemberships:       appRoleMemberships,
+				GenerateApplicationPassword:      generateAppPassword,
+				GeneratedApplicationPasswordFile: generatedAppPasswordFile,
+				InitDBGracePeriod:                initDBGracePeriod,
+				HBARulesFiles:                    hbaRulesFiles,
+				SkipApplicationSetup:             skipApplicationSetup,
+				RecreateApplicationDatabase:      recreateApplicationDatabase,
+				VerifyArchiving:                  verifyArchiving,
+				InitOnly:                         initOnly,
+				AdditionalDatabases:              additionalDatabases,
+				DatabaseCreationConcurrency:      databaseCreationConcurrency,
+				DetectStorage:                    detectStorage,
+			}
+			if cmd.Flags().Changed("fsync") {
+				info.Fsync = &fsync
+			}
+			if cmd.Flags().Changed("checkpoint-completion-target") {
+				info.CheckpointCompletionTarget = &checkpointCompletionTarget
+			}
+			if cmd.Flags().Changed("autovacuum-vacuum-scale-factor") {
+				info.AutovacuumVacuumScaleFactor = &autovacuumVacuumScaleFactor
+			}
+			if cmd.Flags().Changed("jit") {
+				info.JIT = &jit
+			}
+			if cmd.Flags().Changed("max-parallel-workers-per-gather") {
+				info.MaxParallelWorkersPerGather = &maxParallelWorkersPerGather
+			}
+			if cmd.Flags().Changed("effective-io-concurrency") {
+				info.EffectiveIOConcurrency = &effectiveIOConcurrency
 			}
 
 			return initSubCommand(ctx, info)
@@ -121,10 +259,117 @@ func NewCmd() *cobra.Command {
 		"The name of the application containing the database")
 	cmd.Flags().StringVar(&appUser, "app-user", "app",
 		"The name of the application user")
+	cmd.Flags().StringVar(&appPasswordFile, "app-password-file", "", "The file containing "+
+		"the password of the application user. When empty, the password is not managed")
+	cmd.Flags().StringVar(&passwordEncryption, "password-encryption", "", "The password_encryption "+
+		"used while setting the passwords, one of md5 or scram-sha-256. When empty, "+
+		"scram-sha-256 is used where it is the PostgreSQL default")
 	cmd.Flags().StringVar(&clusterName, "cluster-name", os.Getenv("CLUSTER_NAME"), "The name of the "+
 		"current cluster in k8s, used to coordinate switchover and failover")
 	cmd.Flags().StringVar(&initDBFlagsString, "initdb-flags", "", "The list of flags to be passed "+
 		"to initdb while creating the initial database")
+	cmd.Flags().StringVar(&textSearchConfig, "text-search-config", "", "The default text search "+
+		"configuration of the new instance, like pg_catalog.english")
+	cmd.Flags().BoolVar(&allowGroupAccess, "allow-group-access", false, "Create the data directory "+
+		"readable by the group of its owner")
+	cmd.Flags().StringToStringVar(&initDBSettings, "initdb-set", nil, "The PostgreSQL parameters "+
+		"to be written in postgresql.conf by initdb, as name=value pairs")
+	cmd.Flags().StringVar(&sslCertFile, "ssl-cert-file", "", "The server certificate "+
+		"used to enable SSL in the transient instance")
+	cmd.Flags().StringVar(&sslKeyFile, "ssl-key-file", "", "The private key of the server "+
+		"certificate used by the transient instance")
+	cmd.Flags().StringVar(&sslCAFile, "ssl-ca-file", "", "The CA certificate used to verify "+
+		"the connections to the transient instance")
+	cmd.Flags().IntVar(&maxConnections, "max-connections", 0, "The max_connections "+
+		"parameter of the new instance")
+	cmd.Flags().IntVar(&superuserReservedConnections, "superuser-reserved-connections", 0,
+		"The superuser_reserved_connections parameter of the new instance")
+	cmd.Flags().StringVar(&idleSessionTimeout, "idle-session-timeout", "", "The idle_session_timeout "+
+		"parameter of the new instance, like 10min. Requires PostgreSQL 14 or later")
+	cmd.Flags().StringVar(&sharedBuffers, "shared-buffers", "", "The shared_buffers "+
+		"parameter of the new instance, like 128MB")
+	cmd.Flags().StringVar(&effectiveCacheSize, "effective-cache-size", "", "The effective_cache_size "+
+		"parameter of the new instance, like 4GB")
+	cmd.Flags().StringVar(&workMem, "work-mem", "", "The work_mem "+
+		"parameter of the new instance, like 4MB")
+	cmd.Flags().Float64Var(&checkpointCompletionTarget, "checkpoint-completion-target", 0,
+		"The checkpoint_completion_target of the new instance, between 0 and 1")
+	cmd.Flags().StringVar(&checkpointTimeout, "checkpoint-timeout", "", "The checkpoint_timeout "+
+		"of the new instance, like 15min")
+	cmd.Flags().BoolVar(&preallocateWAL, "preallocate-wal", false, "Create zeroed WAL segments, "+
+		"up to min_wal_size, once the data directory is created")
+	cmd.Flags().StringVar(&maxWalSize, "max-wal-size", "", "The max_wal_size "+
+		"parameter of the new instance, like 4GB")
+	cmd.Flags().StringVar(&minWalSize, "min-wal-size", "", "The min_wal_size "+
+		"parameter of the new instance, like 1GB")
+	cmd.Flags().IntVar(&autovacuumMaxWorkers, "autovacuum-max-workers", 0, "The autovacuum_max_workers "+
+		"parameter of the new instance")
+	cmd.Flags().StringVar(&autovacuumNaptime, "autovacuum-naptime", "", "The autovacuum_naptime "+
+		"parameter of the new instance, like 30s")
+	cmd.Flags().Float64Var(&autovacuumVacuumScaleFactor, "autovacuum-vacuum-scale-factor", 0.2,
+		"The autovacuum_vacuum_scale_factor parameter of the new instance, between 0 and 100")
+	cmd.Flags().StringVar(&synchronousCommit, "synchronous-commit", "", "The synchronous_commit "+
+		"parameter of the new instance: on, off, local, remote_write or remote_apply")
+	cmd.Flags().StringVar(&hugePages, "huge-pages", "", "The huge_pages parameter of the new "+
+		"instance, one of off, on and try. When on, the free huge pages of the node are checked in advance")
+	cmd.Flags().StringVar(&authLocal, "auth-local", "", "The authentication method written by "+
+		"initdb for the local connections. When not set, the default of initdb is used")
+	cmd.Flags().StringVar(&authHost, "auth-host", "", "The authentication method written by "+
+		"initdb for the host connections. When not set, the default of initdb is used")
+	cmd.Flags().BoolVar(&trackCommitTimestamp, "track-commit-timestamp", false, "Enable "+
+		"track_commit_timestamp in the new instance, before any data is written")
+	cmd.Flags().BoolVar(&rowSecurity, "row-security", false, "Explicitly set row_security in the "+
+		"new instance, so that the row security policies are always applied")
+	cmd.Flags().BoolVar(&enforceRowSecurity, "enforce-row-security", false, "Set row_security "+
+		"on the application database too, taking precedence over the configuration files")
+	cmd.Flags().StringVar(&logLinePrefix, "log-line-prefix", "", "The log_line_prefix "+
+		"of the new instance")
+	cmd.Flags().StringVar(&timezone, "timezone", "", "The timezone of the new instance, "+
+		"as a name of the tz database like Europe/Rome")
+	cmd.Flags().StringVar(&dateStyle, "datestyle", "", "The datestyle of the new instance, "+
+		"like 'ISO, DMY'")
+	cmd.Flags().StringToStringVar(&tablespaces, "tablespace", nil, "The tablespaces to be "+
+		"created in the new instance, as name=location pairs")
+	cmd.Flags().StringVar(&defaultTablespace, "default-tablespace", "", "The default_tablespace "+
+		"of the new instance, to be chosen among the tablespaces created at bootstrap")
+	cmd.Flags().StringSliceVar(&tempTablespaces, "temp-tablespaces", nil, "The temp_tablespaces "+
+		"of the new instance, to be chosen among the tablespaces created at bootstrap")
+	cmd.Flags().StringToStringVar(&readOnlyRoles, "read-only-role", nil, "The read-only roles to be "+
+		"created in the application database, as name=password-file pairs. The password file can be empty")
+	cmd.Flags().StringSliceVar(&appRoleMemberships, "app-role-membership", nil, "The existing roles, "+
+		"like pg_monitor, the application user is made a member of")
+	cmd.Flags().BoolVar(&generateAppPassword, "generate-app-password", false, "Generate a random "+
+		"password for the application user, when it is not otherwise provided")
+	cmd.Flags().StringVar(&generatedAppPasswordFile, "generated-app-password-file", "", "The file, "+
+		"which must not exist, where the generated password of the application user is written")
+	cmd.Flags().DurationVar(&initDBGracePeriod, "initdb-grace-period", postgres.DefaultInitDBGracePeriod,
+		"The time initdb is given to clean up after itself when interrupted, before being killed")
+	cmd.Flags().StringArrayVar(&hbaRulesFiles, "hba-rules-file", nil, "A file containing pg_hba.conf "+
+		"rules to be appended to the ones created by initdb. It can be repeated, preserving the order")
+	cmd.Flags().BoolVar(&fsync, "fsync", true, "Set fsync in the new instance. "+
+		"Disabling it can corrupt the database after a crash: use it only for benchmarks")
+	cmd.Flags().BoolVar(&jit, "jit", true, "Set jit in the new instance")
+	cmd.Flags().IntVar(&maxParallelWorkersPerGather, "max-parallel-workers-per-gather", 2,
+		"The max_parallel_workers_per_gather parameter of the new instance. Zero disables the parallel queries")
+	cmd.Flags().IntVar(&effectiveIOConcurrency, "effective-io-concurrency", 1, "The "+
+		"effective_io_concurrency parameter of the new instance, between 0 and 1000")
+	cmd.Flags().BoolVar(&detectStorage, "detect-storage", false, "Choose the effective_io_concurrency "+
+		"of the new instance after the type of the device holding the data directory, when not set")
+	cmd.Flags().BoolVar(&skipApplicationSetup, "skip-application-setup", false, "Don't create the "+
+		"application database and user, as needed by the instances used only as replication or restore targets. "+
+		"The post-init SQL for the postgres and template1 databases still runs")
+	cmd.Flags().BoolVar(&initOnly, "init-only", false,
+		"Create the data directory and its configuration without starting PostgreSQL, "+
+			"leaving the application setup to a later step")
+	cmd.Flags().BoolVar(&recreateApplicationDatabase, "recreate-application-database", false,
+		"Drop the application database when it already exists, terminating its connections, and "+
+			"create it again. Every object in the existing database is lost")
+	cmd.Flags().BoolVar(&verifyArchiving, "verify-archiving", false,
+		"Force a WAL switch after the bootstrap and fail unless the WAL file is archived")
+	cmd.Flags().StringArrayVar(&additionalDatabases, "additional-database", nil, "A database, owned by "+
+		"the application user, to be created beside the application database. It can be repeated")
+	cmd.Flags().IntVar(&databaseCreationConcurrency, "database-creation-concurrency",
+		postgres.DefaultDatabaseCreationConcurrency, "The maximum number of additional databases created at the same time")
 	cmd.Flags().StringVar(&namespace, "namespace", os.Getenv("NAMESPACE"), "The namespace of "+
 		"the cluster and the pod in k8s")
 	cmd.Flags().StringVar(&parentNode, "parent-node", "", "The origin node")
@@ -145,11 +390,66 @@ func NewCmd() *cobra.Command {
 			"against the application database immediately after its creation")
 	cmd.Flags().StringVar(&postInitTemplateSQLRefsFolder, "post-init-template-sql-refs-folder",
 		"", "The folder contains a set of SQL files to be executed in alphabetical order")
+	cmd.Flags().StringVar(&socketDirectory, "socket-directory", "", "The directory where "+
+		"the transient instance used during the bootstrap creates its Unix socket")
+	cmd.Flags().DurationVar(&connectTimeout, "connect-timeout", postgres.DefaultBootstrapConnectTimeout,
+		"The time to wait while connecting to the transient instance, 0 meaning no limit")
+	cmd.Flags().StringVar(&applicationName, "application-name", postgres.DefaultBootstrapApplicationName,
+		"The application_name of the connections to the transient instance, as shown in pg_stat_activity")
+	cmd.Flags().DurationVar(&statementTimeout, "statement-timeout", postgres.DefaultBootstrapStatementTimeout,
+		"The maximum duration of the statements executed on the transient instance, 0 meaning no limit. "+
+			"The logical dumps, the custom SQL and ANALYZE are not subject to it")
+	cmd.Flags().StringVar(&systemIdentifierFile, "system-identifier-file", "", "The file where "+
+		"the PostgreSQL system identifier is written once the data directory is ready")
+	cmd.Flags().StringVar(&initialDumpFile, "initial-dump-file", "", "The logical dump to be "+
+		"restored in the application database after the bootstrap. The format is detected automatically")
+	cmd.Flags().BoolVar(&initialDumpNoOwner, "initial-dump-no-owner", false, "Don't restore the "+
+		"original ownership of the objects of the initial dump, which must be a pg_dump archive")
+	cmd.Flags().StringVar(&initialDumpRole, "initial-dump-role", "", "The existing role the objects "+
+		"of the initial dump, which must be a pg_dump archive, are created with")
+	cmd.Flags().Uint32Var(&initialTransactionID, "initial-transaction-id", 0, "The next transaction "+
+		"ID of the new data directory. To be used only for testing, i.e. to test the transaction ID wraparound")
+	cmd.Flags().StringVar(&healthCheckAddress, "health-check-address", "", "The address, i.e. "+
+		"\":8010\", of the HTTP health endpoint exposed while the transient instance is active. "+
+		"Disabled when empty")
 	return cmd
 }
 
+// parseTablespaces converts the name=location pairs of the tablespaces
+// into their specification, sorted by name
+func parseTablespaces(tablespaces map[string]string) []postgres.TablespaceSpec {
+	result := make([]postgres.TablespaceSpec, 0, len(tablespaces))
+	for name, location := range tablespaces {
+		result = append(result, postgres.TablespaceSpec{Name: name, Location: location})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+
+	return result
+}
+
+// parseReadOnlyRoles converts the name=password-file pairs of the
+// read-only roles into their specification, sorted by name
+func parseReadOnlyRoles(roles map[string]string) []postgres.RoleSpec {
+	result := make([]postgres.RoleSpec, 0, len(roles))
+	for name, passwordFile := range roles {
+		result = append(result, postgres.RoleSpec{Name: name, PasswordFile: passwordFile})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+
+	return result
+}
+
 func initSubCommand(ctx context.Context, info postgres.InitInfo) error {
 	contextLogger := log.FromContext(ctx)
+	if err := info.VerifyConfiguration(); err != nil {
+		contextLogger.Error(err, "Invalid bootstrap configuration")
+		return err
+	}
+
 	err := info.CheckTargetDataDirectory(ctx)
 	if err != nil {
 		return err
@@ -161,5 +461,10 @@ func initSubCommand(ctx context.Context, info postgres.InitInfo) error {
 		return err
 	}
 
+	if err := info.ReportSystemIdentifier(ctx); err != nil {
+		contextLogger.Error(err, "Error while reporting the system identifier")
+		return err
+	}
+
 	return nil
 }
@@ -39,6 +39,8 @@ func NewCmd() *cobra.Command {
 	var pgData string
 	var pgWal string
 	var parentNode string
+	var parentPort int
+	var verifyReplicaConnectivity bool
 	var podName string
 	var clusterName string
 	var namespace string
@@ -62,10 +64,14 @@ func NewCmd() *cobra.Command {
 				WithClusterName(clusterName)
 
 			info := postgres.InitInfo{
-				PgData:     pgData,
-				PgWal:      pgWal,
-				ParentNode: parentNode,
-				PodName:    podName,
+				ClusterName: clusterName,
+				PgData:      pgData,
+				PgWal:       pgWal,
+				ParentNode:  parentNode,
+				ParentPort:  parentPort,
+				PodName:     podName,
+
+				VerifyReplicaConnectivity: verifyReplicaConnectivity,
 			}
 
 			return joinSubCommand(ctx, instance, info)
@@ -82,6 +88,10 @@ func NewCmd() *cobra.Command {
 	cmd.Flags().StringVar(&pgData, "pg-data", os.Getenv("PGDATA"), "The PGDATA to be created")
 	cmd.Flags().StringVar(&pgWal, "pg-wal", "", "the PGWAL to be created")
 	cmd.Flags().StringVar(&parentNode, "parent-node", "", "The origin node")
+	cmd.Flags().IntVar(&parentPort, "parent-port", 0, "The port of the origin node. "+
+		"When not set, the port of the local server is used")
+	cmd.Flags().BoolVar(&verifyReplicaConnectivity, "verify-replica-connectivity", false, "Check that "+
+		"the new replica can authenticate to the primary with the primary_conninfo written in its configuration")
 	cmd.Flags().StringVar(&podName, "pod-name", os.Getenv("POD_NAME"), "The name of this pod, to "+
 		"be checked against the cluster state")
 	cmd.Flags().StringVar(&namespace, "namespace", os.Getenv("NAMESPACE"), "The namespace of "+
@@ -0,0 +1,153 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package listbackups implements the "instance list-backups" subcommand of the operator
+package listbackups
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	barmanCatalog "github.com/cloudnative-pg/barman-cloud/pkg/catalog"
+	"github.com/spf13/cobra"
+
+	"github.com/cloudnative-pg/cloudnative-pg/pkg/management/postgres"
+)
+
+const (
+	// outputTable prints the backups as a table
+	outputTable = "table"
+
+	// outputJSON prints the backups as a JSON document
+	outputJSON = "json"
+)
+
+// backupEntry is a backup available in the object store
+type backupEntry struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name,omitempty"`
+	BeginTime *time.Time `json:"beginTime,omitempty"`
+	EndTime   *time.Time `json:"endTime,omitempty"`
+	TimeLine  int        `json:"timeline"`
+	BeginWal  string     `json:"beginWal"`
+	EndWal    string     `json:"endWal,omitempty"`
+	Error     string     `json:"error,omitempty"`
+}
+
+// NewCmd creates the "list-backups" subcommand
+func NewCmd() *cobra.Command {
+	var info postgres.InitInfo
+	var output string
+
+	cmd := &cobra.Command{
+		Use:           "list-backups [flags]",
+		Short:         "List the backups available in the object store the cluster is recovered from",
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		PreRunE: func(_ *cobra.Command, _ []string) error {
+			return validateOutput(output)
+		},
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			catalog, err := info.ListBackups(cmd.Context())
+			if err != nil {
+				return fmt.Errorf("while listing the backups: %w", err)
+			}
+
+			return printBackups(cmd.OutOrStdout(), catalog, output)
+		},
+	}
+
+	cmd.Flags().StringVar(&info.ClusterName, "cluster-name", os.Getenv("CLUSTER_NAME"), "The name of the "+
+		"current cluster in k8s")
+	cmd.Flags().StringVar(&info.Namespace, "namespace", os.Getenv("NAMESPACE"), "The namespace of "+
+		"the cluster and the Pod in k8s")
+	cmd.Flags().StringVarP(&output, "output", "o", outputTable, "The output format, table or json")
+
+	return cmd
+}
+
+// validateOutput checks the requested output format
+func validateOutput(output string) error {
+	if output != outputTable && output != outputJSON {
+		return fmt.Errorf("unknown output format %q, expected %s or %s", output, outputTable, outputJSON)
+	}
+	return nil
+}
+
+// printBackups writes the backups of the catalog in the requested format
+func printBackups(out io.Writer, catalog *barmanCatalog.Catalog, output string) error {
+	if err := validateOutput(output); err != nil {
+		return err
+	}
+
+	entries := make([]backupEntry, 0)
+	if catalog != nil {
+		for _, backup := range catalog.List {
+			entries = append(entries, backupEntry{
+				ID:        backup.ID,
+				Name:      backup.BackupName,
+				BeginTime: knownTime(backup.BeginTime),
+				EndTime:   knownTime(backup.EndTime),
+				TimeLine:  backup.TimeLine,
+				BeginWal:  backup.BeginWal,
+				EndWal:    backup.EndWal,
+				Error:     backup.Error,
+			})
+		}
+	}
+
+	if output == outputJSON {
+		return json.NewEncoder(out).Encode(entries)
+	}
+
+	writer := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(writer, "ID\tNAME\tBEGIN\tEND\tTIMELINE\tBEGIN WAL\tEND WAL\tERROR")
+	for _, entry := range entries {
+		_, _ = fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			entry.ID,
+			entry.Name,
+			formatTime(entry.BeginTime),
+			formatTime(entry.EndTime),
+			strconv.Itoa(entry.TimeLine),
+			entry.BeginWal,
+			entry.EndWal,
+			entry.Error,
+		)
+	}
+	return writer.Flush()
+}
+
+// knownTime returns the passed backup timestamp, or nil when it is unknown
+func knownTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	t = t.UTC()
+	return &t
+}
+
+// formatTime renders a backup timestamp, leaving it empty when unknown
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,125 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package listbackups
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	barmanCatalog "github.com/cloudnative-pg/barman-cloud/pkg/catalog"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const fakeBackupList = `{
+  "backups_list": [
+    {
+      "backup_label": null,
+      "begin_time": "Tue Jan 19 03:14:08 2038",
+      "end_time": "Tue Jan 19 04:14:08 2038",
+      "BeginTime": "0001-01-01T00:00:00Z",
+      "EndTime": "0001-01-01T00:00:00Z",
+      "begin_wal": "000000010000000000000005",
+      "end_wal": "000000010000000000000006",
+      "begin_xlog": "0/5000028",
+      "end_xlog": "0/6000100",
+      "systemid": "6885668674852188181",
+      "backup_id": "20380119T031408",
+      "backup_name": "nightly",
+      "error": "",
+      "timeline": 1
+    },
+    {
+      "backup_label": null,
+      "begin_time": "Mon Jan 18 03:14:08 2038",
+      "end_time": "",
+      "BeginTime": "0001-01-01T00:00:00Z",
+      "EndTime": "0001-01-01T00:00:00Z",
+      "begin_wal": "000000010000000000000002",
+      "end_wal": "",
+      "begin_xlog": "0/2000028",
+      "end_xlog": "",
+      "systemid": "6885668674852188181",
+      "backup_id": "20380118T031408",
+      "error": "failed to upload",
+      "timeline": 1
+    }
+  ]
+}`
+
+var _ = Describe("list-backups output", func() {
+	var catalog *barmanCatalog.Catalog
+
+	BeforeEach(func() {
+		var err error
+		catalog, err = barmanCatalog.NewCatalogFromBarmanCloudBackupList(fakeBackupList)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("prints the backups as a table, in the catalog order", func() {
+		var out bytes.Buffer
+		Expect(printBackups(&out, catalog, outputTable)).To(Succeed())
+
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		Expect(lines).To(HaveLen(3))
+		Expect(strings.Fields(lines[0])).To(Equal([]string{
+			"ID", "NAME", "BEGIN", "END", "TIMELINE", "BEGIN", "WAL", "END", "WAL", "ERROR",
+		}))
+		Expect(strings.Fields(lines[1])).To(Equal([]string{
+			"20380118T031408", "2038-01-18T03:14:08Z", "1", "000000010000000000000002",
+			"failed", "to", "upload",
+		}))
+		Expect(strings.Fields(lines[2])).To(Equal([]string{
+			"20380119T031408", "nightly", "2038-01-19T03:14:08Z", "2038-01-19T04:14:08Z", "1",
+			"000000010000000000000005", "000000010000000000000006",
+		}))
+	})
+
+	It("prints the backups as JSON", func() {
+		var out bytes.Buffer
+		Expect(printBackups(&out, catalog, outputJSON)).To(Succeed())
+
+		var entries []map[string]interface{}
+		Expect(json.Unmarshal(out.Bytes(), &entries)).To(Succeed())
+		Expect(entries).To(HaveLen(2))
+		Expect(entries[1]).To(Equal(map[string]interface{}{
+			"id":        "20380119T031408",
+			"name":      "nightly",
+			"beginTime": "2038-01-19T03:14:08Z",
+			"endTime":   "2038-01-19T04:14:08Z",
+			"timeline":  float64(1),
+			"beginWal":  "000000010000000000000005",
+			"endWal":    "000000010000000000000006",
+		}))
+		Expect(entries[0]).To(HaveKeyWithValue("error", "failed to upload"))
+		Expect(entries[0]).ToNot(HaveKey("endTime"))
+	})
+
+	It("prints an empty list when there are no backups", func() {
+		var out bytes.Buffer
+		Expect(printBackups(&out, &barmanCatalog.Catalog{}, outputJSON)).To(Succeed())
+		Expect(out.String()).To(Equal("[]\n"))
+	})
+
+	It("rejects unknown output formats", func() {
+		var out bytes.Buffer
+		Expect(printBackups(&out, catalog, "yaml")).To(MatchError(ContainSubstring("unknown output format")))
+		Expect(out.String()).To(BeEmpty())
+	})
+})
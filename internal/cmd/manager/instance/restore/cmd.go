@@ -20,7 +20,9 @@ package restore
 import (
 	"context"
 	"errors"
+	"fmt"
 	"os"
+	"time"
 
 	barmanCommand "github.com/cloudnative-pg/barman-cloud/pkg/command"
 	"github.com/cloudnative-pg/machinery/pkg/fileutils"
@@ -40,6 +42,36 @@ func NewCmd() *cobra.Command {
 	var namespace string
 	var pgData string
 	var pgWal string
+	var verifyOnly bool
+	var walOnly bool
+	var atomicRestore bool
+	var verifyWALContinuity bool
+	var backupID string
+	var localBackupPath string
+	var resetSystemIdentifier bool
+	var keepDatabases []string
+	var freeSpaceMargin int
+	var recoveryEndCommand string
+	var restoreCommand string
+	var targetImmediate bool
+	var noRestoreCommandWait bool
+	var recoveryTimeout time.Duration
+	var noArchiveOnRestore bool
+	var analyzeAfterRestore bool
+	var failOnCollationMismatch bool
+	var vacuumAfterRestore bool
+	var allowUnsafeRecoveryEndCommand bool
+	var socketDirectory string
+	var systemIdentifierFile string
+	var connectTimeout time.Duration
+	var applicationName string
+	var statementTimeout time.Duration
+	var keepOnFailure bool
+	var postRestoreSQLFile string
+	var dropStaleSlots bool
+	var resetAutoConf bool
+	var resetAutoConfSettings []string
+	var printRecoveryConfig bool
 
 	cmd := &cobra.Command{
 		Use:           "restore [flags]",
@@ -54,13 +86,47 @@ func NewCmd() *cobra.Command {
 			ctx := cmd.Context()
 
 			info := postgres.InitInfo{
-				ClusterName: clusterName,
-				Namespace:   namespace,
-				PgData:      pgData,
-				PgWal:       pgWal,
+				ClusterName:          clusterName,
+				Namespace:            namespace,
+				PgData:               pgData,
+				PgWal:                pgWal,
+				SocketDirectory:      socketDirectory,
+				SystemIdentifierFile: systemIdentifierFile,
+				ConnectTimeout:       connectTimeout,
+				ApplicationName:      applicationName,
+				StatementTimeout:     statementTimeout,
+				VerifyOnly:           verifyOnly,
+				WALOnly:              walOnly,
+				AtomicRestore:        atomicRestore,
+				VerifyWALContinuity:  verifyWALContinuity,
+				BackupID:             backupID,
+				LocalBackupPath:      localBackupPath,
+
+				ResetSystemIdentifier: resetSystemIdentifier,
+				KeepDatabases:         keepDatabases,
+				FreeSpaceMargin:       freeSpaceMargin,
+
+				RecoveryEndCommand:            recoveryEndCommand,
+				RestoreCommand:                restoreCommand,
+				TargetImmediate:               targetImmediate,
+				PromptPromotion:               noRestoreCommandWait,
+				RecoveryTimeout:               recoveryTimeout,
+				NoArchiveOnRestore:            noArchiveOnRestore,
+				AnalyzeAfterRestore:           analyzeAfterRestore || vacuumAfterRestore,
+				FailOnCollationMismatch:       failOnCollationMismatch,
+				VacuumAfterRestore:            vacuumAfterRestore,
+				AllowUnsafeRecoveryEndCommand: allowUnsafeRecoveryEndCommand,
+				PostRestoreSQLFile:            postRestoreSQLFile,
+				DropStaleSlots:                dropStaleSlots,
+				ResetAutoConf:                 resetAutoConf || len(resetAutoConfSettings) > 0,
+				ResetAutoConfSettings:         resetAutoConfSettings,
+			}
+
+			if printRecoveryConfig {
+				return printRecoveryConfiguration(ctx, info)
 			}
 
-			return restoreSubCommand(ctx, info)
+			return restoreSubCommand(ctx, info, keepOnFailure)
 		},
 		PostRunE: func(cmd *cobra.Command, _ []string) error {
 			if err := istio.TryInvokeQuitEndpoint(cmd.Context()); err != nil {
@@ -77,30 +143,152 @@ func NewCmd() *cobra.Command {
 		"the cluster and the Pod in k8s")
 	cmd.Flags().StringVar(&pgData, "pg-data", os.Getenv("PGDATA"), "The PGDATA to be restored")
 	cmd.Flags().StringVar(&pgWal, "pg-wal", "", "The PGWAL to be restored")
+	cmd.Flags().BoolVar(&verifyOnly, "verify-only", false, "Restore the backup and check it "+
+		"reaches a consistent state, without promoting the instance. "+
+		"The data checksums, when enabled, are verified too")
+	cmd.Flags().BoolVar(&atomicRestore, "atomic-restore", false, "Restore into a staging directory "+
+		"replacing the data directory only once the restore succeeds, leaving the existing one untouched on failure")
+	cmd.Flags().BoolVar(&walOnly, "wal-only", false, "Skip the base backup download and only "+
+		"configure the replay of the archived WAL files, for a data directory restored out-of-band")
+	cmd.Flags().BoolVar(&verifyWALContinuity, "verify-wal-continuity", false, "Check that the archive "+
+		"contains every WAL segment from the beginning of the backup to its end, or to the recovery "+
+		"target LSN, before configuring the recovery")
+	cmd.Flags().StringVar(&backupID, "backup-id", "", "The ID of the backup to be restored. "+
+		"When empty, the backup matching the recovery target, or the latest one, is used")
+	cmd.Flags().StringVar(&localBackupPath, "local-backup-path", "", "A directory containing "+
+		"the base backup, in the base directory, and the WAL files, in the wals directory, to be "+
+		"restored instead of a backup in the object storage")
+	cmd.Flags().BoolVar(&resetSystemIdentifier, "reset-system-identifier", false, "Assign a new "+
+		"system identifier to the restored cluster, giving it a brand-new identity")
+	cmd.Flags().StringSliceVar(&keepDatabases, "keep-databases", nil, "The list of databases to "+
+		"be kept after the restore. When set, every other database is dropped")
+	cmd.Flags().IntVar(&freeSpaceMargin, "free-space-margin", 10, "The percentage of the backup "+
+		"size to be available on disk, on top of the backup size, before starting the restore")
+	cmd.Flags().StringVar(&recoveryEndCommand, "recovery-end-command", "", "The shell command "+
+		"to be executed once at the end of the recovery")
+	cmd.Flags().BoolVar(&allowUnsafeRecoveryEndCommand, "allow-unsafe-recovery-end-command", false,
+		"Allow shell metacharacters in the recovery end command")
+	cmd.Flags().BoolVar(&printRecoveryConfig, "print-recovery-config", false, "Print the recovery "+
+		"configuration the restore would write and exit, without touching the data directory")
+	cmd.Flags().BoolVar(&targetImmediate, "target-immediate", false, "End the recovery as soon as "+
+		"a consistent state is reached, instead of replaying all the archived WAL files")
+	cmd.Flags().BoolVar(&noRestoreCommandWait, "no-restore-command-wait", false, "Promote the "+
+		"restored instance as soon as the recovery target is reached, without looking for newer "+
+		"timelines in the archive. Can't be used with --verify-only")
+	cmd.Flags().DurationVar(&recoveryTimeout, "recovery-timeout", 0, "The maximum time to wait "+
+		"for the restored instance to become consistent and to be promoted. Zero means waiting indefinitely")
+	cmd.Flags().BoolVar(&noArchiveOnRestore, "no-archive-on-restore", false, "Disable archive_mode "+
+		"in the restored instance until its configuration is generated again at startup, so that the WAL "+
+		"files written by the restore are never archived")
+	cmd.Flags().StringVar(&restoreCommand, "restore-command", "", "The restore_command used "+
+		"to fetch the WAL files during the recovery, instead of the object storage of the backup. "+
+		"It must contain the %f and %p placeholders")
+	cmd.Flags().BoolVar(&failOnCollationMismatch, "fail-on-collation-mismatch", false, "Fail the restore "+
+		"when the collation versions of the restored databases don't match the locale library, "+
+		"instead of logging a warning")
+	cmd.Flags().BoolVar(&analyzeAfterRestore, "analyze-after-restore", false, "Run ANALYZE "+
+		"in every restored database once the instance is promoted, refreshing the planner statistics")
+	cmd.Flags().BoolVar(&vacuumAfterRestore, "vacuum-after-restore", false, "Run VACUUM ANALYZE "+
+		"in every restored database once the instance is promoted")
+	cmd.Flags().StringVar(&socketDirectory, "socket-directory", "", "The directory where "+
+		"the transient instance used during the restore creates its Unix socket")
+	cmd.Flags().DurationVar(&connectTimeout, "connect-timeout", postgres.DefaultBootstrapConnectTimeout,
+		"The time to wait while connecting to the transient instance, 0 meaning no limit")
+	cmd.Flags().StringVar(&applicationName, "application-name", postgres.DefaultBootstrapApplicationName,
+		"The application_name of the connections to the transient instance, as shown in pg_stat_activity")
+	cmd.Flags().DurationVar(&statementTimeout, "statement-timeout", postgres.DefaultBootstrapStatementTimeout,
+		"The maximum duration of the statements executed on the transient instance, 0 meaning no limit. "+
+			"The post-restore SQL file and ANALYZE are not subject to it")
+	cmd.Flags().StringVar(&systemIdentifierFile, "system-identifier-file", "", "The file where "+
+		"the PostgreSQL system identifier is written once the data directory is ready")
+	cmd.Flags().StringVar(&postRestoreSQLFile, "post-restore-sql-file", "", "A file containing "+
+		"SQL statements to be executed in the application database once the restored instance is promoted")
+	cmd.Flags().BoolVar(&dropStaleSlots, "drop-stale-slots", true, "Drop the replication slots "+
+		"of the source cluster once the restored instance is promoted")
+	cmd.Flags().BoolVar(&resetAutoConf, "reset-auto-conf", false, "Remove the settings inherited "+
+		"from the source cluster through postgresql.auto.conf before starting the restored instance")
+	cmd.Flags().StringSliceVar(&resetAutoConfSettings, "reset-auto-conf-settings", nil, "The settings "+
+		"to be removed from postgresql.auto.conf, instead of all of them. It implies --reset-auto-conf")
+	cmd.Flags().BoolVar(&keepOnFailure, "keep-on-failure", false, "Move the data directory "+
+		"of a failed restore aside, instead of removing it, so that it can be inspected")
 
 	return cmd
 }
 
-func restoreSubCommand(ctx context.Context, info postgres.InitInfo) error {
-	contextLogger := log.FromContext(ctx)
-	err := info.CheckTargetDataDirectory(ctx)
+// printRecoveryConfiguration prints the recovery configuration
+// the restore would write on the standard output
+func printRecoveryConfiguration(ctx context.Context, info postgres.InitInfo) error {
+	configuration, err := info.PreviewRecoveryConfiguration(ctx)
 	if err != nil {
+		log.FromContext(ctx).Error(err, "Error while assembling the recovery configuration")
 		return err
 	}
 
-	err = info.Restore(ctx)
-	if err != nil {
-		contextLogger.Error(err, "Error while restoring a backup")
-		cleanupDataDirectoryIfNeeded(ctx, err, info.PgData)
+	fmt.Print(configuration)
+	return nil
+}
+
+func restoreSubCommand(ctx context.Context, info postgres.InitInfo, keepOnFailure bool) error {
+	contextLogger := log.FromContext(ctx)
+	if err := info.VerifyConfiguration(); err != nil {
+		contextLogger.Error(err, "Invalid restore configuration")
+		return err
+	}
+
+	// In WAL-only mode the data directory has been restored out-of-band
+	// and must be preserved, while an atomic restore replaces it only
+	// once the restore succeeds
+	if !info.WALOnly && !info.AtomicRestore {
+		if err := info.CheckTargetDataDirectory(ctx); err != nil {
+			return err
+		}
+	}
+
+	options := postgres.RestoreOptions{
+		Progress: func(progress postgres.RestoreProgress) {
+			contextLogger.Info("Restore progress",
+				"phase", progress.Phase,
+				"bytesDownloaded", progress.BytesDownloaded)
+		},
+	}
+
+	if err := info.RestoreWithOptions(ctx, options); err != nil {
+		category, isBarmanError := classifyRestoreError(err)
+		if isBarmanError {
+			contextLogger.Error(err, "Error while restoring a backup", "category", category)
+		} else {
+			contextLogger.Error(err, "Error while restoring a backup")
+		}
+		if !info.WALOnly && !info.AtomicRestore {
+			cleanupDataDirectoryIfNeeded(ctx, err, info.PgData, keepOnFailure)
+		}
+		if isBarmanError {
+			return fmt.Errorf("restore failed with a %s error: %w", category, err)
+		}
 		return err
 	}
 
+	if err := info.ReportSystemIdentifier(ctx); err != nil {
+		contextLogger.Error(err, "Error while reporting the system identifier")
+		return err
+	}
+
+	if info.VerifyOnly {
+		contextLogger.Info("restore verification completed without errors")
+		return nil
+	}
+
 	contextLogger.Info("restore command execution completed without errors")
 
 	return nil
 }
 
-func cleanupDataDirectoryIfNeeded(ctx context.Context, restoreError error, dataDirectory string) {
+func cleanupDataDirectoryIfNeeded(
+	ctx context.Context,
+	restoreError error,
+	dataDirectory string,
+	keepOnFailure bool,
+) {
 	contextLogger := log.FromContext(ctx)
 
 	var barmanError *barmanCommand.CloudRestoreError
@@ -112,6 +300,19 @@ func cleanupDataDirectoryIfNeeded(ctx context.Context, restoreError error, dataD
 		return
 	}
 
+	if keepOnFailure {
+		failedDirectory := fmt.Sprintf("%s.failed.%s", dataDirectory, fileutils.FormatFriendlyTimestamp(time.Now()))
+		contextLogger.Info("Moving aside the data directory of the failed restore",
+			"directory", dataDirectory, "newName", failedDirectory)
+		if err := os.Rename(dataDirectory, failedDirectory); err != nil && !os.IsNotExist(err) {
+			contextLogger.Error(
+				err,
+				"error occurred moving aside the data directory",
+				"directory", dataDirectory)
+		}
+		return
+	}
+
 	contextLogger.Info("Cleaning up data directory", "directory", dataDirectory)
 	if err := fileutils.RemoveDirectory(dataDirectory); err != nil && !os.IsNotExist(err) {
 		contextLogger.Error(
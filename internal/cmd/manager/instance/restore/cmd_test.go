@@ -0,0 +1,59 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package restore
+
+import (
+	"os"
+	"path/filepath"
+
+	barmanCommand "github.com/cloudnative-pg/barman-cloud/pkg/command"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("data directory cleanup after a failed restore", func() {
+	retriableError := &barmanCommand.CloudRestoreError{ExitCode: 2, HasRestoreErrorCodes: true}
+
+	var pgData string
+
+	BeforeEach(func() {
+		pgData = filepath.Join(GinkgoT().TempDir(), "pgdata")
+		Expect(os.MkdirAll(pgData, 0o700)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(pgData, "PG_VERSION"), []byte("16\n"), 0o600)).To(Succeed())
+	})
+
+	It("removes the data directory on retriable errors", func(ctx SpecContext) {
+		cleanupDataDirectoryIfNeeded(ctx, retriableError, pgData, false)
+		Expect(pgData).ToNot(BeAnExistingFile())
+	})
+
+	It("keeps the data directory on non-retriable errors", func(ctx SpecContext) {
+		cleanupDataDirectoryIfNeeded(ctx, &barmanCommand.CloudRestoreError{ExitCode: 3}, pgData, false)
+		Expect(pgData).To(BeADirectory())
+	})
+
+	It("moves the data directory aside when asked to keep it", func(ctx SpecContext) {
+		cleanupDataDirectoryIfNeeded(ctx, retriableError, pgData, true)
+		Expect(pgData).ToNot(BeAnExistingFile())
+
+		failedDirectories, err := filepath.Glob(pgData + ".failed.*")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(failedDirectories).To(HaveLen(1))
+		Expect(filepath.Join(failedDirectories[0], "PG_VERSION")).To(BeARegularFile())
+	})
+})
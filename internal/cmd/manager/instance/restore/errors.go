@@ -0,0 +1,111 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package restore
+
+import (
+	"errors"
+	"strings"
+
+	barmanCommand "github.com/cloudnative-pg/barman-cloud/pkg/command"
+)
+
+// restoreErrorCategory is the category of a failure of barman-cloud-restore,
+// used to help diagnosing why a restore failed
+type restoreErrorCategory string
+
+const (
+	// restoreErrorAuth is a failure to authenticate to the object store
+	restoreErrorAuth restoreErrorCategory = "auth"
+
+	// restoreErrorNetwork is a failure to reach the object store
+	restoreErrorNetwork restoreErrorCategory = "network"
+
+	// restoreErrorNotFound is a failure to find the backup in the object store
+	restoreErrorNotFound restoreErrorCategory = "not-found"
+
+	// restoreErrorCorruption is a failure caused by a damaged backup
+	restoreErrorCorruption restoreErrorCategory = "corruption"
+
+	// restoreErrorConfiguration is a failure caused by the options passed to barman
+	restoreErrorConfiguration restoreErrorCategory = "configuration"
+
+	// restoreErrorUnknown is a failure which couldn't be classified
+	restoreErrorUnknown restoreErrorCategory = "unknown"
+)
+
+// Barman exit codes, as documented in
+// https://docs.pgbarman.org/release/3.10.0/barman-cloud-restore.1.html
+const (
+	barmanNetworkErrorCode = 2
+	barmanCLIErrorCode     = 3
+)
+
+// restoreErrorPatterns are the fragments of the error messages, in lower
+// case, that identify a category of failure
+var restoreErrorPatterns = []struct {
+	category restoreErrorCategory
+	patterns []string
+}{
+	{
+		category: restoreErrorAuth,
+		patterns: []string{
+			"access denied", "accessdenied", "unauthorized", "forbidden",
+			"invalid credentials", "signaturedoesnotmatch", "authentication failed",
+		},
+	},
+	{
+		category: restoreErrorNotFound,
+		patterns: []string{
+			"not found", "nosuchkey", "nosuchbucket", "does not exist", "no such",
+		},
+	},
+	{
+		category: restoreErrorCorruption,
+		patterns: []string{
+			"corrupt", "checksum", "unexpected end of data", "invalid tar",
+		},
+	},
+}
+
+// classifyRestoreError returns the category of the passed barman-cloud-restore
+// failure. Barman reports only an exit code, so the messages wrapping it are
+// inspected first to detect the auth, not-found and corruption failures.
+// The second return value is false when the error doesn't come from barman
+func classifyRestoreError(err error) (restoreErrorCategory, bool) {
+	var barmanError *barmanCommand.CloudRestoreError
+	if !errors.As(err, &barmanError) {
+		return "", false
+	}
+
+	message := strings.ToLower(err.Error())
+	for _, entry := range restoreErrorPatterns {
+		for _, pattern := range entry.patterns {
+			if strings.Contains(message, pattern) {
+				return entry.category, true
+			}
+		}
+	}
+
+	switch barmanError.ExitCode {
+	case barmanNetworkErrorCode:
+		return restoreErrorNetwork, true
+	case barmanCLIErrorCode:
+		return restoreErrorConfiguration, true
+	default:
+		return restoreErrorUnknown, true
+	}
+}
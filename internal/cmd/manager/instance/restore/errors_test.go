@@ -0,0 +1,63 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package restore
+
+import (
+	"errors"
+	"fmt"
+
+	barmanCommand "github.com/cloudnative-pg/barman-cloud/pkg/command"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("barman restore error classification", func() {
+	DescribeTable("maps the barman errors to their category",
+		func(err error, expected restoreErrorCategory) {
+			category, isBarmanError := classifyRestoreError(err)
+			Expect(isBarmanError).To(BeTrue())
+			Expect(category).To(Equal(expected))
+		},
+		Entry("network errors",
+			&barmanCommand.CloudRestoreError{ExitCode: 2, HasRestoreErrorCodes: true},
+			restoreErrorNetwork),
+		Entry("wrapped network errors",
+			fmt.Errorf("while restoring: %w", &barmanCommand.CloudRestoreError{ExitCode: 2}),
+			restoreErrorNetwork),
+		Entry("CLI errors",
+			&barmanCommand.CloudRestoreError{ExitCode: 3},
+			restoreErrorConfiguration),
+		Entry("authentication errors",
+			fmt.Errorf("AccessDenied: access denied to bucket: %w", &barmanCommand.CloudRestoreError{ExitCode: 1}),
+			restoreErrorAuth),
+		Entry("missing backups",
+			fmt.Errorf("NoSuchKey: backup.info: %w", &barmanCommand.CloudRestoreError{ExitCode: 1}),
+			restoreErrorNotFound),
+		Entry("corrupted backups",
+			fmt.Errorf("checksum mismatch in data.tar: %w", &barmanCommand.CloudRestoreError{ExitCode: 4}),
+			restoreErrorCorruption),
+		Entry("generic errors",
+			&barmanCommand.CloudRestoreError{ExitCode: 4, HasRestoreErrorCodes: true},
+			restoreErrorUnknown),
+	)
+
+	It("doesn't classify the errors not coming from barman", func() {
+		_, isBarmanError := classifyRestoreError(errors.New("access denied"))
+		Expect(isBarmanError).To(BeFalse())
+	})
+})
@@ -0,0 +1,123 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package verifyconfig implements the "instance verify-config" subcommand of the operator
+package verifyconfig
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/cloudnative-pg/cloudnative-pg/pkg/management/postgres"
+)
+
+const (
+	// outputText prints the validation result for humans
+	outputText = "text"
+
+	// outputJSON prints the validation result as a JSON document
+	outputJSON = "json"
+)
+
+// errInvalidConfiguration is returned when the configuration is not valid
+var errInvalidConfiguration = errors.New("invalid configuration")
+
+// verificationResult is the outcome of the configuration verification
+type verificationResult struct {
+	Valid bool   `json:"valid"`
+	Error string `json:"error,omitempty"`
+}
+
+// NewCmd creates the "verify-config" subcommand
+func NewCmd() *cobra.Command {
+	var info postgres.InitInfo
+	var output string
+
+	cmd := &cobra.Command{
+		Use:           "verify-config [flags]",
+		Short:         "Verify the bootstrap configuration, without changing anything",
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return verifyConfigSubCommand(cmd.OutOrStdout(), info, output)
+		},
+	}
+
+	cmd.Flags().StringVar(&info.PgData, "pg-data", os.Getenv("PGDATA"), "The PGDATA to be created")
+	cmd.Flags().StringVar(&info.ApplicationDatabase, "app-db-name", "app",
+		"The name of the application containing the database")
+	cmd.Flags().StringVar(&info.SocketDirectory, "socket-directory", "", "The directory where "+
+		"the transient instance used during the bootstrap creates its Unix socket")
+	cmd.Flags().BoolVar(&info.WALOnly, "wal-only", false, "Verify a WAL-only restore, "+
+		"for a data directory restored out-of-band")
+	cmd.Flags().Uint32Var(&info.InitialTransactionID, "initial-transaction-id", 0, "The first "+
+		"transaction ID of the new instance")
+	cmd.Flags().StringVar(&info.InitialDumpFile, "initial-dump-file", "", "The dump file to be "+
+		"restored in the application database")
+	cmd.Flags().StringVar(&info.PostRestoreSQLFile, "post-restore-sql-file", "", "A file containing "+
+		"SQL statements to be executed in the application database once the restored instance is promoted")
+	cmd.Flags().StringVar(&info.SSLCertFile, "ssl-cert-file", "", "The server certificate "+
+		"used to enable SSL in the transient instance")
+	cmd.Flags().StringVar(&info.SSLKeyFile, "ssl-key-file", "", "The private key of the server "+
+		"certificate used by the transient instance")
+	cmd.Flags().StringVar(&info.SSLCAFile, "ssl-ca-file", "", "The CA certificate used to verify "+
+		"the connections to the transient instance")
+	cmd.Flags().StringVarP(&output, "output", "o", outputText, "The output format, text or json")
+
+	return cmd
+}
+
+// verifyConfigSubCommand verifies the passed configuration and writes the
+// result in the requested format, returning an error when it is invalid
+func verifyConfigSubCommand(out io.Writer, info postgres.InitInfo, output string) error {
+	if output != outputText && output != outputJSON {
+		return fmt.Errorf("unknown output format %q, expected %s or %s", output, outputText, outputJSON)
+	}
+
+	result := verificationResult{Valid: true}
+	verificationErr := info.VerifyConfiguration()
+	if verificationErr != nil {
+		result = verificationResult{Valid: false, Error: verificationErr.Error()}
+	}
+
+	if err := printResult(out, result, output); err != nil {
+		return err
+	}
+
+	if verificationErr != nil {
+		return fmt.Errorf("%w: %w", errInvalidConfiguration, verificationErr)
+	}
+	return nil
+}
+
+// printResult writes the verification result in the requested format
+func printResult(out io.Writer, result verificationResult, output string) error {
+	if output == outputJSON {
+		return json.NewEncoder(out).Encode(result)
+	}
+
+	if result.Valid {
+		_, err := fmt.Fprintln(out, "The configuration is valid")
+		return err
+	}
+	_, err := fmt.Fprintf(out, "The configuration is not valid: %s\n", result.Error)
+	return err
+}
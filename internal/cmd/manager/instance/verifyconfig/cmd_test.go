@@ -0,0 +1,68 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package verifyconfig
+
+import (
+	"bytes"
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("verify-config subcommand", func() {
+	run := func(args ...string) (string, error) {
+		var out bytes.Buffer
+		cmd := NewCmd()
+		cmd.SetOut(&out)
+		cmd.SetArgs(args)
+		err := cmd.Execute()
+		return out.String(), err
+	}
+
+	It("succeeds with a valid configuration", func() {
+		out, err := run("--pg-data", GinkgoT().TempDir(), "--app-db-name", "app")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(out).To(Equal("The configuration is valid\n"))
+	})
+
+	It("fails with an invalid configuration", func() {
+		out, err := run("--app-db-name", "postgres")
+		Expect(err).To(MatchError(errInvalidConfiguration))
+		Expect(out).To(HavePrefix("The configuration is not valid: "))
+		Expect(out).To(ContainSubstring("system database"))
+	})
+
+	It("prints the result as JSON", func() {
+		out, err := run("--app-db-name", "template1", "-o", "json")
+		Expect(err).To(MatchError(errInvalidConfiguration))
+
+		var result verificationResult
+		Expect(json.Unmarshal([]byte(out), &result)).To(Succeed())
+		Expect(result.Valid).To(BeFalse())
+		Expect(result.Error).To(ContainSubstring("system database"))
+
+		out, err = run("-o", "json")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(out).To(Equal("{\"valid\":true}\n"))
+	})
+
+	It("refuses an unknown output format", func() {
+		_, err := run("-o", "yaml")
+		Expect(err).To(MatchError(ContainSubstring("unknown output format")))
+	})
+})
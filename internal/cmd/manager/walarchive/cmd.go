@@ -50,14 +50,29 @@ import (
 // and the new primary have not completed the promotion
 var errSwitchoverInProgress = fmt.Errorf("switchover in progress, refusing archiving")
 
+// maxRetryBackoff is the maximum wait between two attempts to archive a WAL
+// file, as PostgreSQL doesn't archive the following ones in the meantime
+const maxRetryBackoff = time.Minute
+
+// retryAfter waits for the passed backoff between two attempts
+var retryAfter = time.After
+
 // NewCmd creates the new cobra command
 func NewCmd() *cobra.Command {
 	var podName string
 	var pgData string
+	var maxRetries int
+	var retryBackoff time.Duration
 	cmd := cobra.Command{
 		Use:           "wal-archive [name]",
 		SilenceErrors: true,
 		Args:          cobra.ExactArgs(1),
+		PreRunE: func(_ *cobra.Command, _ []string) error {
+			if maxRetries < 0 || retryBackoff < 0 {
+				return fmt.Errorf("the retry policy can't be negative")
+			}
+			return nil
+		},
 		RunE: func(cobraCmd *cobra.Command, args []string) error {
 			const logErrorMessage = "failed to run wal-archive command"
 
@@ -81,7 +96,9 @@ func NewCmd() *cobra.Command {
 				return fmt.Errorf("failed to get cluster: %w", err)
 			}
 
-			err = run(ctx, podName, pgData, cluster, args)
+			err = runWithRetries(ctx, maxRetries, retryBackoff, func() error {
+				return run(ctx, podName, pgData, cluster, args)
+			})
 			if err != nil {
 				if errors.Is(err, errSwitchoverInProgress) {
 					contextLog.Warning("Refusing to archive WALs until the switchover is not completed",
@@ -119,10 +136,48 @@ func NewCmd() *cobra.Command {
 	cmd.Flags().StringVar(&podName, "pod-name", os.Getenv("POD_NAME"), "The name of the "+
 		"current pod in k8s")
 	cmd.Flags().StringVar(&pgData, "pg-data", os.Getenv("PGDATA"), "The PGDATA to be used")
+	cmd.Flags().IntVar(&maxRetries, "max-retries", 0, "The number of times the archiving "+
+		"of a WAL file is retried before reporting a failure to PostgreSQL")
+	cmd.Flags().DurationVar(&retryBackoff, "retry-backoff", time.Second, "The wait before "+
+		"retrying to archive a WAL file, doubled after every failed attempt up to "+maxRetryBackoff.String())
 
 	return &cmd
 }
 
+// runWithRetries calls the passed archiving function until it succeeds
+// or maxRetries retries have failed, waiting an exponentially growing
+// backoff, capped at maxRetryBackoff, between the attempts. A switchover
+// in progress is never retried
+func runWithRetries(
+	ctx context.Context,
+	maxRetries int,
+	backoff time.Duration,
+	archive func() error,
+) error {
+	contextLog := log.FromContext(ctx)
+
+	backoff = min(backoff, maxRetryBackoff)
+	for attempt := 0; ; attempt++ {
+		err := archive()
+		if err == nil || attempt >= maxRetries || errors.Is(err, errSwitchoverInProgress) {
+			return err
+		}
+
+		contextLog.Warning("WAL archiving failed, retrying",
+			"attempt", attempt+1,
+			"maxRetries", maxRetries,
+			"backoff", backoff,
+			"err", err)
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-retryAfter(backoff):
+		}
+		backoff = min(backoff*2, maxRetryBackoff)
+	}
+}
+
 func run(
 	ctx context.Context,
 	podName, pgData string,
@@ -0,0 +1,115 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package walarchive
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("archiving with retries", func() {
+	var waits []time.Duration
+
+	BeforeEach(func() {
+		waits = nil
+		originalRetryAfter := retryAfter
+		retryAfter = func(backoff time.Duration) <-chan time.Time {
+			waits = append(waits, backoff)
+			result := make(chan time.Time, 1)
+			result <- time.Now()
+			return result
+		}
+		DeferCleanup(func() {
+			retryAfter = originalRetryAfter
+		})
+	})
+
+	failingFor := func(failures int, attempts *int) func() error {
+		return func() error {
+			*attempts++
+			if *attempts <= failures {
+				return errors.New("upload failed")
+			}
+			return nil
+		}
+	}
+
+	It("doesn't retry when the first attempt succeeds", func(ctx SpecContext) {
+		attempts := 0
+		Expect(runWithRetries(ctx, 3, time.Second, failingFor(0, &attempts))).To(Succeed())
+		Expect(attempts).To(Equal(1))
+		Expect(waits).To(BeEmpty())
+	})
+
+	It("retries until the archiving succeeds", func(ctx SpecContext) {
+		attempts := 0
+		Expect(runWithRetries(ctx, 3, time.Second, failingFor(2, &attempts))).To(Succeed())
+		Expect(attempts).To(Equal(3))
+		Expect(waits).To(Equal([]time.Duration{time.Second, 2 * time.Second}))
+	})
+
+	It("reports the last error after the maximum number of retries", func(ctx SpecContext) {
+		attempts := 0
+		err := runWithRetries(ctx, 2, time.Second, failingFor(10, &attempts))
+		Expect(err).To(MatchError("upload failed"))
+		Expect(attempts).To(Equal(3))
+		Expect(waits).To(HaveLen(2))
+	})
+
+	It("caps the backoff", func(ctx SpecContext) {
+		attempts := 0
+		err := runWithRetries(ctx, 5, 20*time.Second, failingFor(10, &attempts))
+		Expect(err).To(HaveOccurred())
+		Expect(waits).To(Equal([]time.Duration{
+			20 * time.Second, 40 * time.Second, time.Minute, time.Minute, time.Minute,
+		}))
+	})
+
+	It("caps the initial backoff too", func(ctx SpecContext) {
+		attempts := 0
+		Expect(runWithRetries(ctx, 1, time.Hour, failingFor(1, &attempts))).To(Succeed())
+		Expect(waits).To(Equal([]time.Duration{time.Minute}))
+	})
+
+	It("never retries when a switchover is in progress", func(ctx SpecContext) {
+		attempts := 0
+		err := runWithRetries(ctx, 3, time.Second, func() error {
+			attempts++
+			return errSwitchoverInProgress
+		})
+		Expect(err).To(MatchError(errSwitchoverInProgress))
+		Expect(attempts).To(Equal(1))
+		Expect(waits).To(BeEmpty())
+	})
+
+	It("stops retrying when the context is cancelled", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		retryAfter = func(time.Duration) <-chan time.Time {
+			return make(chan time.Time)
+		}
+
+		attempts := 0
+		err := runWithRetries(ctx, 3, time.Second, failingFor(10, &attempts))
+		Expect(err).To(MatchError("upload failed"))
+		Expect(attempts).To(Equal(1))
+	})
+})
@@ -151,16 +151,28 @@ func ReadLinesFromConfigurationContents(content []string, options ...string) []s
 // EnsureIncludes makes sure the passed PostgreSQL configuration file has an include directive
 // to every filesToInclude.
 func EnsureIncludes(fileName string, filesToInclude ...string) (changed bool, err error) {
-	includeLinesToAdd := make(map[string]string, len(filesToInclude))
-	for _, fileToInclude := range filesToInclude {
-		includeLinesToAdd[fileToInclude] = fmt.Sprintf("include '%v'", fileToInclude)
-	}
-
 	lines, err := fileutils.ReadFileLines(fileName)
 	if err != nil {
 		return false, fmt.Errorf("error while reading lines of %v: %w", fileName, err)
 	}
 
+	lines, changed = EnsureIncludesInConfigurationContents(lines, filesToInclude...)
+	if !changed {
+		return false, nil
+	}
+
+	return fileutils.WriteLinesToFile(fileName, lines)
+}
+
+// EnsureIncludesInConfigurationContents adds an include directive to every
+// filesToInclude that is not already included by the passed configuration
+// contents, reporting if any directive was added
+func EnsureIncludesInConfigurationContents(lines []string, filesToInclude ...string) ([]string, bool) {
+	includeLinesToAdd := make(map[string]string, len(filesToInclude))
+	for _, fileToInclude := range filesToInclude {
+		includeLinesToAdd[fileToInclude] = fmt.Sprintf("include '%v'", fileToInclude)
+	}
+
 	for _, line := range lines {
 		trimLine := strings.TrimSpace(line)
 		for targetFile, includeLine := range includeLinesToAdd {
@@ -171,7 +183,7 @@ func EnsureIncludes(fileName string, filesToInclude ...string) (changed bool, er
 	}
 
 	if len(includeLinesToAdd) == 0 {
-		return false, nil
+		return lines, false
 	}
 
 	for _, fileToInclude := range filesToInclude {
@@ -184,5 +196,5 @@ func EnsureIncludes(fileName string, filesToInclude ...string) (changed bool, er
 		}
 	}
 
-	return fileutils.WriteLinesToFile(fileName, lines)
+	return lines, true
 }
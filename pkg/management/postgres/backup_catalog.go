@@ -0,0 +1,51 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	barmanCatalog "github.com/cloudnative-pg/barman-cloud/pkg/catalog"
+
+	"github.com/cloudnative-pg/cloudnative-pg/pkg/management"
+)
+
+// ListBackups returns the catalog of the backups available in the object
+// store of the external cluster this cluster is recovered from, using the
+// same configuration a restore would use
+func (info InitInfo) ListBackups(ctx context.Context) (*barmanCatalog.Catalog, error) {
+	typedClient, err := management.NewControllerRuntimeClient()
+	if err != nil {
+		return nil, err
+	}
+
+	cluster, err := info.loadCluster(ctx, typedClient)
+	if err != nil {
+		return nil, err
+	}
+
+	if cluster.Spec.Bootstrap == nil || cluster.Spec.Bootstrap.Recovery == nil {
+		return nil, errors.New("the cluster is not bootstrapped from a recovery")
+	}
+	if cluster.Spec.Bootstrap.Recovery.Backup != nil {
+		return nil, errors.New("the cluster is recovered from a Backup object, not from an object store")
+	}
+
+	_, backupCatalog, _, err := loadExternalClusterCatalog(ctx, typedClient, cluster)
+	return backupCatalog, err
+}
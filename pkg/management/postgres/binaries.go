@@ -0,0 +1,110 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/cloudnative-pg/cloudnative-pg/pkg/management/postgres/constants"
+	postgresutils "github.com/cloudnative-pg/cloudnative-pg/pkg/management/postgres/utils"
+)
+
+// postgresVersionMarker is the text preceding the version number in the
+// output of the `--version` option of the PostgreSQL binaries
+const postgresVersionMarker = "(PostgreSQL)"
+
+// checkBootstrapBinaries ensures initdb and postgres come
+// from the same PostgreSQL release
+func checkBootstrapBinaries(ctx context.Context) error {
+	return checkBinaryVersionsMatch(ctx, constants.InitdbName, postgresName)
+}
+
+// checkBinaryVersionsMatch ensures the two passed PostgreSQL binaries
+// report the same version, returning a descriptive error otherwise
+func checkBinaryVersionsMatch(ctx context.Context, initdbBinary, postgresBinary string) error {
+	initdbVersion, err := getBinaryVersion(ctx, initdbBinary)
+	if err != nil {
+		return err
+	}
+
+	postgresVersion, err := getBinaryVersion(ctx, postgresBinary)
+	if err != nil {
+		return err
+	}
+
+	if initdbVersion != postgresVersion {
+		return fmt.Errorf(
+			"mismatching PostgreSQL binaries: %s reports version %s while %s reports version %s",
+			initdbBinary, initdbVersion, postgresBinary, postgresVersion)
+	}
+
+	return nil
+}
+
+// getBinaryVersion runs a PostgreSQL binary with the
+// `--version` option and returns the reported version
+func getBinaryVersion(ctx context.Context, binary string) (string, error) {
+	output, err := postgresutils.RunCommand(ctx, binary, []string{"--version"},
+		postgresutils.CommandOptions{SeparateStderr: true})
+	if err != nil {
+		return "", fmt.Errorf("while getting the version of %s: %w", binary, err)
+	}
+
+	return parseBinaryVersion(output)
+}
+
+// parseBinaryVersion extracts the version number from the output of
+// the `--version` option, i.e. "postgres (PostgreSQL) 16.4 (Debian 16.4-1)"
+func parseBinaryVersion(output string) (string, error) {
+	_, version, found := strings.Cut(output, postgresVersionMarker)
+	fields := strings.Fields(version)
+	if !found || len(fields) == 0 {
+		return "", fmt.Errorf("cannot parse the PostgreSQL version from %q", strings.TrimSpace(output))
+	}
+
+	return fields[0], nil
+}
+
+// getBinaryMajorVersion gets the major version of a PostgreSQL binary
+func getBinaryMajorVersion(ctx context.Context, binary string) (int, error) {
+	version, err := getBinaryVersion(ctx, binary)
+	if err != nil {
+		return 0, err
+	}
+
+	return parseMajorVersion(version)
+}
+
+// parseMajorVersion extracts the major version from a version
+// number reported by a PostgreSQL binary, i.e. "16.4" or "17beta1"
+func parseMajorVersion(version string) (int, error) {
+	majorVersion := version
+	if idx := strings.IndexFunc(version, func(r rune) bool { return !unicode.IsDigit(r) }); idx >= 0 {
+		majorVersion = version[:idx]
+	}
+
+	result, err := strconv.Atoi(majorVersion)
+	if err != nil {
+		return 0, fmt.Errorf("cannot parse the major version from %q: %w", version, err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,65 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PostgreSQL binaries version check", func() {
+	fakeBinary := func(dir, name, version string) string {
+		binary := filepath.Join(dir, name)
+		script := fmt.Sprintf("#!/bin/sh\necho '%s (PostgreSQL) %s'\n", name, version)
+		Expect(os.WriteFile(binary, []byte(script), 0o700)).To(Succeed()) // #nosec G306
+		return binary
+	}
+
+	It("parses the version reported by the binaries", func() {
+		Expect(parseBinaryVersion("postgres (PostgreSQL) 16.4\n")).To(Equal("16.4"))
+		Expect(parseBinaryVersion("initdb (PostgreSQL) 16.4 (Debian 16.4-1.pgdg120+1)\n")).To(Equal("16.4"))
+		Expect(parseBinaryVersion("postgres (PostgreSQL) 17beta1\n")).To(Equal("17beta1"))
+	})
+
+	It("fails parsing an unexpected output", func() {
+		_, err := parseBinaryVersion("command not found")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("accepts binaries reporting the same version", func(ctx SpecContext) {
+		dir := GinkgoT().TempDir()
+		Expect(checkBinaryVersionsMatch(ctx,
+			fakeBinary(dir, "initdb", "16.4"),
+			fakeBinary(dir, "postgres", "16.4"),
+		)).To(Succeed())
+	})
+
+	It("refuses binaries reporting different versions", func(ctx SpecContext) {
+		dir := GinkgoT().TempDir()
+		err := checkBinaryVersionsMatch(ctx,
+			fakeBinary(dir, "initdb", "16.4"),
+			fakeBinary(dir, "postgres", "16.2"),
+		)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("16.4"))
+		Expect(err.Error()).To(ContainSubstring("16.2"))
+	})
+})
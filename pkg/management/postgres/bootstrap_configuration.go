@@ -0,0 +1,198 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/cloudnative-pg/machinery/pkg/postgres/version"
+
+	"github.com/cloudnative-pg/cloudnative-pg/pkg/configfile"
+	"github.com/cloudnative-pg/cloudnative-pg/pkg/postgres"
+)
+
+// initdbSetMinimumMajorVersion is the first PostgreSQL major
+// version whose initdb supports the `--set` option
+const initdbSetMinimumMajorVersion = 16
+
+// scramDefaultMinimumMajorVersion is the first PostgreSQL major version
+// where scram-sha-256 is the default password_encryption
+const scramDefaultMinimumMajorVersion = 14
+
+// addVersionedParameters adds to the passed bootstrap configuration the
+// parameters depending on the initdb major version. The resulting parameters
+// and the InitDBSettings are refused when they would be overridden by the
+// configuration files managed by the operator
+func (info InitInfo) addVersionedParameters(parameters map[string]string, initdbMajorVersion int) error {
+	passwordEncryption, err := info.persistentPasswordEncryption(initdbMajorVersion)
+	if err != nil {
+		return err
+	}
+	if passwordEncryption != "" {
+		parameters["password_encryption"] = passwordEncryption
+	}
+
+	if err := info.addIdleSessionTimeoutParameter(parameters, initdbMajorVersion); err != nil {
+		return err
+	}
+
+	return checkOperatorManagedParameters(initdbMajorVersion, parameters, info.InitDBSettings)
+}
+
+// checkOperatorManagedParameters refuses the passed parameters when the
+// operator always writes them in custom.conf. That file is included after
+// postgresql.conf, so their bootstrap value would never take effect
+func checkOperatorManagedParameters(majorVersion int, parameterSets ...map[string]string) error {
+	managedParameters := postgres.CreatePostgresqlConfiguration(postgres.ConfigurationInfo{
+		Settings:           postgres.CnpgConfigurationSettings,
+		Version:            version.New(uint64(majorVersion), 0), //nolint:gosec
+		IncludingMandatory: true,
+	}).GetConfigurationParameters()
+
+	var refused []string
+	for _, parameters := range parameterSets {
+		for name := range parameters {
+			if _, found := managedParameters[name]; found && !slices.Contains(refused, name) {
+				refused = append(refused, name)
+			}
+		}
+	}
+	if len(refused) == 0 {
+		return nil
+	}
+
+	sort.Strings(refused)
+	return fmt.Errorf("the parameters %v are managed by the operator and can't be set at bootstrap: "+
+		"set them in the Cluster resource instead", refused)
+}
+
+// bootstrapConfiguration returns the PostgreSQL parameters that are written
+// in postgresql.conf while creating a new data directory. These parameters
+// are the defaults of the new instance: the configuration files managed by
+// the operator are included afterwards and take precedence.
+func (info InitInfo) bootstrapConfiguration(ctx context.Context) (map[string]string, error) {
+	parameters := make(map[string]string)
+
+	if err := info.addTuningParameters(ctx, parameters); err != nil {
+		return nil, err
+	}
+
+	// Commit timestamps are recorded only for the transactions committed
+	// after enabling it, so it needs to be on before writing any data
+	if info.TrackCommitTimestamp {
+		parameters["track_commit_timestamp"] = "on"
+	}
+
+	if info.RowSecurity || info.EnforceRowSecurity {
+		parameters["row_security"] = "on"
+	}
+
+	if err := info.addLoggingParameters(parameters); err != nil {
+		return nil, err
+	}
+
+	if err := info.addDateTimeParameters(parameters); err != nil {
+		return nil, err
+	}
+
+	info.addTempTablespacesParameter(parameters)
+
+	return parameters, nil
+}
+
+// addLoggingParameters adds the logging parameters of the new instance to
+// the passed bootstrap configuration. The single quotes of log_line_prefix
+// are escaped when writing postgresql.conf, while line breaks and
+// backslashes can't be represented there and are refused
+func (info InitInfo) addLoggingParameters(parameters map[string]string) error {
+	if info.LogLinePrefix != "" {
+		if strings.ContainsAny(info.LogLinePrefix, "\\\r\n") {
+			return fmt.Errorf("invalid log_line_prefix %q: line breaks and backslashes are not allowed",
+				info.LogLinePrefix)
+		}
+		parameters["log_line_prefix"] = info.LogLinePrefix
+	}
+
+	return nil
+}
+
+// persistentPasswordEncryption returns the password_encryption to be written
+// in postgresql.conf, so that every future password change uses it. When
+// not explicitly set, scram-sha-256 is used on the versions where it is the
+// server default. An empty string is returned when the parameter is already
+// among the InitDBSettings or nothing is to be written
+func (info InitInfo) persistentPasswordEncryption(majorVersion int) (string, error) {
+	if _, found := info.InitDBSettings["password_encryption"]; found {
+		return "", nil
+	}
+
+	if info.PasswordEncryption != "" {
+		if err := validatePasswordEncryption(info.PasswordEncryption); err != nil {
+			return "", err
+		}
+		return info.PasswordEncryption, nil
+	}
+
+	if majorVersion >= scramDefaultMinimumMajorVersion {
+		return "scram-sha-256", nil
+	}
+
+	return "", nil
+}
+
+// initdbSettingsOptions returns the initdb options needed to write the
+// InitDBSettings in postgresql.conf. When the passed initdb major version
+// doesn't support the `--set` option, the settings are returned to be
+// appended to postgresql.conf once initdb has completed
+func (info InitInfo) initdbSettingsOptions(initdbMajorVersion int) ([]string, map[string]string) {
+	if len(info.InitDBSettings) == 0 {
+		return nil, nil
+	}
+
+	if initdbMajorVersion < initdbSetMinimumMajorVersion {
+		return nil, info.InitDBSettings
+	}
+
+	names := make([]string, 0, len(info.InitDBSettings))
+	for name := range info.InitDBSettings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	options := make([]string, 0, 2*len(names))
+	for _, name := range names {
+		options = append(options, "--set", fmt.Sprintf("%s=%s", name, info.InitDBSettings[name]))
+	}
+
+	return options, nil
+}
+
+// writeBootstrapConfiguration writes the passed parameters inside the
+// postgresql.conf file of the passed data directory
+func writeBootstrapConfiguration(pgData string, parameters map[string]string) error {
+	if len(parameters) == 0 {
+		return nil
+	}
+
+	_, err := configfile.UpdatePostgresConfigurationFile(path.Join(pgData, "postgresql.conf"), parameters)
+	return err
+}
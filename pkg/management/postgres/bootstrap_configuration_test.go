@@ -0,0 +1,224 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"path/filepath"
+
+	"github.com/cloudnative-pg/machinery/pkg/fileutils"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("bootstrap configuration", func() {
+	It("writes the parameters inside postgresql.conf", func() {
+		pgData := GinkgoT().TempDir()
+		confFile := filepath.Join(pgData, "postgresql.conf")
+		_, err := fileutils.WriteStringToFile(confFile, "# initdb generated\nport = 5432\n")
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(writeBootstrapConfiguration(pgData, map[string]string{"port": "5433"})).To(Succeed())
+
+		content, err := fileutils.ReadFile(confFile)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(content)).To(Equal("# initdb generated\nport = '5433'\n"))
+	})
+
+	It("is empty by default", func(ctx SpecContext) {
+		parameters, err := InitInfo{}.bootstrapConfiguration(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(parameters).To(BeEmpty())
+	})
+
+	Context("operator managed parameters", func() {
+		It("accepts the parameters not managed by the operator", func() {
+			info := InitInfo{InitDBSettings: map[string]string{"work_mem": "8MB"}}
+			parameters := map[string]string{"synchronous_commit": "local"}
+			Expect(info.addVersionedParameters(parameters, 16)).To(Succeed())
+			Expect(parameters).To(HaveKeyWithValue("synchronous_commit", "local"))
+		})
+
+		DescribeTable("refuses the InitDBSettings overridden by custom.conf",
+			func(name string, majorVersion int) {
+				info := InitInfo{InitDBSettings: map[string]string{name: "value"}}
+				err := info.addVersionedParameters(map[string]string{}, majorVersion)
+				Expect(err).To(MatchError(And(
+					ContainSubstring(name),
+					ContainSubstring("managed by the operator"))))
+			},
+			Entry("a global default", "wal_level", 16),
+			Entry("a default of the PostgreSQL version", "wal_keep_size", 16),
+			Entry("a default of an older PostgreSQL version", "wal_keep_segments", 12),
+			Entry("a mandatory setting", "archive_command", 16),
+			Entry("archive_mode", "archive_mode", 16),
+		)
+
+		It("lists every refused parameter in order", func() {
+			info := InitInfo{InitDBSettings: map[string]string{
+				"logging_collector": "off",
+				"archive_timeout":   "1min",
+				"work_mem":          "8MB",
+			}}
+			err := info.addVersionedParameters(map[string]string{}, 16)
+			Expect(err).To(MatchError(ContainSubstring("[archive_timeout logging_collector]")))
+		})
+	})
+
+	Context("password_encryption", func() {
+		DescribeTable("is gated by the PostgreSQL version",
+			func(info InitInfo, majorVersion int, expected string) {
+				passwordEncryption, err := info.persistentPasswordEncryption(majorVersion)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(passwordEncryption).To(Equal(expected))
+			},
+			Entry("defaults to scram-sha-256 on PostgreSQL 14", InitInfo{}, 14, "scram-sha-256"),
+			Entry("defaults to scram-sha-256 on PostgreSQL 17", InitInfo{}, 17, "scram-sha-256"),
+			Entry("is not written on PostgreSQL 13", InitInfo{}, 13, ""),
+			Entry("follows the configured method", InitInfo{PasswordEncryption: "md5"}, 16, "md5"),
+			Entry("is written on PostgreSQL 13 when configured",
+				InitInfo{PasswordEncryption: "scram-sha-256"}, 13, "scram-sha-256"),
+			Entry("leaves the initdb settings untouched",
+				InitInfo{InitDBSettings: map[string]string{"password_encryption": "md5"}}, 16, ""),
+		)
+
+		It("rejects an invalid method", func() {
+			_, err := InitInfo{PasswordEncryption: "plain"}.persistentPasswordEncryption(16)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("is written in postgresql.conf", func() {
+			pgData := GinkgoT().TempDir()
+			confFile := filepath.Join(pgData, "postgresql.conf")
+			_, err := fileutils.WriteStringToFile(confFile, "#password_encryption = scram-sha-256\n")
+			Expect(err).ToNot(HaveOccurred())
+
+			passwordEncryption, err := InitInfo{}.persistentPasswordEncryption(16)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(writeBootstrapConfiguration(pgData, map[string]string{
+				"password_encryption": passwordEncryption,
+			})).To(Succeed())
+
+			content, err := fileutils.ReadFile(confFile)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(content)).To(ContainSubstring("\npassword_encryption = 'scram-sha-256'\n"))
+		})
+	})
+
+	Context("initdb settings", func() {
+		info := InitInfo{
+			InitDBSettings: map[string]string{
+				"work_mem":     "8MB",
+				"max_wal_size": "2GB",
+			},
+		}
+
+		It("passes the settings through --set with initdb 16 or newer", func() {
+			options, appended := info.initdbSettingsOptions(16)
+			Expect(options).To(Equal([]string{"--set", "max_wal_size=2GB", "--set", "work_mem=8MB"}))
+			Expect(appended).To(BeEmpty())
+		})
+
+		It("appends the settings to postgresql.conf with older initdb versions", func() {
+			options, appended := info.initdbSettingsOptions(15)
+			Expect(options).To(BeEmpty())
+			Expect(appended).To(Equal(info.InitDBSettings))
+		})
+
+		It("has nothing to do without settings", func() {
+			options, appended := InitInfo{}.initdbSettingsOptions(17)
+			Expect(options).To(BeEmpty())
+			Expect(appended).To(BeEmpty())
+		})
+
+		It("detects the initdb major version", func() {
+			Expect(parseMajorVersion("16.4")).To(Equal(16))
+			Expect(parseMajorVersion("17beta1")).To(Equal(17))
+			Expect(parseMajorVersion("9.6.24")).To(Equal(9))
+			_, err := parseMajorVersion("devel")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("track_commit_timestamp", func() {
+		It("is enabled when requested", func(ctx SpecContext) {
+			parameters, err := InitInfo{TrackCommitTimestamp: true}.bootstrapConfiguration(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(parameters).To(HaveKeyWithValue("track_commit_timestamp", "on"))
+		})
+
+		It("is not rendered by default", func(ctx SpecContext) {
+			parameters, err := InitInfo{}.bootstrapConfiguration(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(parameters).ToNot(HaveKey("track_commit_timestamp"))
+		})
+	})
+
+	Context("row_security", func() {
+		It("is rendered when requested", func(ctx SpecContext) {
+			parameters, err := InitInfo{RowSecurity: true}.bootstrapConfiguration(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(parameters).To(HaveKeyWithValue("row_security", "on"))
+		})
+
+		It("is rendered when enforced on the application database", func(ctx SpecContext) {
+			parameters, err := InitInfo{EnforceRowSecurity: true}.bootstrapConfiguration(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(parameters).To(HaveKeyWithValue("row_security", "on"))
+		})
+
+		It("is not rendered by default", func(ctx SpecContext) {
+			parameters, err := InitInfo{}.bootstrapConfiguration(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(parameters).ToNot(HaveKey("row_security"))
+		})
+	})
+
+	Context("logging", func() {
+		It("renders the logging settings in postgresql.conf", func(ctx SpecContext) {
+			pgData := GinkgoT().TempDir()
+			confFile := filepath.Join(pgData, "postgresql.conf")
+			_, err := fileutils.WriteStringToFile(confFile, "#log_line_prefix = '%m [%p] '\n")
+			Expect(err).ToNot(HaveOccurred())
+
+			info := InitInfo{LogLinePrefix: "%m [%p] %q%u@%d 'app' "}
+			parameters, err := info.bootstrapConfiguration(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(writeBootstrapConfiguration(pgData, parameters)).To(Succeed())
+
+			content, err := fileutils.ReadFile(confFile)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(content)).To(Equal("#log_line_prefix = '%m [%p] '\n" +
+				"log_line_prefix = '%m [%p] %q%u@%d ''app'' '\n"))
+		})
+
+		It("doesn't render the settings that are not set", func(ctx SpecContext) {
+			parameters, err := InitInfo{}.bootstrapConfiguration(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(parameters).ToNot(HaveKey("log_line_prefix"))
+		})
+
+		DescribeTable("refuses the prefixes that can't be written in postgresql.conf",
+			func(ctx SpecContext, prefix string) {
+				_, err := InitInfo{LogLinePrefix: prefix}.bootstrapConfiguration(ctx)
+				Expect(err).To(MatchError(ContainSubstring("invalid log_line_prefix")))
+			},
+			Entry("line breaks", "%m\n"),
+			Entry("backslashes", `%m \ `),
+		)
+	})
+})
@@ -0,0 +1,221 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/cloudnative-pg/machinery/pkg/log"
+	"github.com/jackc/pgx/v5"
+)
+
+// DefaultDatabaseCreationConcurrency is the default number of additional
+// databases created at the same time while configuring a new instance
+const DefaultDatabaseCreationConcurrency = 4
+
+// validateAdditionalDatabases ensures that the additional databases can
+// be created beside the application database
+func (info InitInfo) validateAdditionalDatabases() error {
+	if info.DatabaseCreationConcurrency < 0 {
+		return fmt.Errorf("the database creation concurrency can't be negative")
+	}
+
+	names := make(map[string]bool, len(info.AdditionalDatabases))
+	for _, name := range info.AdditionalDatabases {
+		if name == "" {
+			return fmt.Errorf("missing name for an additional database")
+		}
+		if systemDatabases.Has(name) {
+			return fmt.Errorf("the additional database can't be named %q, as it is a PostgreSQL system database", name)
+		}
+		if name == info.ApplicationDatabase {
+			return fmt.Errorf("the additional database %q is already the application database", name)
+		}
+		if names[name] {
+			return fmt.Errorf("duplicate additional database %q", name)
+		}
+		names[name] = true
+	}
+
+	return nil
+}
+
+// createAdditionalDatabases creates the additional databases, owned by the
+// application user, using up to DatabaseCreationConcurrency connections at
+// the same time. The existing databases are kept. Every database is tried,
+// and the errors are aggregated
+func (info InitInfo) createAdditionalDatabases(ctx context.Context, db *sql.DB) error {
+	if len(info.AdditionalDatabases) == 0 {
+		return nil
+	}
+
+	concurrency := info.DatabaseCreationConcurrency
+	if concurrency == 0 {
+		concurrency = DefaultDatabaseCreationConcurrency
+	}
+	concurrency = min(concurrency, len(info.AdditionalDatabases))
+
+	// The pool used for the bootstrap is shared with the other statements:
+	// its limit is raised to the number of workers while the databases are
+	// created, and restored afterwards
+	if maxOpenConnections := db.Stats().MaxOpenConnections; maxOpenConnections > 0 &&
+		maxOpenConnections < concurrency {
+		db.SetMaxOpenConns(concurrency)
+		defer db.SetMaxOpenConns(maxOpenConnections)
+	}
+
+	log.FromContext(ctx).Info("Creating additional databases",
+		"databases", info.AdditionalDatabases,
+		"concurrency", concurrency)
+
+	names := make(chan int)
+	errs := make([]error, len(info.AdditionalDatabases))
+	var wg sync.WaitGroup
+	for range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range names {
+				errs[index] = info.createDatabase(ctx, db, info.AdditionalDatabases[index])
+			}
+		}()
+	}
+
+	for index := range info.AdditionalDatabases {
+		names <- index
+	}
+	close(names)
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// createDatabase creates a database owned by the application user,
+// unless it already exists
+func (info InitInfo) createDatabase(ctx context.Context, db *sql.DB, name string) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("while connecting to create database %s: %w", name, err)
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	var exists bool
+	row := conn.QueryRowContext(ctx, "SELECT COUNT(*) > 0 FROM pg_database WHERE datname = $1", name)
+	if err := row.Scan(&exists); err != nil {
+		return fmt.Errorf("while checking database %s: %w", name, err)
+	}
+	if exists {
+		return nil
+	}
+
+	statement := fmt.Sprintf("CREATE DATABASE %s", pgx.Identifier{name}.Sanitize())
+	if info.ApplicationUser != "" {
+		statement += fmt.Sprintf(" OWNER %s", pgx.Identifier{info.ApplicationUser}.Sanitize())
+	}
+	if _, err := conn.ExecContext(ctx, statement); err != nil {
+		return fmt.Errorf("while creating database %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// createApplicationDatabase creates the application database, owned by
+// the application user. An existing database is kept, and false is
+// returned, unless RecreateApplicationDatabase is set
+func (info InitInfo) createApplicationDatabase(ctx context.Context, db *sql.DB) (bool, error) {
+	var exists bool
+	row := db.QueryRowContext(ctx, "SELECT COUNT(*) > 0 FROM pg_database WHERE datname = $1", info.ApplicationDatabase)
+	if err := row.Scan(&exists); err != nil {
+		return false, err
+	}
+
+	if exists && !info.RecreateApplicationDatabase {
+		return false, nil
+	}
+
+	if exists {
+		if err := info.dropApplicationDatabase(ctx, db); err != nil {
+			return false, err
+		}
+	}
+
+	_, err := db.ExecContext(ctx, fmt.Sprintf("CREATE DATABASE %v OWNER %v",
+		pgx.Identifier{info.ApplicationDatabase}.Sanitize(),
+		pgx.Identifier{info.ApplicationUser}.Sanitize()))
+	if err != nil {
+		return false, fmt.Errorf("could not create ApplicationDatabase: %w", err)
+	}
+
+	return true, nil
+}
+
+// enforceRowSecurity sets row_security on the application database, when
+// requested, so that it can't be disabled by the configuration files
+func (info InitInfo) enforceRowSecurity(ctx context.Context, db *sql.DB) error {
+	if !info.EnforceRowSecurity {
+		return nil
+	}
+
+	if _, err := db.ExecContext(ctx, fmt.Sprintf("ALTER DATABASE %s SET row_security = on",
+		pgx.Identifier{info.ApplicationDatabase}.Sanitize())); err != nil {
+		return fmt.Errorf("while enforcing the row security on the application database: %w", err)
+	}
+
+	return nil
+}
+
+// dropApplicationDatabase drops the existing application database, to
+// recreate it from a clean slate. New connections are refused before
+// terminating the existing ones, so that none of them can hold the
+// database while it is being dropped
+func (info InitInfo) dropApplicationDatabase(ctx context.Context, db *sql.DB) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("while connecting to drop the application database: %w", err)
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	log.Warning("Dropping the existing application database, as requested",
+		"database", info.ApplicationDatabase)
+
+	name := pgx.Identifier{info.ApplicationDatabase}.Sanitize()
+	if _, err := conn.ExecContext(ctx,
+		fmt.Sprintf("ALTER DATABASE %s WITH ALLOW_CONNECTIONS false", name)); err != nil {
+		return fmt.Errorf("while refusing the connections to the application database: %w", err)
+	}
+
+	if _, err := conn.ExecContext(ctx,
+		"SELECT pg_catalog.pg_terminate_backend(pid) FROM pg_catalog.pg_stat_activity "+
+			"WHERE datname = $1 AND pid <> pg_catalog.pg_backend_pid()",
+		info.ApplicationDatabase); err != nil {
+		return fmt.Errorf("while terminating the connections to the application database: %w", err)
+	}
+
+	if _, err := conn.ExecContext(ctx, fmt.Sprintf("DROP DATABASE %s", name)); err != nil {
+		return fmt.Errorf("while dropping the application database: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,228 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("additional databases", func() {
+	const existsQuery = "SELECT COUNT(*) > 0 FROM pg_database WHERE datname = $1"
+
+	tenants := func(count int) []string {
+		names := make([]string, count)
+		for i := range names {
+			names[i] = fmt.Sprintf("tenant%02d", i)
+		}
+		return names
+	}
+
+	Context("validation", func() {
+		It("accepts distinct databases", func() {
+			info := InitInfo{ApplicationDatabase: "app", AdditionalDatabases: tenants(3)}
+			Expect(info.validateAdditionalDatabases()).To(Succeed())
+		})
+
+		DescribeTable("refuses invalid databases",
+			func(info InitInfo, message string) {
+				Expect(info.validateAdditionalDatabases()).To(MatchError(ContainSubstring(message)))
+			},
+			Entry("empty names", InitInfo{AdditionalDatabases: []string{""}}, "missing name"),
+			Entry("system databases", InitInfo{AdditionalDatabases: []string{"template1"}}, "system database"),
+			Entry("the application database",
+				InitInfo{ApplicationDatabase: "app", AdditionalDatabases: []string{"app"}}, "application database"),
+			Entry("duplicates", InitInfo{AdditionalDatabases: []string{"one", "one"}}, "duplicate"),
+			Entry("negative concurrency", InitInfo{DatabaseCreationConcurrency: -1}, "can't be negative"),
+		)
+	})
+
+	Context("creation", func() {
+		It("creates all the databases concurrently", func(ctx SpecContext) {
+			db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			Expect(err).ToNot(HaveOccurred())
+			mock.MatchExpectationsInOrder(false)
+
+			names := tenants(30)
+			for _, name := range names {
+				mock.ExpectQuery(existsQuery).WithArgs(name).
+					WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(name == "tenant07"))
+				if name != "tenant07" {
+					mock.ExpectExec(fmt.Sprintf(`CREATE DATABASE "%s" OWNER "app"`, name)).
+						WillReturnResult(sqlmock.NewResult(0, 0))
+				}
+			}
+
+			info := InitInfo{ApplicationUser: "app", AdditionalDatabases: names, DatabaseCreationConcurrency: 8}
+			Expect(info.createAdditionalDatabases(ctx, db)).To(Succeed())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+			Expect(db.Stats().MaxOpenConnections).To(BeZero())
+		})
+
+		It("tries every database and aggregates the errors", func(ctx SpecContext) {
+			db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			Expect(err).ToNot(HaveOccurred())
+			mock.MatchExpectationsInOrder(false)
+
+			names := tenants(10)
+			for _, name := range names {
+				mock.ExpectQuery(existsQuery).WithArgs(name).
+					WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+				expectation := mock.ExpectExec(fmt.Sprintf(`CREATE DATABASE "%s"`, name))
+				if name == "tenant03" || name == "tenant08" {
+					expectation.WillReturnError(errors.New("out of disk space"))
+				} else {
+					expectation.WillReturnResult(sqlmock.NewResult(0, 0))
+				}
+			}
+
+			err = InitInfo{AdditionalDatabases: names}.createAdditionalDatabases(ctx, db)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal(
+				"while creating database tenant03: out of disk space\n" +
+					"while creating database tenant08: out of disk space"))
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("restores the connection limit of the pool after using more workers", func(ctx SpecContext) {
+			db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			Expect(err).ToNot(HaveOccurred())
+			db.SetMaxOpenConns(1)
+
+			mock.ExpectQuery(existsQuery).WithArgs("tenant00").
+				WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+			mock.ExpectQuery(existsQuery).WithArgs("tenant01").
+				WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+			mock.MatchExpectationsInOrder(false)
+
+			Expect(InitInfo{AdditionalDatabases: tenants(2)}.createAdditionalDatabases(ctx, db)).To(Succeed())
+			Expect(db.Stats().MaxOpenConnections).To(Equal(1))
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+	})
+
+	Context("application database", func() {
+		const (
+			refuseConnections = `ALTER DATABASE "app" WITH ALLOW_CONNECTIONS false`
+			terminateBackends = "SELECT pg_catalog.pg_terminate_backend(pid) FROM pg_catalog.pg_stat_activity " +
+				"WHERE datname = $1 AND pid <> pg_catalog.pg_backend_pid()"
+			createDatabase = `CREATE DATABASE "app" OWNER "app"`
+		)
+
+		info := InitInfo{ApplicationDatabase: "app", ApplicationUser: "app"}
+
+		It("creates a missing database", func(ctx SpecContext) {
+			db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			Expect(err).ToNot(HaveOccurred())
+
+			mock.ExpectQuery(existsQuery).WithArgs("app").
+				WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+			mock.ExpectExec(createDatabase).WillReturnResult(sqlmock.NewResult(0, 0))
+
+			Expect(info.createApplicationDatabase(ctx, db)).To(BeTrue())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("keeps an existing database", func(ctx SpecContext) {
+			db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			Expect(err).ToNot(HaveOccurred())
+
+			mock.ExpectQuery(existsQuery).WithArgs("app").
+				WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+			Expect(info.createApplicationDatabase(ctx, db)).To(BeFalse())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("drops and recreates an existing database when requested", func(ctx SpecContext) {
+			db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			Expect(err).ToNot(HaveOccurred())
+
+			mock.ExpectQuery(existsQuery).WithArgs("app").
+				WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+			mock.ExpectExec(refuseConnections).WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectExec(terminateBackends).WithArgs("app").WillReturnResult(sqlmock.NewResult(0, 2))
+			mock.ExpectExec(`DROP DATABASE "app"`).WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectExec(createDatabase).WillReturnResult(sqlmock.NewResult(0, 0))
+
+			recreate := info
+			recreate.RecreateApplicationDatabase = true
+			Expect(recreate.createApplicationDatabase(ctx, db)).To(BeTrue())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("doesn't create the database when the drop fails", func(ctx SpecContext) {
+			db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			Expect(err).ToNot(HaveOccurred())
+
+			mock.ExpectQuery(existsQuery).WithArgs("app").
+				WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+			mock.ExpectExec(refuseConnections).WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectExec(terminateBackends).WithArgs("app").WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectExec(`DROP DATABASE "app"`).WillReturnError(errors.New("database is being accessed"))
+
+			recreate := info
+			recreate.RecreateApplicationDatabase = true
+			_, err = recreate.createApplicationDatabase(ctx, db)
+			Expect(err).To(MatchError(ContainSubstring("while dropping the application database")))
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+	})
+})
+
+var _ = Describe("row security enforcement", func() {
+	It("sets row_security on the application database", func(ctx SpecContext) {
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		Expect(err).ToNot(HaveOccurred())
+
+		mock.ExpectExec(`ALTER DATABASE "my""app" SET row_security = on`).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		info := InitInfo{ApplicationDatabase: `my"app`, EnforceRowSecurity: true}
+		Expect(info.enforceRowSecurity(ctx, db)).To(Succeed())
+		Expect(mock.ExpectationsWereMet()).To(Succeed())
+	})
+
+	It("doesn't alter the application database unless requested", func(ctx SpecContext) {
+		db, mock, err := sqlmock.New()
+		Expect(err).ToNot(HaveOccurred())
+
+		info := InitInfo{ApplicationDatabase: "app", RowSecurity: true}
+		Expect(info.enforceRowSecurity(ctx, db)).To(Succeed())
+		Expect(mock.ExpectationsWereMet()).To(Succeed())
+	})
+
+	It("reports the failure", func(ctx SpecContext) {
+		db, mock, err := sqlmock.New()
+		Expect(err).ToNot(HaveOccurred())
+
+		mock.ExpectExec("ALTER DATABASE").WillReturnError(errors.New("permission denied"))
+
+		info := InitInfo{ApplicationDatabase: "app", EnforceRowSecurity: true}
+		Expect(info.enforceRowSecurity(ctx, db)).To(MatchError(ContainSubstring("permission denied")))
+	})
+
+	It("requires the application database", func() {
+		info := InitInfo{SkipApplicationSetup: true, EnforceRowSecurity: true}
+		Expect(info.VerifyConfiguration()).To(MatchError(ContainSubstring("requires the application database")))
+	})
+})
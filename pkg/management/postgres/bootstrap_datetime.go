@@ -0,0 +1,93 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// dateStyleOutputFormats are the output formats accepted in DateStyle
+var dateStyleOutputFormats = []string{"iso", "postgres", "sql", "german"}
+
+// dateStyleOrderings are the field orderings accepted in DateStyle,
+// European being a synonym of DMY and US and NonEuropean of MDY
+var dateStyleOrderings = []string{"dmy", "mdy", "ymd", "european", "us", "noneuropean"}
+
+// addDateTimeParameters adds the validated timezone and
+// datestyle of the new instance to the passed bootstrap configuration
+func (info InitInfo) addDateTimeParameters(parameters map[string]string) error {
+	if info.Timezone != "" {
+		if err := validateTimezone(info.Timezone); err != nil {
+			return err
+		}
+		parameters["timezone"] = info.Timezone
+	}
+
+	if info.DateStyle != "" {
+		if err := validateDateStyle(info.DateStyle); err != nil {
+			return err
+		}
+		parameters["datestyle"] = info.DateStyle
+	}
+
+	return nil
+}
+
+// validateTimezone ensures that the passed timezone is a
+// name of the tz database, like `Europe/Rome`
+func validateTimezone(timezone string) error {
+	// "Local" is the local time of the Go runtime, not a tz database name
+	if timezone == "Local" {
+		return fmt.Errorf("invalid timezone %q: expected a name of the tz database, like Europe/Rome", timezone)
+	}
+
+	if _, err := time.LoadLocation(timezone); err != nil {
+		return fmt.Errorf("invalid timezone %q: expected a name of the tz database, like Europe/Rome: %w",
+			timezone, err)
+	}
+
+	return nil
+}
+
+// validateDateStyle ensures that the passed datestyle is made of an
+// output format, a field ordering, or both of them, separated by a comma
+func validateDateStyle(dateStyle string) error {
+	tokens := strings.Split(dateStyle, ",")
+	if len(tokens) > 2 {
+		return fmt.Errorf("invalid datestyle %q: expected an output format and a field ordering", dateStyle)
+	}
+
+	var outputFormat, ordering string
+	for _, token := range tokens {
+		token = strings.ToLower(strings.TrimSpace(token))
+		switch {
+		case slices.Contains(dateStyleOutputFormats, token) && outputFormat == "":
+			outputFormat = token
+		case slices.Contains(dateStyleOrderings, token) && ordering == "":
+			ordering = token
+		default:
+			return fmt.Errorf("invalid datestyle %q: unexpected %q, expected one of %v as the output "+
+				"format and one of %v as the field ordering",
+				dateStyle, token, dateStyleOutputFormats, dateStyleOrderings)
+		}
+	}
+
+	return nil
+}
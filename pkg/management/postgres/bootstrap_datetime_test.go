@@ -0,0 +1,93 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("bootstrap date and time parameters", func() {
+	It("renders the timezone and the datestyle", func(ctx SpecContext) {
+		parameters, err := InitInfo{Timezone: "Europe/Rome", DateStyle: "ISO, DMY"}.bootstrapConfiguration(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(parameters).To(Equal(map[string]string{
+			"timezone":  "Europe/Rome",
+			"datestyle": "ISO, DMY",
+		}))
+	})
+
+	It("replaces the values written by initdb in postgresql.conf", func(ctx SpecContext) {
+		pgData := GinkgoT().TempDir()
+		Expect(os.WriteFile(filepath.Join(pgData, "postgresql.conf"),
+			[]byte("timezone = 'Etc/UTC'\ndatestyle = 'iso, mdy'\n"), 0o600)).To(Succeed())
+
+		parameters, err := InitInfo{Timezone: "America/New_York", DateStyle: "SQL"}.bootstrapConfiguration(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(writeBootstrapConfiguration(pgData, parameters)).To(Succeed())
+
+		content, err := os.ReadFile(filepath.Join(pgData, "postgresql.conf")) // #nosec
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(content)).To(ContainSubstring("timezone = 'America/New_York'"))
+		Expect(string(content)).To(ContainSubstring("datestyle = 'SQL'"))
+		Expect(string(content)).ToNot(ContainSubstring("Etc/UTC"))
+	})
+
+	DescribeTable("validates the timezone",
+		func(timezone string, valid bool) {
+			err := validateTimezone(timezone)
+			if valid {
+				Expect(err).ToNot(HaveOccurred())
+			} else {
+				Expect(err).To(MatchError(ContainSubstring("invalid timezone")))
+			}
+		},
+		Entry("UTC", "UTC", true),
+		Entry("a region", "Asia/Tokyo", true),
+		Entry("an unknown name", "Mars/Olympus_Mons", false),
+		Entry("the Go local time", "Local", false),
+		Entry("a relative path", "../../etc/passwd", false),
+	)
+
+	DescribeTable("validates the datestyle",
+		func(dateStyle string, valid bool) {
+			err := validateDateStyle(dateStyle)
+			if valid {
+				Expect(err).ToNot(HaveOccurred())
+			} else {
+				Expect(err).To(MatchError(ContainSubstring("invalid datestyle")))
+			}
+		},
+		Entry("output format and ordering", "ISO, MDY", true),
+		Entry("ordering and output format", "dmy,german", true),
+		Entry("only the output format", "Postgres", true),
+		Entry("only the ordering", "European", true),
+		Entry("an unknown token", "ISO, XYZ", false),
+		Entry("two output formats", "ISO, SQL", false),
+		Entry("two orderings", "DMY, MDY", false),
+		Entry("too many tokens", "ISO, DMY, US", false),
+		Entry("an empty token", "ISO,", false),
+	)
+
+	It("refuses an invalid timezone in the bootstrap configuration", func(ctx SpecContext) {
+		_, err := InitInfo{Timezone: "Nowhere/Land"}.bootstrapConfiguration(ctx)
+		Expect(err).To(MatchError(ContainSubstring("invalid timezone")))
+	})
+})
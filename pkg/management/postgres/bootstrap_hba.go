@@ -0,0 +1,172 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/cloudnative-pg/machinery/pkg/fileutils"
+)
+
+// initdbLocalAuthMethods are the authentication methods for the local
+// connections which can be selected with the `--auth-local` option of initdb
+var initdbLocalAuthMethods = []string{"trust", "reject", "peer", "md5", "password", "scram-sha-256"}
+
+// initdbHostAuthMethods are the authentication methods for the host
+// connections which can be selected with the `--auth-host` option of initdb
+var initdbHostAuthMethods = []string{"trust", "reject", "ident", "md5", "password", "scram-sha-256"}
+
+// authOptions returns the initdb options selecting the authentication
+// methods written in pg_hba.conf. When AuthLocal and AuthHost are empty,
+// no option is passed and the default of initdb is used
+func (info InitInfo) authOptions() ([]string, error) {
+	var options []string
+
+	if info.AuthLocal != "" {
+		if !slices.Contains(initdbLocalAuthMethods, info.AuthLocal) {
+			return nil, fmt.Errorf("invalid authentication method for the local connections %q, expected one of %v",
+				info.AuthLocal, initdbLocalAuthMethods)
+		}
+		options = append(options, "--auth-local", info.AuthLocal)
+	}
+
+	if info.AuthHost != "" {
+		if !slices.Contains(initdbHostAuthMethods, info.AuthHost) {
+			return nil, fmt.Errorf("invalid authentication method for the host connections %q, expected one of %v",
+				info.AuthHost, initdbHostAuthMethods)
+		}
+		options = append(options, "--auth-host", info.AuthHost)
+	}
+
+	return options, nil
+}
+
+// validateHBARulesFiles ensures the HBA rules files can be read
+func (info InitInfo) validateHBARulesFiles() error {
+	for _, fileName := range info.HBARulesFiles {
+		stat, err := os.Stat(fileName)
+		if err != nil {
+			return fmt.Errorf("invalid HBA rules file: %w", err)
+		}
+		if stat.IsDir() {
+			return fmt.Errorf("invalid HBA rules file: %s is a directory", fileName)
+		}
+	}
+
+	return nil
+}
+
+// initdbHBARules is the content of the pg_hba.conf file created by initdb,
+// where the local and host authentication methods are the placeholders
+const initdbHBARules = `# TYPE  DATABASE        USER            ADDRESS                 METHOD
+local   all             all                                     %[1]s
+host    all             all             127.0.0.1/32            %[2]s
+host    all             all             ::1/128                 %[2]s
+local   replication     all                                     %[1]s
+host    replication     all             127.0.0.1/32            %[2]s
+host    replication     all             ::1/128                 %[2]s
+`
+
+// EffectiveHBA returns the pg_hba.conf file assembled while bootstrapping
+// the new instance, without the need of a running instance. This is the
+// pg_hba.conf of the data directory or, when it has not been created yet,
+// the one initdb creates with the selected authentication methods, followed
+// by the HBA rules files in the given order
+func (info InitInfo) EffectiveHBA() (string, error) {
+	hbaFileName := path.Join(info.PgData, "pg_hba.conf")
+	exists, err := fileutils.FileExists(hbaFileName)
+	if err != nil {
+		return "", fmt.Errorf("while reading pg_hba.conf: %w", err)
+	}
+
+	content := []byte(info.initdbHBA())
+	if exists {
+		if content, err = fileutils.ReadFile(hbaFileName); err != nil {
+			return "", fmt.Errorf("while reading pg_hba.conf: %w", err)
+		}
+	}
+
+	rules, err := info.hbaRulesFilesContent()
+	if err != nil {
+		return "", err
+	}
+	if rules == "" {
+		return string(content), nil
+	}
+
+	// The same separator of appendHBARulesFiles
+	return string(content) + "\n" + rules, nil
+}
+
+// initdbHBA returns the pg_hba.conf rules created by initdb with
+// the selected authentication methods, trust being its default
+func (info InitInfo) initdbHBA() string {
+	authLocal := info.AuthLocal
+	if authLocal == "" {
+		authLocal = "trust"
+	}
+	authHost := info.AuthHost
+	if authHost == "" {
+		authHost = "trust"
+	}
+
+	return fmt.Sprintf(initdbHBARules, authLocal, authHost)
+}
+
+// appendHBARulesFiles appends the content of the HBA rules files,
+// in the given order, to the pg_hba.conf file of the data directory
+func (info InitInfo) appendHBARulesFiles() error {
+	rules, err := info.hbaRulesFilesContent()
+	if err != nil {
+		return err
+	}
+
+	if rules == "" {
+		return nil
+	}
+
+	return fileutils.AppendStringToFile(path.Join(info.PgData, "pg_hba.conf"), rules)
+}
+
+// hbaRulesFilesContent returns the content of the HBA rules files,
+// in the given order, each one preceded by a comment naming it
+func (info InitInfo) hbaRulesFilesContent() (string, error) {
+	var rules strings.Builder
+	for _, fileName := range info.HBARulesFiles {
+		content, err := fileutils.ReadFile(fileName)
+		if err != nil {
+			return "", fmt.Errorf("while reading the HBA rules file: %w", err)
+		}
+
+		// AppendStringToFile already separates the rules from the
+		// existing content with an empty line
+		if rules.Len() > 0 {
+			rules.WriteByte('\n')
+		}
+		fmt.Fprintf(&rules, "# Rules from %s\n", fileName)
+		rules.Write(content)
+		if len(content) > 0 && content[len(content)-1] != '\n' {
+			rules.WriteByte('\n')
+		}
+	}
+
+	return rules.String(), nil
+}
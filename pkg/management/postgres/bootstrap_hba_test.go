@@ -0,0 +1,136 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/cloudnative-pg/machinery/pkg/fileutils"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("bootstrap HBA rules files", func() {
+	var info InitInfo
+
+	BeforeEach(func() {
+		dir := GinkgoT().TempDir()
+		info = InitInfo{PgData: filepath.Join(dir, "pgdata")}
+		Expect(fileutils.EnsureDirectoryExists(info.PgData)).To(Succeed())
+		_, err := fileutils.WriteStringToFile(filepath.Join(info.PgData, "pg_hba.conf"),
+			"local all all trust\n")
+		Expect(err).ToNot(HaveOccurred())
+
+		for name, content := range map[string]string{
+			"team-a.conf": "host app app 10.0.0.0/8 scram-sha-256\n",
+			"team-b.conf": "host reports reports 10.1.0.0/16 scram-sha-256",
+		} {
+			fileName := filepath.Join(dir, name)
+			_, err := fileutils.WriteStringToFile(fileName, content)
+			Expect(err).ToNot(HaveOccurred())
+		}
+		info.HBARulesFiles = []string{filepath.Join(dir, "team-b.conf"), filepath.Join(dir, "team-a.conf")}
+	})
+
+	It("appends the files in the given order", func() {
+		Expect(info.validateHBARulesFiles()).To(Succeed())
+		Expect(info.appendHBARulesFiles()).To(Succeed())
+
+		content, err := fileutils.ReadFile(filepath.Join(info.PgData, "pg_hba.conf"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(content)).To(Equal("local all all trust\n" +
+			"\n# Rules from " + info.HBARulesFiles[0] + "\n" +
+			"host reports reports 10.1.0.0/16 scram-sha-256\n" +
+			"\n# Rules from " + info.HBARulesFiles[1] + "\n" +
+			"host app app 10.0.0.0/8 scram-sha-256\n"))
+	})
+
+	It("leaves pg_hba.conf untouched without rules files", func() {
+		info.HBARulesFiles = nil
+		Expect(info.appendHBARulesFiles()).To(Succeed())
+
+		content, err := fileutils.ReadFile(filepath.Join(info.PgData, "pg_hba.conf"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(content)).To(Equal("local all all trust\n"))
+	})
+
+	It("reports the effective rules in the order they are appended", func() {
+		effectiveHBA, err := info.EffectiveHBA()
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(info.appendHBARulesFiles()).To(Succeed())
+		content, err := fileutils.ReadFile(filepath.Join(info.PgData, "pg_hba.conf"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(effectiveHBA).To(Equal(string(content)))
+	})
+
+	It("synthesizes the initdb rules when the data directory has not been created", func() {
+		info.PgData = filepath.Join(GinkgoT().TempDir(), "missing")
+		info.AuthLocal = "peer"
+		info.AuthHost = "scram-sha-256"
+
+		effectiveHBA, err := info.EffectiveHBA()
+		Expect(err).ToNot(HaveOccurred())
+		lines := strings.Split(effectiveHBA, "\n")
+		Expect(lines[1]).To(MatchRegexp(`^local\s+all\s+all\s+peer$`))
+		Expect(lines[2]).To(MatchRegexp(`^host\s+all\s+all\s+127\.0\.0\.1/32\s+scram-sha-256$`))
+		Expect(effectiveHBA).To(HaveSuffix("\n\n# Rules from " + info.HBARulesFiles[0] + "\n" +
+			"host reports reports 10.1.0.0/16 scram-sha-256\n" +
+			"\n# Rules from " + info.HBARulesFiles[1] + "\n" +
+			"host app app 10.0.0.0/8 scram-sha-256\n"))
+	})
+
+	It("uses the trust default of initdb", func() {
+		info.PgData = filepath.Join(GinkgoT().TempDir(), "missing")
+		info.HBARulesFiles = nil
+
+		effectiveHBA, err := info.EffectiveHBA()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(effectiveHBA).To(MatchRegexp(`(?m)^local\s+replication\s+all\s+trust$`))
+		Expect(effectiveHBA).ToNot(ContainSubstring("# Rules from"))
+	})
+
+	It("refuses missing files", func() {
+		info.HBARulesFiles = append(info.HBARulesFiles, filepath.Join(GinkgoT().TempDir(), "missing.conf"))
+		Expect(info.validateHBARulesFiles()).ToNot(Succeed())
+		Expect(info.VerifyConfiguration()).To(MatchError(ContainSubstring("HBA rules file")))
+	})
+})
+
+var _ = Describe("initdb authentication methods", func() {
+	It("passes no option by default", func() {
+		Expect(InitInfo{}.authOptions()).To(BeEmpty())
+	})
+
+	It("passes the selected methods to initdb", func() {
+		options, err := InitInfo{AuthLocal: "peer", AuthHost: "scram-sha-256"}.authOptions()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(options).To(Equal([]string{"--auth-local", "peer", "--auth-host", "scram-sha-256"}))
+	})
+
+	DescribeTable("refuses invalid methods",
+		func(info InitInfo, message string) {
+			_, err := info.authOptions()
+			Expect(err).To(MatchError(ContainSubstring(message)))
+		},
+		Entry("unknown local method", InitInfo{AuthLocal: "kerberos"}, "local connections"),
+		Entry("peer for the host connections", InitInfo{AuthHost: "peer"}, "host connections"),
+		Entry("ident for the local connections", InitInfo{AuthLocal: "ident"}, "local connections"),
+	)
+})
@@ -0,0 +1,103 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/cloudnative-pg/machinery/pkg/log"
+)
+
+const (
+	// bootstrapHealthPath is the path of the health endpoint
+	// exposed while the transient instance is active
+	bootstrapHealthPath = "/healthz"
+
+	// bootstrapHealthCheckTimeout is the maximum time spent checking
+	// the status of the instance for a single health request
+	bootstrapHealthCheckTimeout = 5 * time.Second
+
+	// bootstrapHealthReadHeaderTimeout is the read header timeout of the health endpoint
+	bootstrapHealthReadHeaderTimeout = 3 * time.Second
+)
+
+// healthCheckFunc checks the status of an instance, returning
+// nil when the instance is accepting connections
+type healthCheckFunc func(ctx context.Context) error
+
+// withHealthEndpoint runs the passed function while exposing an HTTP health
+// endpoint on the passed address. The endpoint reports the result of the
+// passed check, and is shut down once the function returns.
+func withHealthEndpoint(ctx context.Context, address string, check healthCheckFunc, inner func() error) error {
+	contextLogger := log.FromContext(ctx)
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("while starting the health endpoint: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(bootstrapHealthPath, func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), bootstrapHealthCheckTimeout)
+		defer cancel()
+
+		if err := check(ctx); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+
+		_, _ = fmt.Fprint(w, "OK")
+	})
+
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: bootstrapHealthReadHeaderTimeout,
+		BaseContext: func(net.Listener) context.Context {
+			return ctx
+		},
+	}
+
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			contextLogger.Error(err, "Error while serving the health endpoint", "address", address)
+		}
+	}()
+
+	defer func() {
+		if err := server.Close(); err != nil {
+			contextLogger.Error(err, "Error while stopping the health endpoint", "address", address)
+		}
+	}()
+
+	contextLogger.Info("Exposing the health endpoint of the transient instance",
+		"address", listener.Addr().String(),
+		"path", bootstrapHealthPath)
+
+	return inner()
+}
+
+// pingInstance checks whether the instance is accepting connections.
+// pg_isready is used instead of the connection pool, so that the health
+// requests don't take connections away from the bootstrap statements
+func (instance *Instance) pingInstance(context.Context) error {
+	return PgIsReady()
+}
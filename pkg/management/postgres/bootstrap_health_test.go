@@ -0,0 +1,99 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("bootstrap health endpoint", func() {
+	var address string
+
+	BeforeEach(func() {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+		address = listener.Addr().String()
+		Expect(listener.Close()).To(Succeed())
+	})
+
+	getHealth := func() (int, error) {
+		resp, err := http.Get(fmt.Sprintf("http://%s%s", address, bootstrapHealthPath)) // nolint:noctx
+		if err != nil {
+			return 0, err
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode, nil
+	}
+
+	It("reports healthy while active and stops afterward", func(ctx SpecContext) {
+		err := withHealthEndpoint(ctx, address, func(context.Context) error { return nil }, func() error {
+			status, err := getHealth()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(status).To(Equal(http.StatusOK))
+			return nil
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = getHealth()
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("reports unhealthy when the instance is not accepting connections", func(ctx SpecContext) {
+		notReady := func(context.Context) error { return errors.New("not accepting connections") }
+		err := withHealthEndpoint(ctx, address, notReady, func() error {
+			status, err := getHealth()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(status).To(Equal(http.StatusServiceUnavailable))
+			return nil
+		})
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("checks the instance within the context of the caller", func(ctx SpecContext) {
+		type contextKey struct{}
+		callerCtx := context.WithValue(ctx, contextKey{}, "bootstrap")
+		var checkedValue any
+		check := func(checkCtx context.Context) error {
+			checkedValue = checkCtx.Value(contextKey{})
+			return nil
+		}
+
+		err := withHealthEndpoint(callerCtx, address, check, func() error {
+			status, err := getHealth()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(status).To(Equal(http.StatusOK))
+			return nil
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(checkedValue).To(Equal("bootstrap"))
+	})
+
+	It("returns the error of the inner function", func(ctx SpecContext) {
+		innerErr := errors.New("inner error")
+		err := withHealthEndpoint(ctx, address, func(context.Context) error { return nil }, func() error {
+			return innerErr
+		})
+		Expect(err).To(MatchError(innerErr))
+	})
+})
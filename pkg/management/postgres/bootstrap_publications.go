@@ -0,0 +1,158 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/cloudnative-pg/machinery/pkg/log"
+	"github.com/jackc/pgx/v5"
+)
+
+// maxIdentifierLength is the maximum length of a PostgreSQL identifier
+const maxIdentifierLength = 63
+
+// PublicationSpec is a logical replication publication to be
+// created in the application database of a new instance
+type PublicationSpec struct {
+	// The name of the publication
+	Name string
+
+	// Whether the publication includes all the tables of the database
+	AllTables bool
+
+	// The tables included in the publication, optionally qualified
+	// by their schema, like `sales.orders`. Ignored when AllTables is set
+	Tables []string
+}
+
+// validatePublications ensures the publications are well-formed
+func (info InitInfo) validatePublications() error {
+	if len(info.Publications) == 0 {
+		return nil
+	}
+
+	names := make(map[string]bool, len(info.Publications))
+	for _, publication := range info.Publications {
+		if err := validateIdentifier(publication.Name); err != nil {
+			return fmt.Errorf("invalid publication name: %w", err)
+		}
+		if names[publication.Name] {
+			return fmt.Errorf("duplicate publication %q", publication.Name)
+		}
+		names[publication.Name] = true
+
+		if publication.AllTables == (len(publication.Tables) > 0) {
+			return fmt.Errorf("publication %q must either include all the tables or a list of tables",
+				publication.Name)
+		}
+		for _, table := range publication.Tables {
+			if _, err := parseTableName(table); err != nil {
+				return fmt.Errorf("invalid table in publication %q: %w", publication.Name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// createPublications creates the configured publications in the passed
+// application database, once checked that the running instance is
+// configured for logical replication
+func (info InitInfo) createPublications(ctx context.Context, db *sql.DB) error {
+	if len(info.Publications) == 0 {
+		return nil
+	}
+
+	var walLevel string
+	if err := db.QueryRowContext(ctx, "SHOW wal_level").Scan(&walLevel); err != nil {
+		return fmt.Errorf("while reading wal_level: %w", err)
+	}
+	if walLevel != "logical" {
+		return fmt.Errorf("publications require wal_level to be logical, found %s: "+
+			"wal_level is set in .spec.postgresql.parameters of the Cluster", walLevel)
+	}
+
+	for _, publication := range info.Publications {
+		statement, err := publication.createStatement()
+		if err != nil {
+			return err
+		}
+
+		log.FromContext(ctx).Info("Creating publication", "name", publication.Name)
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("while creating publication %s: %w", publication.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// createStatement returns the CREATE PUBLICATION statement of the publication
+func (publication PublicationSpec) createStatement() (string, error) {
+	name := pgx.Identifier{publication.Name}.Sanitize()
+	if publication.AllTables {
+		return fmt.Sprintf("CREATE PUBLICATION %s FOR ALL TABLES", name), nil
+	}
+
+	tables := make([]string, 0, len(publication.Tables))
+	for _, table := range publication.Tables {
+		identifier, err := parseTableName(table)
+		if err != nil {
+			return "", err
+		}
+		tables = append(tables, identifier.Sanitize())
+	}
+
+	return fmt.Sprintf("CREATE PUBLICATION %s FOR TABLE %s", name, strings.Join(tables, ", ")), nil
+}
+
+// parseTableName splits a table name, optionally qualified by its
+// schema, into its identifiers
+func parseTableName(table string) (pgx.Identifier, error) {
+	identifier := pgx.Identifier(strings.Split(table, "."))
+	if len(identifier) > 2 {
+		return nil, fmt.Errorf("%q has too many qualifiers, expected table or schema.table", table)
+	}
+
+	for _, part := range identifier {
+		if err := validateIdentifier(part); err != nil {
+			return nil, fmt.Errorf("%q: %w", table, err)
+		}
+	}
+
+	return identifier, nil
+}
+
+// validateIdentifier ensures the passed name can be used
+// as a PostgreSQL identifier
+func validateIdentifier(name string) error {
+	switch {
+	case name == "":
+		return errors.New("empty identifier")
+	case len(name) > maxIdentifierLength:
+		return fmt.Errorf("identifier %q is longer than %d bytes", name, maxIdentifierLength)
+	case strings.ContainsRune(name, 0):
+		return fmt.Errorf("identifier %q contains a NUL character", name)
+	}
+
+	return nil
+}
@@ -0,0 +1,106 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"strings"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("bootstrap publications", func() {
+	It("creates a publication for all the tables", func(ctx SpecContext) {
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		Expect(err).ToNot(HaveOccurred())
+
+		mock.ExpectQuery("SHOW wal_level").
+			WillReturnRows(sqlmock.NewRows([]string{"wal_level"}).AddRow("logical"))
+		mock.ExpectExec(`CREATE PUBLICATION "cdc" FOR ALL TABLES`).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		info := InitInfo{Publications: []PublicationSpec{{Name: "cdc", AllTables: true}}}
+		Expect(info.createPublications(ctx, db)).To(Succeed())
+		Expect(mock.ExpectationsWereMet()).To(Succeed())
+	})
+
+	It("creates a publication for specific tables", func(ctx SpecContext) {
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		Expect(err).ToNot(HaveOccurred())
+
+		mock.ExpectQuery("SHOW wal_level").
+			WillReturnRows(sqlmock.NewRows([]string{"wal_level"}).AddRow("logical"))
+		mock.ExpectExec(`CREATE PUBLICATION "Orders" FOR TABLE "sales"."orders", "audit""log"`).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		info := InitInfo{Publications: []PublicationSpec{
+			{Name: "Orders", Tables: []string{"sales.orders", `audit"log`}},
+		}}
+		Expect(info.createPublications(ctx, db)).To(Succeed())
+		Expect(mock.ExpectationsWereMet()).To(Succeed())
+	})
+
+	It("requires the instance to have wal_level logical", func(ctx SpecContext) {
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		Expect(err).ToNot(HaveOccurred())
+
+		mock.ExpectQuery("SHOW wal_level").
+			WillReturnRows(sqlmock.NewRows([]string{"wal_level"}).AddRow("replica"))
+
+		info := InitInfo{Publications: []PublicationSpec{{Name: "cdc", AllTables: true}}}
+		Expect(info.createPublications(ctx, db)).To(MatchError(ContainSubstring("wal_level")))
+		Expect(mock.ExpectationsWereMet()).To(Succeed())
+	})
+
+	It("doesn't query the instance without publications", func(ctx SpecContext) {
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(InitInfo{}.createPublications(ctx, db)).To(Succeed())
+		Expect(mock.ExpectationsWereMet()).To(Succeed())
+	})
+
+	Context("validation", func() {
+		It("accepts well-formed publications", func() {
+			info := InitInfo{Publications: []PublicationSpec{{Name: "cdc", AllTables: true}}}
+			Expect(info.validatePublications()).To(Succeed())
+		})
+
+		DescribeTable("rejects malformed publications",
+			func(publication PublicationSpec) {
+				info := InitInfo{Publications: []PublicationSpec{publication}}
+				Expect(info.validatePublications()).ToNot(Succeed())
+			},
+			Entry("missing name", PublicationSpec{AllTables: true}),
+			Entry("no tables", PublicationSpec{Name: "cdc"}),
+			Entry("both all tables and a list", PublicationSpec{Name: "cdc", AllTables: true, Tables: []string{"t"}}),
+			Entry("too many qualifiers", PublicationSpec{Name: "cdc", Tables: []string{"db.sales.orders"}}),
+			Entry("empty schema", PublicationSpec{Name: "cdc", Tables: []string{".orders"}}),
+			Entry("name too long", PublicationSpec{Name: "cdc", Tables: []string{strings.Repeat("t", 64)}}),
+		)
+
+		It("rejects duplicate publications", func() {
+			info := InitInfo{Publications: []PublicationSpec{
+				{Name: "cdc", AllTables: true},
+				{Name: "cdc", Tables: []string{"orders"}},
+			}}
+			Expect(info.validatePublications()).To(MatchError(ContainSubstring("duplicate")))
+		})
+	})
+})
@@ -0,0 +1,212 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/cloudnative-pg/machinery/pkg/log"
+	"github.com/jackc/pgx/v5"
+	"github.com/lib/pq"
+)
+
+// RoleSpec is a read-only login role created alongside the owner of
+// the application database, as needed by reporting tools
+type RoleSpec struct {
+	// The name of the role
+	Name string
+
+	// PasswordFile is the file containing the password of the role.
+	// When empty, the role is created without a password
+	PasswordFile string
+}
+
+// validateReadOnlyRoles ensures the read-only roles can be created
+// in the application database of the new instance
+func (info InitInfo) validateReadOnlyRoles() error {
+	if len(info.ReadOnlyRoles) == 0 {
+		return nil
+	}
+
+	if info.SkipApplicationSetup || info.ApplicationDatabase == "" {
+		return fmt.Errorf("read-only roles require the application database")
+	}
+
+	names := make(map[string]bool, len(info.ReadOnlyRoles))
+	for _, role := range info.ReadOnlyRoles {
+		if err := validateIdentifier(role.Name); err != nil {
+			return fmt.Errorf("invalid read-only role name: %w", err)
+		}
+		if strings.HasPrefix(role.Name, "pg_") || role.Name == "postgres" || role.Name == info.ApplicationUser {
+			return fmt.Errorf("invalid read-only role name %q: it is reserved", role.Name)
+		}
+		if names[role.Name] {
+			return fmt.Errorf("duplicate read-only role %q", role.Name)
+		}
+		names[role.Name] = true
+
+		if role.PasswordFile == "" {
+			continue
+		}
+		password, err := readPasswordFile(role.PasswordFile)
+		if err != nil {
+			return fmt.Errorf("invalid password of read-only role %q: %w", role.Name, err)
+		}
+		if password == "" || strings.ContainsRune(password, 0) {
+			return fmt.Errorf("invalid password of read-only role %q: it must be non-empty "+
+				"and can't contain NUL characters", role.Name)
+		}
+	}
+
+	return nil
+}
+
+// createReadOnlyRoles creates the read-only roles, granting them access to
+// the current and future tables of the public schema of the passed
+// application database
+func (info InitInfo) createReadOnlyRoles(ctx context.Context, db *sql.DB) error {
+	for _, role := range info.ReadOnlyRoles {
+		log.FromContext(ctx).Info("Creating read-only role", "name", role.Name)
+		if err := info.createReadOnlyRole(ctx, db, role); err != nil {
+			return fmt.Errorf("while creating read-only role %s: %w", role.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// createReadOnlyRole creates the passed read-only role in a transaction
+func (info InitInfo) createReadOnlyRole(ctx context.Context, db *sql.DB, role RoleSpec) error {
+	var password string
+	if role.PasswordFile != "" {
+		var err error
+		if password, err = readPasswordFile(role.PasswordFile); err != nil {
+			return err
+		}
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// This has no effect if the transaction
+		// is committed
+		_ = tx.Rollback()
+	}()
+
+	if info.PasswordEncryption != "" {
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("SET LOCAL password_encryption = %s",
+			pq.QuoteLiteral(info.PasswordEncryption))); err != nil {
+			return fmt.Errorf("while setting password_encryption: %w", err)
+		}
+	}
+
+	name := pgx.Identifier{role.Name}.Sanitize()
+	createStatement := fmt.Sprintf("CREATE ROLE %s LOGIN", name)
+	if password != "" {
+		createStatement += " PASSWORD " + pq.QuoteLiteral(password)
+	}
+	if _, err := tx.ExecContext(ctx, createStatement); err != nil {
+		// The statement is not included, as it may contain the password
+		return fmt.Errorf("while running CREATE ROLE: %w", err)
+	}
+
+	for _, statement := range role.grantStatements(info.ApplicationDatabase, info.ApplicationUser) {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("while running %s: %w", statement, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// grantStatements returns the statements granting the role read-only
+// access to the passed database. The default privileges make the tables
+// created afterwards by the owner readable too
+func (role RoleSpec) grantStatements(database, owner string) []string {
+	name := pgx.Identifier{role.Name}.Sanitize()
+	statements := []string{
+		fmt.Sprintf("GRANT CONNECT ON DATABASE %s TO %s", pgx.Identifier{database}.Sanitize(), name),
+		fmt.Sprintf("GRANT USAGE ON SCHEMA public TO %s", name),
+		fmt.Sprintf("GRANT SELECT ON ALL TABLES IN SCHEMA public TO %s", name),
+		fmt.Sprintf("GRANT SELECT ON ALL SEQUENCES IN SCHEMA public TO %s", name),
+	}
+	if owner != "" {
+		statements = append(statements, fmt.Sprintf(
+			"ALTER DEFAULT PRIVILEGES FOR ROLE %s IN SCHEMA public GRANT SELECT ON TABLES TO %s",
+			pgx.Identifier{owner}.Sanitize(), name))
+	}
+
+	return statements
+}
+
+// validateApplicationRoleMemberships ensures the application user can be
+// made a member of the requested roles
+func (info InitInfo) validateApplicationRoleMemberships() error {
+	if len(info.ApplicationRoleMemberships) == 0 {
+		return nil
+	}
+
+	if info.SkipApplicationSetup || info.ApplicationUser == "" {
+		return fmt.Errorf("role memberships require the application user")
+	}
+
+	names := make(map[string]bool, len(info.ApplicationRoleMemberships))
+	for _, role := range info.ApplicationRoleMemberships {
+		if err := validateIdentifier(role); err != nil {
+			return fmt.Errorf("invalid role membership: %w", err)
+		}
+		if role == "postgres" || role == info.ApplicationUser {
+			return fmt.Errorf("invalid role membership %q: the application user can't be a member of it", role)
+		}
+		if names[role] {
+			return fmt.Errorf("duplicate role membership %q", role)
+		}
+		names[role] = true
+	}
+
+	return nil
+}
+
+// grantApplicationRoleMemberships makes the application user a member
+// of the requested roles
+func (info InitInfo) grantApplicationRoleMemberships(ctx context.Context, db *sql.DB) error {
+	for _, statement := range info.applicationRoleMembershipStatements() {
+		log.FromContext(ctx).Info("Granting role membership to the application user", "statement", statement)
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("while running %s: %w", statement, err)
+		}
+	}
+
+	return nil
+}
+
+// applicationRoleMembershipStatements returns the statements making the
+// application user a member of the requested roles
+func (info InitInfo) applicationRoleMembershipStatements() []string {
+	statements := make([]string, 0, len(info.ApplicationRoleMemberships))
+	for _, role := range info.ApplicationRoleMemberships {
+		statements = append(statements, fmt.Sprintf("GRANT %s TO %s",
+			pgx.Identifier{role}.Sanitize(), pgx.Identifier{info.ApplicationUser}.Sanitize()))
+	}
+
+	return statements
+}
@@ -0,0 +1,182 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("read-only roles", func() {
+	It("grants read-only access to the current and future tables", func() {
+		Expect(RoleSpec{Name: "reporting"}.grantStatements("app", "app")).To(Equal([]string{
+			`GRANT CONNECT ON DATABASE "app" TO "reporting"`,
+			`GRANT USAGE ON SCHEMA public TO "reporting"`,
+			`GRANT SELECT ON ALL TABLES IN SCHEMA public TO "reporting"`,
+			`GRANT SELECT ON ALL SEQUENCES IN SCHEMA public TO "reporting"`,
+			`ALTER DEFAULT PRIVILEGES FOR ROLE "app" IN SCHEMA public GRANT SELECT ON TABLES TO "reporting"`,
+		}))
+	})
+
+	It("creates the roles with their password in a transaction", func(ctx SpecContext) {
+		passwordFile := filepath.Join(GinkgoT().TempDir(), "password")
+		Expect(os.WriteFile(passwordFile, []byte("s3cr3t'\n"), 0o600)).To(Succeed())
+
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		Expect(err).ToNot(HaveOccurred())
+
+		info := InitInfo{
+			ApplicationDatabase: "app",
+			ApplicationUser:     "app",
+			PasswordEncryption:  "scram-sha-256",
+			ReadOnlyRoles:       []RoleSpec{{Name: "reporting", PasswordFile: passwordFile}},
+		}
+
+		mock.ExpectBegin()
+		mock.ExpectExec("SET LOCAL password_encryption = 'scram-sha-256'").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(`CREATE ROLE "reporting" LOGIN PASSWORD 's3cr3t'''`).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		for _, statement := range info.ReadOnlyRoles[0].grantStatements("app", "app") {
+			mock.ExpectExec(statement).WillReturnResult(sqlmock.NewResult(0, 0))
+		}
+		mock.ExpectCommit()
+
+		Expect(info.createReadOnlyRoles(ctx, db)).To(Succeed())
+		Expect(mock.ExpectationsWereMet()).To(Succeed())
+	})
+
+	It("doesn't leak the password when the role can't be created", func(ctx SpecContext) {
+		passwordFile := filepath.Join(GinkgoT().TempDir(), "password")
+		Expect(os.WriteFile(passwordFile, []byte("s3cr3t"), 0o600)).To(Succeed())
+
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		Expect(err).ToNot(HaveOccurred())
+
+		mock.ExpectBegin()
+		mock.ExpectExec(`CREATE ROLE "reporting" LOGIN PASSWORD 's3cr3t'`).
+			WillReturnError(os.ErrPermission)
+		mock.ExpectRollback()
+
+		info := InitInfo{
+			ApplicationDatabase: "app",
+			ReadOnlyRoles:       []RoleSpec{{Name: "reporting", PasswordFile: passwordFile}},
+		}
+		err = info.createReadOnlyRoles(ctx, db)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).ToNot(ContainSubstring("s3cr3t"))
+		Expect(mock.ExpectationsWereMet()).To(Succeed())
+	})
+
+	DescribeTable("validates the roles",
+		func(roles []RoleSpec, message string) {
+			info := InitInfo{ApplicationDatabase: "app", ApplicationUser: "app", ReadOnlyRoles: roles}
+			Expect(info.VerifyConfiguration()).To(MatchError(ContainSubstring(message)))
+		},
+		Entry("empty name", []RoleSpec{{}}, "invalid read-only role name"),
+		Entry("the application user", []RoleSpec{{Name: "app"}}, "reserved"),
+		Entry("a predefined role", []RoleSpec{{Name: "pg_monitor"}}, "reserved"),
+		Entry("duplicate roles", []RoleSpec{{Name: "bi"}, {Name: "bi"}}, "duplicate read-only role"),
+		Entry("missing password file", []RoleSpec{{Name: "bi", PasswordFile: "/nonexistent/password"}},
+			"invalid password"),
+	)
+
+	It("refuses an empty password", func() {
+		passwordFile := filepath.Join(GinkgoT().TempDir(), "password")
+		Expect(os.WriteFile(passwordFile, []byte("\n"), 0o600)).To(Succeed())
+
+		info := InitInfo{
+			ApplicationDatabase: "app",
+			ReadOnlyRoles:       []RoleSpec{{Name: "bi", PasswordFile: passwordFile}},
+		}
+		Expect(info.VerifyConfiguration()).To(MatchError(ContainSubstring("it must be non-empty")))
+	})
+
+	It("requires the application database", func() {
+		info := InitInfo{SkipApplicationSetup: true, ReadOnlyRoles: []RoleSpec{{Name: "bi"}}}
+		Expect(info.VerifyConfiguration()).To(MatchError(ContainSubstring("require the application database")))
+	})
+})
+
+var _ = Describe("application role memberships", func() {
+	It("grants the roles to the application user, quoting the identifiers", func() {
+		info := InitInfo{
+			ApplicationUser:            `app"user`,
+			ApplicationRoleMemberships: []string{"pg_read_all_data", "pg_monitor", "Reporting"},
+		}
+		Expect(info.applicationRoleMembershipStatements()).To(Equal([]string{
+			`GRANT "pg_read_all_data" TO "app""user"`,
+			`GRANT "pg_monitor" TO "app""user"`,
+			`GRANT "Reporting" TO "app""user"`,
+		}))
+	})
+
+	It("runs the grants", func(ctx SpecContext) {
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		Expect(err).ToNot(HaveOccurred())
+
+		info := InitInfo{ApplicationUser: "app", ApplicationRoleMemberships: []string{"pg_monitor"}}
+		mock.ExpectExec(`GRANT "pg_monitor" TO "app"`).WillReturnResult(sqlmock.NewResult(0, 0))
+
+		Expect(info.grantApplicationRoleMemberships(ctx, db)).To(Succeed())
+		Expect(mock.ExpectationsWereMet()).To(Succeed())
+	})
+
+	It("reports the failing grant", func(ctx SpecContext) {
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		Expect(err).ToNot(HaveOccurred())
+
+		info := InitInfo{ApplicationUser: "app", ApplicationRoleMemberships: []string{"missing"}}
+		mock.ExpectExec(`GRANT "missing" TO "app"`).WillReturnError(os.ErrNotExist)
+
+		Expect(info.grantApplicationRoleMemberships(ctx, db)).To(MatchError(ContainSubstring(`GRANT "missing"`)))
+		Expect(mock.ExpectationsWereMet()).To(Succeed())
+	})
+
+	DescribeTable("validates the roles",
+		func(info InitInfo, expectedError string) {
+			err := info.validateApplicationRoleMemberships()
+			if expectedError == "" {
+				Expect(err).ToNot(HaveOccurred())
+				return
+			}
+			Expect(err).To(MatchError(ContainSubstring(expectedError)))
+		},
+		Entry("no roles", InitInfo{}, ""),
+		Entry("predefined roles",
+			InitInfo{ApplicationUser: "app", ApplicationRoleMemberships: []string{"pg_monitor", "pg_read_all_data"}}, ""),
+		Entry("without the application setup",
+			InitInfo{ApplicationUser: "app", SkipApplicationSetup: true, ApplicationRoleMemberships: []string{"pg_monitor"}},
+			"require the application user"),
+		Entry("empty role",
+			InitInfo{ApplicationUser: "app", ApplicationRoleMemberships: []string{""}}, "empty identifier"),
+		Entry("NUL character",
+			InitInfo{ApplicationUser: "app", ApplicationRoleMemberships: []string{"pg_\x00monitor"}}, "NUL"),
+		Entry("superuser",
+			InitInfo{ApplicationUser: "app", ApplicationRoleMemberships: []string{"postgres"}}, "can't be a member"),
+		Entry("application user itself",
+			InitInfo{ApplicationUser: "app", ApplicationRoleMemberships: []string{"app"}}, "can't be a member"),
+		Entry("duplicate role",
+			InitInfo{ApplicationUser: "app", ApplicationRoleMemberships: []string{"pg_monitor", "pg_monitor"}},
+			"duplicate"),
+	)
+})
@@ -0,0 +1,64 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/lib/pq"
+)
+
+// validateSSLFiles ensures that the SSL files of the transient instance
+// are either all set or all unset, and that they contain a valid server
+// certificate, private key and CA certificate
+func (info InitInfo) validateSSLFiles() error {
+	if info.SSLCertFile == "" && info.SSLKeyFile == "" && info.SSLCAFile == "" {
+		return nil
+	}
+
+	if info.SSLCertFile == "" || info.SSLKeyFile == "" || info.SSLCAFile == "" {
+		return errors.New("the SSL certificate, key and CA files must be set together")
+	}
+
+	if _, err := tls.LoadX509KeyPair(info.SSLCertFile, info.SSLKeyFile); err != nil {
+		return fmt.Errorf("while loading the SSL certificate and key: %w", err)
+	}
+
+	caCertificates, err := os.ReadFile(info.SSLCAFile)
+	if err != nil {
+		return fmt.Errorf("while reading the SSL CA file: %w", err)
+	}
+	if !x509.NewCertPool().AppendCertsFromPEM(caCertificates) {
+		return fmt.Errorf("no valid certificate found in the SSL CA file %s", info.SSLCAFile)
+	}
+
+	return nil
+}
+
+// sslStartupOptions returns the options enabling SSL in the transient instance
+func (info InitInfo) sslStartupOptions() []string {
+	return []string{
+		"ssl=on",
+		"ssl_cert_file=" + pq.QuoteLiteral(info.SSLCertFile),
+		"ssl_key_file=" + pq.QuoteLiteral(info.SSLKeyFile),
+		"ssl_ca_file=" + pq.QuoteLiteral(info.SSLCAFile),
+	}
+}
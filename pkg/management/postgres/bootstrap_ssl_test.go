@@ -0,0 +1,86 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/cloudnative-pg/cloudnative-pg/pkg/certs"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("transient instance SSL configuration", func() {
+	var info InitInfo
+
+	BeforeEach(func() {
+		dir := GinkgoT().TempDir()
+		ca, err := certs.CreateRootCA("bootstrap-ca", "cnpg")
+		Expect(err).ToNot(HaveOccurred())
+		server, err := ca.CreateAndSignPair("localhost", certs.CertTypeServer, []string{"localhost"})
+		Expect(err).ToNot(HaveOccurred())
+
+		info = InitInfo{
+			PgData:      filepath.Join(dir, "pgdata"),
+			SSLCertFile: filepath.Join(dir, "server.crt"),
+			SSLKeyFile:  filepath.Join(dir, "server.key"),
+			SSLCAFile:   filepath.Join(dir, "ca.crt"),
+		}
+		Expect(os.WriteFile(info.SSLCertFile, server.Certificate, 0o600)).To(Succeed())
+		Expect(os.WriteFile(info.SSLKeyFile, server.Private, 0o600)).To(Succeed())
+		Expect(os.WriteFile(info.SSLCAFile, ca.Certificate, 0o600)).To(Succeed())
+	})
+
+	It("enables SSL in the transient instance when the certificates are provided", func() {
+		Expect(info.validateSSLFiles()).To(Succeed())
+
+		instance := info.GetInstance()
+		Expect(instance.StartupOptions).To(ContainElements(
+			"ssl=on",
+			"ssl_cert_file='"+info.SSLCertFile+"'",
+			"ssl_key_file='"+info.SSLKeyFile+"'",
+			"ssl_ca_file='"+info.SSLCAFile+"'",
+		))
+		Expect(instance.SSLRootCertFile).To(Equal(info.SSLCAFile))
+	})
+
+	It("doesn't enable SSL by default", func() {
+		instance := InitInfo{}.GetInstance()
+		Expect(instance.StartupOptions).ToNot(ContainElement("ssl=on"))
+		Expect(instance.SSLRootCertFile).To(BeEmpty())
+	})
+
+	It("requires the certificate, key and CA files together", func() {
+		info.SSLCAFile = ""
+		Expect(info.validateSSLFiles()).ToNot(Succeed())
+	})
+
+	It("refuses a key not matching the certificate", func() {
+		other, err := certs.CreateRootCA("other", "cnpg")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(os.WriteFile(info.SSLKeyFile, other.Private, 0o600)).To(Succeed())
+
+		Expect(info.validateSSLFiles()).ToNot(Succeed())
+	})
+
+	It("refuses a CA file without certificates", func() {
+		Expect(os.WriteFile(info.SSLCAFile, []byte("not a certificate"), 0o600)).To(Succeed())
+		Expect(info.validateSSLFiles()).ToNot(Succeed())
+	})
+})
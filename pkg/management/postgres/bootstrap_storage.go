@@ -0,0 +1,172 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/cloudnative-pg/machinery/pkg/log"
+)
+
+// maxEffectiveIOConcurrency is the maximum effective_io_concurrency
+// accepted by PostgreSQL
+const maxEffectiveIOConcurrency = 1000
+
+// The effective_io_concurrency chosen for the detected storage: solid
+// state drives serve many concurrent requests, while spinning disks
+// benefit only from a few prefetch requests
+const (
+	solidStateEffectiveIOConcurrency = 200
+	rotationalEffectiveIOConcurrency = 2
+)
+
+var (
+	// mountInfoFile is the file listing the mount points of the
+	// instance manager, with the device backing each of them
+	mountInfoFile = "/proc/self/mountinfo"
+
+	// blockDevicesDirectory is the sysfs directory containing
+	// the block devices, named after their major and minor numbers
+	blockDevicesDirectory = "/sys/dev/block"
+)
+
+// addStorageParameters adds effective_io_concurrency to the passed
+// bootstrap configuration. When not explicitly set and the storage
+// detection is enabled, the value is chosen after the type of the device
+// holding the data directory, keeping the default if it can't be detected
+func (info InitInfo) addStorageParameters(parameters map[string]string) error {
+	if info.EffectiveIOConcurrency != nil {
+		value := *info.EffectiveIOConcurrency
+		if value < 0 || value > maxEffectiveIOConcurrency {
+			return fmt.Errorf("invalid effective_io_concurrency %d: expected a value between 0 and %d",
+				value, maxEffectiveIOConcurrency)
+		}
+		parameters["effective_io_concurrency"] = strconv.Itoa(value)
+		return nil
+	}
+
+	if !info.DetectStorage {
+		return nil
+	}
+
+	rotational, err := isRotationalStorage(info.PgData)
+	if err != nil {
+		log.Warning("Cannot detect the storage of the data directory, "+
+			"keeping the default effective_io_concurrency",
+			"pgdata", info.PgData, "err", err)
+		return nil
+	}
+
+	value := solidStateEffectiveIOConcurrency
+	if rotational {
+		value = rotationalEffectiveIOConcurrency
+	}
+	log.Info("Detected the storage of the data directory",
+		"pgdata", info.PgData, "rotational", rotational, "effective_io_concurrency", value)
+	parameters["effective_io_concurrency"] = strconv.Itoa(value)
+
+	return nil
+}
+
+// isRotationalStorage checks if the device holding the passed
+// path is a spinning disk
+var isRotationalStorage = detectRotationalStorage
+
+// detectRotationalStorage checks if the block device holding the passed
+// path is a spinning disk, as reported by sysfs. The path doesn't need to
+// exist, as the device is the one of the mount point containing it
+func detectRotationalStorage(path string) (bool, error) {
+	device, err := findMountDevice(path)
+	if err != nil {
+		return false, err
+	}
+
+	devicePath, err := filepath.EvalSymlinks(filepath.Join(blockDevicesDirectory, device))
+	if err != nil {
+		return false, fmt.Errorf("device %s is not a block device: %w", device, err)
+	}
+
+	// The queue settings of a partition are the ones of its parent device
+	for _, queueDirectory := range []string{devicePath, filepath.Dir(devicePath)} {
+		// #nosec
+		content, err := os.ReadFile(filepath.Join(queueDirectory, "queue", "rotational"))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+		return strings.TrimSpace(string(content)) == "1", nil
+	}
+
+	return false, fmt.Errorf("device %s doesn't report if it is rotational", device)
+}
+
+// findMountDevice returns the major and minor numbers, like 8:1, of
+// the device backing the mount point containing the passed path
+func findMountDevice(path string) (string, error) {
+	absolutePath, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	// #nosec
+	file, err := os.Open(mountInfoFile)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	var device, mountPoint string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 {
+			continue
+		}
+		// The later mounts shadow the earlier ones on the same mount point
+		if isPathInside(absolutePath, fields[4]) && len(fields[4]) >= len(mountPoint) {
+			device, mountPoint = fields[2], fields[4]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+
+	if device == "" {
+		return "", fmt.Errorf("no mount point contains %s", absolutePath)
+	}
+
+	return device, nil
+}
+
+// isPathInside checks if the passed path is the passed directory
+// or is contained in it
+func isPathInside(path, directory string) bool {
+	if directory == "/" || path == directory {
+		return true
+	}
+
+	return strings.HasPrefix(path, directory+"/")
+}
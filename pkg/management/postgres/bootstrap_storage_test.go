@@ -0,0 +1,118 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"k8s.io/utils/ptr"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("storage parameters", func() {
+	stubStorage := func(rotational bool, err error) {
+		previous := isRotationalStorage
+		isRotationalStorage = func(string) (bool, error) {
+			return rotational, err
+		}
+		DeferCleanup(func() {
+			isRotationalStorage = previous
+		})
+	}
+
+	DescribeTable("renders effective_io_concurrency",
+		func(info InitInfo, rotational bool, detectionErr error, expected string) {
+			stubStorage(rotational, detectionErr)
+			parameters := make(map[string]string)
+			Expect(info.addStorageParameters(parameters)).To(Succeed())
+			if expected == "" {
+				Expect(parameters).ToNot(HaveKey("effective_io_concurrency"))
+				return
+			}
+			Expect(parameters).To(HaveKeyWithValue("effective_io_concurrency", expected))
+		},
+		Entry("not set", InitInfo{}, false, nil, ""),
+		Entry("explicit value", InitInfo{EffectiveIOConcurrency: ptr.To(64)}, false, nil, "64"),
+		Entry("explicit zero", InitInfo{EffectiveIOConcurrency: ptr.To(0)}, false, nil, "0"),
+		Entry("explicit value winning over the detection",
+			InitInfo{EffectiveIOConcurrency: ptr.To(32), DetectStorage: true}, true, nil, "32"),
+		Entry("detected solid state drive", InitInfo{DetectStorage: true}, false, nil, "200"),
+		Entry("detected spinning disk", InitInfo{DetectStorage: true}, true, nil, "2"),
+		Entry("undetectable storage",
+			InitInfo{DetectStorage: true}, false, errors.New("not a block device"), ""),
+	)
+
+	It("refuses an out of range value", func() {
+		for _, value := range []int{-1, 1001} {
+			info := InitInfo{EffectiveIOConcurrency: ptr.To(value)}
+			Expect(info.addStorageParameters(map[string]string{})).
+				To(MatchError(ContainSubstring("between 0 and 1000")))
+		}
+	})
+})
+
+var _ = Describe("storage detection", func() {
+	var sysBlock string
+
+	writeFile := func(name, content string) {
+		Expect(os.MkdirAll(filepath.Dir(name), 0o700)).To(Succeed())
+		Expect(os.WriteFile(name, []byte(content), 0o600)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		root := GinkgoT().TempDir()
+		sysBlock = filepath.Join(root, "sys", "dev", "block")
+		mountInfo := filepath.Join(root, "mountinfo")
+		writeFile(mountInfo,
+			"22 1 0:21 / / rw,relatime - overlay overlay rw\n"+
+				"30 22 8:1 / /var/lib/postgresql/data rw,relatime - ext4 /dev/sda1 rw\n"+
+				"31 22 259:0 / /var/lib/postgresql/wal rw,relatime - xfs /dev/nvme0n1 rw\n")
+
+		// sda1 is a partition of a spinning disk, while
+		// nvme0n1 is a whole solid state drive
+		devices := filepath.Join(root, "sys", "devices")
+		writeFile(filepath.Join(devices, "sda", "queue", "rotational"), "1\n")
+		Expect(os.MkdirAll(filepath.Join(devices, "sda", "sda1"), 0o700)).To(Succeed())
+		writeFile(filepath.Join(devices, "nvme0n1", "queue", "rotational"), "0\n")
+		Expect(os.MkdirAll(sysBlock, 0o700)).To(Succeed())
+		Expect(os.Symlink(filepath.Join(devices, "sda", "sda1"), filepath.Join(sysBlock, "8:1"))).To(Succeed())
+		Expect(os.Symlink(filepath.Join(devices, "nvme0n1"), filepath.Join(sysBlock, "259:0"))).To(Succeed())
+
+		previousMountInfo, previousBlockDevices := mountInfoFile, blockDevicesDirectory
+		mountInfoFile, blockDevicesDirectory = mountInfo, sysBlock
+		DeferCleanup(func() {
+			mountInfoFile, blockDevicesDirectory = previousMountInfo, previousBlockDevices
+		})
+	})
+
+	It("detects a partition of a spinning disk", func() {
+		Expect(detectRotationalStorage("/var/lib/postgresql/data/pgdata")).To(BeTrue())
+	})
+
+	It("detects a solid state drive", func() {
+		Expect(detectRotationalStorage("/var/lib/postgresql/wal")).To(BeFalse())
+	})
+
+	It("doesn't match a mount point by a partial name", func() {
+		_, err := detectRotationalStorage("/var/lib/postgresql/database")
+		Expect(err).To(MatchError(ContainSubstring("0:21")))
+	})
+})
@@ -0,0 +1,181 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cloudnative-pg/machinery/pkg/log"
+	"github.com/jackc/pgx/v5"
+	"github.com/lib/pq"
+)
+
+// TablespaceSpec is a tablespace to be created while configuring
+// a new instance
+type TablespaceSpec struct {
+	// The name of the tablespace
+	Name string
+
+	// The directory where the tablespace is stored. It must
+	// be an existing, empty and writable directory
+	Location string
+}
+
+// builtinDefaultTablespace is the tablespace created by initdb
+// which can be used as default_tablespace
+const builtinDefaultTablespace = "pg_default"
+
+// validateTablespaces ensures that the tablespaces have a valid name
+// and that their locations can be used by PostgreSQL
+func (info InitInfo) validateTablespaces() error {
+	names := make(map[string]bool, len(info.Tablespaces))
+	for _, tablespace := range info.Tablespaces {
+		if tablespace.Name == "" {
+			return fmt.Errorf("missing name for the tablespace in %q", tablespace.Location)
+		}
+		if strings.HasPrefix(tablespace.Name, "pg_") {
+			return fmt.Errorf("the tablespace name %q is reserved, as it starts with pg_", tablespace.Name)
+		}
+		if names[tablespace.Name] {
+			return fmt.Errorf("duplicate tablespace %q", tablespace.Name)
+		}
+		names[tablespace.Name] = true
+
+		if err := checkTablespaceLocation(tablespace.Location); err != nil {
+			return fmt.Errorf("invalid location for tablespace %q: %w", tablespace.Name, err)
+		}
+	}
+
+	if info.DefaultTablespace != "" && !names[info.DefaultTablespace] &&
+		info.DefaultTablespace != builtinDefaultTablespace {
+		return fmt.Errorf("the default tablespace %q is not among the tablespaces to be created",
+			info.DefaultTablespace)
+	}
+
+	tempTablespaces := make(map[string]bool, len(info.TempTablespaces))
+	for _, name := range info.TempTablespaces {
+		if !names[name] && name != builtinDefaultTablespace {
+			return fmt.Errorf("the temporary tablespace %q is not among the tablespaces to be created", name)
+		}
+		if tempTablespaces[name] {
+			return fmt.Errorf("duplicate temporary tablespace %q", name)
+		}
+		tempTablespaces[name] = true
+	}
+
+	return nil
+}
+
+// addTempTablespacesParameter adds the temp_tablespaces of the new instance
+// to the passed bootstrap configuration. PostgreSQL doesn't check the
+// tablespaces listed in the configuration file, so the parameter can be
+// written before creating them
+func (info InitInfo) addTempTablespacesParameter(parameters map[string]string) {
+	if len(info.TempTablespaces) == 0 {
+		return
+	}
+
+	names := make([]string, len(info.TempTablespaces))
+	for i, name := range info.TempTablespaces {
+		names[i] = pgx.Identifier{name}.Sanitize()
+	}
+	parameters["temp_tablespaces"] = strings.Join(names, ", ")
+}
+
+// checkTablespaceLocation ensures the passed location is an absolute path
+// to an existing, empty and writable directory
+func checkTablespaceLocation(location string) error {
+	if !filepath.IsAbs(location) {
+		return fmt.Errorf("%q is not an absolute path", location)
+	}
+
+	entries, err := os.ReadDir(location)
+	if err != nil {
+		return err
+	}
+	if len(entries) > 0 {
+		return fmt.Errorf("%q is not empty", location)
+	}
+
+	return checkDirectoryWritable(location)
+}
+
+// createTablespaces creates the configured tablespaces
+func (info InitInfo) createTablespaces(ctx context.Context, db *sql.DB) error {
+	for _, tablespace := range info.Tablespaces {
+		log.FromContext(ctx).Info("Creating tablespace",
+			"name", tablespace.Name,
+			"location", tablespace.Location)
+		if _, err := db.ExecContext(ctx, fmt.Sprintf("CREATE TABLESPACE %s LOCATION %s",
+			pgx.Identifier{tablespace.Name}.Sanitize(),
+			pq.QuoteLiteral(tablespace.Location))); err != nil {
+			return fmt.Errorf("while creating tablespace %s: %w", tablespace.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// setDefaultTablespace sets the default_tablespace of the instance,
+// once ensured that the tablespace exists
+func (info InitInfo) setDefaultTablespace(ctx context.Context, db *sql.DB) error {
+	if info.DefaultTablespace == "" {
+		return nil
+	}
+
+	var exists bool
+	row := db.QueryRowContext(ctx,
+		"SELECT COUNT(*) > 0 FROM pg_catalog.pg_tablespace WHERE spcname = $1", info.DefaultTablespace)
+	if err := row.Scan(&exists); err != nil {
+		return fmt.Errorf("while checking the default tablespace: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("the default tablespace %q doesn't exist", info.DefaultTablespace)
+	}
+
+	log.FromContext(ctx).Info("Setting the default tablespace", "name", info.DefaultTablespace)
+	if _, err := db.ExecContext(ctx, fmt.Sprintf("ALTER SYSTEM SET default_tablespace = %s",
+		pq.QuoteLiteral(info.DefaultTablespace))); err != nil {
+		return fmt.Errorf("while setting the default tablespace: %w", err)
+	}
+
+	return nil
+}
+
+// checkTempTablespaces ensures that the temporary tablespaces exist once
+// the tablespaces have been created, as PostgreSQL silently ignores the
+// missing ones
+func (info InitInfo) checkTempTablespaces(ctx context.Context, db *sql.DB) error {
+	for _, name := range info.TempTablespaces {
+		var exists bool
+		row := db.QueryRowContext(ctx,
+			"SELECT COUNT(*) > 0 FROM pg_catalog.pg_tablespace WHERE spcname = $1", name)
+		if err := row.Scan(&exists); err != nil {
+			return fmt.Errorf("while checking the temporary tablespaces: %w", err)
+		}
+		if !exists {
+			return fmt.Errorf("the temporary tablespace %q doesn't exist", name)
+		}
+	}
+
+	return nil
+}
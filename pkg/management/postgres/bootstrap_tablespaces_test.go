@@ -0,0 +1,189 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("bootstrap tablespaces", func() {
+	Context("creation", func() {
+		It("creates every configured tablespace", func(ctx SpecContext) {
+			db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			Expect(err).ToNot(HaveOccurred())
+
+			info := InitInfo{Tablespaces: []TablespaceSpec{
+				{Name: "fast", Location: "/var/lib/tablespaces/fast"},
+				{Name: "Archive", Location: "/var/lib/tablespaces/o'brien"},
+			}}
+
+			mock.ExpectExec(`CREATE TABLESPACE "fast" LOCATION '/var/lib/tablespaces/fast'`).
+				WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectExec(`CREATE TABLESPACE "Archive" LOCATION '/var/lib/tablespaces/o''brien'`).
+				WillReturnResult(sqlmock.NewResult(0, 0))
+
+			Expect(info.createTablespaces(ctx, db)).To(Succeed())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+	})
+
+	Context("default tablespace", func() {
+		const existsQuery = "SELECT COUNT(*) > 0 FROM pg_catalog.pg_tablespace WHERE spcname = $1"
+
+		It("sets the default tablespace", func(ctx SpecContext) {
+			db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			Expect(err).ToNot(HaveOccurred())
+
+			mock.ExpectQuery(existsQuery).WithArgs("o'brien").
+				WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+			mock.ExpectExec(`ALTER SYSTEM SET default_tablespace = 'o''brien'`).
+				WillReturnResult(sqlmock.NewResult(0, 0))
+
+			Expect(InitInfo{DefaultTablespace: "o'brien"}.setDefaultTablespace(ctx, db)).To(Succeed())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("refuses a tablespace which doesn't exist", func(ctx SpecContext) {
+			db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			Expect(err).ToNot(HaveOccurred())
+
+			mock.ExpectQuery(existsQuery).WithArgs("fast").
+				WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+			err = InitInfo{DefaultTablespace: "fast"}.setDefaultTablespace(ctx, db)
+			Expect(err).To(MatchError(`the default tablespace "fast" doesn't exist`))
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("does nothing when not set", func(ctx SpecContext) {
+			db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(InitInfo{}.setDefaultTablespace(ctx, db)).To(Succeed())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("must be among the tablespaces to be created", func() {
+			location := GinkgoT().TempDir()
+			info := InitInfo{
+				Tablespaces:       []TablespaceSpec{{Name: "fast", Location: location}},
+				DefaultTablespace: "fast",
+			}
+			Expect(info.validateTablespaces()).To(Succeed())
+
+			info.DefaultTablespace = "pg_default"
+			Expect(info.validateTablespaces()).To(Succeed())
+
+			info.DefaultTablespace = "slow"
+			Expect(info.validateTablespaces()).To(MatchError(ContainSubstring("not among the tablespaces")))
+		})
+	})
+
+	Context("temporary tablespaces", func() {
+		const existsQuery = "SELECT COUNT(*) > 0 FROM pg_catalog.pg_tablespace WHERE spcname = $1"
+
+		It("renders temp_tablespaces", func(ctx SpecContext) {
+			parameters, err := InitInfo{TempTablespaces: []string{"temp1", "Temp 2"}}.bootstrapConfiguration(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(parameters).To(HaveKeyWithValue("temp_tablespaces", `"temp1", "Temp 2"`))
+		})
+
+		It("doesn't render temp_tablespaces when not set", func(ctx SpecContext) {
+			parameters, err := InitInfo{}.bootstrapConfiguration(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(parameters).ToNot(HaveKey("temp_tablespaces"))
+		})
+
+		It("must be among the tablespaces to be created", func() {
+			location := GinkgoT().TempDir()
+			info := InitInfo{
+				Tablespaces:     []TablespaceSpec{{Name: "temp1", Location: location}},
+				TempTablespaces: []string{"temp1", "pg_default"},
+			}
+			Expect(info.validateTablespaces()).To(Succeed())
+
+			info.TempTablespaces = []string{"temp1", "temp2"}
+			Expect(info.validateTablespaces()).To(MatchError(ContainSubstring(`"temp2" is not among the tablespaces`)))
+
+			info.TempTablespaces = []string{"temp1", "temp1"}
+			Expect(info.validateTablespaces()).To(MatchError(ContainSubstring("duplicate temporary tablespace")))
+		})
+
+		It("checks that the tablespaces have been created", func(ctx SpecContext) {
+			db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			Expect(err).ToNot(HaveOccurred())
+
+			mock.ExpectQuery(existsQuery).WithArgs("temp1").
+				WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+			mock.ExpectQuery(existsQuery).WithArgs("temp2").
+				WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+			err = InitInfo{TempTablespaces: []string{"temp1", "temp2"}}.checkTempTablespaces(ctx, db)
+			Expect(err).To(MatchError(`the temporary tablespace "temp2" doesn't exist`))
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+	})
+
+	Context("validation", func() {
+		var location string
+
+		BeforeEach(func() {
+			location = GinkgoT().TempDir()
+		})
+
+		It("accepts an empty and writable location", func() {
+			info := InitInfo{Tablespaces: []TablespaceSpec{{Name: "fast", Location: location}}}
+			Expect(info.validateTablespaces()).To(Succeed())
+		})
+
+		It("refuses a location which is not empty", func() {
+			Expect(os.WriteFile(filepath.Join(location, "PG_VERSION"), []byte("16\n"), 0o600)).To(Succeed())
+
+			info := InitInfo{Tablespaces: []TablespaceSpec{{Name: "fast", Location: location}}}
+			Expect(info.validateTablespaces()).To(MatchError(ContainSubstring("not empty")))
+		})
+
+		It("refuses a location which doesn't exist", func() {
+			info := InitInfo{Tablespaces: []TablespaceSpec{
+				{Name: "fast", Location: filepath.Join(location, "missing")},
+			}}
+			Expect(info.validateTablespaces()).ToNot(Succeed())
+		})
+
+		It("refuses a relative location", func() {
+			info := InitInfo{Tablespaces: []TablespaceSpec{{Name: "fast", Location: "tablespaces/fast"}}}
+			Expect(info.validateTablespaces()).To(MatchError(ContainSubstring("absolute")))
+		})
+
+		It("refuses reserved and duplicate names", func() {
+			info := InitInfo{Tablespaces: []TablespaceSpec{{Name: "pg_fast", Location: location}}}
+			Expect(info.validateTablespaces()).To(MatchError(ContainSubstring("reserved")))
+
+			info = InitInfo{Tablespaces: []TablespaceSpec{
+				{Name: "fast", Location: location},
+				{Name: "fast", Location: location},
+			}}
+			Expect(info.validateTablespaces()).To(MatchError(ContainSubstring("duplicate")))
+		})
+	})
+})
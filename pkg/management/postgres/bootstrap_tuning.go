@@ -0,0 +1,356 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/cloudnative-pg/machinery/pkg/log"
+)
+
+// memorySizeRegex matches the PostgreSQL memory sizes, like `128MB`.
+// A value without unit is expressed in the unit of the parameter
+var memorySizeRegex = regexp.MustCompile(`^([0-9]+)\s*(B|kB|MB|GB|TB)?$`)
+
+// memoryUnits are the multipliers of the PostgreSQL memory units
+var memoryUnits = map[string]int64{
+	"B":  1,
+	"kB": 1 << 10,
+	"MB": 1 << 20,
+	"GB": 1 << 30,
+	"TB": 1 << 40,
+}
+
+// durationRegex matches the PostgreSQL durations, like `5min`.
+// A value without unit is expressed in the unit of the parameter
+var durationRegex = regexp.MustCompile(`^([0-9]+)\s*(us|ms|s|min|h|d)?$`)
+
+// durationUnits are the multipliers of the PostgreSQL time units
+var durationUnits = map[string]time.Duration{
+	"us":  time.Microsecond,
+	"ms":  time.Millisecond,
+	"s":   time.Second,
+	"min": time.Minute,
+	"h":   time.Hour,
+	"d":   24 * time.Hour,
+}
+
+// The limits of the autovacuum parameters accepted by PostgreSQL
+const (
+	maxAutovacuumMaxWorkers  = 262143
+	maxAutovacuumNaptime     = 2147483 * time.Second
+	maxAutovacuumScaleFactor = 100
+)
+
+// validSynchronousCommitLevels are the values accepted
+// by PostgreSQL for synchronous_commit
+var validSynchronousCommitLevels = []string{"on", "off", "local", "remote_write", "remote_apply"}
+
+// minCheckpointTimeout and maxCheckpointTimeout are the limits
+// of checkpoint_timeout accepted by PostgreSQL
+const (
+	minCheckpointTimeout = 30 * time.Second
+	maxCheckpointTimeout = 24 * time.Hour
+)
+
+// maxParallelWorkerLimit is the maximum value of
+// max_parallel_workers_per_gather accepted by PostgreSQL
+const maxParallelWorkerLimit = 1024
+
+// idleSessionTimeoutMinimumMajorVersion is the first PostgreSQL
+// major version supporting idle_session_timeout
+const idleSessionTimeoutMinimumMajorVersion = 14
+
+// maxIdleSessionTimeout is the maximum idle_session_timeout accepted by PostgreSQL
+const maxIdleSessionTimeout = math.MaxInt32 * time.Millisecond
+
+// walSizeDefaultUnit is the unit of max_wal_size and min_wal_size
+// when no explicit unit is used
+const walSizeDefaultUnit = 1 << 20
+
+// addTuningParameters adds the validated tuning parameters of the
+// new instance to the passed bootstrap configuration
+func (info InitInfo) addTuningParameters(ctx context.Context, parameters map[string]string) error {
+	if info.MaxConnections < 0 {
+		return fmt.Errorf("max_connections can't be negative, got %d", info.MaxConnections)
+	}
+	if info.MaxConnections > 0 {
+		parameters["max_connections"] = strconv.Itoa(info.MaxConnections)
+	}
+
+	if info.SuperuserReservedConnections < 0 {
+		return fmt.Errorf("superuser_reserved_connections can't be negative, got %d",
+			info.SuperuserReservedConnections)
+	}
+	if info.SuperuserReservedConnections > 0 {
+		if info.MaxConnections > 0 && info.SuperuserReservedConnections >= info.MaxConnections {
+			return fmt.Errorf("superuser_reserved_connections %d must be lower than max_connections %d",
+				info.SuperuserReservedConnections, info.MaxConnections)
+		}
+		parameters["superuser_reserved_connections"] = strconv.Itoa(info.SuperuserReservedConnections)
+	}
+
+	sizeParameters := map[string]string{
+		"shared_buffers":       info.SharedBuffers,
+		"effective_cache_size": info.EffectiveCacheSize,
+		"work_mem":             info.WorkMem,
+		"max_wal_size":         info.MaxWalSize,
+		"min_wal_size":         info.MinWalSize,
+	}
+	for name, value := range sizeParameters {
+		if value == "" {
+			continue
+		}
+		if !memorySizeRegex.MatchString(value) {
+			return fmt.Errorf("invalid %s value %q: expected a size like 128MB", name, value)
+		}
+		parameters[name] = value
+	}
+
+	if err := info.checkWalSizes(); err != nil {
+		return err
+	}
+
+	if err := info.addCheckpointParameters(parameters); err != nil {
+		return err
+	}
+
+	if err := info.addAutovacuumParameters(parameters); err != nil {
+		return err
+	}
+
+	if info.HugePages != "" {
+		if !slices.Contains(validHugePages, info.HugePages) {
+			return fmt.Errorf("invalid huge_pages %q, expected one of %v", info.HugePages, validHugePages)
+		}
+		parameters["huge_pages"] = info.HugePages
+	}
+
+	if info.SynchronousCommit != "" {
+		if !slices.Contains(validSynchronousCommitLevels, info.SynchronousCommit) {
+			return fmt.Errorf("invalid synchronous_commit %q, expected one of %v",
+				info.SynchronousCommit, validSynchronousCommitLevels)
+		}
+		parameters["synchronous_commit"] = info.SynchronousCommit
+	}
+
+	if err := info.addParallelismParameters(parameters); err != nil {
+		return err
+	}
+
+	if err := info.addStorageParameters(parameters); err != nil {
+		return err
+	}
+
+	if info.Fsync != nil {
+		parameters["fsync"] = "on"
+		if !*info.Fsync {
+			parameters["fsync"] = "off"
+			log.FromContext(ctx).Warning("DURABILITY REDUCED: fsync is disabled. A crash of the " +
+				"operating system can corrupt the database: use this only for benchmarks")
+		}
+	}
+
+	return nil
+}
+
+// addCheckpointParameters adds the validated checkpoint tuning
+// parameters to the passed bootstrap configuration
+func (info InitInfo) addCheckpointParameters(parameters map[string]string) error {
+	if target := info.CheckpointCompletionTarget; target != nil {
+		if math.IsNaN(*target) || *target < 0 || *target > 1 {
+			return fmt.Errorf("invalid checkpoint_completion_target %v: expected a value between 0 and 1",
+				*target)
+		}
+		parameters["checkpoint_completion_target"] = strconv.FormatFloat(*target, 'f', -1, 64)
+	}
+
+	if info.CheckpointTimeout != "" {
+		timeout, err := parseDuration(info.CheckpointTimeout, time.Second)
+		if err != nil {
+			return fmt.Errorf("invalid checkpoint_timeout: %w", err)
+		}
+		if timeout < minCheckpointTimeout || timeout > maxCheckpointTimeout {
+			return fmt.Errorf("invalid checkpoint_timeout %q: expected a duration between %s and %s",
+				info.CheckpointTimeout, minCheckpointTimeout, maxCheckpointTimeout)
+		}
+		parameters["checkpoint_timeout"] = info.CheckpointTimeout
+	}
+
+	return nil
+}
+
+// addAutovacuumParameters adds the validated autovacuum
+// parameters to the passed bootstrap configuration
+func (info InitInfo) addAutovacuumParameters(parameters map[string]string) error {
+	if info.AutovacuumMaxWorkers < 0 || info.AutovacuumMaxWorkers > maxAutovacuumMaxWorkers {
+		return fmt.Errorf("invalid autovacuum_max_workers %d: expected a value between 1 and %d, "+
+			"or 0 to keep the default",
+			info.AutovacuumMaxWorkers, maxAutovacuumMaxWorkers)
+	}
+	if info.AutovacuumMaxWorkers > 0 {
+		parameters["autovacuum_max_workers"] = strconv.Itoa(info.AutovacuumMaxWorkers)
+	}
+
+	if info.AutovacuumNaptime != "" {
+		naptime, err := parseDuration(info.AutovacuumNaptime, time.Second)
+		if err != nil {
+			return fmt.Errorf("invalid autovacuum_naptime: %w", err)
+		}
+		if naptime < time.Second || naptime > maxAutovacuumNaptime {
+			return fmt.Errorf("invalid autovacuum_naptime %q: expected a duration between 1s and %s",
+				info.AutovacuumNaptime, maxAutovacuumNaptime)
+		}
+		parameters["autovacuum_naptime"] = info.AutovacuumNaptime
+	}
+
+	if scaleFactor := info.AutovacuumVacuumScaleFactor; scaleFactor != nil {
+		if math.IsNaN(*scaleFactor) || *scaleFactor < 0 || *scaleFactor > maxAutovacuumScaleFactor {
+			return fmt.Errorf("invalid autovacuum_vacuum_scale_factor %v: expected a value between 0 and %d",
+				*scaleFactor, maxAutovacuumScaleFactor)
+		}
+		parameters["autovacuum_vacuum_scale_factor"] = strconv.FormatFloat(*scaleFactor, 'f', -1, 64)
+	}
+
+	return nil
+}
+
+// addParallelismParameters adds the validated jit and parallel query
+// parameters to the passed bootstrap configuration. Zero is a valid
+// number of workers per gather, disabling the parallel queries
+func (info InitInfo) addParallelismParameters(parameters map[string]string) error {
+	if info.JIT != nil {
+		parameters["jit"] = "off"
+		if *info.JIT {
+			parameters["jit"] = "on"
+		}
+	}
+
+	if workers := info.MaxParallelWorkersPerGather; workers != nil {
+		if *workers < 0 || *workers > maxParallelWorkerLimit {
+			return fmt.Errorf("invalid max_parallel_workers_per_gather %d: expected a value between 0 and %d",
+				*workers, maxParallelWorkerLimit)
+		}
+		parameters["max_parallel_workers_per_gather"] = strconv.Itoa(*workers)
+	}
+
+	return nil
+}
+
+// checkWalSizes ensures that min_wal_size doesn't exceed max_wal_size,
+// which are expressed in megabytes when no unit is used
+func (info InitInfo) checkWalSizes() error {
+	if info.MinWalSize == "" || info.MaxWalSize == "" {
+		return nil
+	}
+
+	minWalSize, err := parseMemorySize(info.MinWalSize, walSizeDefaultUnit)
+	if err != nil {
+		return fmt.Errorf("invalid min_wal_size: %w", err)
+	}
+	maxWalSize, err := parseMemorySize(info.MaxWalSize, walSizeDefaultUnit)
+	if err != nil {
+		return fmt.Errorf("invalid max_wal_size: %w", err)
+	}
+
+	if minWalSize > maxWalSize {
+		return fmt.Errorf("min_wal_size %s can't be greater than max_wal_size %s", info.MinWalSize, info.MaxWalSize)
+	}
+
+	return nil
+}
+
+// addIdleSessionTimeoutParameter adds the validated idle_session_timeout
+// to the passed bootstrap configuration, refusing it on the major versions
+// where PostgreSQL doesn't support it
+func (info InitInfo) addIdleSessionTimeoutParameter(parameters map[string]string, majorVersion int) error {
+	if info.IdleSessionTimeout == "" {
+		return nil
+	}
+
+	if majorVersion < idleSessionTimeoutMinimumMajorVersion {
+		return fmt.Errorf("idle_session_timeout requires PostgreSQL %d or later, got %d",
+			idleSessionTimeoutMinimumMajorVersion, majorVersion)
+	}
+
+	timeout, err := parseDuration(info.IdleSessionTimeout, time.Millisecond)
+	if err != nil {
+		return fmt.Errorf("invalid idle_session_timeout: %w", err)
+	}
+	if timeout > maxIdleSessionTimeout {
+		return fmt.Errorf("invalid idle_session_timeout %q: it can't exceed %s",
+			info.IdleSessionTimeout, maxIdleSessionTimeout)
+	}
+
+	parameters["idle_session_timeout"] = info.IdleSessionTimeout
+	return nil
+}
+
+// parseMemorySize converts a PostgreSQL memory size into bytes. The
+// defaultUnit is the size in bytes of the unit used by the parameter
+// when the value has no explicit unit
+func parseMemorySize(value string, defaultUnit int64) (int64, error) {
+	matches := memorySizeRegex.FindStringSubmatch(value)
+	if matches == nil {
+		return 0, fmt.Errorf("invalid size %q: expected a size like 128MB", value)
+	}
+
+	size, err := strconv.ParseInt(matches[1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q: %w", value, err)
+	}
+
+	unit := defaultUnit
+	if matches[2] != "" {
+		unit = memoryUnits[matches[2]]
+	}
+	if size > math.MaxInt64/unit {
+		return 0, fmt.Errorf("invalid size %q: out of range", value)
+	}
+
+	return size * unit, nil
+}
+
+// parseDuration parses a PostgreSQL duration, using the passed
+// default unit when the value has no unit
+func parseDuration(value string, defaultUnit time.Duration) (time.Duration, error) {
+	matches := durationRegex.FindStringSubmatch(value)
+	if matches == nil {
+		return 0, fmt.Errorf("invalid duration %q: expected a duration like 5min", value)
+	}
+
+	amount, err := strconv.ParseInt(matches[1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q: %w", value, err)
+	}
+
+	unit := defaultUnit
+	if matches[2] != "" {
+		unit = durationUnits[matches[2]]
+	}
+	if amount > int64(math.MaxInt64/unit) {
+		return 0, fmt.Errorf("invalid duration %q: out of range", value)
+	}
+
+	return time.Duration(amount) * unit, nil
+}
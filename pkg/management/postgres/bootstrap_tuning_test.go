@@ -0,0 +1,356 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"math"
+
+	"github.com/cloudnative-pg/machinery/pkg/log"
+	"github.com/go-logr/logr/funcr"
+	"k8s.io/utils/ptr"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("bootstrap tuning parameters", func() {
+	It("renders the configured parameters", func(ctx SpecContext) {
+		info := InitInfo{
+			MaxConnections:     200,
+			SharedBuffers:      "1GB",
+			EffectiveCacheSize: "3 GB",
+			WorkMem:            "4096",
+		}
+
+		parameters, err := info.bootstrapConfiguration(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(parameters).To(Equal(map[string]string{
+			"max_connections":      "200",
+			"shared_buffers":       "1GB",
+			"effective_cache_size": "3 GB",
+			"work_mem":             "4096",
+		}))
+	})
+
+	It("doesn't render the parameters that are not set", func(ctx SpecContext) {
+		parameters, err := InitInfo{SharedBuffers: "128MB"}.bootstrapConfiguration(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(parameters).To(Equal(map[string]string{"shared_buffers": "128MB"}))
+	})
+
+	It("rejects a negative max_connections", func(ctx SpecContext) {
+		_, err := InitInfo{MaxConnections: -1}.bootstrapConfiguration(ctx)
+		Expect(err).To(MatchError("max_connections can't be negative, got -1"))
+	})
+
+	It("rejects the WAL sizes overflowing when converted to bytes", func(ctx SpecContext) {
+		_, err := InitInfo{MinWalSize: "1GB", MaxWalSize: "9999999999TB"}.bootstrapConfiguration(ctx)
+		Expect(err).To(MatchError(ContainSubstring("out of range")))
+	})
+
+	Context("superuser reserved connections", func() {
+		It("renders superuser_reserved_connections", func(ctx SpecContext) {
+			parameters, err := InitInfo{
+				MaxConnections:               100,
+				SuperuserReservedConnections: 5,
+			}.bootstrapConfiguration(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(parameters).To(HaveKeyWithValue("superuser_reserved_connections", "5"))
+		})
+
+		It("rejects a negative value", func(ctx SpecContext) {
+			_, err := InitInfo{SuperuserReservedConnections: -1}.bootstrapConfiguration(ctx)
+			Expect(err).To(MatchError("superuser_reserved_connections can't be negative, got -1"))
+		})
+
+		It("rejects a value not lower than max_connections", func(ctx SpecContext) {
+			_, err := InitInfo{MaxConnections: 10, SuperuserReservedConnections: 10}.bootstrapConfiguration(ctx)
+			Expect(err).To(MatchError(ContainSubstring("lower than max_connections")))
+		})
+	})
+
+	DescribeTable("rejects malformed sizes",
+		func(ctx SpecContext, info InitInfo, parameter string) {
+			_, err := info.bootstrapConfiguration(ctx)
+			Expect(err).To(MatchError(ContainSubstring(parameter)))
+		},
+		Entry("unknown unit", InitInfo{SharedBuffers: "1GiB"}, "shared_buffers"),
+		Entry("lowercase unit", InitInfo{WorkMem: "4mb"}, "work_mem"),
+		Entry("fractional value", InitInfo{EffectiveCacheSize: "1.5GB"}, "effective_cache_size"),
+		Entry("not a number", InitInfo{SharedBuffers: "a lot"}, "shared_buffers"),
+	)
+
+	Context("autovacuum", func() {
+		It("renders the autovacuum parameters", func(ctx SpecContext) {
+			parameters, err := InitInfo{
+				AutovacuumMaxWorkers:        6,
+				AutovacuumNaptime:           "15s",
+				AutovacuumVacuumScaleFactor: ptr.To(0.05),
+			}.bootstrapConfiguration(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(parameters).To(Equal(map[string]string{
+				"autovacuum_max_workers":         "6",
+				"autovacuum_naptime":             "15s",
+				"autovacuum_vacuum_scale_factor": "0.05",
+			}))
+		})
+
+		DescribeTable("validates the scale factor range",
+			func(ctx SpecContext, scaleFactor float64, valid bool) {
+				parameters, err := InitInfo{AutovacuumVacuumScaleFactor: &scaleFactor}.bootstrapConfiguration(ctx)
+				if !valid {
+					Expect(err).To(MatchError(ContainSubstring("invalid autovacuum_vacuum_scale_factor")))
+					return
+				}
+				Expect(err).ToNot(HaveOccurred())
+				Expect(parameters).To(HaveKey("autovacuum_vacuum_scale_factor"))
+			},
+			Entry("zero", 0.0, true),
+			Entry("the maximum", 100.0, true),
+			Entry("negative", -0.01, false),
+			Entry("above the maximum", 100.5, false),
+			Entry("not a number", math.NaN(), false),
+		)
+
+		DescribeTable("refuses the invalid parameters",
+			func(ctx SpecContext, info InitInfo, message string) {
+				_, err := info.bootstrapConfiguration(ctx)
+				Expect(err).To(MatchError(ContainSubstring(message)))
+			},
+			Entry("negative workers", InitInfo{AutovacuumMaxWorkers: -1}, "or 0 to keep the default"),
+			Entry("too many workers", InitInfo{AutovacuumMaxWorkers: 300000}, "invalid autovacuum_max_workers"),
+			Entry("naptime too short", InitInfo{AutovacuumNaptime: "500ms"}, "invalid autovacuum_naptime"),
+			Entry("malformed naptime", InitInfo{AutovacuumNaptime: "often"}, "invalid autovacuum_naptime"),
+		)
+	})
+
+	Context("checkpoints", func() {
+		It("renders the checkpoint parameters", func(ctx SpecContext) {
+			parameters, err := InitInfo{
+				CheckpointCompletionTarget: ptr.To(0.75),
+				CheckpointTimeout:          "15min",
+			}.bootstrapConfiguration(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(parameters).To(Equal(map[string]string{
+				"checkpoint_completion_target": "0.75",
+				"checkpoint_timeout":           "15min",
+			}))
+		})
+
+		It("renders a zero checkpoint_completion_target", func(ctx SpecContext) {
+			parameters, err := InitInfo{CheckpointCompletionTarget: ptr.To(0.0)}.bootstrapConfiguration(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(parameters).To(HaveKeyWithValue("checkpoint_completion_target", "0"))
+		})
+
+		DescribeTable("refuses the out of range values",
+			func(ctx SpecContext, info InitInfo, message string) {
+				_, err := info.bootstrapConfiguration(ctx)
+				Expect(err).To(MatchError(ContainSubstring(message)))
+			},
+			Entry("negative target", InitInfo{CheckpointCompletionTarget: ptr.To(-0.1)}, "checkpoint_completion_target"),
+			Entry("target above one", InitInfo{CheckpointCompletionTarget: ptr.To(1.5)}, "checkpoint_completion_target"),
+			Entry("not a number", InitInfo{CheckpointCompletionTarget: ptr.To(math.NaN())},
+				"checkpoint_completion_target"),
+			Entry("timeout too short", InitInfo{CheckpointTimeout: "10s"}, "between 30s and 24h0m0s"),
+			Entry("timeout too long", InitInfo{CheckpointTimeout: "2d"}, "between 30s and 24h0m0s"),
+			Entry("malformed timeout", InitInfo{CheckpointTimeout: "15 minutes"}, "invalid checkpoint_timeout"),
+		)
+	})
+
+	Context("WAL sizes", func() {
+		It("renders the WAL sizes", func(ctx SpecContext) {
+			parameters, err := InitInfo{MaxWalSize: "4GB", MinWalSize: "512"}.bootstrapConfiguration(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(parameters).To(Equal(map[string]string{
+				"max_wal_size": "4GB",
+				"min_wal_size": "512",
+			}))
+		})
+
+		DescribeTable("rejects malformed sizes",
+			func(ctx SpecContext, info InitInfo, message string) {
+				_, err := info.bootstrapConfiguration(ctx)
+				Expect(err).To(MatchError(ContainSubstring(message)))
+			},
+			Entry("unknown unit", InitInfo{MaxWalSize: "4GiB"}, "max_wal_size"),
+			Entry("negative size", InitInfo{MinWalSize: "-80MB"}, "min_wal_size"),
+			Entry("min_wal_size greater than max_wal_size",
+				InitInfo{MaxWalSize: "1GB", MinWalSize: "2048MB"}, "can't be greater than max_wal_size"),
+		)
+	})
+
+	DescribeTable("validates huge_pages",
+		func(ctx SpecContext, hugePages string, valid bool) {
+			parameters, err := InitInfo{HugePages: hugePages}.bootstrapConfiguration(ctx)
+			if !valid {
+				Expect(err).To(MatchError(ContainSubstring("invalid huge_pages")))
+				return
+			}
+			Expect(err).ToNot(HaveOccurred())
+			Expect(parameters).To(HaveKeyWithValue("huge_pages", hugePages))
+		},
+		Entry("off", "off", true),
+		Entry("on", "on", true),
+		Entry("try", "try", true),
+		Entry("a boolean", "true", false),
+		Entry("uppercase", "ON", false),
+	)
+
+	DescribeTable("validates synchronous_commit",
+		func(ctx SpecContext, synchronousCommit string, valid bool) {
+			parameters, err := InitInfo{SynchronousCommit: synchronousCommit}.bootstrapConfiguration(ctx)
+			if !valid {
+				Expect(err).To(MatchError(ContainSubstring("invalid synchronous_commit")))
+				return
+			}
+			Expect(err).ToNot(HaveOccurred())
+			Expect(parameters).To(HaveKeyWithValue("synchronous_commit", synchronousCommit))
+		},
+		Entry("on", "on", true),
+		Entry("off", "off", true),
+		Entry("local", "local", true),
+		Entry("remote_write", "remote_write", true),
+		Entry("remote_apply", "remote_apply", true),
+		Entry("a boolean", "true", false),
+		Entry("a misspelled level", "remote-apply", false),
+	)
+
+	Context("durability", func() {
+		var messages []string
+
+		BeforeEach(func() {
+			messages = nil
+			originalLogger := log.GetLogger().GetLogger()
+			log.SetLogger(funcr.New(func(_, args string) {
+				messages = append(messages, args)
+			}, funcr.Options{Verbosity: 10}))
+			DeferCleanup(func() {
+				log.SetLogger(originalLogger)
+			})
+		})
+
+		It("keeps the defaults of PostgreSQL when not set", func(ctx SpecContext) {
+			parameters, err := InitInfo{}.bootstrapConfiguration(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(parameters).ToNot(HaveKey("fsync"))
+			Expect(messages).To(BeEmpty())
+		})
+
+		It("renders the safe values without warnings", func(ctx SpecContext) {
+			enabled := true
+			parameters, err := InitInfo{Fsync: &enabled}.bootstrapConfiguration(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(parameters).To(HaveKeyWithValue("fsync", "on"))
+			Expect(messages).To(BeEmpty())
+		})
+
+		It("warns when the durability is reduced", func(ctx SpecContext) {
+			disabled := false
+			parameters, err := InitInfo{Fsync: &disabled}.bootstrapConfiguration(log.IntoContext(ctx, log.GetLogger()))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(parameters).To(HaveKeyWithValue("fsync", "off"))
+
+			Expect(messages).To(HaveLen(1))
+			Expect(messages[0]).To(ContainSubstring("DURABILITY REDUCED: fsync is disabled"))
+		})
+	})
+
+	Context("jit and parallelism", func() {
+		It("keeps the defaults of PostgreSQL when not set", func(ctx SpecContext) {
+			parameters, err := InitInfo{}.bootstrapConfiguration(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(parameters).ToNot(HaveKey("jit"))
+			Expect(parameters).ToNot(HaveKey("max_parallel_workers_per_gather"))
+		})
+
+		It("renders the configured parameters", func(ctx SpecContext) {
+			parameters, err := InitInfo{
+				JIT:                         ptr.To(true),
+				MaxParallelWorkersPerGather: ptr.To(4),
+			}.bootstrapConfiguration(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(parameters).To(Equal(map[string]string{
+				"jit":                             "on",
+				"max_parallel_workers_per_gather": "4",
+			}))
+		})
+
+		It("renders the values disabling jit and the parallel queries", func(ctx SpecContext) {
+			parameters, err := InitInfo{
+				JIT:                         ptr.To(false),
+				MaxParallelWorkersPerGather: ptr.To(0),
+			}.bootstrapConfiguration(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(parameters).To(Equal(map[string]string{
+				"jit":                             "off",
+				"max_parallel_workers_per_gather": "0",
+			}))
+		})
+
+		DescribeTable("validates the number of workers",
+			func(ctx SpecContext, info InitInfo, message string) {
+				_, err := info.bootstrapConfiguration(ctx)
+				Expect(err).To(MatchError(ContainSubstring(message)))
+			},
+			Entry("negative max_parallel_workers_per_gather", InitInfo{MaxParallelWorkersPerGather: ptr.To(-2)},
+				"invalid max_parallel_workers_per_gather -2"),
+			Entry("max_parallel_workers_per_gather over the limit", InitInfo{MaxParallelWorkersPerGather: ptr.To(1025)},
+				"between 0 and 1024"),
+		)
+	})
+
+	Context("idle session timeout", func() {
+		It("renders nothing when not set", func() {
+			parameters := map[string]string{}
+			Expect(InitInfo{}.addIdleSessionTimeoutParameter(parameters, 13)).To(Succeed())
+			Expect(parameters).To(BeEmpty())
+		})
+
+		It("renders idle_session_timeout since PostgreSQL 14", func() {
+			parameters := map[string]string{}
+			Expect(InitInfo{IdleSessionTimeout: "10min"}.addIdleSessionTimeoutParameter(parameters, 14)).
+				To(Succeed())
+			Expect(parameters).To(Equal(map[string]string{"idle_session_timeout": "10min"}))
+		})
+
+		It("refuses idle_session_timeout before PostgreSQL 14", func() {
+			parameters := map[string]string{}
+			err := InitInfo{IdleSessionTimeout: "10min"}.addIdleSessionTimeoutParameter(parameters, 13)
+			Expect(err).To(MatchError(ContainSubstring("requires PostgreSQL 14")))
+			Expect(parameters).To(BeEmpty())
+		})
+
+		DescribeTable("validates the value",
+			func(value string, valid bool) {
+				err := InitInfo{IdleSessionTimeout: value}.addIdleSessionTimeoutParameter(map[string]string{}, 17)
+				if valid {
+					Expect(err).ToNot(HaveOccurred())
+				} else {
+					Expect(err).To(MatchError(ContainSubstring("idle_session_timeout")))
+				}
+			},
+			Entry("disabled", "0", true),
+			Entry("in milliseconds", "60000", true),
+			Entry("with a unit", "1h", true),
+			Entry("the maximum", "2147483647ms", true),
+			Entry("over the maximum", "25d", false),
+			Entry("negative", "-1s", false),
+			Entry("an unknown unit", "10m", false),
+		)
+	})
+})
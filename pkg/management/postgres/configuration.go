@@ -386,6 +386,7 @@ func createPostgresqlConfiguration(cluster *apiv1.Cluster, preserveUserSettings
 		AdditionalSharedPreloadLibraries: cluster.Spec.PostgresConfiguration.AdditionalLibraries,
 		IsReplicaCluster:                 cluster.IsReplica(),
 		IsWalArchivingDisabled:           utils.IsWalArchivingDisabled(&cluster.ObjectMeta),
+		IsWalArchivingViaPlugin:          utils.IsWalArchivingViaPlugin(&cluster.ObjectMeta),
 		IsAlterSystemEnabled:             cluster.Spec.PostgresConfiguration.EnableAlterSystem,
 		SynchronousStandbyNames:          replication.GetSynchronousStandbyNames(cluster),
 	}
@@ -26,6 +26,7 @@ import (
 	"k8s.io/utils/ptr"
 
 	apiv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	"github.com/cloudnative-pg/cloudnative-pg/pkg/utils"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -254,3 +255,29 @@ var _ = Describe("recovery_min_apply_delay", func() {
 		Expect(config).ToNot(ContainSubstring("recovery_min_apply_delay"))
 	})
 })
+
+var _ = Describe("WAL archiving via plugin", func() {
+	cluster := apiv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "configurationTest",
+			Namespace: "default",
+			Annotations: map[string]string{
+				utils.WalArchivingViaPlugin: "enabled",
+			},
+		},
+	}
+
+	It("uses a no-op archive_command", func() {
+		config, _, err := createPostgresqlConfiguration(&cluster, false)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(config).To(ContainSubstring("archive_mode = 'on'"))
+		Expect(config).To(ContainSubstring("archive_command = '/bin/true'"))
+		Expect(config).ToNot(ContainSubstring("wal-archive"))
+	})
+
+	It("uses the instance manager archive_command without the annotation", func() {
+		config, _, err := createPostgresqlConfiguration(&apiv1.Cluster{}, false)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(config).To(ContainSubstring("archive_command = '/controller/manager wal-archive"))
+	})
+})
@@ -24,13 +24,17 @@ import (
 )
 
 // buildPrimaryConnInfo builds the connection string to connect to primaryHostname
-func buildPrimaryConnInfo(primaryHostname, applicationName string) string {
+// on the passed port.
+// The connection uses the dedicated streaming replication user, created with the
+// REPLICATION attribute by the instance manager, which authenticates with its
+// TLS client certificate rather than with a password
+func buildPrimaryConnInfo(primaryHostname string, port int, applicationName string) string {
 	// We should have been using configfile.CreateConnectionString
 	// but doing that we would cause an unnecessary restart of
 	// existing PostgreSQL 12 clusters.
 	primaryConnInfo := fmt.Sprintf("host=%v ", primaryHostname) +
 		fmt.Sprintf("user=%v ", apiv1.StreamingReplicationUser) +
-		fmt.Sprintf("port=%v ", GetServerPort()) +
+		fmt.Sprintf("port=%v ", port) +
 		fmt.Sprintf("sslkey=%v ", postgres.StreamingReplicaKeyLocation) +
 		fmt.Sprintf("sslcert=%v ", postgres.StreamingReplicaCertificateLocation) +
 		fmt.Sprintf("sslrootcert=%v ", postgres.ServerCACertificateLocation) +
@@ -0,0 +1,66 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cloudnative-pg/machinery/pkg/log"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+
+	"github.com/cloudnative-pg/cloudnative-pg/pkg/resources"
+)
+
+// retryUntilHostResolved is the retry configuration used to wait for
+// the DNS record of a host to be propagated, up to about half a minute
+var retryUntilHostResolved = wait.Backoff{
+	Duration: time.Second,
+	Factor:   2,
+	Steps:    6,
+	Cap:      10 * time.Second,
+}
+
+// hostResolver resolves a host name into its addresses, like net.Resolver
+type hostResolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// waitForHostResolution waits for the passed host name to be resolved,
+// retrying with the passed backoff to accommodate the DNS propagation
+func waitForHostResolution(ctx context.Context, resolver hostResolver, host string, backoff wait.Backoff) error {
+	contextLogger := log.FromContext(ctx)
+
+	err := retry.OnError(backoff, resources.RetryAlways, func() error {
+		addresses, err := resolver.LookupHost(ctx, host)
+		if err == nil && len(addresses) == 0 {
+			err = errors.New("no addresses found")
+		}
+		if err != nil {
+			contextLogger.Info("Waiting for the host name to be resolved", "host", host, "err", err)
+		}
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("cannot resolve the host name %q: %w", host, err)
+	}
+
+	return nil
+}
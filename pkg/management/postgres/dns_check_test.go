@@ -0,0 +1,71 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// stubResolver resolves the host names after a number of failures
+type stubResolver struct {
+	failures  int
+	addresses []string
+	lookups   int
+}
+
+func (resolver *stubResolver) LookupHost(_ context.Context, _ string) ([]string, error) {
+	resolver.lookups++
+	if resolver.lookups <= resolver.failures {
+		return nil, errors.New("no such host")
+	}
+	return resolver.addresses, nil
+}
+
+var _ = Describe("host name resolution", func() {
+	backoff := wait.Backoff{Duration: time.Millisecond, Steps: 3}
+
+	It("succeeds when the host name is resolved", func(ctx SpecContext) {
+		resolver := &stubResolver{addresses: []string{"10.0.0.1"}}
+		Expect(waitForHostResolution(ctx, resolver, "cluster-example-1", backoff)).To(Succeed())
+		Expect(resolver.lookups).To(Equal(1))
+	})
+
+	It("waits for the DNS propagation", func(ctx SpecContext) {
+		resolver := &stubResolver{failures: 2, addresses: []string{"10.0.0.1"}}
+		Expect(waitForHostResolution(ctx, resolver, "cluster-example-1", backoff)).To(Succeed())
+		Expect(resolver.lookups).To(Equal(3))
+	})
+
+	It("fails when the host name is never resolved", func(ctx SpecContext) {
+		resolver := &stubResolver{failures: 10, addresses: []string{"10.0.0.1"}}
+		err := waitForHostResolution(ctx, resolver, "cluster-example-1", backoff)
+		Expect(err).To(MatchError(`cannot resolve the host name "cluster-example-1": no such host`))
+		Expect(resolver.lookups).To(Equal(3))
+	})
+
+	It("fails when no addresses are found", func(ctx SpecContext) {
+		err := waitForHostResolution(ctx, &stubResolver{}, "cluster-example-1", backoff)
+		Expect(err).To(MatchError(ContainSubstring("no addresses found")))
+	})
+})
@@ -0,0 +1,84 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/cloudnative-pg/machinery/pkg/fileutils"
+
+	"github.com/cloudnative-pg/cloudnative-pg/pkg/configfile"
+	"github.com/cloudnative-pg/cloudnative-pg/pkg/management/postgres/constants"
+)
+
+// EffectiveConfig returns the postgresql.conf that the bootstrap process
+// would produce, without writing it. The content of the postgresql.conf file
+// in the data directory, if any, is followed by the parameters rendered from
+// this InitInfo and by the include directives of the configuration files
+// managed by the operator. This is meant for logging and auditing
+func (info InitInfo) EffectiveConfig(ctx context.Context) (string, error) {
+	initdbMajorVersion, err := getBinaryMajorVersion(ctx, constants.InitdbName)
+	if err != nil {
+		return "", err
+	}
+
+	return info.effectiveConfig(ctx, initdbMajorVersion)
+}
+
+// effectiveConfig is the implementation of EffectiveConfig for the passed
+// initdb major version
+func (info InitInfo) effectiveConfig(ctx context.Context, initdbMajorVersion int) (string, error) {
+	lines, err := fileutils.ReadFileLines(path.Join(info.PgData, "postgresql.conf"))
+	if err != nil {
+		return "", err
+	}
+
+	parameters, err := info.bootstrapConfiguration(ctx)
+	if err != nil {
+		return "", fmt.Errorf("while generating the bootstrap configuration: %w", err)
+	}
+
+	if err := info.addVersionedParameters(parameters, initdbMajorVersion); err != nil {
+		return "", err
+	}
+
+	// Whether they are passed to initdb or appended later, the
+	// InitDBSettings end up in postgresql.conf
+	for name, value := range info.InitDBSettings {
+		if _, found := parameters[name]; !found {
+			parameters[name] = value
+		}
+	}
+
+	lines, err = configfile.UpdateConfigurationContents(lines, parameters)
+	if err != nil {
+		return "", err
+	}
+
+	lines, _ = configfile.EnsureIncludesInConfigurationContents(lines,
+		constants.PostgresqlCustomConfigurationFile,
+		constants.PostgresqlOverrideConfigurationFile,
+	)
+
+	if len(lines) == 0 {
+		return "", nil
+	}
+	return strings.Join(lines, "\n") + "\n", nil
+}
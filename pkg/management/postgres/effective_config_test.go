@@ -0,0 +1,124 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/cloudnative-pg/machinery/pkg/fileutils"
+	"github.com/cloudnative-pg/machinery/pkg/postgres/version"
+	"k8s.io/utils/ptr"
+
+	"github.com/cloudnative-pg/cloudnative-pg/pkg/postgres"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("effective bootstrap configuration", func() {
+	var pgData, confFile string
+
+	BeforeEach(func() {
+		pgData = GinkgoT().TempDir()
+		confFile = filepath.Join(pgData, "postgresql.conf")
+	})
+
+	It("renders the configuration when postgresql.conf doesn't exist", func(ctx SpecContext) {
+		config, err := InitInfo{PgData: pgData}.effectiveConfig(ctx, 13)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(config).To(Equal(
+			"\n# load CloudNativePG custom.conf configuration\ninclude 'custom.conf'\n" +
+				"\n# load CloudNativePG override.conf configuration\ninclude 'override.conf'\n"))
+	})
+
+	It("renders the custom parameters in order", func(ctx SpecContext) {
+		_, err := fileutils.WriteStringToFile(confFile,
+			"max_connections = 100\n#archive_mode = off\nshared_preload_libraries = 'pg_stat_statements'\n")
+		Expect(err).ToNot(HaveOccurred())
+
+		info := InitInfo{
+			PgData:         pgData,
+			InitDBSettings: map[string]string{"work_mem": "8MB"},
+		}
+		config, err := info.effectiveConfig(ctx, 16)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(strings.Split(strings.TrimSuffix(config, "\n"), "\n")).To(Equal([]string{
+			"max_connections = 100",
+			"#archive_mode = off",
+			"shared_preload_libraries = 'pg_stat_statements'",
+			"password_encryption = 'scram-sha-256'",
+			"work_mem = '8MB'",
+			"",
+			"# load CloudNativePG custom.conf configuration",
+			"include 'custom.conf'",
+			"",
+			"# load CloudNativePG override.conf configuration",
+			"include 'override.conf'",
+		}))
+	})
+
+	It("doesn't render the parameters managed by the operator", func(ctx SpecContext) {
+		_, err := fileutils.WriteStringToFile(confFile, "max_connections = 100\n")
+		Expect(err).ToNot(HaveOccurred())
+
+		info := InitInfo{
+			PgData:            pgData,
+			SynchronousCommit: "local",
+			Fsync:             ptr.To(true),
+			LogLinePrefix:     "%m [%p] ",
+		}
+		config, err := info.effectiveConfig(ctx, 16)
+		Expect(err).ToNot(HaveOccurred())
+
+		managedParameters := postgres.CreatePostgresqlConfiguration(postgres.ConfigurationInfo{
+			Settings:           postgres.CnpgConfigurationSettings,
+			Version:            version.New(16, 0),
+			IncludingMandatory: true,
+		}).GetConfigurationParameters()
+		for _, line := range strings.Split(config, "\n") {
+			name, _, found := strings.Cut(line, " = ")
+			if found {
+				Expect(managedParameters).ToNot(HaveKey(name))
+			}
+		}
+		Expect(config).To(ContainSubstring("synchronous_commit = 'local'\n"))
+	})
+
+	It("fails when a parameter is managed by the operator", func(ctx SpecContext) {
+		info := InitInfo{PgData: pgData, InitDBSettings: map[string]string{"full_page_writes": "off"}}
+		_, err := info.effectiveConfig(ctx, 16)
+		Expect(err).To(MatchError(ContainSubstring("full_page_writes")))
+	})
+
+	It("doesn't write postgresql.conf", func(ctx SpecContext) {
+		_, err := fileutils.WriteStringToFile(confFile, "max_connections = 100\n")
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = InitInfo{PgData: pgData, MaxConnections: 200}.effectiveConfig(ctx, 16)
+		Expect(err).ToNot(HaveOccurred())
+
+		content, err := fileutils.ReadFile(confFile)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(content)).To(Equal("max_connections = 100\n"))
+	})
+
+	It("fails when the bootstrap configuration is not valid", func(ctx SpecContext) {
+		_, err := InitInfo{PgData: pgData, MaxConnections: -1}.effectiveConfig(ctx, 16)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -24,13 +24,13 @@ import (
 	"database/sql"
 	"fmt"
 	"os"
-	"os/exec"
 	"path"
 	"path/filepath"
+	"regexp"
 	"sort"
+	"strings"
 	"time"
 
-	"github.com/cloudnative-pg/machinery/pkg/execlog"
 	"github.com/cloudnative-pg/machinery/pkg/fileutils"
 	"github.com/cloudnative-pg/machinery/pkg/fileutils/compatibility"
 	"github.com/cloudnative-pg/machinery/pkg/log"
@@ -44,6 +44,7 @@ import (
 	"github.com/cloudnative-pg/cloudnative-pg/pkg/management/postgres/constants"
 	"github.com/cloudnative-pg/cloudnative-pg/pkg/management/postgres/logicalimport"
 	"github.com/cloudnative-pg/cloudnative-pg/pkg/management/postgres/pool"
+	postgresutils "github.com/cloudnative-pg/cloudnative-pg/pkg/management/postgres/utils"
 	"github.com/cloudnative-pg/cloudnative-pg/pkg/system"
 )
 
@@ -54,6 +55,40 @@ const (
 	CheckEmptyWalArchiveFile = ".check-empty-wal-archive"
 )
 
+const (
+	// DefaultBootstrapConnectTimeout is the default time to wait while
+	// connecting to the transient instance used during the bootstrap
+	DefaultBootstrapConnectTimeout = 5 * time.Second
+
+	// DefaultBootstrapStatementTimeout is the default maximum duration
+	// of the statements executed by the bootstrap process, except the
+	// ones expected to run for a long time
+	DefaultBootstrapStatementTimeout = time.Hour
+
+	// DefaultInitDBGracePeriod is the default time initdb is given to
+	// clean up after itself when the bootstrap is canceled
+	DefaultInitDBGracePeriod = 30 * time.Second
+
+	// DefaultBootstrapApplicationName is the default application_name of
+	// the connections to the transient instance used during the bootstrap
+	DefaultBootstrapApplicationName = "cnpg-bootstrap"
+)
+
+// applicationNameRegex matches the application names which can be
+// used in a connection string without being quoted, within the
+// NAMEDATALEN limit of PostgreSQL
+var applicationNameRegex = regexp.MustCompile(`^[A-Za-z0-9._-]{1,63}$`)
+
+// bootstrapConnectionLimits are the limits of the connection pool used
+// to run the bootstrap SQL statements, which are executed sequentially.
+// No idle connection is kept, as CREATE DATABASE fails while any other
+// session is connected to template1
+var bootstrapConnectionLimits = pool.ConnectionLimits{
+	MaxOpenConns:    2,
+	MaxIdleConns:    0,
+	ConnMaxLifetime: 5 * time.Minute,
+}
+
 // InitInfo contains all the info needed to bootstrap a new PostgreSQL instance
 type InitInfo struct {
 	// The data directory where to generate the new cluster
@@ -62,15 +97,114 @@ type InitInfo struct {
 	// the data directory where to store the WAL
 	PgWal string
 
+	// SocketDirectory is the directory where the transient instance used
+	// during the bootstrap creates its Unix socket. When empty, the default
+	// one is used
+	SocketDirectory string
+
+	// HealthCheckAddress is the address of the HTTP health endpoint exposed
+	// while the transient instance used during the bootstrap is active.
+	// When empty, no endpoint is exposed
+	HealthCheckAddress string
+
+	// SSLCertFile, SSLKeyFile and SSLCAFile are the server certificate,
+	// its private key and the CA certificate used to enable SSL in the
+	// transient instance used during the bootstrap. The certificate must
+	// be valid for localhost, as the connections use sslmode=verify-full
+	SSLCertFile string
+	SSLKeyFile  string
+	SSLCAFile   string
+
+	// ConnectTimeout is the time to wait while connecting to the transient
+	// instance used during the bootstrap, zero meaning no limit
+	ConnectTimeout time.Duration
+
+	// StatementTimeout is the maximum duration of the statements executed
+	// on the transient instance used during the bootstrap, zero meaning no
+	// limit. The logical dumps and imports, the custom SQL and the
+	// refresh of the planner statistics are not subject to it
+	StatementTimeout time.Duration
+
+	// ApplicationName is the application_name of the connections to the
+	// transient instance used during the bootstrap, making them
+	// recognizable in pg_stat_activity and in the logs. When empty,
+	// DefaultBootstrapApplicationName is used
+	ApplicationName string
+
+	// InitDBGracePeriod is the time initdb is given to remove the data
+	// directory it was creating when the bootstrap is canceled, before
+	// being killed. When zero, DefaultInitDBGracePeriod is used
+	InitDBGracePeriod time.Duration
+
+	// SkipApplicationSetup disables the creation of the application
+	// database and user and the application SQL, as needed by the
+	// instances used only as replication or restore targets. The other
+	// post-init steps still run. When set, the application fields are
+	// ignored
+	SkipApplicationSetup bool
+
 	// The name of the database to be generated for the applications
 	ApplicationDatabase string
 
+	// InitOnly stops the bootstrap once the data directory has been
+	// created and the configuration files have been written, without
+	// starting PostgreSQL. The application and replica configuration is
+	// left to a later step
+	InitOnly bool
+
+	// VerifyArchiving forces a WAL switch once the new instance has been
+	// configured, failing the bootstrap unless the WAL file is archived.
+	// This detects a misconfigured archive_command or object store early
+	VerifyArchiving bool
+
+	// RecreateApplicationDatabase drops the application database when it
+	// already exists, terminating its connections, and creates it again.
+	// Every object in the existing database is lost
+	RecreateApplicationDatabase bool
+
 	// The name of the role to be generated for the applications
 	ApplicationUser string
 
+	// AdditionalDatabases are the databases, owned by the application
+	// user, created beside the application database
+	AdditionalDatabases []string
+
+	// DatabaseCreationConcurrency is the maximum number of additional
+	// databases created at the same time. When zero,
+	// DefaultDatabaseCreationConcurrency is used
+	DatabaseCreationConcurrency int
+
+	// The file containing the password of the application user.
+	// When empty, the password of the application user is not managed
+	ApplicationPasswordFile string
+
+	// GenerateApplicationPassword generates a random password for the
+	// application user, when no password file has been set and the
+	// PasswordProvider doesn't provide it. The password is written in
+	// GeneratedApplicationPasswordFile, which must not exist
+	GenerateApplicationPassword      bool
+	GeneratedApplicationPasswordFile string
+
+	// The provider of the passwords of the superuser and of the
+	// application user, used when no password file has been set
+	PasswordProvider PasswordProvider
+
+	// The value of password_encryption used while setting
+	// passwords. When empty, the server default is used
+	PasswordEncryption string
+
 	// The parent node, used to fill primary_conninfo
 	ParentNode string
 
+	// ParentPort is the port the parent node is listening on. When
+	// zero, the port of the local server is used
+	ParentPort int
+
+	// VerifyReplicaConnectivity checks, once a replica has been joined,
+	// that it can authenticate to the primary with the primary_conninfo
+	// written in its configuration
+	VerifyReplicaConnectivity bool
+
 	// The current node, used to fill application_name
 	PodName string
 
@@ -84,6 +218,22 @@ type InitInfo struct {
 	// create the cluster
 	InitDBOptions []string
 
+	// TextSearchConfig is the default text search configuration of the
+	// new instance, like `pg_catalog.english`. When empty, initdb chooses
+	// it from the locale
+	TextSearchConfig string
+
+	// AllowGroupAccess creates the data directory readable by the group
+	// of the owner, as needed by the backup tools running as another user
+	AllowGroupAccess bool
+
+	// AuthLocal and AuthHost are the authentication methods written by
+	// initdb in pg_hba.conf for the local and host connections. When empty,
+	// the default of initdb is used. The new instance is configured through
+	// the local socket as the postgres user, which AuthLocal must allow
+	AuthLocal string
+	AuthHost  string
+
 	// The list of queries to be executed just after having
 	// configured a new instance
 	PostInitSQL []string
@@ -99,6 +249,142 @@ type InitInfo struct {
 	// Whether it is a temporary instance that will never contain real data.
 	Temporary bool
 
+	// InitDBSettings are the PostgreSQL parameters written in postgresql.conf
+	// by initdb through the `--set` option. When initdb doesn't support it,
+	// the parameters are appended to postgresql.conf after initdb completes
+	InitDBSettings map[string]string
+
+	// MaxConnections is the max_connections parameter of the new
+	// instance. When zero, the default of initdb is used
+	MaxConnections int
+
+	// SuperuserReservedConnections is the superuser_reserved_connections
+	// parameter of the new instance, which must be lower than
+	// MaxConnections. When zero, the default of PostgreSQL is used
+	SuperuserReservedConnections int
+
+	// IdleSessionTimeout is the idle_session_timeout of the new instance,
+	// like `10min`, supported since PostgreSQL 14. A value without unit is
+	// expressed in milliseconds. When empty, idle sessions are never closed
+	IdleSessionTimeout string
+
+	// Fsync is the fsync parameter of the new instance. When nil, the safe
+	// default of PostgreSQL is used. Disabling it risks data corruption and
+	// is meant only for benchmarks
+	Fsync *bool
+
+	// TrackCommitTimestamp enables track_commit_timestamp in the new
+	// instance, as required for the commit timestamps of every transaction
+	TrackCommitTimestamp bool
+
+	// RowSecurity explicitly sets row_security in the new instance, so that
+	// the row security policies are always applied, instead of making the
+	// queries they affect fail. EnforceRowSecurity also sets it on the
+	// application database, taking precedence over the configuration files
+	RowSecurity        bool
+	EnforceRowSecurity bool
+
+	// LogLinePrefix is the log_line_prefix of the new instance. When
+	// empty, the default of PostgreSQL is used
+	LogLinePrefix string
+
+	// Timezone and DateStyle are the timezone and datestyle parameters of
+	// the new instance, like `Europe/Rome` and `ISO, DMY`. The timezone
+	// must be a name of the tz database. When empty, the defaults of
+	// initdb are used
+	Timezone  string
+	DateStyle string
+
+	// SharedBuffers, EffectiveCacheSize and WorkMem are the memory
+	// parameters of the new instance, expressed as PostgreSQL sizes
+	// like `128MB`. When empty, the default of initdb is used
+	SharedBuffers      string
+	EffectiveCacheSize string
+	WorkMem            string
+
+	// CheckpointCompletionTarget is the checkpoint_completion_target of
+	// the new instance, between 0 and 1. When nil, the default is used
+	CheckpointCompletionTarget *float64
+
+	// CheckpointTimeout is the checkpoint_timeout of the new instance,
+	// like `15min`. A value without unit is expressed in seconds
+	CheckpointTimeout string
+
+	// PreallocateWAL creates zeroed WAL segments, up to min_wal_size, once
+	// the data directory is created, reducing the latency of the first
+	// checkpoints on slow storage
+	PreallocateWAL bool
+
+	// MaxWalSize and MinWalSize are the max_wal_size and min_wal_size
+	// parameters of the new instance, driving the checkpoints, expressed
+	// as PostgreSQL sizes like `1GB`. When empty, the default is used
+	MaxWalSize string
+	MinWalSize string
+
+	// AutovacuumMaxWorkers, AutovacuumNaptime and AutovacuumVacuumScaleFactor
+	// are the autovacuum_max_workers, autovacuum_naptime and
+	// autovacuum_vacuum_scale_factor parameters of the new instance. The
+	// naptime is a duration like `30s`. When zero, empty or nil, the
+	// defaults are used
+	AutovacuumMaxWorkers        int
+	AutovacuumNaptime           string
+	AutovacuumVacuumScaleFactor *float64
+
+	// JIT and MaxParallelWorkersPerGather are the jit and
+	// max_parallel_workers_per_gather parameters of the new instance. Zero
+	// workers disable the parallel queries. When nil, the defaults are used
+	JIT                         *bool
+	MaxParallelWorkersPerGather *int
+
+	// EffectiveIOConcurrency is the effective_io_concurrency parameter of
+	// the new instance, where zero disables the prefetching. When nil and
+	// DetectStorage is set, it is chosen after the type of the device
+	// holding the data directory
+	EffectiveIOConcurrency *int
+	DetectStorage          bool
+
+	// SynchronousCommit is the synchronous_commit parameter of the new
+	// instance, one of on, off, local, remote_write and remote_apply.
+	// When empty, the default is used
+	SynchronousCommit string
+
+	// HugePages is the huge_pages parameter of the new instance, one of
+	// off, on and try. When on, the free huge pages of the node are
+	// checked before starting the instance. When empty, try is used
+	HugePages string
+
+	// Tablespaces are the tablespaces created while configuring the new
+	// instance, before the application database
+	Tablespaces []TablespaceSpec
+
+	// DefaultTablespace is the default_tablespace of the new instance,
+	// set with ALTER SYSTEM once the Tablespaces have been created. It
+	// must be one of them, or pg_default
+	DefaultTablespace string
+
+	// TempTablespaces are the temp_tablespaces of the new instance, used
+	// for the temporary objects and the sort files. They must be among the
+	// Tablespaces, or pg_default
+	TempTablespaces []string
+
+	// Publications are the logical replication publications created in
+	// the application database of the new instance. They require
+	// wal_level to be logical
+	Publications []PublicationSpec
+
+	// ReadOnlyRoles are login roles created alongside the application
+	// user, granted read-only access to the public schema of the
+	// application database, including the tables created afterwards
+	ReadOnlyRoles []RoleSpec
+
+	// ApplicationRoleMemberships are the existing roles, i.e. predefined
+	// roles like pg_monitor, the application user is made a member of
+	ApplicationRoleMemberships []string
+
+	// HBARulesFiles are files containing pg_hba.conf rules, appended
+	// in the given order to the pg_hba.conf file created by initdb
+	HBARulesFiles []string
+
 	// PostInitApplicationSQLRefsFolder is the folder which contains a bunch
 	// of SQL files to be executed inside the application database right after
 	// having configured a new instance
@@ -117,6 +403,331 @@ type InitInfo struct {
 
 	// TablespaceMapFile holds the content returned by pg_stop_backup. Needed for a hot backup restore
 	TablespaceMapFile []byte
+
+	// VerifyOnly is true when the restored instance should only be checked
+	// for consistency without ever being promoted
+	VerifyOnly bool
+
+	// WALOnly is true when the data directory has already been restored
+	// out-of-band, i.e. from a volume snapshot, and the restore only needs
+	// to configure the replay of the archived WAL files
+	WALOnly bool
+
+	// AtomicRestore restores the data directory into a sibling staging
+	// directory, which replaces the data directory only once the restore
+	// succeeds, leaving the existing one untouched on failure
+	AtomicRestore bool
+
+	// VerifyWALContinuity enables checking, before configuring the
+	// recovery, that the archive contains every WAL segment from the
+	// beginning of the backup to its end or to the recovery target LSN
+	VerifyWALContinuity bool
+
+	// LocalBackupPath is a directory containing a base backup, in the
+	// `base` directory, and the WAL files, in the `wals` directory, to be
+	// restored instead of a backup in the object storage
+	LocalBackupPath string
+
+	// BackupID is the ID of the backup to be restored. When empty, the
+	// backup matching the recovery target, or the latest one, is used
+	BackupID string
+
+	// ResetSystemIdentifier is true when the restored cluster
+	// should get a new system identifier
+	ResetSystemIdentifier bool
+
+	// KeepDatabases is the list of databases to be kept after a restore.
+	// When not empty, every other non-system database is dropped
+	KeepDatabases []string
+
+	// FreeSpaceMargin is the percentage of the backup size that is required
+	// to be available on disk, on top of the backup size itself, before
+	// starting to download a backup
+	FreeSpaceMargin int
+
+	// SystemIdentifierFile is the file where the PostgreSQL system
+	// identifier is written once the data directory is ready
+	SystemIdentifierFile string
+
+	// InitialTransactionID, when not zero, is the next transaction ID of
+	// the new data directory, set by pg_resetwal after initdb. This is
+	// meant only for testing, i.e. to test the behavior of a cluster that
+	// is close to the transaction ID wraparound
+	InitialTransactionID uint32
+
+	// InitialDumpFile is the path of a logical dump to be restored in the
+	// application database after the bootstrap. Plain SQL scripts and
+	// pg_dump archives are supported, optionally gzip compressed
+	InitialDumpFile string
+
+	// InitialDumpNoOwner skips restoring the original ownership of the
+	// objects of the initial dump, which is an archive. Together with
+	// InitialDumpRole, it remaps the ownership to an existing role, i.e.
+	// the application user
+	InitialDumpNoOwner bool
+
+	// InitialDumpRole is the existing role the objects of the initial
+	// dump, which is an archive, are created with
+	InitialDumpRole string
+
+	// RecoveryEndCommand is the command to be executed by PostgreSQL
+	// at the end of the recovery
+	RecoveryEndCommand string
+
+	// TargetImmediate ends the recovery as soon as a consistent state is
+	// reached, instead of replaying all the archived WAL files. It can't be
+	// combined with the other recovery targets of the cluster
+	TargetImmediate bool
+
+	// RecoveryTimeout is the maximum time the restore waits for the
+	// restored instance to become consistent and, unless the restore is
+	// only verified, to be promoted. Zero means waiting indefinitely
+	RecoveryTimeout time.Duration
+
+	// NoArchiveOnRestore disables archive_mode in the restored instance,
+	// so that the WAL files written while restoring are never archived,
+	// i.e. into the archive of the source cluster. The archiving is enabled
+	// again when the configuration of the instance is generated at startup
+	NoArchiveOnRestore bool
+
+	// PromptPromotion promotes the restored instance as soon as the
+	// recovery target, or the end of the archived WAL files, is reached,
+	// instead of probing the archive for newer timelines first
+	PromptPromotion bool
+
+	// RestoreCommand is the restore_command used to fetch the WAL files
+	// during the recovery, replacing the one invoking the object storage
+	// of the backup. It must contain the %f and %p placeholders
+	RestoreCommand string
+
+	// ResetAutoConf removes, before the restored instance is started, the
+	// settings inherited from the source cluster through postgresql.auto.conf,
+	// which may refer to its environment. When ResetAutoConfSettings is not
+	// empty, only those settings are removed
+	ResetAutoConf         bool
+	ResetAutoConfSettings []string
+
+	// DropStaleSlots drops the replication slots of a restored instance
+	// once PostgreSQL has been promoted, as they belong to the source cluster
+	DropStaleSlots bool
+
+	// FailOnCollationMismatch makes the restore fail when the collation
+	// versions recorded in the restored databases don't match the ones
+	// of the running locale library. Otherwise, a warning is logged
+	FailOnCollationMismatch bool
+
+	// AnalyzeAfterRestore runs ANALYZE in every database of a restored
+	// instance once it is promoted, refreshing the planner statistics.
+	// VacuumAfterRestore runs VACUUM ANALYZE instead
+	AnalyzeAfterRestore bool
+	VacuumAfterRestore  bool
+
+	// PostRestoreSQLFile is a file containing SQL statements executed in
+	// the application database once the restored instance is promoted
+	PostRestoreSQLFile string
+
+	// AllowUnsafeRecoveryEndCommand is true when RecoveryEndCommand
+	// is allowed to contain shell metacharacters
+	AllowUnsafeRecoveryEndCommand bool
+}
+
+// VerifyConfiguration checks the bootstrap options before
+// starting to change the data directory
+func (info InitInfo) VerifyConfiguration() error {
+	if sources := info.bootstrapSources(); len(sources) > 1 {
+		return fmt.Errorf("conflicting bootstrap sources, only one of them can be selected: %s",
+			strings.Join(sources, ", "))
+	}
+
+	if err := info.validateSkipApplicationSetup(); err != nil {
+		return err
+	}
+
+	if err := info.validateInitOnly(); err != nil {
+		return err
+	}
+
+	if !info.SkipApplicationSetup && systemDatabases.Has(info.ApplicationDatabase) {
+		return fmt.Errorf(
+			"the application database can't be named %q, as it is a PostgreSQL system database",
+			info.ApplicationDatabase)
+	}
+
+	if info.ApplicationName != "" && !applicationNameRegex.MatchString(info.ApplicationName) {
+		return fmt.Errorf("invalid application name %q: it must be made of at most 63 "+
+			"letters, digits, dots, dashes and underscores", info.ApplicationName)
+	}
+
+	if err := info.validatePasswordFiles(); err != nil {
+		return err
+	}
+
+	if err := info.validateApplicationPasswordGeneration(); err != nil {
+		return err
+	}
+
+	if info.SocketDirectory != "" {
+		if err := checkDirectoryWritable(info.SocketDirectory); err != nil {
+			return fmt.Errorf("invalid socket directory: %w", err)
+		}
+	}
+
+	if err := info.validateHBARulesFiles(); err != nil {
+		return err
+	}
+
+	if err := info.validateAdditionalDatabases(); err != nil {
+		return err
+	}
+
+	if err := info.validatePublications(); err != nil {
+		return err
+	}
+
+	if err := info.validateReadOnlyRoles(); err != nil {
+		return err
+	}
+
+	if err := info.validateApplicationRoleMemberships(); err != nil {
+		return err
+	}
+
+	if info.EnforceRowSecurity && (info.SkipApplicationSetup || info.ApplicationDatabase == "") {
+		return fmt.Errorf("the row security enforcement requires the application database")
+	}
+
+	if err := info.validateTablespaces(); err != nil {
+		return err
+	}
+
+	if err := info.validateSSLFiles(); err != nil {
+		return fmt.Errorf("invalid SSL configuration: %w", err)
+	}
+
+	if info.WALOnly {
+		if err := checkExistingDataDirectory(info.PgData); err != nil {
+			return fmt.Errorf("WAL-only restore requires an existing data directory: %w", err)
+		}
+	}
+
+	if err := validateInitialTransactionID(info.InitialTransactionID); err != nil {
+		return err
+	}
+
+	if info.PostRestoreSQLFile != "" {
+		if _, err := os.Stat(info.PostRestoreSQLFile); err != nil {
+			return fmt.Errorf("invalid post-restore SQL file: %w", err)
+		}
+	}
+
+	if info.InitialDumpFile != "" {
+		dump, err := logicalimport.DetectDumpFile(info.InitialDumpFile)
+		if err != nil {
+			return fmt.Errorf("invalid initial dump file: %w", err)
+		}
+		if (info.InitialDumpNoOwner || info.InitialDumpRole != "") && dump.Format == logicalimport.DumpFormatPlain {
+			return fmt.Errorf("the ownership of the initial dump can be remapped only for pg_dump archives, " +
+				"not for plain SQL scripts")
+		}
+	} else if info.InitialDumpNoOwner || info.InitialDumpRole != "" {
+		return fmt.Errorf("the ownership remap options require an initial dump file")
+	}
+
+	return nil
+}
+
+// validateSkipApplicationSetup checks that no option requiring the
+// application database is used when the application setup is skipped
+func (info InitInfo) validateSkipApplicationSetup() error {
+	if !info.SkipApplicationSetup {
+		return nil
+	}
+
+	if info.InitialDumpFile != "" {
+		return fmt.Errorf("the initial dump file can't be restored when the application setup is skipped")
+	}
+
+	if len(info.Publications) > 0 {
+		return fmt.Errorf("publications can't be created when the application setup is skipped")
+	}
+
+	if len(info.AdditionalDatabases) > 0 {
+		return fmt.Errorf("additional databases can't be created when the application setup is skipped")
+	}
+
+	if info.RecreateApplicationDatabase {
+		return fmt.Errorf("the application database can't be recreated when the application setup is skipped")
+	}
+
+	return nil
+}
+
+// validateInitOnly checks that no option requiring a running
+// instance is used in init-only mode
+func (info InitInfo) validateInitOnly() error {
+	if !info.InitOnly {
+		return nil
+	}
+
+	if info.InitialDumpFile != "" {
+		return fmt.Errorf("the initial dump file can't be restored in init-only mode")
+	}
+
+	if info.RecreateApplicationDatabase {
+		return fmt.Errorf("the application database can't be recreated in init-only mode")
+	}
+
+	if info.VerifyArchiving {
+		return fmt.Errorf("the WAL archiving can't be verified in init-only mode")
+	}
+
+	return nil
+}
+
+// bootstrapSources returns the names of the fields selecting the source
+// the data directory is bootstrapped from. When none of them is set, a new
+// data directory is created with initdb
+func (info InitInfo) bootstrapSources() []string {
+	var sources []string
+	if info.InitialDumpFile != "" {
+		sources = append(sources, "InitialDumpFile")
+	}
+	if len(info.BackupLabelFile) > 0 {
+		sources = append(sources, "BackupLabelFile")
+	}
+	if info.WALOnly {
+		sources = append(sources, "WALOnly")
+	}
+	return sources
+}
+
+// checkExistingDataDirectory ensures the passed directory
+// contains a PostgreSQL data directory
+func checkExistingDataDirectory(pgData string) error {
+	exists, err := fileutils.FileExists(filepath.Join(pgData, "PG_VERSION"))
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("%s is not a PostgreSQL data directory", pgData)
+	}
+
+	return nil
+}
+
+// checkDirectoryWritable ensures the passed directory exists
+// and we can create files inside it
+func checkDirectoryWritable(directory string) error {
+	probe, err := os.CreateTemp(directory, ".cnpg-write-probe-")
+	if err != nil {
+		return err
+	}
+
+	if err := probe.Close(); err != nil {
+		return err
+	}
+
+	return os.Remove(probe.Name())
 }
 
 // CheckTargetDataDirectory ensures that the target data directory does not exist.
@@ -153,6 +764,16 @@ func (info InitInfo) CheckTargetDataDirectory(ctx context.Context) error {
 		return nil
 	}
 
+	leftoverFiles, err := findLeftoverSignalFiles(info.PgData)
+	if err != nil {
+		return fmt.Errorf("while looking for leftover signal files: %w", err)
+	}
+	if len(leftoverFiles) > 0 {
+		contextLogger.Warning("Found leftover recovery files in the existing PGDATA, "+
+			"it will not be used for the bootstrap",
+			"files", leftoverFiles)
+	}
+
 	// We've an existing directory. Let's check if this is a real
 	// PGDATA directory or not.
 	out, err := info.GetInstance().GetPgControldata()
@@ -183,42 +804,105 @@ func (info InitInfo) CheckTargetDataDirectory(ctx context.Context) error {
 	return nil
 }
 
+// leftoverSignalFiles are the files that make PostgreSQL start in recovery
+// mode, and that are not expected in a data directory being bootstrapped
+var leftoverSignalFiles = []string{
+	"recovery.signal",
+	"standby.signal",
+	constants.BackupLabelFile,
+}
+
+// findLeftoverSignalFiles returns the recovery signal files found
+// inside the passed data directory
+func findLeftoverSignalFiles(pgData string) ([]string, error) {
+	var result []string
+	for _, fileName := range leftoverSignalFiles {
+		exists, err := fileutils.FileExists(filepath.Join(pgData, fileName))
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			result = append(result, fileName)
+		}
+	}
+
+	return result, nil
+}
+
 // CreateDataDirectory creates a new data directory given the configuration
-func (info InitInfo) CreateDataDirectory() error {
-	// Invoke initdb to generate a data directory
-	options := []string{
-		"--username",
-		"postgres",
-		"-D",
-		info.PgData,
+func (info InitInfo) CreateDataDirectory(ctx context.Context) (err error) {
+	ctx, span := info.startSpan(ctx, "CreateDataDirectory")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	bootstrapConfiguration, err := info.bootstrapConfiguration(ctx)
+	if err != nil {
+		return fmt.Errorf("while generating the bootstrap configuration: %w", err)
 	}
 
-	// If temporary instance disable fsync on creation
-	if info.Temporary {
-		options = append(options, "--no-sync")
+	initdbMajorVersion, err := getBinaryMajorVersion(ctx, constants.InitdbName)
+	if err != nil {
+		return err
 	}
+	span.SetAttributes(majorVersionAttribute.Int(initdbMajorVersion))
 
-	if info.PgWal != "" {
-		options = append(options, "--waldir", info.PgWal)
+	if err := info.addVersionedParameters(bootstrapConfiguration, initdbMajorVersion); err != nil {
+		return err
 	}
-	// Add custom initdb options from the user
-	options = append(options, info.InitDBOptions...)
+
+	// Invoke initdb to generate a data directory
+	options, err := buildInitdbOptions(info, initdbMajorVersion)
+	if err != nil {
+		return err
+	}
+	_, appendedSettings := info.initdbSettingsOptions(initdbMajorVersion)
 
 	log.Info("Creating new data directory",
-		"pgdata", info.PgData,
-		"initDbOptions", options)
+		"pgdata", info.PgData)
 
 	// Certain CSI drivers may add setgid permissions on newly created folders.
 	// A default umask is set to attempt to avoid this, by revoking group/other
 	// permission bits on the PGDATA
 	_ = compatibility.Umask(0o077)
 
-	initdbCmd := exec.Command(constants.InitdbName, options...) // #nosec
-	err := execlog.RunBuffering(initdbCmd, constants.InitdbName)
+	gracePeriod := info.InitDBGracePeriod
+	if gracePeriod == 0 {
+		gracePeriod = DefaultInitDBGracePeriod
+	}
+	err = runInitdb(ctx, gracePeriod, options)
 	if err != nil {
 		return fmt.Errorf("error while creating the PostgreSQL instance: %w", err)
 	}
 
+	if info.InitialTransactionID != 0 {
+		if err = info.resetTransactionID(ctx); err != nil {
+			return err
+		}
+	}
+
+	// pg_resetwal replaces the WAL segments, hence the
+	// preallocation happens after resetting the transaction ID
+	if info.PreallocateWAL {
+		if err = info.preallocateWAL(); err != nil {
+			return err
+		}
+	}
+
+	for name, value := range appendedSettings {
+		if _, found := bootstrapConfiguration[name]; !found {
+			bootstrapConfiguration[name] = value
+		}
+	}
+
+	if err = writeBootstrapConfiguration(info.PgData, bootstrapConfiguration); err != nil {
+		return fmt.Errorf("while writing the bootstrap configuration: %w", err)
+	}
+
+	if err = info.appendHBARulesFiles(); err != nil {
+		return err
+	}
+
 	// Always read the custom and override configuration files created by the operator
 	_, err = configfile.EnsureIncludes(path.Join(info.PgData, "postgresql.conf"),
 		constants.PostgresqlCustomConfigurationFile,
@@ -253,47 +937,89 @@ func (info InitInfo) CreateDataDirectory() error {
 func (info InitInfo) GetInstance() *Instance {
 	postgresInstance := NewInstance()
 	postgresInstance.PgData = info.PgData
+	if info.SocketDirectory != "" {
+		postgresInstance.WithSocketDirectory(info.SocketDirectory)
+	}
 	postgresInstance.StartupOptions = []string{"listen_addresses='127.0.0.1'"}
+	connectionLimits := bootstrapConnectionLimits
+	postgresInstance.ConnectionLimits = &connectionLimits
+	postgresInstance.ConnectTimeout = info.ConnectTimeout
+	postgresInstance.StatementTimeout = info.StatementTimeout
+	postgresInstance.ApplicationName = info.ApplicationName
+	if postgresInstance.ApplicationName == "" {
+		postgresInstance.ApplicationName = DefaultBootstrapApplicationName
+	}
+	postgresInstance.HealthCheckAddress = info.HealthCheckAddress
+	if info.SSLCertFile != "" {
+		postgresInstance.StartupOptions = append(postgresInstance.StartupOptions, info.sslStartupOptions()...)
+		postgresInstance.SSLRootCertFile = info.SSLCAFile
+	}
 	return postgresInstance
 }
 
 // ConfigureNewInstance creates the expected users and databases in a new
 // PostgreSQL instance. If any error occurs, we return it
-func (info InitInfo) ConfigureNewInstance(instance *Instance) error {
+func (info InitInfo) ConfigureNewInstance(ctx context.Context, instance *Instance) (err error) {
+	ctx, span := info.startSpan(ctx, "ConfigureNewInstance")
+	defer func() {
+		endSpan(span, err)
+	}()
+
 	log.Info("Configuring new PostgreSQL instance")
 
-	dbSuperUser, err := instance.GetSuperUserDB()
+	// The custom init queries can run for a long time, and
+	// are not subject to the statement timeout of the bootstrap
+	return info.configureNewInstance(ctx, instance.ConnectionPool().Connection,
+		instance.LongRunningConnectionPool().Connection)
+}
+
+// configureNewInstance runs the post-init steps of a new instance. When the
+// application setup is skipped, the steps creating the application user and
+// database and running the application SQL are left out. The connections
+// used by the custom init queries are obtained from longRunningConnect
+func (info InitInfo) configureNewInstance(
+	ctx context.Context,
+	connect func(dbname string) (*sql.DB, error),
+	longRunningConnect func(dbname string) (*sql.DB, error),
+) error {
+	dbSuperUser, err := connect("postgres")
 	if err != nil {
 		return fmt.Errorf("while getting superuser database: %w", err)
 	}
 
-	var existsRole bool
-	userRow := dbSuperUser.QueryRow("SELECT COUNT(*) > 0 FROM pg_catalog.pg_roles WHERE rolname = $1",
-		info.ApplicationUser)
-	err = userRow.Scan(&existsRole)
-	if err != nil {
+	if info.SkipApplicationSetup {
+		log.Info("Skipping the application setup of the new PostgreSQL instance, as requested")
+	} else if err = info.createApplicationUser(ctx, dbSuperUser); err != nil {
 		return err
 	}
 
-	if !existsRole {
-		_, err = dbSuperUser.Exec(fmt.Sprintf(
-			"CREATE ROLE %v LOGIN",
-			pgx.Identifier{info.ApplicationUser}.Sanitize()))
-		if err != nil {
-			return err
-		}
+	if err = info.createTablespaces(ctx, dbSuperUser); err != nil {
+		return err
+	}
+
+	if err = info.setDefaultTablespace(ctx, dbSuperUser); err != nil {
+		return err
+	}
+
+	if err = info.checkTempTablespaces(ctx, dbSuperUser); err != nil {
+		return err
+	}
+
+	dbPostInit, err := longRunningConnect("postgres")
+	if err != nil {
+		return fmt.Errorf("while getting superuser database: %w", err)
 	}
 
 	// Execute the custom set of init queries for the `postgres` database
 	log.Info("Executing post-init SQL instructions")
-	if err = info.executeQueries(dbSuperUser, info.PostInitSQL); err != nil {
+	if err = info.executeQueries(dbPostInit, info.PostInitSQL); err != nil {
 		return err
 	}
-	if err = info.executeSQLRefs(dbSuperUser, info.PostInitSQLRefsFolder); err != nil {
+	if err = info.executeSQLRefs(dbPostInit, info.PostInitSQLRefsFolder); err != nil {
 		return fmt.Errorf("could not execute post init application SQL refs: %w", err)
 	}
 
-	dbTemplate, err := instance.GetTemplateDB()
+	dbTemplate, err := longRunningConnect("template1")
 	if err != nil {
 		return fmt.Errorf("while getting template database: %w", err)
 	}
@@ -305,27 +1031,26 @@ func (info InitInfo) ConfigureNewInstance(instance *Instance) error {
 	if err = info.executeSQLRefs(dbTemplate, info.PostInitTemplateSQLRefsFolder); err != nil {
 		return fmt.Errorf("could not execute post init application SQL refs: %w", err)
 	}
-	if info.ApplicationDatabase == "" {
+
+	if err = info.createAdditionalDatabases(ctx, dbSuperUser); err != nil {
+		return fmt.Errorf("could not create the additional databases: %w", err)
+	}
+
+	if info.SkipApplicationSetup || info.ApplicationDatabase == "" {
 		return nil
 	}
 
-	var existsDB bool
-	dbRow := dbSuperUser.QueryRow("SELECT COUNT(*) > 0 FROM pg_database WHERE datname = $1", info.ApplicationDatabase)
-	err = dbRow.Scan(&existsDB)
+	created, err := info.createApplicationDatabase(ctx, dbSuperUser)
 	if err != nil {
 		return err
 	}
-
-	if existsDB {
-		return nil
+	if err = info.enforceRowSecurity(ctx, dbSuperUser); err != nil {
+		return err
 	}
-	_, err = dbSuperUser.Exec(fmt.Sprintf("CREATE DATABASE %v OWNER %v",
-		pgx.Identifier{info.ApplicationDatabase}.Sanitize(),
-		pgx.Identifier{info.ApplicationUser}.Sanitize()))
-	if err != nil {
-		return fmt.Errorf("could not create ApplicationDatabase: %w", err)
+	if !created {
+		return nil
 	}
-	appDB, err := instance.ConnectionPool().Connection(info.ApplicationDatabase)
+	appDB, err := longRunningConnect(info.ApplicationDatabase)
 	if err != nil {
 		return fmt.Errorf("could not get connection to ApplicationDatabase: %w", err)
 	}
@@ -339,6 +1064,14 @@ func (info InitInfo) ConfigureNewInstance(instance *Instance) error {
 		return fmt.Errorf("could not execute post init application SQL refs: %w", err)
 	}
 
+	if err = info.createPublications(ctx, appDB); err != nil {
+		return err
+	}
+
+	if err = info.createReadOnlyRoles(ctx, appDB); err != nil {
+		return err
+	}
+
 	filePath := filepath.Join(info.PgData, CheckEmptyWalArchiveFile)
 	// We create the check empty wal archive file to tell that we should check if the
 	// destination path it is empty
@@ -349,6 +1082,35 @@ func (info InitInfo) ConfigureNewInstance(instance *Instance) error {
 	return nil
 }
 
+// createApplicationUser creates the application user when missing, and
+// sets its password and role memberships
+func (info InitInfo) createApplicationUser(ctx context.Context, dbSuperUser *sql.DB) error {
+	var existsRole bool
+	userRow := dbSuperUser.QueryRow("SELECT COUNT(*) > 0 FROM pg_catalog.pg_roles WHERE rolname = $1",
+		info.ApplicationUser)
+	err := userRow.Scan(&existsRole)
+	if err != nil {
+		return err
+	}
+
+	if !existsRole {
+		_, err = dbSuperUser.Exec(fmt.Sprintf(
+			"CREATE ROLE %v LOGIN",
+			pgx.Identifier{info.ApplicationUser}.Sanitize()))
+		if err != nil {
+			return err
+		}
+	}
+
+	if info.ApplicationPasswordFile != "" {
+		if err = info.UpdateApplicationPassword(ctx, dbSuperUser); err != nil {
+			return fmt.Errorf("while setting the application user password: %w", err)
+		}
+	}
+
+	return info.grantApplicationRoleMemberships(ctx, dbSuperUser)
+}
+
 func (info InitInfo) executeSQLRefs(sqlUser *sql.DB, directory string) error {
 	if directory == "" {
 		return nil
@@ -390,7 +1152,7 @@ func (info InitInfo) executeQueries(sqlUser *sql.DB, queries []string) error {
 	}
 
 	for _, sqlQuery := range queries {
-		log.Debug("Executing query", "sqlQuery", sqlQuery)
+		log.Debug("Executing query", "sqlQuery", postgresutils.RedactSecrets(sqlQuery))
 		_, err := sqlUser.Exec(sqlQuery)
 		if err != nil {
 			return err
@@ -401,7 +1163,12 @@ func (info InitInfo) executeQueries(sqlUser *sql.DB, queries []string) error {
 }
 
 // Bootstrap creates and configures this new PostgreSQL instance
-func (info InitInfo) Bootstrap(ctx context.Context) error {
+func (info InitInfo) Bootstrap(ctx context.Context) (err error) {
+	ctx, span := info.startSpan(ctx, "Bootstrap")
+	defer func() {
+		endSpan(span, err)
+	}()
+
 	typedClient, err := management.NewControllerRuntimeClient()
 	if err != nil {
 		return err
@@ -417,7 +1184,25 @@ func (info InitInfo) Bootstrap(ctx context.Context) error {
 		return err
 	}
 
-	err = info.CreateDataDirectory()
+	if err := checkBootstrapBinaries(ctx); err != nil {
+		return err
+	}
+
+	if err := info.checkSharedMemory(); err != nil {
+		return err
+	}
+
+	cleanupPasswordFiles, err := info.fetchProvidedPasswords(ctx)
+	if err != nil {
+		return err
+	}
+	defer cleanupPasswordFiles()
+
+	if err := info.generateApplicationPassword(ctx); err != nil {
+		return err
+	}
+
+	err = info.CreateDataDirectory(ctx)
 	if err != nil {
 		return err
 	}
@@ -439,6 +1224,10 @@ func (info InitInfo) Bootstrap(ctx context.Context) error {
 	primaryConnInfo := info.GetPrimaryConnInfo()
 	slotName := cluster.GetSlotNameFromInstanceName(info.PodName)
 
+	if isImportBootstrap && info.InitOnly {
+		return fmt.Errorf("the logical import can't be executed in init-only mode")
+	}
+
 	if isImportBootstrap {
 		// Write a special configuration for the import phase
 		if _, err := configurePostgresForImport(ctx, info.PgData); err != nil {
@@ -452,8 +1241,8 @@ func (info InitInfo) Bootstrap(ctx context.Context) error {
 	}
 
 	// Configure the instance and run the logical import process
-	if err := instance.WithActiveInstance(func() error {
-		err = info.ConfigureNewInstance(instance)
+	if err := info.configureActiveInstance(ctx, instance, func() error {
+		err = info.ConfigureNewInstance(ctx, instance)
 		if err != nil {
 			return fmt.Errorf("while configuring new instance: %w", err)
 		}
@@ -465,7 +1254,13 @@ func (info InitInfo) Bootstrap(ctx context.Context) error {
 			}
 		}
 
-		return nil
+		if info.InitialDumpFile != "" {
+			if err := info.restoreInitialDumpFile(ctx, instance); err != nil {
+				return fmt.Errorf("while restoring the initial dump file: %w", err)
+			}
+		}
+
+		return info.verifyArchiving(newWalArchiveBootstrapperForPrimary())
 	}); err != nil {
 		return err
 	}
@@ -486,13 +1281,77 @@ func (info InitInfo) Bootstrap(ctx context.Context) error {
 	return nil
 }
 
+// configureActiveInstance runs the passed function while the instance
+// is running, unless the bootstrap is in init-only mode, where PostgreSQL
+// is never started
+func (info InitInfo) configureActiveInstance(ctx context.Context, instance *Instance, configure func() error) error {
+	if info.InitOnly {
+		log.FromContext(ctx).Info("Init-only mode, skipping the configuration of the new instance",
+			"pgdata", info.PgData)
+		return nil
+	}
+
+	return instance.WithActiveInstance(ctx, configure)
+}
+
+// verifyArchiving checks, when requested, that the new instance
+// can archive its WAL files
+func (info InitInfo) verifyArchiving(bootstrapper *walArchiveBootstrapper) error {
+	if !info.VerifyArchiving {
+		return nil
+	}
+
+	if err := bootstrapper.verifyArchiving(retryUntilBootstrapWalArchived); err != nil {
+		return fmt.Errorf("while verifying the WAL archiving: %w", err)
+	}
+
+	return nil
+}
+
+// restoreInitialDumpFile restores the initial dump file
+// inside the application database
+func (info InitInfo) restoreInitialDumpFile(ctx context.Context, instance *Instance) error {
+	dump, err := logicalimport.DetectDumpFile(info.InitialDumpFile)
+	if err != nil {
+		return err
+	}
+
+	if info.InitialDumpRole != "" {
+		db, err := instance.GetSuperUserDB()
+		if err != nil {
+			return err
+		}
+		if err := checkRoleExists(ctx, db, info.InitialDumpRole); err != nil {
+			return fmt.Errorf("invalid role for the initial dump restore: %w", err)
+		}
+	}
+	dump.NoOwner = info.InitialDumpNoOwner
+	dump.Role = info.InitialDumpRole
+
+	return dump.Restore(ctx, instance.LongRunningConnectionPool().GetDsn(info.ApplicationDatabase))
+}
+
+// checkRoleExists ensures the passed role exists in the instance
+func checkRoleExists(ctx context.Context, db *sql.DB, role string) error {
+	var exists bool
+	row := db.QueryRowContext(ctx, "SELECT COUNT(*) > 0 FROM pg_catalog.pg_roles WHERE rolname = $1", role)
+	if err := row.Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("role %q doesn't exist", role)
+	}
+
+	return nil
+}
+
 func executeLogicalImport(
 	ctx context.Context,
 	client ctrl.Client,
 	instance *Instance,
 	cluster *apiv1.Cluster,
 ) error {
-	destinationPool := instance.ConnectionPool()
+	destinationPool := instance.LongRunningConnectionPool()
 	defer destinationPool.ShutdownConnections()
 
 	originPool, err := getConnectionPoolerForExternalCluster(ctx, cluster, client, cluster.Namespace)
@@ -550,8 +1409,7 @@ func (info InitInfo) initdbSyncOnly(ctx context.Context) error {
 		"--sync-only",
 	}
 	contextLogger.Info("Running initdb --sync-only", "pgdata", info.PgData)
-	initdbCmd := exec.Command(constants.InitdbName, options...) // #nosec
-	if err := execlog.RunBuffering(initdbCmd, constants.InitdbName); err != nil {
+	if _, err := postgresutils.RunCommand(ctx, constants.InitdbName, options, postgresutils.CommandOptions{}); err != nil {
 		return fmt.Errorf("error while running initdb --sync-only: %w", err)
 	}
 	return nil
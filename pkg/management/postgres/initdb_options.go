@@ -0,0 +1,143 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// textSearchConfigRegex matches the names of the text search
+// configurations, optionally qualified with their schema
+var textSearchConfigRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// initdbOption is an initdb option, with its long and short names
+type initdbOption struct {
+	long  string
+	short string
+}
+
+// The initdb options set from the InitInfo fields, which can't be repeated
+// among the InitDBOptions passed by the user
+var (
+	initdbUsernameOption         = initdbOption{long: "--username", short: "-U"}
+	initdbPgDataOption           = initdbOption{long: "--pgdata", short: "-D"}
+	initdbWalDirOption           = initdbOption{long: "--waldir", short: "-X"}
+	initdbAuthOption             = initdbOption{long: "--auth", short: "-A"}
+	initdbAuthLocalOption        = initdbOption{long: "--auth-local"}
+	initdbAuthHostOption         = initdbOption{long: "--auth-host"}
+	initdbTextSearchConfigOption = initdbOption{long: "--text-search-config", short: "-T"}
+	initdbAllowGroupAccessOption = initdbOption{long: "--allow-group-access", short: "-g"}
+	initdbSyncOnlyOption         = initdbOption{long: "--sync-only", short: "-S"}
+)
+
+// buildInitdbOptions returns the options used to run the initdb with
+// the passed major version, refusing the combinations initdb would reject
+// or silently resolve in an unexpected way
+func buildInitdbOptions(info InitInfo, initdbMajorVersion int) ([]string, error) {
+	if err := info.validateInitdbOptions(); err != nil {
+		return nil, err
+	}
+
+	options := []string{
+		initdbUsernameOption.long,
+		"postgres",
+		initdbPgDataOption.short,
+		info.PgData,
+	}
+
+	// If temporary instance disable fsync on creation
+	if info.Temporary {
+		options = append(options, "--no-sync")
+	}
+
+	if info.PgWal != "" {
+		options = append(options, initdbWalDirOption.long, info.PgWal)
+	}
+
+	authOptions, err := info.authOptions()
+	if err != nil {
+		return nil, err
+	}
+	options = append(options, authOptions...)
+
+	settingsOptions, _ := info.initdbSettingsOptions(initdbMajorVersion)
+	options = append(options, settingsOptions...)
+	options = append(options, initdbOutputOptions(initdbMajorVersion)...)
+
+	if info.TextSearchConfig != "" {
+		options = append(options, initdbTextSearchConfigOption.long, info.TextSearchConfig)
+	}
+
+	if info.AllowGroupAccess {
+		options = append(options, initdbAllowGroupAccessOption.long)
+	}
+
+	// Add custom initdb options from the user
+	options = append(options, info.InitDBOptions...)
+
+	return options, nil
+}
+
+// validateInitdbOptions ensures that the InitDBOptions don't repeat the
+// options set from the other InitInfo fields, and that those are valid
+func (info InitInfo) validateInitdbOptions() error {
+	if info.TextSearchConfig != "" && !textSearchConfigRegex.MatchString(info.TextSearchConfig) {
+		return fmt.Errorf("invalid text search configuration %q: expected a name like pg_catalog.english",
+			info.TextSearchConfig)
+	}
+
+	conflicts := []struct {
+		option initdbOption
+		field  string
+		isSet  bool
+	}{
+		{option: initdbUsernameOption, field: "the superuser name", isSet: true},
+		{option: initdbPgDataOption, field: "the data directory", isSet: true},
+		{option: initdbSyncOnlyOption, field: "the creation of a data directory", isSet: true},
+		{option: initdbWalDirOption, field: "the WAL directory", isSet: info.PgWal != ""},
+		{option: initdbAuthOption, field: "the authentication methods", isSet: info.AuthLocal != "" || info.AuthHost != ""},
+		{option: initdbAuthLocalOption, field: "the local authentication method", isSet: info.AuthLocal != ""},
+		{option: initdbAuthHostOption, field: "the host authentication method", isSet: info.AuthHost != ""},
+		{option: initdbTextSearchConfigOption, field: "the text search configuration", isSet: info.TextSearchConfig != ""},
+		{option: initdbAllowGroupAccessOption, field: "the group access", isSet: info.AllowGroupAccess},
+	}
+	for _, conflict := range conflicts {
+		if conflict.isSet && hasInitdbOption(info.InitDBOptions, conflict.option) {
+			return fmt.Errorf("the initdb option %s conflicts with %s set by the bootstrap",
+				conflict.option.long, conflict.field)
+		}
+	}
+
+	return nil
+}
+
+// hasInitdbOption checks if the passed initdb option is among
+// the passed options, in its long or short form
+func hasInitdbOption(options []string, option initdbOption) bool {
+	for _, value := range options {
+		if value == option.long || strings.HasPrefix(value, option.long+"=") {
+			return true
+		}
+		if option.short != "" && strings.HasPrefix(value, option.short) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,123 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("initdb options", func() {
+	It("builds the minimal set of options", func() {
+		info := InitInfo{PgData: "/pgdata"}
+		Expect(buildInitdbOptions(info, 17)).To(Equal([]string{
+			"--username", "postgres", "-D", "/pgdata", "--no-instructions",
+		}))
+	})
+
+	It("builds the options in a stable order", func() {
+		info := InitInfo{
+			PgData:           "/pgdata",
+			PgWal:            "/pgwal",
+			Temporary:        true,
+			AuthLocal:        "peer",
+			AuthHost:         "scram-sha-256",
+			InitDBSettings:   map[string]string{"wal_segment_size": "64"},
+			TextSearchConfig: "pg_catalog.english",
+			AllowGroupAccess: true,
+			InitDBOptions:    []string{"--encoding=UTF8", "-k"},
+		}
+		Expect(buildInitdbOptions(info, 16)).To(Equal([]string{
+			"--username", "postgres", "-D", "/pgdata",
+			"--no-sync",
+			"--waldir", "/pgwal",
+			"--auth-local", "peer", "--auth-host", "scram-sha-256",
+			"--set", "wal_segment_size=64",
+			"--no-instructions",
+			"--text-search-config", "pg_catalog.english",
+			"--allow-group-access",
+			"--encoding=UTF8", "-k",
+		}))
+	})
+
+	It("doesn't pass the settings to an initdb not supporting them", func() {
+		info := InitInfo{
+			PgData:           "/pgdata",
+			InitDBSettings:   map[string]string{"wal_segment_size": "64"},
+			TextSearchConfig: "english",
+		}
+		Expect(buildInitdbOptions(info, 10)).To(Equal([]string{
+			"--username", "postgres", "-D", "/pgdata",
+			"--text-search-config", "english",
+		}))
+	})
+
+	It("accepts the managed options among the custom ones when they are not set", func() {
+		info := InitInfo{
+			PgData:        "/pgdata",
+			InitDBOptions: []string{"-T", "pg_catalog.simple", "-g", "--waldir=/pgwal"},
+		}
+		Expect(buildInitdbOptions(info, 17)).To(Equal([]string{
+			"--username", "postgres", "-D", "/pgdata", "--no-instructions",
+			"-T", "pg_catalog.simple", "-g", "--waldir=/pgwal",
+		}))
+	})
+
+	It("refuses an invalid text search configuration", func() {
+		info := InitInfo{PgData: "/pgdata", TextSearchConfig: "english; DROP"}
+		_, err := buildInitdbOptions(info, 17)
+		Expect(err).To(MatchError(ContainSubstring("invalid text search configuration")))
+	})
+
+	It("refuses an invalid authentication method", func() {
+		info := InitInfo{PgData: "/pgdata", AuthLocal: "password123"}
+		_, err := buildInitdbOptions(info, 17)
+		Expect(err).To(HaveOccurred())
+	})
+
+	DescribeTable("refuses the custom options conflicting with the managed ones",
+		func(info InitInfo, expectedOption string) {
+			info.PgData = "/pgdata"
+			_, err := buildInitdbOptions(info, 17)
+			Expect(err).To(MatchError(ContainSubstring("the initdb option " + expectedOption + " conflicts")))
+		},
+		Entry("text search configuration",
+			InitInfo{TextSearchConfig: "english", InitDBOptions: []string{"-T", "simple"}},
+			"--text-search-config"),
+		Entry("group access",
+			InitInfo{AllowGroupAccess: true, InitDBOptions: []string{"--allow-group-access"}},
+			"--allow-group-access"),
+		Entry("WAL directory",
+			InitInfo{PgWal: "/pgwal", InitDBOptions: []string{"--waldir=/other"}},
+			"--waldir"),
+		Entry("authentication methods",
+			InitInfo{AuthHost: "md5", InitDBOptions: []string{"-A", "trust"}},
+			"--auth"),
+		Entry("local authentication method",
+			InitInfo{AuthLocal: "peer", InitDBOptions: []string{"--auth-local=trust"}},
+			"--auth-local"),
+		Entry("superuser name",
+			InitInfo{InitDBOptions: []string{"-Uadmin"}},
+			"--username"),
+		Entry("data directory",
+			InitInfo{InitDBOptions: []string{"--pgdata", "/other"}},
+			"--pgdata"),
+		Entry("sync only",
+			InitInfo{InitDBOptions: []string{"--sync-only"}},
+			"--sync-only"),
+	)
+})
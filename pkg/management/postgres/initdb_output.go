@@ -0,0 +1,80 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/cloudnative-pg/machinery/pkg/log"
+
+	"github.com/cloudnative-pg/cloudnative-pg/pkg/management/postgres/constants"
+	postgresutils "github.com/cloudnative-pg/cloudnative-pg/pkg/management/postgres/utils"
+)
+
+// initdbNoInstructionsMinimumMajorVersion is the first initdb major
+// version supporting the `--no-instructions` option
+const initdbNoInstructionsMinimumMajorVersion = 14
+
+// initdbNotableLineRegex matches the lines of the initdb output
+// reporting warnings and errors, together with their hints
+var initdbNotableLineRegex = regexp.MustCompile(`(?i)^(initdb: )?(warning|error|fatal|hint|detail):`)
+
+// initdbOutputOptions returns the options suppressing the instructions
+// to start the server printed by the initdb with the passed major version,
+// which don't apply to an instance managed by the operator
+func initdbOutputOptions(initdbMajorVersion int) []string {
+	if initdbMajorVersion < initdbNoInstructionsMinimumMajorVersion {
+		return nil
+	}
+
+	return []string{"--no-instructions"}
+}
+
+// runInitdb runs initdb with the passed options. Its whole output is
+// logged at debug level, while only the warnings and the errors are logged
+// at info level. When initdb fails, the output is included in the error
+func runInitdb(ctx context.Context, gracePeriod time.Duration, options []string) error {
+	output, err := postgresutils.RunCommand(ctx, constants.InitdbName, options,
+		postgresutils.CommandOptions{GracePeriod: gracePeriod})
+	if err != nil {
+		return err
+	}
+
+	logger := log.WithName(constants.InitdbName)
+	logger.Debug(output)
+	if notableLines := initdbNotableLines(output); len(notableLines) > 0 {
+		logger.Info(strings.Join(notableLines, "\n"))
+	}
+
+	return nil
+}
+
+// initdbNotableLines returns the lines of the passed initdb
+// output reporting warnings and errors
+func initdbNotableLines(output string) []string {
+	var result []string
+	for _, line := range strings.Split(output, "\n") {
+		if initdbNotableLineRegex.MatchString(strings.TrimSpace(line)) {
+			result = append(result, line)
+		}
+	}
+
+	return result
+}
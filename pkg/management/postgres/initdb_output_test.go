@@ -0,0 +1,52 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("initdb output", func() {
+	DescribeTable("suppresses the instructions when supported",
+		func(majorVersion int, expected []string) {
+			Expect(initdbOutputOptions(majorVersion)).To(Equal(expected))
+		},
+		Entry("PostgreSQL 13", 13, nil),
+		Entry("PostgreSQL 14", 14, []string{"--no-instructions"}),
+		Entry("PostgreSQL 17", 17, []string{"--no-instructions"}),
+	)
+
+	It("surfaces only the warnings and the errors", func() {
+		output := "The files belonging to this database system will be owned by user \"postgres\".\n" +
+			"creating subdirectories ... ok\n" +
+			"initdb: warning: enabling \"trust\" authentication for local connections\n" +
+			"initdb: hint: You can change this by editing pg_hba.conf.\n" +
+			"WARNING: enabling \"trust\" authentication for local connections\n" +
+			"syncing data to disk ... ok\n"
+
+		Expect(initdbNotableLines(output)).To(Equal([]string{
+			"initdb: warning: enabling \"trust\" authentication for local connections",
+			"initdb: hint: You can change this by editing pg_hba.conf.",
+			"WARNING: enabling \"trust\" authentication for local connections",
+		}))
+	})
+
+	It("doesn't surface anything from a clean output", func() {
+		Expect(initdbNotableLines("creating subdirectories ... ok\nsyncing data to disk ... ok\n")).To(BeEmpty())
+	})
+})
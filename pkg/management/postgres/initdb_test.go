@@ -0,0 +1,365 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/cloudnative-pg/machinery/pkg/log"
+	"github.com/go-logr/logr/funcr"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("bootstrap configuration verification", func() {
+	DescribeTable("refuses a system database as the application database",
+		func(name string) {
+			info := InitInfo{ApplicationDatabase: name}
+			Expect(info.VerifyConfiguration()).To(MatchError(ContainSubstring("system database")))
+		},
+		Entry("postgres", "postgres"),
+		Entry("template0", "template0"),
+		Entry("template1", "template1"),
+	)
+
+	It("requires an existing data directory in WAL-only mode", func() {
+		pgData := GinkgoT().TempDir()
+		info := InitInfo{PgData: pgData, WALOnly: true}
+		Expect(info.VerifyConfiguration()).ToNot(Succeed())
+
+		Expect(os.WriteFile(filepath.Join(pgData, "PG_VERSION"), []byte("16\n"), 0o600)).To(Succeed())
+		Expect(info.VerifyConfiguration()).To(Succeed())
+	})
+
+	It("accepts a custom application database name", func() {
+		info := InitInfo{ApplicationDatabase: "app"}
+		Expect(info.VerifyConfiguration()).To(Succeed())
+	})
+
+	Context("bootstrap sources", func() {
+		It("creates a new data directory when no source is selected", func() {
+			info := InitInfo{}
+			Expect(info.bootstrapSources()).To(BeEmpty())
+			Expect(info.VerifyConfiguration()).To(Succeed())
+		})
+
+		It("accepts a single source", func() {
+			info := InitInfo{BackupLabelFile: []byte("START WAL LOCATION: 0/2000028\n")}
+			Expect(info.bootstrapSources()).To(Equal([]string{"BackupLabelFile"}))
+			Expect(info.VerifyConfiguration()).To(Succeed())
+		})
+
+		It("lists the conflicting sources", func() {
+			info := InitInfo{
+				InitialDumpFile: "/nonexistent/dump.sql",
+				BackupLabelFile: []byte("START WAL LOCATION: 0/2000028\n"),
+				WALOnly:         true,
+			}
+			Expect(info.VerifyConfiguration()).To(MatchError(
+				ContainSubstring("InitialDumpFile, BackupLabelFile, WALOnly")))
+		})
+	})
+
+	Context("leftover signal files", func() {
+		It("finds nothing in a clean data directory", func() {
+			pgData := GinkgoT().TempDir()
+			Expect(os.WriteFile(filepath.Join(pgData, "PG_VERSION"), []byte("16\n"), 0o600)).To(Succeed())
+
+			Expect(findLeftoverSignalFiles(pgData)).To(BeEmpty())
+		})
+
+		It("detects the leftover recovery files", func() {
+			pgData := GinkgoT().TempDir()
+			for _, name := range []string{"standby.signal", "backup_label"} {
+				Expect(os.WriteFile(filepath.Join(pgData, name), nil, 0o600)).To(Succeed())
+			}
+
+			Expect(findLeftoverSignalFiles(pgData)).To(Equal([]string{"standby.signal", "backup_label"}))
+		})
+	})
+
+	Context("application setup", func() {
+		It("ignores the application fields when skipped", func() {
+			info := InitInfo{ApplicationDatabase: "postgres", SkipApplicationSetup: true}
+			Expect(info.VerifyConfiguration()).To(Succeed())
+		})
+
+		It("refuses the options requiring the application database when skipped", func() {
+			info := InitInfo{
+				SkipApplicationSetup: true,
+				Publications:         []PublicationSpec{{Name: "pub", AllTables: true}},
+			}
+			Expect(info.VerifyConfiguration()).To(MatchError(ContainSubstring("application setup is skipped")))
+		})
+
+		It("doesn't run any statement when skipped", func(ctx SpecContext) {
+			// No PostgreSQL is listening on this socket directory, so any
+			// statement would fail
+			info := InitInfo{
+				SocketDirectory: GinkgoT().TempDir(),
+				ApplicationUser: "app",
+				ConnectTimeout:  time.Second,
+			}
+			Expect(info.ConfigureNewInstance(ctx, info.GetInstance())).ToNot(Succeed())
+
+			info.SkipApplicationSetup = true
+			Expect(info.ConfigureNewInstance(ctx, info.GetInstance())).To(Succeed())
+		})
+
+		It("still runs the post-init SQL when skipped", func(ctx SpecContext) {
+			db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			Expect(err).ToNot(HaveOccurred())
+			template, templateMock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			Expect(err).ToNot(HaveOccurred())
+			connect := func(name string) (*sql.DB, error) {
+				if name == "template1" {
+					return template, nil
+				}
+				Expect(name).To(Equal("postgres"))
+				return db, nil
+			}
+
+			info := InitInfo{
+				SkipApplicationSetup:   true,
+				ApplicationUser:        "app",
+				ApplicationDatabase:    "app",
+				PostInitSQL:            []string{"CREATE EXTENSION pg_stat_statements"},
+				PostInitTemplateSQL:    []string{"CREATE EXTENSION hstore"},
+				PostInitApplicationSQL: []string{"CREATE TABLE orders (id int)"},
+			}
+			mock.ExpectExec("CREATE EXTENSION pg_stat_statements").WillReturnResult(sqlmock.NewResult(0, 0))
+			templateMock.ExpectExec("CREATE EXTENSION hstore").WillReturnResult(sqlmock.NewResult(0, 0))
+
+			Expect(info.configureNewInstance(ctx, connect, connect)).To(Succeed())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+			Expect(templateMock.ExpectationsWereMet()).To(Succeed())
+		})
+	})
+
+	Context("initial dump ownership", func() {
+		writeDumpFile := func(name, content string) string {
+			dumpFile := filepath.Join(GinkgoT().TempDir(), name)
+			Expect(os.WriteFile(dumpFile, []byte(content), 0o600)).To(Succeed())
+			return dumpFile
+		}
+
+		It("remaps the ownership of archives", func() {
+			info := InitInfo{
+				InitialDumpFile:    writeDumpFile("dump.custom", "PGDMP"),
+				InitialDumpNoOwner: true,
+				InitialDumpRole:    "app",
+			}
+			Expect(info.VerifyConfiguration()).To(Succeed())
+		})
+
+		It("refuses to remap the ownership of plain SQL scripts", func() {
+			info := InitInfo{
+				InitialDumpFile:    writeDumpFile("dump.sql", "CREATE TABLE test (id integer);\n"),
+				InitialDumpNoOwner: true,
+			}
+			Expect(info.VerifyConfiguration()).To(MatchError(ContainSubstring("plain SQL scripts")))
+		})
+
+		It("requires an initial dump file", func() {
+			info := InitInfo{InitialDumpRole: "app"}
+			Expect(info.VerifyConfiguration()).To(MatchError(ContainSubstring("require an initial dump file")))
+		})
+
+		It("checks that the target role exists", func(ctx SpecContext) {
+			db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			Expect(err).ToNot(HaveOccurred())
+
+			const query = "SELECT COUNT(*) > 0 FROM pg_catalog.pg_roles WHERE rolname = $1"
+			mock.ExpectQuery(query).WithArgs("app").
+				WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+			mock.ExpectQuery(query).WithArgs("missing").
+				WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+			Expect(checkRoleExists(ctx, db, "app")).To(Succeed())
+			Expect(checkRoleExists(ctx, db, "missing")).To(MatchError(`role "missing" doesn't exist`))
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+	})
+})
+
+var _ = Describe("init-only bootstrap", func() {
+	var instance *Instance
+	var invocationsFile string
+
+	BeforeEach(func() {
+		binDir := GinkgoT().TempDir()
+		invocationsFile = filepath.Join(binDir, "invocations")
+		script := "#!/bin/sh\necho \"$@\" >> " + invocationsFile + "\n"
+		Expect(os.WriteFile(filepath.Join(binDir, pgCtlName), []byte(script), 0o700)).To(Succeed()) // #nosec
+		GinkgoT().Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+		instance = InitInfo{
+			PgData:          GinkgoT().TempDir(),
+			SocketDirectory: GinkgoT().TempDir(),
+		}.GetInstance()
+	})
+
+	It("doesn't start PostgreSQL nor execute any SQL", func(ctx SpecContext) {
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		Expect(err).ToNot(HaveOccurred())
+
+		info := InitInfo{InitOnly: true}
+		Expect(info.configureActiveInstance(ctx, instance, func() error {
+			_, err := db.ExecContext(ctx, "CREATE DATABASE app")
+			return err
+		})).To(Succeed())
+
+		Expect(mock.ExpectationsWereMet()).To(Succeed())
+		Expect(invocationsFile).ToNot(BeAnExistingFile())
+	})
+
+	It("configures the running instance otherwise", func(ctx SpecContext) {
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		Expect(err).ToNot(HaveOccurred())
+		mock.ExpectExec("CREATE DATABASE app").WillReturnResult(sqlmock.NewResult(0, 0))
+
+		Expect(InitInfo{}.configureActiveInstance(ctx, instance, func() error {
+			_, err := db.ExecContext(ctx, "CREATE DATABASE app")
+			return err
+		})).To(Succeed())
+
+		Expect(mock.ExpectationsWereMet()).To(Succeed())
+		Expect(invocationsFile).To(BeAnExistingFile())
+	})
+
+	DescribeTable("refuses the options requiring a running instance",
+		func(info InitInfo, message string) {
+			info.InitOnly = true
+			Expect(info.VerifyConfiguration()).To(MatchError(ContainSubstring(message)))
+		},
+		Entry("initial dump file", InitInfo{InitialDumpFile: "/dump.sql"}, "initial dump file"),
+		Entry("recreated application database", InitInfo{RecreateApplicationDatabase: true},
+			"can't be recreated in init-only mode"),
+		Entry("archiving verification", InitInfo{VerifyArchiving: true}, "WAL archiving can't be verified"),
+	)
+})
+
+var _ = Describe("initial dump restore", func() {
+	It("is not subject to the statement timeout", func(ctx SpecContext) {
+		binDir := GinkgoT().TempDir()
+		invocationsFile := filepath.Join(binDir, "invocations")
+		script := "#!/bin/sh\necho \"$@\" >> " + invocationsFile + "\n"
+		Expect(os.WriteFile(filepath.Join(binDir, "psql"), []byte(script), 0o700)).To(Succeed()) // #nosec
+		GinkgoT().Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+		dumpFile := filepath.Join(GinkgoT().TempDir(), "dump.sql")
+		Expect(os.WriteFile(dumpFile, []byte("CREATE TABLE orders (id int);\n"), 0o600)).To(Succeed())
+
+		info := InitInfo{
+			SocketDirectory:     GinkgoT().TempDir(),
+			StatementTimeout:    time.Minute,
+			ApplicationDatabase: "app",
+			InitialDumpFile:     dumpFile,
+		}
+		instance := info.GetInstance()
+		Expect(instance.ConnectionPool().GetDsn("app")).To(ContainSubstring("statement_timeout"))
+
+		Expect(info.restoreInitialDumpFile(ctx, instance)).To(Succeed())
+		invocations, err := os.ReadFile(invocationsFile) // #nosec
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(invocations)).To(ContainSubstring("dbname=app"))
+		Expect(string(invocations)).ToNot(ContainSubstring("statement_timeout"))
+	})
+})
+
+var _ = Describe("executeQueries", func() {
+	It("does not log the passwords contained in the queries", func() {
+		var messages []string
+		originalLogger := log.GetLogger().GetLogger()
+		log.SetLogger(funcr.New(func(_, args string) {
+			messages = append(messages, args)
+		}, funcr.Options{Verbosity: 10}))
+		DeferCleanup(func() {
+			log.SetLogger(originalLogger)
+		})
+
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		Expect(err).ToNot(HaveOccurred())
+		mock.ExpectExec("ALTER USER app PASSWORD 'secret'").WillReturnResult(sqlmock.NewResult(0, 0))
+
+		Expect(InitInfo{}.executeQueries(db, []string{"ALTER USER app PASSWORD 'secret'"})).To(Succeed())
+		Expect(mock.ExpectationsWereMet()).To(Succeed())
+		Expect(messages).To(ContainElement(ContainSubstring("ALTER USER app PASSWORD ****")))
+		Expect(messages).ToNot(ContainElement(ContainSubstring("secret")))
+	})
+})
+
+var _ = Describe("archiving verification at bootstrap", func() {
+	var (
+		db           *sql.DB
+		mock         sqlmock.Sqlmock
+		bootstrapper *walArchiveBootstrapper
+	)
+
+	BeforeEach(func() {
+		originalBackoff := retryUntilBootstrapWalArchived
+		retryUntilBootstrapWalArchived = wait.Backoff{Duration: time.Millisecond, Steps: 1}
+		DeferCleanup(func() {
+			retryUntilBootstrapWalArchived = originalBackoff
+		})
+
+		var err error
+		db, mock, err = sqlmock.New()
+		Expect(err).ToNot(HaveOccurred())
+		bootstrapper = &walArchiveBootstrapper{
+			walArchiveAnalyzer: walArchiveAnalyzer{
+				dbFactory: func() (*sql.DB, error) {
+					return db, nil
+				},
+			},
+		}
+	})
+
+	It("is skipped unless requested", func() {
+		Expect(InitInfo{}.verifyArchiving(bootstrapper)).To(Succeed())
+		Expect(mock.ExpectationsWereMet()).To(Succeed())
+	})
+
+	It("fails the bootstrap when the archive_command is failing", func() {
+		mock.ExpectQuery("SHOW archive_mode").
+			WillReturnRows(sqlmock.NewRows([]string{"archive_mode"}).AddRow("on"))
+		// The connection is closed after reading archive_mode, use a new one
+		// to check the archiver
+		archiverDB, archiverMock, err := sqlmock.New()
+		Expect(err).ToNot(HaveOccurred())
+		archiverMock.ExpectQuery("SELECT COALESCE.*FROM pg_stat_archiver").
+			WillReturnRows(sqlmock.NewRows([]string{"is_archiving", "last_failed_time_present"}).AddRow(false, true))
+		connections := []*sql.DB{db, archiverDB}
+		bootstrapper.dbFactory = func() (*sql.DB, error) {
+			next := connections[0]
+			connections = connections[1:]
+			return next, nil
+		}
+
+		err = InitInfo{VerifyArchiving: true}.verifyArchiving(bootstrapper)
+		Expect(err).To(MatchError(ContainSubstring("while verifying the WAL archiving")))
+		Expect(err).To(MatchError(ContainSubstring("wal-archive not working")))
+		Expect(mock.ExpectationsWereMet()).To(Succeed())
+		Expect(archiverMock.ExpectationsWereMet()).To(Succeed())
+	})
+})
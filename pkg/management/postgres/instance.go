@@ -22,6 +22,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"math"
 	"os"
@@ -29,6 +30,7 @@ import (
 	"path"
 	"path/filepath"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/blang/semver"
@@ -57,10 +59,12 @@ const (
 	postgresName      = "postgres"
 	pgCtlName         = "pg_ctl"
 	pgRewindName      = "pg_rewind"
+	pgResetWalName    = "pg_resetwal"
 	pgBaseBackupName  = "pg_basebackup"
 	pgIsReady         = "pg_isready"
 	pgCtlTimeout      = "40000000" // greater than one year in seconds, big enough to simulate an infinite timeout
 	pgControlDataName = "pg_controldata"
+	pgChecksumsName   = "pg_checksums"
 
 	pqPingOk         = 0 // server is accepting connections
 	pqPingReject     = 1 // server is alive but rejecting connections
@@ -128,6 +132,10 @@ type Instance struct {
 	// The socket directory
 	SocketDirectory string
 
+	// The socket directory configured explicitly for this instance,
+	// taking precedence over the one detected by GetSocketDir
+	customSocketDirectory string
+
 	// The environment variables that will be used to start the instance
 	Env []string
 
@@ -135,9 +143,38 @@ type Instance struct {
 	// '-c' option of pg_ctl for an useful example
 	StartupOptions []string
 
+	// The address of the HTTP health endpoint exposed while the instance
+	// is active through WithActiveInstance. When empty, no endpoint is exposed
+	HealthCheckAddress string
+
+	// The limits of the connections used to reach the local instance.
+	// When nil, the defaults of the pool are used
+	ConnectionLimits *pool.ConnectionLimits
+
+	// The maximum time to wait while connecting to the local instance,
+	// zero meaning no limit
+	ConnectTimeout time.Duration
+
+	// The maximum time a statement executed on the local instance can
+	// take, zero meaning no limit
+	StatementTimeout time.Duration
+
+	// The application_name of the connections to the local instance.
+	// When empty, the name of the instance manager is used
+	ApplicationName string
+
+	// The CA certificate used to verify the server certificate of the
+	// local instance. When set, the instance is reached through TCP on
+	// localhost with sslmode=verify-full instead of the Unix socket
+	SSLRootCertFile string
+
 	// Pool of DB connections pointing to every used database
 	pool *pool.ConnectionPool
 
+	// Pool of DB connections not subject to StatementTimeout, used
+	// for the statements expected to run for a long time
+	longRunningPool *pool.ConnectionPool
+
 	// Pool of DB connections pointing to primary instance
 	primaryPool *pool.ConnectionPool
 
@@ -163,6 +200,17 @@ type Instance struct {
 	// pgVersion is the PostgreSQL version
 	pgVersion *semver.Version
 
+	// majorVersion is the PostgreSQL major version, as detected from PG_VERSION
+	majorVersion *int
+
+	// majorVersionMux protects majorVersion, that can be detected
+	// concurrently by the probes and by the reconciliation loop
+	majorVersionMux sync.Mutex
+
+	// transientLogPipeStop stops the CSV logpipe of the transient instance
+	// started by StartTransient, and is nil when it is not running
+	transientLogPipeStop func()
+
 	// instanceCommandChan is a channel for requesting actions on the instance
 	instanceCommandChan chan InstanceCommand
 
@@ -367,6 +415,14 @@ func NewInstance() *Instance {
 	}
 }
 
+// WithSocketDirectory specifies the directory where the
+// Unix socket for this instance is created
+func (instance *Instance) WithSocketDirectory(socketDirectory string) *Instance {
+	instance.SocketDirectory = socketDirectory
+	instance.customSocketDirectory = socketDirectory
+	return instance
+}
+
 // WithNamespace specifies the namespace for this Instance
 func (instance *Instance) WithNamespace(namespace string) *Instance {
 	instance.namespace = namespace
@@ -406,6 +462,16 @@ func GetSocketDir() string {
 	return socketDir
 }
 
+// getSocketDir gets the directory where this instance creates the
+// Unix socket, giving precedence to the explicitly configured one
+func (instance *Instance) getSocketDir() string {
+	if instance.customSocketDirectory != "" {
+		return instance.customSocketDirectory
+	}
+
+	return GetSocketDir()
+}
+
 // GetServerPort gets the port where the postmaster will be listening
 // using the environment variable or, when empty, the default one
 func GetServerPort() int {
@@ -427,7 +493,7 @@ func GetServerPort() int {
 // Startup starts up a PostgreSQL instance and wait for the instance to be
 // started
 func (instance *Instance) Startup() error {
-	socketDir := GetSocketDir()
+	socketDir := instance.getSocketDir()
 	if err := fileutils.EnsureDirectoryExists(socketDir); err != nil {
 		return fmt.Errorf("while creating socket directory: %w", err)
 	}
@@ -468,6 +534,9 @@ func (instance *Instance) ShutdownConnections() {
 	if instance.pool != nil {
 		instance.pool.ShutdownConnections()
 	}
+	if instance.longRunningPool != nil {
+		instance.longRunningPool.ShutdownConnections()
+	}
 	if instance.primaryPool != nil {
 		instance.primaryPool.ShutdownConnections()
 	}
@@ -687,35 +756,74 @@ func (instance *Instance) Run() (*execlog.StreamingCmd, error) {
 	return streamingCmd, nil
 }
 
-// WithActiveInstance execute the internal function while this
-// PostgreSQL instance is running
-func (instance *Instance) WithActiveInstance(inner func() error) error {
+// StartTransient starts this PostgreSQL instance, redirecting its CSV
+// log to stdout, until StopTransient is called. Starting an instance
+// which is already running with StartTransient does nothing
+func (instance *Instance) StartTransient(ctx context.Context) error {
+	if instance.transientLogPipeStop != nil {
+		return nil
+	}
+
 	// Start the CSV logpipe to redirect log to stdout
-	ctx, ctxCancel := context.WithCancel(context.Background())
+	logPipeCtx, logPipeCancel := context.WithCancel(ctx)
 	csvPipe := logpipe.NewLogPipe()
 
 	go func() {
-		if err := csvPipe.Start(ctx); err != nil {
-			log.Info("csv pipeline encountered an error", "err", err)
+		if err := csvPipe.Start(logPipeCtx); err != nil {
+			log.FromContext(ctx).Info("csv pipeline encountered an error", "err", err)
 		}
 	}()
 
-	defer func() {
-		ctxCancel()
+	stopLogPipe := func() {
+		logPipeCancel()
 		csvPipe.GetExitedCondition().Wait()
-	}()
+	}
 
-	err := instance.Startup()
-	if err != nil {
+	if err := instance.Startup(); err != nil {
+		stopLogPipe()
 		return fmt.Errorf("while activating instance: %w", err)
 	}
 
+	instance.transientLogPipeStop = stopLogPipe
+	return nil
+}
+
+// StopTransient shuts down the PostgreSQL instance started by
+// StartTransient. Stopping an instance which is not running
+// with StartTransient does nothing
+func (instance *Instance) StopTransient(ctx context.Context) error {
+	if instance.transientLogPipeStop == nil {
+		return nil
+	}
+
+	stopLogPipe := instance.transientLogPipeStop
+	instance.transientLogPipeStop = nil
+	defer stopLogPipe()
+
+	if err := instance.Shutdown(ctx, defaultShutdownOptions); err != nil {
+		return fmt.Errorf("while deactivating instance: %w", err)
+	}
+
+	return nil
+}
+
+// WithActiveInstance execute the internal function while this
+// PostgreSQL instance is running
+func (instance *Instance) WithActiveInstance(ctx context.Context, inner func() error) error {
+	if err := instance.StartTransient(ctx); err != nil {
+		return err
+	}
+
 	defer func() {
-		if err := instance.Shutdown(ctx, defaultShutdownOptions); err != nil {
-			log.Info("Error while deactivating instance", "err", err)
+		if err := instance.StopTransient(ctx); err != nil {
+			log.FromContext(ctx).Info("Error while deactivating instance", "err", err)
 		}
 	}()
 
+	if instance.HealthCheckAddress != "" {
+		return withHealthEndpoint(ctx, instance.HealthCheckAddress, instance.pingInstance, inner)
+	}
+
 	return inner()
 }
 
@@ -749,23 +857,87 @@ func (instance *Instance) GetPgVersion() (semver.Version, error) {
 	return *parsedVersion, nil
 }
 
+// MajorVersion reads the major version of the instance from the PG_VERSION file
+// inside the data directory, and memoizes it for future uses.
+// IMPORTANT: this method also works when the instance is not started up
+func (instance *Instance) MajorVersion() (int, error) {
+	instance.majorVersionMux.Lock()
+	defer instance.majorVersionMux.Unlock()
+
+	if instance.majorVersion != nil {
+		return *instance.majorVersion, nil
+	}
+
+	majorVersion, err := postgresutils.GetMajorVersion(instance.PgData)
+	if err != nil {
+		return 0, err
+	}
+	instance.majorVersion = &majorVersion
+	return majorVersion, nil
+}
+
 // ConnectionPool gets or initializes the connection pool for this instance
 func (instance *Instance) ConnectionPool() *pool.ConnectionPool {
-	const applicationName = "cnpg-instance-manager"
 	if instance.pool == nil {
-		socketDir := GetSocketDir()
-		dsn := fmt.Sprintf(
-			"host=%s port=%v user=%v sslmode=disable application_name=%v",
-			socketDir,
+		instance.pool = instance.newConnectionPool(instance.StatementTimeout)
+	}
+
+	return instance.pool
+}
+
+// LongRunningConnectionPool gets or initializes the connection pool used
+// for the statements expected to run for a long time, like restoring a
+// logical dump or analyzing the databases. Its connections are not subject
+// to StatementTimeout
+func (instance *Instance) LongRunningConnectionPool() *pool.ConnectionPool {
+	if instance.StatementTimeout == 0 {
+		return instance.ConnectionPool()
+	}
+
+	if instance.longRunningPool == nil {
+		instance.longRunningPool = instance.newConnectionPool(0)
+	}
+
+	return instance.longRunningPool
+}
+
+// newConnectionPool creates a connection pool for this instance, whose
+// statements are canceled after statementTimeout, zero meaning no limit
+func (instance *Instance) newConnectionPool(statementTimeout time.Duration) *pool.ConnectionPool {
+	applicationName := instance.ApplicationName
+	if applicationName == "" {
+		applicationName = "cnpg-instance-manager"
+	}
+
+	dsn := fmt.Sprintf(
+		"host=%s port=%v user=%v sslmode=disable application_name=%v",
+		instance.getSocketDir(),
+		GetServerPort(),
+		"postgres",
+		applicationName,
+	)
+	if instance.SSLRootCertFile != "" {
+		dsn = fmt.Sprintf(
+			"host=localhost port=%v user=%v sslmode=verify-full sslrootcert=%v application_name=%v",
 			GetServerPort(),
 			"postgres",
+			instance.SSLRootCertFile,
 			applicationName,
 		)
+	}
+	if instance.ConnectTimeout > 0 {
+		dsn += fmt.Sprintf(" connect_timeout=%d", int(math.Ceil(instance.ConnectTimeout.Seconds())))
+	}
+	if statementTimeout > 0 {
+		dsn += fmt.Sprintf(" options='-c statement_timeout=%d'", statementTimeout.Milliseconds())
+	}
 
-		instance.pool = pool.NewPostgresqlConnectionPool(dsn)
+	connectionPool := pool.NewPostgresqlConnectionPool(dsn)
+	if instance.ConnectionLimits != nil {
+		connectionPool.SetConnectionLimits(*instance.ConnectionLimits)
 	}
 
-	return instance.pool
+	return connectionPool
 }
 
 // PrimaryConnectionPool gets or initializes the primary connection pool for this instance
@@ -840,7 +1012,7 @@ func (instance *Instance) CompleteCrashRecovery(ctx context.Context) error {
 		instance.ShutdownConnections()
 	}()
 
-	return instance.WithActiveInstance(func() error {
+	return instance.WithActiveInstance(ctx, func() error {
 		return instance.WaitForSuperuserConnectionAvailable(ctx)
 	})
 }
@@ -1051,9 +1223,22 @@ func (instance *Instance) Rewind(ctx context.Context, postgresVersion version.Da
 
 	pgRewindCmd := exec.Command(pgRewindName, options...) // #nosec
 	pgRewindCmd.Env = instance.Env
-	err = execlog.RunStreaming(pgRewindCmd, pgRewindName)
+	var output rewindOutput
+	pgRewindLogger := log.WithName(pgRewindName)
+	streamingCmd, err := execlog.RunStreamingNoWaitWithWriter(
+		pgRewindCmd,
+		pgRewindName,
+		&execlog.LogWriter{Logger: pgRewindLogger.WithValues(execlog.PipeKey, execlog.StdOut)},
+		io.MultiWriter(&execlog.LogWriter{Logger: pgRewindLogger.WithValues(execlog.PipeKey, execlog.StdErr)}, &output),
+	)
+	if err == nil {
+		err = streamingCmd.Wait()
+	}
 	if err != nil {
 		contextLogger.Error(err, "Failed to execute pg_rewind", "options", options)
+		if output.rewindNotPossible() {
+			return fmt.Errorf("%w: error executing pg_rewind: %w", ErrRewindNotPossible, err)
+		}
 		return fmt.Errorf("error executing pg_rewind: %w", err)
 	}
 
@@ -1282,7 +1467,7 @@ func (instance *Instance) DropConnections() error {
 
 // GetPrimaryConnInfo returns the DSN to reach the primary
 func (instance *Instance) GetPrimaryConnInfo() string {
-	return buildPrimaryConnInfo(instance.GetClusterName()+"-rw", instance.GetPodName())
+	return buildPrimaryConnInfo(instance.GetClusterName()+"-rw", GetServerPort(), instance.GetPodName())
 }
 
 // HandleInstanceCommandRequests execute a command requested by the reconciliation
@@ -21,10 +21,14 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
+	"time"
 
 	"github.com/cloudnative-pg/machinery/pkg/fileutils"
+	"github.com/jackc/pgx/v5"
 
 	apiv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	"github.com/cloudnative-pg/cloudnative-pg/pkg/management/postgres/pool"
 	"github.com/cloudnative-pg/cloudnative-pg/pkg/postgres"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -263,3 +267,234 @@ var _ = Describe("ALTER SYSTEM enable and disable in PostgreSQL <17", func() {
 		Expect(info.Mode()).To(BeEquivalentTo(0o400))
 	})
 })
+
+var _ = Describe("detecting the major version", func() {
+	It("should read PG_VERSION only once", func() {
+		instance := Instance{
+			PgData: GinkgoT().TempDir(),
+		}
+
+		_, err := fileutils.WriteStringToFile(filepath.Join(instance.PgData, "PG_VERSION"), "16\n")
+		Expect(err).ToNot(HaveOccurred())
+
+		majorVersion, err := instance.MajorVersion()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(majorVersion).To(Equal(16))
+
+		// Changing or removing the file has no effect on the memoized value
+		err = os.Remove(filepath.Join(instance.PgData, "PG_VERSION"))
+		Expect(err).ToNot(HaveOccurred())
+
+		majorVersion, err = instance.MajorVersion()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(majorVersion).To(Equal(16))
+	})
+
+	It("should be safe to detect it concurrently", func() {
+		instance := Instance{
+			PgData: GinkgoT().TempDir(),
+		}
+
+		_, err := fileutils.WriteStringToFile(filepath.Join(instance.PgData, "PG_VERSION"), "16\n")
+		Expect(err).ToNot(HaveOccurred())
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer GinkgoRecover()
+				defer wg.Done()
+				majorVersion, err := instance.MajorVersion()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(majorVersion).To(Equal(16))
+			}()
+		}
+		wg.Wait()
+	})
+
+	It("should report an error when PG_VERSION is missing", func() {
+		instance := Instance{
+			PgData: GinkgoT().TempDir(),
+		}
+
+		_, err := instance.MajorVersion()
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("connection pool limits", func() {
+	It("applies the bootstrap limits to the instance used during bootstrap", func() {
+		instance := InitInfo{PgData: "/var/lib/postgresql/data/pgdata"}.GetInstance()
+		Expect(instance.ConnectionLimits).ToNot(BeNil())
+		Expect(*instance.ConnectionLimits).To(Equal(bootstrapConnectionLimits))
+
+		db, err := instance.GetSuperUserDB()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(db.Stats().MaxOpenConnections).To(Equal(bootstrapConnectionLimits.MaxOpenConns))
+	})
+
+	It("doesn't keep idle connections during bootstrap", func() {
+		// A connection to template1 left idle would make
+		// CREATE DATABASE fail
+		Expect(bootstrapConnectionLimits.MaxIdleConns).To(BeZero())
+	})
+
+	It("uses the pool defaults when no limits are configured", func() {
+		instance := NewInstance()
+
+		db, err := instance.GetSuperUserDB()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(db.Stats().MaxOpenConnections).To(Equal(pool.DefaultConnectionLimits.MaxOpenConns))
+	})
+})
+
+var _ = Describe("custom socket directory", func() {
+	It("uses the custom socket directory instead of PGHOST", func() {
+		GinkgoT().Setenv("PGHOST", "/controller/run")
+		socketDirectory := GinkgoT().TempDir()
+
+		instance := InitInfo{SocketDirectory: socketDirectory}.GetInstance()
+		Expect(instance.getSocketDir()).To(Equal(socketDirectory))
+		Expect(instance.ConnectionPool().GetDsn("postgres")).To(ContainSubstring("host=" + socketDirectory + " "))
+	})
+
+	It("uses PGHOST when no socket directory is specified", func() {
+		GinkgoT().Setenv("PGHOST", "/controller/run")
+
+		instance := InitInfo{}.GetInstance()
+		Expect(instance.getSocketDir()).To(Equal("/controller/run"))
+	})
+
+	It("accepts a writable socket directory", func() {
+		info := InitInfo{SocketDirectory: GinkgoT().TempDir()}
+		Expect(info.VerifyConfiguration()).To(Succeed())
+		Expect(os.ReadDir(info.SocketDirectory)).To(BeEmpty())
+	})
+
+	It("refuses a missing socket directory", func() {
+		info := InitInfo{SocketDirectory: filepath.Join(GinkgoT().TempDir(), "missing")}
+		Expect(info.VerifyConfiguration()).ToNot(Succeed())
+	})
+})
+
+var _ = Describe("connection timeouts", func() {
+	It("adds the timeouts to the DSN of the instance used during bootstrap", func() {
+		instance := InitInfo{
+			ConnectTimeout:   DefaultBootstrapConnectTimeout,
+			StatementTimeout: 90 * time.Second,
+		}.GetInstance()
+
+		dsn := instance.ConnectionPool().GetDsn("postgres")
+		Expect(dsn).To(ContainSubstring(" connect_timeout=5"))
+		Expect(dsn).To(ContainSubstring(" options='-c statement_timeout=90000'"))
+
+		config, err := pgx.ParseConfig(dsn)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(config.ConnectTimeout).To(Equal(5 * time.Second))
+		Expect(config.RuntimeParams).To(HaveKeyWithValue("options", "-c statement_timeout=90000"))
+	})
+
+	It("doesn't add any timeout when not requested", func() {
+		dsn := InitInfo{}.GetInstance().ConnectionPool().GetDsn("postgres")
+		Expect(dsn).ToNot(ContainSubstring("connect_timeout"))
+		Expect(dsn).ToNot(ContainSubstring("statement_timeout"))
+	})
+
+	It("doesn't cap the statements expected to run for a long time", func() {
+		instance := InitInfo{
+			ConnectTimeout:   DefaultBootstrapConnectTimeout,
+			StatementTimeout: 90 * time.Second,
+		}.GetInstance()
+
+		dsn := instance.LongRunningConnectionPool().GetDsn("postgres")
+		Expect(dsn).To(ContainSubstring(" connect_timeout=5"))
+		Expect(dsn).ToNot(ContainSubstring("statement_timeout"))
+		Expect(instance.ConnectionPool().GetDsn("postgres")).To(ContainSubstring("statement_timeout"))
+	})
+
+	It("uses a single pool when the statements are not capped", func() {
+		instance := InitInfo{}.GetInstance()
+		Expect(instance.LongRunningConnectionPool()).To(BeIdenticalTo(instance.ConnectionPool()))
+	})
+})
+
+var _ = Describe("application name", func() {
+	It("tags the connections of the instance used during bootstrap", func() {
+		dsn := InitInfo{}.GetInstance().ConnectionPool().GetDsn("postgres")
+		Expect(dsn).To(ContainSubstring(" application_name=cnpg-bootstrap"))
+
+		config, err := pgx.ParseConfig(dsn)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(config.RuntimeParams).To(HaveKeyWithValue("application_name", DefaultBootstrapApplicationName))
+	})
+
+	It("uses the configured application name", func() {
+		dsn := InitInfo{ApplicationName: "ci-provisioning"}.GetInstance().ConnectionPool().GetDsn("postgres")
+		Expect(dsn).To(ContainSubstring(" application_name=ci-provisioning"))
+	})
+
+	It("uses the name of the instance manager outside of the bootstrap", func() {
+		dsn := NewInstance().ConnectionPool().GetDsn("postgres")
+		Expect(dsn).To(ContainSubstring(" application_name=cnpg-instance-manager"))
+	})
+
+	It("refuses application names which can't be used in the connection string", func() {
+		Expect(InitInfo{ApplicationName: "ci provisioning"}.VerifyConfiguration()).
+			To(MatchError(ContainSubstring("invalid application name")))
+	})
+})
+
+var _ = Describe("transient instance lifecycle", func() {
+	var instance *Instance
+	var actionsFile string
+
+	// actions returns the pg_ctl actions executed by the fake pg_ctl
+	actions := func() []string {
+		lines, err := fileutils.ReadFileLines(actionsFile)
+		Expect(err).ToNot(HaveOccurred())
+		return lines
+	}
+
+	BeforeEach(func() {
+		binDir := GinkgoT().TempDir()
+		actionsFile = filepath.Join(binDir, "actions")
+		script := fmt.Sprintf("#!/bin/sh\nfor arg in \"$@\"; do\n"+
+			"  case \"$arg\" in start|stop|status) echo \"$arg\" >> %s ;; esac\ndone\n", actionsFile)
+		Expect(os.WriteFile(filepath.Join(binDir, pgCtlName), []byte(script), 0o700)).To(Succeed()) // #nosec
+		GinkgoT().Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+		instance = InitInfo{
+			PgData:          GinkgoT().TempDir(),
+			SocketDirectory: GinkgoT().TempDir(),
+		}.GetInstance()
+	})
+
+	It("balances the start and the stop of the instance", func(ctx SpecContext) {
+		Expect(instance.StartTransient(ctx)).To(Succeed())
+		Expect(actions()).To(Equal([]string{"start"}))
+
+		Expect(instance.StopTransient(ctx)).To(Succeed())
+		Expect(actions()).To(Equal([]string{"start", "status", "stop"}))
+	})
+
+	It("is idempotent", func(ctx SpecContext) {
+		Expect(instance.StartTransient(ctx)).To(Succeed())
+		Expect(instance.StartTransient(ctx)).To(Succeed())
+		Expect(instance.StopTransient(ctx)).To(Succeed())
+		Expect(instance.StopTransient(ctx)).To(Succeed())
+		Expect(actions()).To(Equal([]string{"start", "status", "stop"}))
+	})
+
+	It("doesn't stop an instance which was never started", func(ctx SpecContext) {
+		Expect(instance.StopTransient(ctx)).To(Succeed())
+		Expect(actions()).To(BeEmpty())
+	})
+
+	It("stops the instance after running the function", func(ctx SpecContext) {
+		Expect(instance.WithActiveInstance(ctx, func() error {
+			Expect(actions()).To(Equal([]string{"start"}))
+			return nil
+		})).To(Succeed())
+		Expect(actions()).To(Equal([]string{"start", "status", "stop"}))
+	})
+})
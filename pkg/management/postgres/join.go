@@ -19,6 +19,7 @@ package postgres
 import (
 	"context"
 	"fmt"
+	"net"
 	"os/exec"
 
 	"github.com/cloudnative-pg/machinery/pkg/execlog"
@@ -71,8 +72,24 @@ func ClonePgData(ctx context.Context, connectionString, targetPgData, walDir str
 }
 
 // Join creates a new instance joined to an existing PostgreSQL cluster
-func (info InitInfo) Join(ctx context.Context, cluster *apiv1.Cluster) error {
-	primaryConnInfo := buildPrimaryConnInfo(info.ParentNode, info.PodName) + " dbname=postgres connect_timeout=5"
+func (info InitInfo) Join(ctx context.Context, cluster *apiv1.Cluster) (err error) {
+	ctx, span := info.startSpan(ctx, "Join")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	primaryConnInfo, err := info.parentConnInfo()
+	if err != nil {
+		return err
+	}
+
+	// A parent node which can't be resolved would make the new instance
+	// wait forever for the streaming connection to be available
+	if err = waitForHostResolution(ctx, net.DefaultResolver, info.ParentNode, retryUntilHostResolved); err != nil {
+		return fmt.Errorf("while checking the parent node: %w", err)
+	}
+
+	primaryConnInfo += " dbname=postgres connect_timeout=5"
 
 	pgVersion, err := cluster.GetPostgresqlVersion()
 	if err != nil {
@@ -96,7 +113,43 @@ func (info InitInfo) Join(ctx context.Context, cluster *apiv1.Cluster) error {
 		return err
 	}
 
+	replicaConnInfo, err := info.writeReplicaConfiguration(cluster)
+	if err != nil {
+		return err
+	}
+
+	if info.VerifyReplicaConnectivity {
+		if err := verifyReplicaConnectivity(ctx, replicaConnInfo, openReplicationConnection); err != nil {
+			return err
+		}
+		log.FromContext(ctx).Info("Verified the connectivity of the new replica to the primary")
+	}
+
+	return nil
+}
+
+// writeReplicaConfiguration makes the cloned data directory a replica of
+// the primary, returning the primary_conninfo it has been configured with
+func (info InitInfo) writeReplicaConfiguration(cluster *apiv1.Cluster) (string, error) {
+	replicaConnInfo := info.GetPrimaryConnInfo()
 	slotName := cluster.GetSlotNameFromInstanceName(info.PodName)
-	_, err = UpdateReplicaConfiguration(info.PgData, info.GetPrimaryConnInfo(), slotName)
-	return err
+	if _, err := UpdateReplicaConfiguration(info.PgData, replicaConnInfo, slotName); err != nil {
+		return "", err
+	}
+
+	return replicaConnInfo, nil
+}
+
+// parentConnInfo returns the connection string to reach the parent node,
+// on ParentPort or, when not set, on the same port of the local server
+func (info InitInfo) parentConnInfo() (string, error) {
+	port := info.ParentPort
+	if port < 0 || port > 65535 {
+		return "", fmt.Errorf("invalid parent node port %d", port)
+	}
+	if port == 0 {
+		port = GetServerPort()
+	}
+
+	return buildPrimaryConnInfo(info.ParentNode, port, info.PodName), nil
 }
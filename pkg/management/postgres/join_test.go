@@ -0,0 +1,93 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"os"
+	"path/filepath"
+
+	apiv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	"github.com/cloudnative-pg/cloudnative-pg/pkg/management/postgres/constants"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("parent node connection string", func() {
+	It("connects to the parent port", func() {
+		connInfo, err := InitInfo{ParentNode: "cluster-example-rw", ParentPort: 6432}.parentConnInfo()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(connInfo).To(And(ContainSubstring("host=cluster-example-rw "), ContainSubstring("port=6432 ")))
+	})
+
+	It("defaults to the port of the local server", func() {
+		GinkgoT().Setenv("PGPORT", "")
+		connInfo, err := InitInfo{ParentNode: "cluster-example-rw"}.parentConnInfo()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(connInfo).To(And(ContainSubstring("host=cluster-example-rw "), ContainSubstring("port=5432 ")))
+	})
+
+	It("uses PGPORT as the port of the local server", func() {
+		GinkgoT().Setenv("PGPORT", "5433")
+		connInfo, err := InitInfo{ParentNode: "cluster-example-rw"}.parentConnInfo()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(connInfo).To(ContainSubstring("port=5433 "))
+	})
+
+	It("refuses invalid ports", func() {
+		_, err := InitInfo{ParentNode: "cluster-example-rw", ParentPort: 70000}.parentConnInfo()
+		Expect(err).To(MatchError(ContainSubstring("invalid parent node port")))
+	})
+})
+
+var _ = Describe("joined replica configuration", func() {
+	readPrimaryConnInfo := func(pgData string) string {
+		content, err := os.ReadFile(filepath.Join(pgData, constants.PostgresqlOverrideConfigurationFile)) // #nosec
+		Expect(err).ToNot(HaveOccurred())
+		return string(content)
+	}
+
+	It("streams from the primary on the parent port", func() {
+		info := InitInfo{
+			PgData:      GinkgoT().TempDir(),
+			ClusterName: "cluster-example",
+			PodName:     "cluster-example-2",
+			ParentNode:  "cluster-example-1",
+			ParentPort:  6432,
+		}
+
+		connInfo, err := info.writeReplicaConfiguration(&apiv1.Cluster{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(connInfo).To(And(ContainSubstring("host=cluster-example-rw "), ContainSubstring("port=6432 ")))
+		Expect(readPrimaryConnInfo(info.PgData)).To(ContainSubstring("primary_conninfo = '" + connInfo + "'"))
+		Expect(filepath.Join(info.PgData, "standby.signal")).To(BeAnExistingFile())
+	})
+
+	It("streams from the primary on the port of the local server by default", func() {
+		GinkgoT().Setenv("PGPORT", "")
+		info := InitInfo{
+			PgData:      GinkgoT().TempDir(),
+			ClusterName: "cluster-example",
+			PodName:     "cluster-example-2",
+		}
+
+		connInfo, err := info.writeReplicaConfiguration(&apiv1.Cluster{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(connInfo).To(ContainSubstring("port=5432 "))
+		Expect(readPrimaryConnInfo(info.PgData)).To(ContainSubstring("primary_conninfo = '" + connInfo + "'"))
+	})
+})
@@ -31,6 +31,7 @@ type (
 const (
 	pgDump           executable = "pg_dump"
 	pgRestore        executable = "pg_restore"
+	psql             executable = "psql"
 	postgresDatabase            = "postgres"
 	dumpDirectory               = specs.PgDataPath + "/dumps"
 )
@@ -0,0 +1,248 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logicalimport
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cloudnative-pg/machinery/pkg/log"
+
+	"github.com/cloudnative-pg/cloudnative-pg/pkg/management/postgres/utils"
+)
+
+// DumpFormat is the format of a logical dump, as produced by pg_dump
+type DumpFormat string
+
+const (
+	// DumpFormatPlain is a plain SQL script
+	DumpFormatPlain DumpFormat = "plain"
+
+	// DumpFormatCustom is the pg_dump custom archive format
+	DumpFormatCustom DumpFormat = "custom"
+
+	// DumpFormatDirectory is the pg_dump directory archive format
+	DumpFormatDirectory DumpFormat = "directory"
+
+	// DumpFormatTar is the pg_dump tar archive format
+	DumpFormatTar DumpFormat = "tar"
+)
+
+const (
+	// customFormatMagic is the header of every custom format archive
+	customFormatMagic = "PGDMP"
+
+	// tarFormatMagic is the magic string of a tar archive, found at
+	// tarFormatMagicOffset bytes from the beginning of the file
+	tarFormatMagic       = "ustar"
+	tarFormatMagicOffset = 257
+
+	// directoryFormatTOC is the table of contents of a directory archive
+	directoryFormatTOC = "toc.dat"
+
+	// dumpHeaderSize is the number of bytes needed to detect the dump format
+	dumpHeaderSize = tarFormatMagicOffset + len(tarFormatMagic)
+)
+
+// gzipMagic is the header of every gzip compressed file
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// DumpFile is a logical dump file whose format has been detected
+type DumpFile struct {
+	// The path of the dump
+	Path string
+
+	// The format of the dump
+	Format DumpFormat
+
+	// Whether the dump is gzip compressed
+	Gzipped bool
+
+	// NoOwner skips restoring the ownership of the objects of an archive,
+	// which are owned by the user running the restore instead
+	NoOwner bool
+
+	// Role is the role the objects of an archive are created with,
+	// when not empty
+	Role string
+}
+
+// DetectDumpFile detects the format of the logical dump stored at the passed
+// path, looking at its magic bytes and, for plain SQL scripts, at its extension
+func DetectDumpFile(dumpPath string) (*DumpFile, error) {
+	stat, err := os.Stat(dumpPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if stat.IsDir() {
+		if _, err := os.Stat(filepath.Join(dumpPath, directoryFormatTOC)); err != nil {
+			return nil, fmt.Errorf(
+				"%s is a directory but not a pg_dump directory archive, missing %s",
+				dumpPath, directoryFormatTOC)
+		}
+		return &DumpFile{Path: dumpPath, Format: DumpFormatDirectory}, nil
+	}
+
+	reader, closer, gzipped, err := openDumpFile(dumpPath)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = closer.Close()
+	}()
+
+	header := make([]byte, dumpHeaderSize)
+	n, err := io.ReadFull(reader, header)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, fmt.Errorf("while reading the header of %s: %w", dumpPath, err)
+	}
+	header = header[:n]
+
+	format, err := detectDumpFormat(dumpPath, header)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DumpFile{Path: dumpPath, Format: format, Gzipped: gzipped}, nil
+}
+
+// detectDumpFormat detects the format of a dump given its
+// path and the first bytes of its uncompressed content
+func detectDumpFormat(dumpPath string, header []byte) (DumpFormat, error) {
+	switch {
+	case bytes.HasPrefix(header, []byte(customFormatMagic)):
+		return DumpFormatCustom, nil
+
+	case len(header) >= dumpHeaderSize &&
+		string(header[tarFormatMagicOffset:tarFormatMagicOffset+len(tarFormatMagic)]) == tarFormatMagic:
+		return DumpFormatTar, nil
+
+	case strings.HasSuffix(strings.TrimSuffix(dumpPath, ".gz"), ".sql"),
+		bytes.HasPrefix(header, []byte("--")):
+		return DumpFormatPlain, nil
+	}
+
+	return "", fmt.Errorf(
+		"cannot detect the format of %s: expected a plain SQL script (.sql), "+
+			"or a custom, directory or tar archive produced by pg_dump, optionally gzip compressed",
+		dumpPath)
+}
+
+// openDumpFile opens a dump file, decompressing it on
+// the fly when it is gzip compressed
+func openDumpFile(dumpPath string) (io.Reader, io.Closer, bool, error) {
+	file, err := os.Open(dumpPath) // #nosec G304
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	bufferedReader := bufio.NewReader(file)
+	magic, err := bufferedReader.Peek(len(gzipMagic))
+	if err != nil && err != io.EOF {
+		_ = file.Close()
+		return nil, nil, false, err
+	}
+
+	if !bytes.Equal(magic, gzipMagic) {
+		return bufferedReader, file, false, nil
+	}
+
+	gzipReader, err := gzip.NewReader(bufferedReader)
+	if err != nil {
+		_ = file.Close()
+		return nil, nil, false, fmt.Errorf("while decompressing %s: %w", dumpPath, err)
+	}
+
+	return gzipReader, file, true, nil
+}
+
+// restoreTool returns the tool needed to restore the dump
+func (dump DumpFile) restoreTool() executable {
+	if dump.Format == DumpFormatPlain {
+		return psql
+	}
+
+	return pgRestore
+}
+
+// restoreOptions returns the options of the restore tool. Compressed
+// dumps are read from the standard input
+func (dump DumpFile) restoreOptions(dsn string) []string {
+	source := dump.Path
+	if dump.Gzipped {
+		source = "-"
+	}
+
+	if dump.restoreTool() == psql {
+		return []string{"-v", "ON_ERROR_STOP=1", "-d", dsn, "-f", source}
+	}
+
+	options := []string{"-d", dsn}
+	if dump.NoOwner {
+		options = append(options, "--no-owner")
+	}
+	if dump.Role != "" {
+		options = append(options, "--role", dump.Role)
+	}
+	if !dump.Gzipped {
+		options = append(options, source)
+	}
+
+	return options
+}
+
+// Restore restores the dump into the database identified by the passed DSN,
+// using psql for plain SQL scripts and pg_restore for archives
+func (dump DumpFile) Restore(ctx context.Context, dsn string) error {
+	contextLogger := log.FromContext(ctx)
+
+	tool := dump.restoreTool()
+
+	var commandOptions utils.CommandOptions
+	if dump.Gzipped {
+		reader, closer, _, err := openDumpFile(dump.Path)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = closer.Close()
+		}()
+		commandOptions.Stdin = reader
+	}
+
+	contextLogger.Info("Restoring logical dump",
+		"path", dump.Path,
+		"format", dump.Format,
+		"gzipped", dump.Gzipped,
+		"cmd", tool)
+
+	output, err := utils.RunCommand(ctx, tool, dump.restoreOptions(dsn), commandOptions)
+	if err != nil {
+		return fmt.Errorf("error while restoring %s with %s: %w", dump.Path, tool, err)
+	}
+	contextLogger.Debug("Logical dump restored", "output", output)
+
+	return nil
+}
@@ -0,0 +1,152 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logicalimport
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("logical dump format detection", func() {
+	const dsn = "host=/controller/run user=postgres dbname=app"
+
+	var tempDir string
+
+	BeforeEach(func() {
+		tempDir = GinkgoT().TempDir()
+	})
+
+	writeDump := func(name string, content []byte, gzipped bool) string {
+		if gzipped {
+			var buffer bytes.Buffer
+			writer := gzip.NewWriter(&buffer)
+			_, err := writer.Write(content)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(writer.Close()).To(Succeed())
+			content = buffer.Bytes()
+		}
+
+		dumpPath := filepath.Join(tempDir, name)
+		Expect(os.WriteFile(dumpPath, content, 0o600)).To(Succeed())
+		return dumpPath
+	}
+
+	tarContent := func() []byte {
+		var buffer bytes.Buffer
+		writer := tar.NewWriter(&buffer)
+		Expect(writer.WriteHeader(&tar.Header{Name: "toc.dat", Mode: 0o600, Size: 5})).To(Succeed())
+		_, err := writer.Write([]byte(customFormatMagic))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(writer.Close()).To(Succeed())
+		return buffer.Bytes()
+	}
+
+	plainContent := []byte("--\n-- PostgreSQL database dump\n--\n\nCREATE TABLE test (id integer);\n")
+	customContent := []byte(customFormatMagic + "\x01\x0e\x00\x04\x08\x01\x01")
+
+	It("restores plain SQL scripts with psql", func() {
+		dump, err := DetectDumpFile(writeDump("dump", plainContent, false))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(dump.Format).To(Equal(DumpFormatPlain))
+		Expect(dump.Gzipped).To(BeFalse())
+		Expect(dump.restoreTool()).To(Equal(psql))
+		Expect(dump.restoreOptions(dsn)).To(Equal([]string{"-v", "ON_ERROR_STOP=1", "-d", dsn, "-f", dump.Path}))
+	})
+
+	It("detects plain SQL scripts by their extension", func() {
+		dump, err := DetectDumpFile(writeDump("dump.sql", []byte("CREATE TABLE test (id integer);\n"), false))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(dump.Format).To(Equal(DumpFormatPlain))
+	})
+
+	It("restores gzipped plain SQL scripts with psql reading from stdin", func() {
+		dump, err := DetectDumpFile(writeDump("dump.sql.gz", plainContent, true))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(dump.Format).To(Equal(DumpFormatPlain))
+		Expect(dump.Gzipped).To(BeTrue())
+		Expect(dump.restoreTool()).To(Equal(psql))
+		Expect(dump.restoreOptions(dsn)).To(Equal([]string{"-v", "ON_ERROR_STOP=1", "-d", dsn, "-f", "-"}))
+	})
+
+	It("restores custom archives with pg_restore", func() {
+		dump, err := DetectDumpFile(writeDump("dump.custom", customContent, false))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(dump.Format).To(Equal(DumpFormatCustom))
+		Expect(dump.restoreTool()).To(Equal(pgRestore))
+		Expect(dump.restoreOptions(dsn)).To(Equal([]string{"-d", dsn, dump.Path}))
+	})
+
+	It("restores gzipped custom archives with pg_restore reading from stdin", func() {
+		dump, err := DetectDumpFile(writeDump("dump.custom.gz", customContent, true))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(dump.Format).To(Equal(DumpFormatCustom))
+		Expect(dump.Gzipped).To(BeTrue())
+		Expect(dump.restoreTool()).To(Equal(pgRestore))
+		Expect(dump.restoreOptions(dsn)).To(Equal([]string{"-d", dsn}))
+	})
+
+	It("remaps the ownership of the archives", func() {
+		dump, err := DetectDumpFile(writeDump("dump.custom", customContent, false))
+		Expect(err).ToNot(HaveOccurred())
+		dump.NoOwner = true
+		dump.Role = "app"
+		Expect(dump.restoreOptions(dsn)).To(Equal([]string{"-d", dsn, "--no-owner", "--role", "app", dump.Path}))
+	})
+
+	It("restores tar archives with pg_restore", func() {
+		dump, err := DetectDumpFile(writeDump("dump.tar", tarContent(), false))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(dump.Format).To(Equal(DumpFormatTar))
+		Expect(dump.restoreTool()).To(Equal(pgRestore))
+	})
+
+	It("restores gzipped tar archives with pg_restore", func() {
+		dump, err := DetectDumpFile(writeDump("dump.tar.gz", tarContent(), true))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(dump.Format).To(Equal(DumpFormatTar))
+		Expect(dump.Gzipped).To(BeTrue())
+		Expect(dump.restoreTool()).To(Equal(pgRestore))
+	})
+
+	It("restores directory archives with pg_restore", func() {
+		dumpPath := filepath.Join(tempDir, "dump")
+		Expect(os.Mkdir(dumpPath, 0o700)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(dumpPath, directoryFormatTOC), customContent, 0o600)).To(Succeed())
+
+		dump, err := DetectDumpFile(dumpPath)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(dump.Format).To(Equal(DumpFormatDirectory))
+		Expect(dump.restoreTool()).To(Equal(pgRestore))
+		Expect(dump.restoreOptions(dsn)).To(Equal([]string{"-d", dsn, dumpPath}))
+	})
+
+	It("refuses directories that are not pg_dump archives", func() {
+		_, err := DetectDumpFile(tempDir)
+		Expect(err).To(MatchError(ContainSubstring(directoryFormatTOC)))
+	})
+
+	It("refuses files in an unknown format", func() {
+		_, err := DetectDumpFile(writeDump("dump.bin", []byte{0x00, 0x01, 0x02}, false))
+		Expect(err).To(MatchError(ContainSubstring("cannot detect the format")))
+	})
+})
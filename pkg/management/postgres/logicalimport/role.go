@@ -80,7 +80,7 @@ func (rs *roleManager) importRoles(ctx context.Context, roles []Role) error {
 	}
 	for _, role := range roles {
 		query := rs.createSQLStatement(role)
-		contextLogger.Info("executing import role query", "query", query)
+		contextLogger.Info("executing import role query", "query", utils.RedactSecrets(query))
 		_, err := db.Exec(query)
 		if err != nil {
 			contextLogger.Error(err, "error while importing the role")
@@ -0,0 +1,169 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/cloudnative-pg/machinery/pkg/fileutils"
+	"github.com/cloudnative-pg/machinery/pkg/log"
+	"github.com/jackc/pgx/v5"
+	"github.com/lib/pq"
+)
+
+// validPasswordEncryptionMethods are the values accepted by PostgreSQL
+// for the password_encryption GUC
+var validPasswordEncryptionMethods = []string{"md5", "scram-sha-256"}
+
+// readPasswordFile reads a password from the passed file, removing
+// the trailing newline if present
+func readPasswordFile(fileName string) (string, error) {
+	content, err := fileutils.ReadFile(fileName)
+	if err != nil {
+		return "", fmt.Errorf("while reading password file %s: %w", fileName, err)
+	}
+
+	return strings.TrimRight(string(content), "\r\n"), nil
+}
+
+// superuserPasswordFile returns the file containing the superuser
+// password passed to initdb through the `--pwfile` option, if any
+func (info InitInfo) superuserPasswordFile() string {
+	for i, option := range info.InitDBOptions {
+		if fileName, found := strings.CutPrefix(option, "--pwfile="); found {
+			return fileName
+		}
+		if option == "--pwfile" && i+1 < len(info.InitDBOptions) {
+			return info.InitDBOptions[i+1]
+		}
+	}
+
+	return ""
+}
+
+// validatePasswordFiles ensures that the password files of the
+// superuser and of the application user, when set, contain a password
+func (info InitInfo) validatePasswordFiles() error {
+	if fileName := info.superuserPasswordFile(); fileName != "" {
+		if err := validatePasswordFileContent(fileName); err != nil {
+			return fmt.Errorf("invalid superuser password file: %w", err)
+		}
+	}
+
+	if info.ApplicationPasswordFile != "" && !info.SkipApplicationSetup {
+		if err := validatePasswordFileContent(info.ApplicationPasswordFile); err != nil {
+			return fmt.Errorf("invalid application password file: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// validatePasswordFileContent ensures that the first line of the passed
+// file, which is the password read by initdb, is not empty and is made
+// only of printable characters. The password itself is never reported
+func validatePasswordFileContent(fileName string) error {
+	content, err := os.ReadFile(fileName) // #nosec
+	if err != nil {
+		return fmt.Errorf("while reading password file %s: %w", fileName, err)
+	}
+
+	firstLine, _, _ := strings.Cut(string(content), "\n")
+	password := strings.TrimSuffix(firstLine, "\r")
+	if password == "" {
+		return fmt.Errorf("the password file %s is empty", fileName)
+	}
+	if !utf8.ValidString(password) {
+		return fmt.Errorf("the password in %s is not valid UTF-8 text", fileName)
+	}
+	for _, r := range password {
+		if !unicode.IsPrint(r) {
+			return fmt.Errorf("the password in %s contains the non-printable character %U", fileName, r)
+		}
+	}
+
+	return nil
+}
+
+// validatePasswordEncryption checks if the passed method is
+// accepted by PostgreSQL as password_encryption
+func validatePasswordEncryption(method string) error {
+	for _, validMethod := range validPasswordEncryptionMethods {
+		if method == validMethod {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("invalid password encryption method %q, expected one of %v",
+		method, validPasswordEncryptionMethods)
+}
+
+// UpdateApplicationPassword sets the password of the application user
+// to the content of the application password file. It can be used both
+// during the bootstrap and every time the underlying secret changes
+func (info InitInfo) UpdateApplicationPassword(ctx context.Context, db *sql.DB) error {
+	contextLogger := log.FromContext(ctx)
+
+	if info.ApplicationPasswordFile == "" {
+		return fmt.Errorf("missing application password file")
+	}
+
+	if info.PasswordEncryption != "" {
+		if err := validatePasswordEncryption(info.PasswordEncryption); err != nil {
+			return err
+		}
+	}
+
+	password, err := readPasswordFile(info.ApplicationPasswordFile)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// This has no effect if the transaction
+		// is committed
+		_ = tx.Rollback()
+	}()
+
+	if info.PasswordEncryption != "" {
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("SET LOCAL password_encryption = %s",
+			pq.QuoteLiteral(info.PasswordEncryption))); err != nil {
+			return fmt.Errorf("while setting password_encryption: %w", err)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("ALTER ROLE %v WITH PASSWORD %v",
+		pgx.Identifier{info.ApplicationUser}.Sanitize(),
+		pq.QuoteLiteral(password))); err != nil {
+		return fmt.Errorf("while running ALTER ROLE %v WITH PASSWORD: %w", info.ApplicationUser, err)
+	}
+
+	contextLogger.Info("Updated the application user password",
+		"user", info.ApplicationUser,
+		"passwordEncryption", info.PasswordEncryption)
+	return tx.Commit()
+}
@@ -0,0 +1,194 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/cloudnative-pg/machinery/pkg/log"
+)
+
+// ErrPasswordNotProvided is returned by a PasswordProvider which
+// doesn't manage the password of the requested role
+var ErrPasswordNotProvided = errors.New("password not provided")
+
+// PasswordProvider fetches the passwords of the roles created during
+// the bootstrap from an external secret manager, like Vault
+type PasswordProvider interface {
+	// GetPassword returns the password of the passed role, or
+	// ErrPasswordNotProvided when the role is not managed
+	GetPassword(ctx context.Context, role string) (string, error)
+}
+
+// fetchProvidedPasswords reads the passwords of the superuser and of the
+// application user from the PasswordProvider, when no password file has
+// been set for them. The passwords are written in temporary files, readable
+// only by the current user, which are removed by the returned function
+func (info *InitInfo) fetchProvidedPasswords(ctx context.Context) (cleanup func(), err error) {
+	var passwordFiles []string
+	cleanup = func() {
+		for _, fileName := range passwordFiles {
+			if err := os.Remove(fileName); err != nil && !os.IsNotExist(err) {
+				log.FromContext(ctx).Warning("Error while removing a temporary password file",
+					"file", fileName, "err", err)
+			}
+		}
+	}
+
+	if info.PasswordProvider == nil {
+		return cleanup, nil
+	}
+
+	fetch := func(role string) (string, error) {
+		password, err := info.PasswordProvider.GetPassword(ctx, role)
+		if errors.Is(err, ErrPasswordNotProvided) {
+			return "", nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("while fetching the password of %s: %w", role, err)
+		}
+
+		fileName, err := writePasswordFile(password)
+		if err != nil {
+			return "", err
+		}
+		passwordFiles = append(passwordFiles, fileName)
+		return fileName, nil
+	}
+
+	hasPasswordFile := slices.ContainsFunc(info.InitDBOptions, func(option string) bool {
+		return strings.HasPrefix(option, "--pwfile")
+	})
+	if !hasPasswordFile {
+		fileName, err := fetch("postgres")
+		if err != nil {
+			cleanup()
+			return nil, err
+		}
+		if fileName != "" {
+			info.InitDBOptions = append(slices.Clone(info.InitDBOptions), "--pwfile="+fileName)
+		}
+	}
+
+	if info.ApplicationPasswordFile == "" && info.ApplicationUser != "" && !info.SkipApplicationSetup {
+		fileName, err := fetch(info.ApplicationUser)
+		if err != nil {
+			cleanup()
+			return nil, err
+		}
+		info.ApplicationPasswordFile = fileName
+	}
+
+	return cleanup, nil
+}
+
+// writePasswordFile writes the passed password in a new temporary
+// file, readable only by the current user
+func writePasswordFile(password string) (string, error) {
+	file, err := os.CreateTemp("", "cnpg-password-*")
+	if err != nil {
+		return "", fmt.Errorf("while creating a temporary password file: %w", err)
+	}
+
+	_, err = file.WriteString(password + "\n")
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(file.Name())
+		return "", fmt.Errorf("while writing a temporary password file: %w", err)
+	}
+
+	return file.Name(), nil
+}
+
+// generatedPasswordBytes is the number of random bytes of the generated
+// passwords, which are base64 encoded
+const generatedPasswordBytes = 32
+
+// validateApplicationPasswordGeneration ensures the generated
+// application password can be used and persisted
+func (info InitInfo) validateApplicationPasswordGeneration() error {
+	if !info.GenerateApplicationPassword {
+		return nil
+	}
+
+	if info.SkipApplicationSetup || info.ApplicationUser == "" {
+		return fmt.Errorf("the generation of the application password requires the application user")
+	}
+
+	if info.GeneratedApplicationPasswordFile == "" {
+		return fmt.Errorf("the generation of the application password requires the file to write it in")
+	}
+
+	return nil
+}
+
+// generateApplicationPassword generates a random password for the
+// application user, when requested and no password has been set otherwise.
+// The password is written in GeneratedApplicationPasswordFile, which is
+// readable only by the current user and becomes the application password file
+func (info *InitInfo) generateApplicationPassword(ctx context.Context) error {
+	if !info.GenerateApplicationPassword || info.ApplicationPasswordFile != "" {
+		return nil
+	}
+
+	password, err := generatePassword()
+	if err != nil {
+		return err
+	}
+
+	// The file is never overwritten, as it could be the only
+	// copy of a password already in use
+	// #nosec
+	file, err := os.OpenFile(info.GeneratedApplicationPasswordFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return fmt.Errorf("while creating the generated application password file: %w", err)
+	}
+
+	_, err = file.WriteString(password + "\n")
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(info.GeneratedApplicationPasswordFile)
+		return fmt.Errorf("while writing the generated application password file: %w", err)
+	}
+
+	log.FromContext(ctx).Info("Generated the password of the application user",
+		"user", info.ApplicationUser, "file", info.GeneratedApplicationPasswordFile)
+	info.ApplicationPasswordFile = info.GeneratedApplicationPasswordFile
+	return nil
+}
+
+// generatePassword returns a cryptographically strong random password,
+// made only of URL-safe base64 characters
+func generatePassword() (string, error) {
+	buffer := make([]byte, generatedPasswordBytes)
+	if _, err := rand.Read(buffer); err != nil {
+		return "", fmt.Errorf("while generating a password: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(buffer), nil
+}
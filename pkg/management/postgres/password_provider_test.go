@@ -0,0 +1,222 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cloudnative-pg/machinery/pkg/fileutils"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type fakePasswordProvider struct {
+	passwords map[string]string
+	err       error
+}
+
+func (provider fakePasswordProvider) GetPassword(_ context.Context, role string) (string, error) {
+	if provider.err != nil {
+		return "", provider.err
+	}
+	password, found := provider.passwords[role]
+	if !found {
+		return "", ErrPasswordNotProvided
+	}
+	return password, nil
+}
+
+var _ = Describe("password provider", func() {
+	It("is a no-op without a provider", func(ctx SpecContext) {
+		info := InitInfo{ApplicationUser: "app"}
+		cleanup, err := info.fetchProvidedPasswords(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		defer cleanup()
+
+		Expect(info.ApplicationPasswordFile).To(BeEmpty())
+		Expect(info.InitDBOptions).To(BeEmpty())
+	})
+
+	It("writes the provided passwords in temporary files", func(ctx SpecContext) {
+		info := InitInfo{
+			ApplicationUser: "app",
+			InitDBOptions:   []string{"--encoding=UTF8"},
+			PasswordProvider: fakePasswordProvider{passwords: map[string]string{
+				"postgres": "superpassword",
+				"app":      "apppassword",
+			}},
+		}
+
+		cleanup, err := info.fetchProvidedPasswords(ctx)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(info.InitDBOptions).To(HaveLen(2))
+		Expect(info.InitDBOptions[1]).To(HavePrefix("--pwfile="))
+		superuserFile := strings.TrimPrefix(info.InitDBOptions[1], "--pwfile=")
+		Expect(readPasswordFile(superuserFile)).To(Equal("superpassword"))
+		Expect(readPasswordFile(info.ApplicationPasswordFile)).To(Equal("apppassword"))
+
+		stat, err := os.Stat(info.ApplicationPasswordFile)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stat.Mode().Perm()).To(Equal(os.FileMode(0o600)))
+
+		cleanup()
+		Expect(superuserFile).ToNot(BeAnExistingFile())
+		Expect(info.ApplicationPasswordFile).ToNot(BeAnExistingFile())
+	})
+
+	It("prefers the configured password files", func(ctx SpecContext) {
+		passwordFile := filepath.Join(GinkgoT().TempDir(), "password")
+		_, err := fileutils.WriteStringToFile(passwordFile, "filepassword")
+		Expect(err).ToNot(HaveOccurred())
+
+		info := InitInfo{
+			ApplicationUser:         "app",
+			ApplicationPasswordFile: passwordFile,
+			InitDBOptions:           []string{"--pwfile=/etc/superuser"},
+			PasswordProvider: fakePasswordProvider{passwords: map[string]string{
+				"postgres": "superpassword",
+				"app":      "apppassword",
+			}},
+		}
+
+		cleanup, err := info.fetchProvidedPasswords(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		defer cleanup()
+
+		Expect(info.ApplicationPasswordFile).To(Equal(passwordFile))
+		Expect(info.InitDBOptions).To(Equal([]string{"--pwfile=/etc/superuser"}))
+	})
+
+	It("skips the roles not managed by the provider", func(ctx SpecContext) {
+		info := InitInfo{
+			ApplicationUser:  "app",
+			PasswordProvider: fakePasswordProvider{passwords: map[string]string{"app": "apppassword"}},
+		}
+
+		cleanup, err := info.fetchProvidedPasswords(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		defer cleanup()
+
+		Expect(info.InitDBOptions).To(BeEmpty())
+		Expect(readPasswordFile(info.ApplicationPasswordFile)).To(Equal("apppassword"))
+	})
+
+	It("doesn't fetch the application password when the application setup is skipped", func(ctx SpecContext) {
+		info := InitInfo{
+			ApplicationUser:      "app",
+			SkipApplicationSetup: true,
+			PasswordProvider:     fakePasswordProvider{passwords: map[string]string{"app": "apppassword"}},
+		}
+
+		cleanup, err := info.fetchProvidedPasswords(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		defer cleanup()
+
+		Expect(info.ApplicationPasswordFile).To(BeEmpty())
+	})
+
+	It("reports the provider errors", func(ctx SpecContext) {
+		info := InitInfo{
+			ApplicationUser:  "app",
+			PasswordProvider: fakePasswordProvider{err: errors.New("vault is sealed")},
+		}
+
+		_, err := info.fetchProvidedPasswords(ctx)
+		Expect(err).To(MatchError(ContainSubstring("vault is sealed")))
+	})
+})
+
+var _ = Describe("application password generation", func() {
+	var outputFile string
+
+	BeforeEach(func() {
+		outputFile = filepath.Join(GinkgoT().TempDir(), "app-password")
+	})
+
+	It("generates a strong password readable only by the current user", func(ctx SpecContext) {
+		info := InitInfo{
+			ApplicationUser:                  "app",
+			GenerateApplicationPassword:      true,
+			GeneratedApplicationPasswordFile: outputFile,
+		}
+		Expect(info.validateApplicationPasswordGeneration()).To(Succeed())
+		Expect(info.generateApplicationPassword(ctx)).To(Succeed())
+		Expect(info.ApplicationPasswordFile).To(Equal(outputFile))
+
+		stat, err := os.Stat(outputFile)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stat.Mode().Perm()).To(Equal(os.FileMode(0o600)))
+
+		password, err := readPasswordFile(outputFile)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(password).To(MatchRegexp(`^[A-Za-z0-9_-]{43}$`))
+		Expect(validatePasswordFileContent(outputFile)).To(Succeed())
+	})
+
+	It("generates a different password every time", func() {
+		first, err := generatePassword()
+		Expect(err).ToNot(HaveOccurred())
+		second, err := generatePassword()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(first).ToNot(Equal(second))
+	})
+
+	It("doesn't generate a password when a password file has been set", func(ctx SpecContext) {
+		info := InitInfo{
+			ApplicationUser:                  "app",
+			ApplicationPasswordFile:          "/etc/secrets/app",
+			GenerateApplicationPassword:      true,
+			GeneratedApplicationPasswordFile: outputFile,
+		}
+		Expect(info.generateApplicationPassword(ctx)).To(Succeed())
+		Expect(info.ApplicationPasswordFile).To(Equal("/etc/secrets/app"))
+		Expect(outputFile).ToNot(BeAnExistingFile())
+	})
+
+	It("never overwrites an existing file", func(ctx SpecContext) {
+		Expect(os.WriteFile(outputFile, []byte("previous\n"), 0o600)).To(Succeed())
+		info := InitInfo{
+			ApplicationUser:                  "app",
+			GenerateApplicationPassword:      true,
+			GeneratedApplicationPasswordFile: outputFile,
+		}
+		Expect(info.generateApplicationPassword(ctx)).ToNot(Succeed())
+		Expect(info.ApplicationPasswordFile).To(BeEmpty())
+		Expect(readPasswordFile(outputFile)).To(Equal("previous"))
+	})
+
+	It("requires the file to write the password in", func() {
+		info := InitInfo{ApplicationUser: "app", GenerateApplicationPassword: true}
+		Expect(info.validateApplicationPasswordGeneration()).To(MatchError(ContainSubstring("file")))
+	})
+
+	It("requires the application user", func() {
+		info := InitInfo{
+			ApplicationUser:                  "app",
+			SkipApplicationSetup:             true,
+			GenerateApplicationPassword:      true,
+			GeneratedApplicationPasswordFile: outputFile,
+		}
+		Expect(info.validateApplicationPasswordGeneration()).To(MatchError(ContainSubstring("application user")))
+	})
+})
@@ -0,0 +1,148 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"database/sql"
+	"path/filepath"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/cloudnative-pg/machinery/pkg/fileutils"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("application password rotation", func() {
+	var (
+		db           *sql.DB
+		mock         sqlmock.Sqlmock
+		passwordFile string
+	)
+
+	BeforeEach(func() {
+		var err error
+		db, mock, err = sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		Expect(err).ToNot(HaveOccurred())
+
+		passwordFile = filepath.Join(GinkgoT().TempDir(), "password")
+		_, err = fileutils.WriteStringToFile(passwordFile, "newpassword\n")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(mock.ExpectationsWereMet()).To(Succeed())
+	})
+
+	It("updates the password using the configured encryption method", func(ctx SpecContext) {
+		info := InitInfo{
+			ApplicationUser:         "app",
+			ApplicationPasswordFile: passwordFile,
+			PasswordEncryption:      "scram-sha-256",
+		}
+
+		mock.ExpectBegin()
+		mock.ExpectExec("SET LOCAL password_encryption = 'scram-sha-256'").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(`ALTER ROLE "app" WITH PASSWORD 'newpassword'`).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+
+		Expect(info.UpdateApplicationPassword(ctx, db)).To(Succeed())
+	})
+
+	It("uses the server default when no encryption method is set", func(ctx SpecContext) {
+		info := InitInfo{
+			ApplicationUser:         "app",
+			ApplicationPasswordFile: passwordFile,
+		}
+
+		mock.ExpectBegin()
+		mock.ExpectExec(`ALTER ROLE "app" WITH PASSWORD 'newpassword'`).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+
+		Expect(info.UpdateApplicationPassword(ctx, db)).To(Succeed())
+	})
+
+	It("rejects an invalid encryption method", func(ctx SpecContext) {
+		info := InitInfo{
+			ApplicationUser:         "app",
+			ApplicationPasswordFile: passwordFile,
+			PasswordEncryption:      "plain",
+		}
+
+		Expect(info.UpdateApplicationPassword(ctx, db)).ToNot(Succeed())
+	})
+})
+
+var _ = Describe("password file validation", func() {
+	var directory string
+
+	BeforeEach(func() {
+		directory = GinkgoT().TempDir()
+	})
+
+	writePasswordFile := func(content string) string {
+		fileName := filepath.Join(directory, "password")
+		_, err := fileutils.WriteStringToFile(fileName, content)
+		Expect(err).ToNot(HaveOccurred())
+		return fileName
+	}
+
+	DescribeTable("checks the first line of the superuser password file",
+		func(content string, message string) {
+			info := InitInfo{InitDBOptions: []string{"--pwfile=" + writePasswordFile(content)}}
+			err := info.VerifyConfiguration()
+			if message == "" {
+				Expect(err).ToNot(HaveOccurred())
+				return
+			}
+			Expect(err).To(MatchError(ContainSubstring("invalid superuser password file")))
+			Expect(err).To(MatchError(ContainSubstring(message)))
+		},
+		Entry("a valid password", "s3cr3t P@ss\n", ""),
+		Entry("a valid password without newline", "s3cr3t", ""),
+		Entry("a valid password with a CRLF line ending", "s3cr3t\r\nignored", ""),
+		Entry("an empty file", "", "is empty"),
+		Entry("an empty first line", "\nsecret\n", "is empty"),
+		Entry("a NUL character", "sec\x00ret\n", "non-printable character U+0000"),
+		Entry("a control character", "sec\tret\n", "non-printable character U+0009"),
+		Entry("binary content", "\xff\xfe\xfd\n", "not valid UTF-8"),
+	)
+
+	It("doesn't report the password in the error", func() {
+		info := InitInfo{InitDBOptions: []string{"--pwfile", writePasswordFile("topsecret\x01\n")}}
+		err := info.VerifyConfiguration()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).ToNot(ContainSubstring("topsecret"))
+	})
+
+	It("refuses a missing password file", func() {
+		info := InitInfo{InitDBOptions: []string{"--pwfile=" + filepath.Join(directory, "missing")}}
+		Expect(info.VerifyConfiguration()).To(MatchError(ContainSubstring("while reading password file")))
+	})
+
+	It("checks the application password file too", func() {
+		info := InitInfo{
+			ApplicationDatabase:     "app",
+			ApplicationUser:         "app",
+			ApplicationPasswordFile: writePasswordFile("\n"),
+		}
+		Expect(info.VerifyConfiguration()).To(MatchError(ContainSubstring("invalid application password file")))
+	})
+})
@@ -0,0 +1,87 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/cloudnative-pg/machinery/pkg/fileutils"
+
+	"github.com/cloudnative-pg/cloudnative-pg/pkg/utils"
+)
+
+// Role is the role an existing data directory has when PostgreSQL is started
+type Role string
+
+const (
+	// RolePrimary is a data directory accepting writes
+	RolePrimary Role = "primary"
+
+	// RoleStandby is a data directory continuously replaying
+	// WAL, as requested by the standby.signal file
+	RoleStandby Role = "standby"
+
+	// RoleInRecovery is a data directory running a targeted recovery,
+	// as requested by the recovery.signal file, or whose last recovery
+	// didn't complete
+	RoleInRecovery Role = "in-recovery"
+)
+
+// DetectRole returns the role of the data directory without starting
+// PostgreSQL, looking at the signal files and, when there are none, at
+// the cluster state reported by pg_controldata
+func (info InitInfo) DetectRole() (Role, error) {
+	return detectRole(info.PgData, info.GetInstance().GetPgControldata)
+}
+
+// detectRole is the implementation of DetectRole, reading the
+// pg_controldata output through the passed function
+func detectRole(pgData string, controldata func() (string, error)) (Role, error) {
+	if exists, err := fileutils.FileExists(filepath.Join(pgData, "PG_VERSION")); err != nil {
+		return "", err
+	} else if !exists {
+		return "", fmt.Errorf("%s is not a PostgreSQL data directory", pgData)
+	}
+
+	if exists, err := fileutils.FileExists(filepath.Join(pgData, "standby.signal")); err != nil {
+		return "", err
+	} else if exists {
+		return RoleStandby, nil
+	}
+
+	if exists, err := fileutils.FileExists(filepath.Join(pgData, "recovery.signal")); err != nil {
+		return "", err
+	} else if exists {
+		return RoleInRecovery, nil
+	}
+
+	output, err := controldata()
+	if err != nil {
+		return "", err
+	}
+
+	state := utils.ParsePgControldataOutput(output)[utils.PgControlDataDatabaseClusterStateKey]
+	switch state {
+	case "in production", "shut down", "shutting down":
+		return RolePrimary, nil
+	case "starting up", "in crash recovery", "in archive recovery", "shut down in recovery":
+		return RoleInRecovery, nil
+	}
+
+	return "", fmt.Errorf("unknown pg_controldata cluster state %q", state)
+}
@@ -0,0 +1,86 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("data directory role detection", func() {
+	var pgData string
+
+	BeforeEach(func() {
+		pgData = GinkgoT().TempDir()
+		Expect(os.WriteFile(filepath.Join(pgData, "PG_VERSION"), []byte("16\n"), 0o600)).To(Succeed())
+	})
+
+	controldataWithState := func(state string) func() (string, error) {
+		return func() (string, error) {
+			return "pg_control version number:            1300\n" +
+				"Database cluster state:               " + state + "\n" +
+				"Latest checkpoint's TimeLineID:       1\n", nil
+		}
+	}
+
+	failingControldata := func() (string, error) {
+		return "", errors.New("pg_controldata should not be invoked")
+	}
+
+	It("detects a standby from the standby.signal file", func() {
+		Expect(os.WriteFile(filepath.Join(pgData, "standby.signal"), nil, 0o600)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(pgData, "recovery.signal"), nil, 0o600)).To(Succeed())
+
+		Expect(detectRole(pgData, failingControldata)).To(Equal(RoleStandby))
+	})
+
+	It("detects a recovery from the recovery.signal file", func() {
+		Expect(os.WriteFile(filepath.Join(pgData, "recovery.signal"), nil, 0o600)).To(Succeed())
+
+		Expect(detectRole(pgData, failingControldata)).To(Equal(RoleInRecovery))
+	})
+
+	DescribeTable("uses the cluster state without signal files",
+		func(state string, expected Role) {
+			Expect(detectRole(pgData, controldataWithState(state))).To(Equal(expected))
+		},
+		Entry("a running primary", "in production", RolePrimary),
+		Entry("a cleanly shut down primary", "shut down", RolePrimary),
+		Entry("an interrupted crash recovery", "in crash recovery", RoleInRecovery),
+		Entry("an interrupted archive recovery", "in archive recovery", RoleInRecovery),
+		Entry("a shut down standby", "shut down in recovery", RoleInRecovery),
+	)
+
+	It("refuses an unknown cluster state", func() {
+		_, err := detectRole(pgData, controldataWithState("on vacation"))
+		Expect(err).To(MatchError(ContainSubstring(`unknown pg_controldata cluster state "on vacation"`)))
+	})
+
+	It("reports the pg_controldata failures", func() {
+		_, err := detectRole(pgData, failingControldata)
+		Expect(err).To(MatchError("pg_controldata should not be invoked"))
+	})
+
+	It("refuses a directory which is not a data directory", func() {
+		_, err := detectRole(GinkgoT().TempDir(), failingControldata)
+		Expect(err).To(MatchError(ContainSubstring("is not a PostgreSQL data directory")))
+	})
+})
@@ -21,6 +21,7 @@ package pool
 import (
 	"database/sql"
 	"fmt"
+	"time"
 
 	// this is needed to correctly open the sql connection with the pgx driver
 	_ "github.com/jackc/pgx/v5/stdlib"
@@ -38,6 +39,37 @@ type Pooler interface {
 	ShutdownConnections()
 }
 
+// ConnectionLimits is the configuration of the connections kept
+// by the pool for every database
+type ConnectionLimits struct {
+	// The maximum number of open connections to a database
+	MaxOpenConns int
+
+	// The maximum number of idle connections to a database
+	MaxIdleConns int
+
+	// The maximum amount of time a connection may be reused,
+	// zero meaning no limit
+	ConnMaxLifetime time.Duration
+}
+
+// DefaultConnectionLimits are the limits used when none are specified.
+//
+// This is the list of long-running processes of the instance manager
+// that need a PostgreSQL connection:
+//
+// * Declarative Role Management
+// * Probes
+// * Replication slots reconciler
+// * Online VolumeSnapshot backup connection
+//
+// The latter will use an exclusive connection, that is required
+// for the PostgreSQL Physical backup APIs
+var DefaultConnectionLimits = ConnectionLimits{
+	MaxOpenConns: 3,
+	MaxIdleConns: 0,
+}
+
 // ConnectionPool is a repository of DB connections, pointing to the same instance
 // given a base DSN without the "dbname" parameter
 type ConnectionPool struct {
@@ -47,6 +79,9 @@ type ConnectionPool struct {
 	// The configuration to be used
 	connectionProfile ConnectionProfile
 
+	// The limits applied to every connection
+	connectionLimits ConnectionLimits
+
 	// A map of connection for every used database
 	connectionMap map[string]*sql.DB
 }
@@ -70,9 +105,26 @@ func newConnectionPool(baseConnectionString string, connectionProfile Connection
 		baseConnectionString: baseConnectionString,
 		connectionMap:        make(map[string]*sql.DB),
 		connectionProfile:    connectionProfile,
+		connectionLimits:     DefaultConnectionLimits,
 	}
 }
 
+// SetConnectionLimits changes the limits applied to connections,
+// including the ones that have already been created
+func (pool *ConnectionPool) SetConnectionLimits(limits ConnectionLimits) {
+	pool.connectionLimits = limits
+	for _, db := range pool.connectionMap {
+		pool.applyConnectionLimits(db)
+	}
+}
+
+// applyConnectionLimits applies the configured limits to a database connection
+func (pool *ConnectionPool) applyConnectionLimits(db *sql.DB) {
+	db.SetMaxOpenConns(pool.connectionLimits.MaxOpenConns)
+	db.SetMaxIdleConns(pool.connectionLimits.MaxIdleConns)
+	db.SetConnMaxLifetime(pool.connectionLimits.ConnMaxLifetime)
+}
+
 // Connection gets the connection for the given database
 func (pool *ConnectionPool) Connection(dbname string) (*sql.DB, error) {
 	if result, ok := pool.connectionMap[dbname]; ok {
@@ -106,19 +158,7 @@ func (pool *ConnectionPool) newConnection(dbname string) (*sql.DB, error) {
 		return nil, fmt.Errorf("cannot create connection connectionMap: %w", err)
 	}
 
-	// This is the list of long-running processes of the instance manager
-	// that need a PostgreSQL connection:
-	//
-	// * Declarative Role Management
-	// * Probes
-	// * Replication slots reconciler
-	// * Online VolumeSnapshot backup connection
-	//
-	// The latter will use an exclusive connection, that is required
-	// for the PostgreSQL Physical backup APIs
-
-	db.SetMaxOpenConns(3)
-	db.SetMaxIdleConns(0)
+	pool.applyConnectionLimits(db)
 
 	return db, nil
 }
@@ -50,4 +50,24 @@ var _ = Describe("Connection pool test", func() {
 		pool.ShutdownConnections()
 		Expect(pool.connectionMap).To(BeEmpty())
 	})
+
+	It("applies the default connection limits", func() {
+		pool := NewPostgresqlConnectionPool("host=127.0.0.1")
+		conn, err := pool.Connection("test")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(conn.Stats().MaxOpenConnections).To(Equal(DefaultConnectionLimits.MaxOpenConns))
+	})
+
+	It("applies custom connection limits to new and existing connections", func() {
+		pool := NewPostgresqlConnectionPool("host=127.0.0.1")
+		existing, err := pool.Connection("existing")
+		Expect(err).ToNot(HaveOccurred())
+
+		pool.SetConnectionLimits(ConnectionLimits{MaxOpenConns: 1, MaxIdleConns: 1})
+		Expect(existing.Stats().MaxOpenConnections).To(Equal(1))
+
+		created, err := pool.Connection("created")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(created.Stats().MaxOpenConnections).To(Equal(1))
+	})
 })
@@ -0,0 +1,346 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/cloudnative-pg/machinery/pkg/log"
+	"github.com/cloudnative-pg/machinery/pkg/stringset"
+	"github.com/jackc/pgx/v5"
+)
+
+// analyzeConcurrency is the maximum number of restored
+// databases analyzed at the same time
+const analyzeConcurrency = 4
+
+// systemDatabases are the databases created by initdb. They are never
+// dropped while pruning a restored instance and can't be used as the
+// application database
+var systemDatabases = stringset.From([]string{"postgres", "template0", "template1"})
+
+// pruneDatabases drops all the databases of a restored instance that are
+// not contained in the passed keep-list. System databases are always kept.
+func pruneDatabases(ctx context.Context, db *sql.DB, keepDatabases []string) error {
+	contextLogger := log.FromContext(ctx)
+
+	rows, err := db.QueryContext(ctx, "SELECT datname FROM pg_catalog.pg_database")
+	if err != nil {
+		return fmt.Errorf("while listing the restored databases: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	existingDatabases := stringset.New()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		existingDatabases.Put(name)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	keepSet := stringset.From(keepDatabases)
+	var missingDatabases []string
+	for _, name := range keepSet.ToSortedList() {
+		if !existingDatabases.Has(name) {
+			missingDatabases = append(missingDatabases, name)
+		}
+	}
+	if len(missingDatabases) > 0 {
+		return fmt.Errorf("databases to be kept are not present in the backup: %v", missingDatabases)
+	}
+
+	for _, name := range existingDatabases.ToSortedList() {
+		if keepSet.Has(name) || systemDatabases.Has(name) {
+			continue
+		}
+
+		contextLogger.Info("Dropping database not contained in the keep-list", "database", name)
+		if _, err := db.ExecContext(ctx, fmt.Sprintf("DROP DATABASE %s",
+			pgx.Identifier{name}.Sanitize())); err != nil {
+			return fmt.Errorf("while dropping database %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// dropReplicationSlots drops all the replication slots of a restored
+// instance. They belong to the topology of the source cluster and, being
+// never consumed, would retain WAL files until the disk is full
+func dropReplicationSlots(ctx context.Context, db *sql.DB) error {
+	contextLogger := log.FromContext(ctx)
+
+	rows, err := db.QueryContext(ctx, "SELECT slot_name, slot_type FROM pg_catalog.pg_replication_slots")
+	if err != nil {
+		return fmt.Errorf("while listing the restored replication slots: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	type replicationSlot struct {
+		name     string
+		slotType string
+	}
+	var slots []replicationSlot
+	for rows.Next() {
+		var slot replicationSlot
+		if err := rows.Scan(&slot.name, &slot.slotType); err != nil {
+			return err
+		}
+		slots = append(slots, slot)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, slot := range slots {
+		contextLogger.Info("Dropping stale replication slot", "slot", slot.name, "type", slot.slotType)
+		if _, err := db.ExecContext(ctx, "SELECT pg_catalog.pg_drop_replication_slot($1)", slot.name); err != nil {
+			return fmt.Errorf("while dropping replication slot %s: %w", slot.name, err)
+		}
+	}
+
+	return nil
+}
+
+// analyzeDatabases refreshes the planner statistics of every database of a
+// restored instance, which are stale until autovacuum catches up. The
+// databases are analyzed concurrently, running VACUUM ANALYZE when vacuum
+// is set. The connections are obtained from connect before starting
+func analyzeDatabases(
+	ctx context.Context,
+	db *sql.DB,
+	connect func(dbname string) (*sql.DB, error),
+	vacuum bool,
+) error {
+	contextLogger := log.FromContext(ctx)
+
+	names, connections, err := connectRestoredDatabases(ctx, db, connect)
+	if err != nil {
+		return err
+	}
+
+	statement := "ANALYZE"
+	if vacuum {
+		statement = "VACUUM ANALYZE"
+	}
+
+	errs := make([]error, len(names))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for range min(analyzeConcurrency, len(names)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				contextLogger.Info("Refreshing the planner statistics", "database", names[index],
+					"statement", statement)
+				if _, err := connections[index].ExecContext(ctx, statement); err != nil {
+					errs[index] = fmt.Errorf("while analyzing database %s: %w", names[index], err)
+				}
+			}
+		}()
+	}
+
+	for index := range names {
+		indexes <- index
+	}
+	close(indexes)
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// connectRestoredDatabases lists the databases of a restored instance
+// accepting connections, excluding the templates, and connects to each
+// of them using connect
+func connectRestoredDatabases(
+	ctx context.Context,
+	db *sql.DB,
+	connect func(dbname string) (*sql.DB, error),
+) ([]string, []*sql.DB, error) {
+	rows, err := db.QueryContext(ctx,
+		"SELECT datname FROM pg_catalog.pg_database WHERE datallowconn AND NOT datistemplate")
+	if err != nil {
+		return nil, nil, fmt.Errorf("while listing the restored databases: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, nil, err
+		}
+		names = append(names, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	connections := make([]*sql.DB, len(names))
+	for index, name := range names {
+		if connections[index], err = connect(name); err != nil {
+			return nil, nil, fmt.Errorf("while connecting to database %s: %w", name, err)
+		}
+	}
+
+	return names, connections, nil
+}
+
+// collationMismatch is a collation whose version, as recorded in the
+// restored catalog, differs from the one of the running locale library.
+// An empty collation stands for the default collation of the database
+type collationMismatch struct {
+	database        string
+	collation       string
+	recordedVersion string
+	actualVersion   string
+}
+
+// CollationMismatchError is raised when the locale library of the restored
+// instance differs from the one used by the backed-up cluster, i.e. after a
+// glibc upgrade. The indexes depending on the affected collations can be
+// silently corrupted and need to be rebuilt
+type CollationMismatchError struct {
+	mismatches []collationMismatch
+}
+
+// Error implements the error interface
+func (e *CollationMismatchError) Error() string {
+	descriptions := make([]string, len(e.mismatches))
+	for index, mismatch := range e.mismatches {
+		if mismatch.collation == "" {
+			descriptions[index] = fmt.Sprintf("the default collation of database %s (recorded %s, actual %s)",
+				mismatch.database, mismatch.recordedVersion, mismatch.actualVersion)
+			continue
+		}
+		descriptions[index] = fmt.Sprintf("%s in database %s (recorded %s, actual %s)",
+			mismatch.collation, mismatch.database, mismatch.recordedVersion, mismatch.actualVersion)
+	}
+
+	return fmt.Sprintf("the collation versions of the restored instance don't match the locale library: %s. "+
+		"The indexes depending on them may be corrupted: run REINDEX on them and then "+
+		"ALTER COLLATION ... REFRESH VERSION, or ALTER DATABASE ... REFRESH COLLATION VERSION "+
+		"for the default collation of a database", strings.Join(descriptions, ", "))
+}
+
+// checkCollationVersions compares the version of the collations recorded
+// in every database of a restored instance with the one provided by the
+// current locale library, returning a CollationMismatchError when they differ.
+// Since PostgreSQL 15 the default collation of every database is checked too
+func checkCollationVersions(
+	ctx context.Context,
+	db *sql.DB,
+	connect func(dbname string) (*sql.DB, error),
+	majorVersion int,
+) error {
+	names, connections, err := connectRestoredDatabases(ctx, db, connect)
+	if err != nil {
+		return err
+	}
+
+	var mismatches []collationMismatch
+	for index, name := range names {
+		rows, err := connections[index].QueryContext(ctx,
+			"SELECT collname, collversion, pg_catalog.pg_collation_actual_version(oid) "+
+				"FROM pg_catalog.pg_collation "+
+				"WHERE collversion IS DISTINCT FROM pg_catalog.pg_collation_actual_version(oid) "+
+				"AND collversion IS NOT NULL ORDER BY collname")
+		if err != nil {
+			return fmt.Errorf("while checking the collation versions of database %s: %w", name, err)
+		}
+
+		for rows.Next() {
+			mismatch := collationMismatch{database: name}
+			var actualVersion sql.NullString
+			if err := rows.Scan(&mismatch.collation, &mismatch.recordedVersion, &actualVersion); err != nil {
+				_ = rows.Close()
+				return err
+			}
+			mismatch.actualVersion = actualVersion.String
+			mismatches = append(mismatches, mismatch)
+		}
+		err = rows.Err()
+		_ = rows.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	if majorVersion >= 15 {
+		databaseMismatches, err := checkDatabaseCollationVersions(ctx, db)
+		if err != nil {
+			return err
+		}
+		mismatches = append(mismatches, databaseMismatches...)
+	}
+
+	if len(mismatches) > 0 {
+		return &CollationMismatchError{mismatches: mismatches}
+	}
+
+	return nil
+}
+
+// checkDatabaseCollationVersions compares the version of the default
+// collation recorded for every database accepting connections with the
+// one provided by the current locale library. It requires PostgreSQL 15
+func checkDatabaseCollationVersions(ctx context.Context, db *sql.DB) ([]collationMismatch, error) {
+	rows, err := db.QueryContext(ctx,
+		"SELECT datname, datcollversion, pg_catalog.pg_database_collation_actual_version(oid) "+
+			"FROM pg_catalog.pg_database "+
+			"WHERE datallowconn "+
+			"AND datcollversion IS DISTINCT FROM pg_catalog.pg_database_collation_actual_version(oid) "+
+			"AND datcollversion IS NOT NULL ORDER BY datname")
+	if err != nil {
+		return nil, fmt.Errorf("while checking the collation versions of the databases: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var mismatches []collationMismatch
+	for rows.Next() {
+		var mismatch collationMismatch
+		var actualVersion sql.NullString
+		if err := rows.Scan(&mismatch.database, &mismatch.recordedVersion, &actualVersion); err != nil {
+			return nil, err
+		}
+		mismatch.actualVersion = actualVersion.String
+		mismatches = append(mismatches, mismatch)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return mismatches, nil
+}
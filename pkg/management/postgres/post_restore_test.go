@@ -0,0 +1,256 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("post-restore steps", func() {
+	var (
+		db   *sql.DB
+		mock sqlmock.Sqlmock
+	)
+
+	BeforeEach(func() {
+		var err error
+		db, mock, err = sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(mock.ExpectationsWereMet()).To(Succeed())
+	})
+
+	Context("pruning databases", func() {
+		databaseRows := func() *sqlmock.Rows {
+			return sqlmock.NewRows([]string{"datname"}).
+				AddRow("postgres").
+				AddRow("template0").
+				AddRow("template1").
+				AddRow("tenant1").
+				AddRow("tenant2").
+				AddRow("tenant3")
+		}
+
+		It("drops the databases not in the keep-list", func(ctx SpecContext) {
+			mock.ExpectQuery("SELECT datname FROM pg_catalog.pg_database").WillReturnRows(databaseRows())
+			mock.ExpectExec(`DROP DATABASE "tenant1"`).WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectExec(`DROP DATABASE "tenant3"`).WillReturnResult(sqlmock.NewResult(0, 0))
+
+			Expect(pruneDatabases(ctx, db, []string{"tenant2"})).To(Succeed())
+		})
+
+		It("fails when a database in the keep-list is not present", func(ctx SpecContext) {
+			mock.ExpectQuery("SELECT datname FROM pg_catalog.pg_database").WillReturnRows(databaseRows())
+
+			err := pruneDatabases(ctx, db, []string{"tenant2", "tenant4"})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("tenant4"))
+		})
+	})
+
+	Context("dropping stale replication slots", func() {
+		It("drops every restored slot", func(ctx SpecContext) {
+			mock.ExpectQuery("SELECT slot_name, slot_type FROM pg_catalog.pg_replication_slots").
+				WillReturnRows(sqlmock.NewRows([]string{"slot_name", "slot_type"}).
+					AddRow("_cnpg_source_2", "physical").
+					AddRow("debezium", "logical"))
+			mock.ExpectExec("SELECT pg_catalog.pg_drop_replication_slot($1)").
+				WithArgs("_cnpg_source_2").WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectExec("SELECT pg_catalog.pg_drop_replication_slot($1)").
+				WithArgs("debezium").WillReturnResult(sqlmock.NewResult(0, 0))
+
+			Expect(dropReplicationSlots(ctx, db)).To(Succeed())
+		})
+
+		It("does nothing without slots", func(ctx SpecContext) {
+			mock.ExpectQuery("SELECT slot_name, slot_type FROM pg_catalog.pg_replication_slots").
+				WillReturnRows(sqlmock.NewRows([]string{"slot_name", "slot_type"}))
+
+			Expect(dropReplicationSlots(ctx, db)).To(Succeed())
+		})
+	})
+
+	Context("analyzing the restored databases", func() {
+		const listQuery = "SELECT datname FROM pg_catalog.pg_database WHERE datallowconn AND NOT datistemplate"
+
+		var databases map[string]sqlmock.Sqlmock
+		var connect func(string) (*sql.DB, error)
+
+		BeforeEach(func() {
+			databases = make(map[string]sqlmock.Sqlmock)
+			connections := make(map[string]*sql.DB)
+			for _, name := range []string{"postgres", "app", "tenant1"} {
+				conn, connMock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+				Expect(err).ToNot(HaveOccurred())
+				databases[name] = connMock
+				connections[name] = conn
+			}
+			connect = func(name string) (*sql.DB, error) {
+				conn, ok := connections[name]
+				if !ok {
+					return nil, fmt.Errorf("unknown database %s", name)
+				}
+				return conn, nil
+			}
+		})
+
+		databaseRows := func() *sqlmock.Rows {
+			return sqlmock.NewRows([]string{"datname"}).AddRow("postgres").AddRow("app").AddRow("tenant1")
+		}
+
+		It("runs ANALYZE in every database", func(ctx SpecContext) {
+			mock.ExpectQuery(listQuery).WillReturnRows(databaseRows())
+			for _, connMock := range databases {
+				connMock.ExpectExec("ANALYZE").WillReturnResult(sqlmock.NewResult(0, 0))
+			}
+
+			Expect(analyzeDatabases(ctx, db, connect, false)).To(Succeed())
+			for _, connMock := range databases {
+				Expect(connMock.ExpectationsWereMet()).To(Succeed())
+			}
+		})
+
+		It("runs VACUUM ANALYZE when requested", func(ctx SpecContext) {
+			mock.ExpectQuery(listQuery).WillReturnRows(databaseRows())
+			for _, connMock := range databases {
+				connMock.ExpectExec("VACUUM ANALYZE").WillReturnResult(sqlmock.NewResult(0, 0))
+			}
+
+			Expect(analyzeDatabases(ctx, db, connect, true)).To(Succeed())
+			for _, connMock := range databases {
+				Expect(connMock.ExpectationsWereMet()).To(Succeed())
+			}
+		})
+
+		It("analyzes every database and reports the failures", func(ctx SpecContext) {
+			mock.ExpectQuery(listQuery).WillReturnRows(databaseRows())
+			databases["postgres"].ExpectExec("ANALYZE").WillReturnResult(sqlmock.NewResult(0, 0))
+			databases["app"].ExpectExec("ANALYZE").WillReturnError(errors.New("canceling statement"))
+			databases["tenant1"].ExpectExec("ANALYZE").WillReturnResult(sqlmock.NewResult(0, 0))
+
+			err := analyzeDatabases(ctx, db, connect, false)
+			Expect(err).To(MatchError(ContainSubstring("while analyzing database app")))
+			for _, connMock := range databases {
+				Expect(connMock.ExpectationsWereMet()).To(Succeed())
+			}
+		})
+	})
+
+	Context("checking the collation versions", func() {
+		const (
+			listQuery      = "SELECT datname FROM pg_catalog.pg_database WHERE datallowconn AND NOT datistemplate"
+			collationQuery = "SELECT collname, collversion, pg_catalog.pg_collation_actual_version(oid) " +
+				"FROM pg_catalog.pg_collation " +
+				"WHERE collversion IS DISTINCT FROM pg_catalog.pg_collation_actual_version(oid) " +
+				"AND collversion IS NOT NULL ORDER BY collname"
+			databaseCollationQuery = "SELECT datname, datcollversion, " +
+				"pg_catalog.pg_database_collation_actual_version(oid) " +
+				"FROM pg_catalog.pg_database " +
+				"WHERE datallowconn " +
+				"AND datcollversion IS DISTINCT FROM pg_catalog.pg_database_collation_actual_version(oid) " +
+				"AND datcollversion IS NOT NULL ORDER BY datname"
+		)
+
+		var postgresMock, appMock sqlmock.Sqlmock
+		var connect func(string) (*sql.DB, error)
+
+		BeforeEach(func() {
+			postgresConn, postgresConnMock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			Expect(err).ToNot(HaveOccurred())
+			appConn, appConnMock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			Expect(err).ToNot(HaveOccurred())
+			postgresMock, appMock = postgresConnMock, appConnMock
+			connect = func(name string) (*sql.DB, error) {
+				if name == "postgres" {
+					return postgresConn, nil
+				}
+				return appConn, nil
+			}
+
+			mock.ExpectQuery(listQuery).WillReturnRows(
+				sqlmock.NewRows([]string{"datname"}).AddRow("postgres").AddRow("app"))
+		})
+
+		collationColumns := []string{"collname", "collversion", "pg_collation_actual_version"}
+
+		It("succeeds when the versions match", func(ctx SpecContext) {
+			postgresMock.ExpectQuery(collationQuery).WillReturnRows(sqlmock.NewRows(collationColumns))
+			appMock.ExpectQuery(collationQuery).WillReturnRows(sqlmock.NewRows(collationColumns))
+
+			Expect(checkCollationVersions(ctx, db, connect, 14)).To(Succeed())
+			Expect(postgresMock.ExpectationsWereMet()).To(Succeed())
+			Expect(appMock.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("reports the mismatched collations with the REINDEX guidance", func(ctx SpecContext) {
+			postgresMock.ExpectQuery(collationQuery).WillReturnRows(sqlmock.NewRows(collationColumns))
+			appMock.ExpectQuery(collationQuery).WillReturnRows(sqlmock.NewRows(collationColumns).
+				AddRow("en-US-x-icu", "153.112", "153.120").
+				AddRow("en_US", "2.28", "2.36"))
+
+			err := checkCollationVersions(ctx, db, connect, 14)
+			var mismatchError *CollationMismatchError
+			Expect(errors.As(err, &mismatchError)).To(BeTrue())
+			Expect(err).To(MatchError(ContainSubstring("en_US in database app (recorded 2.28, actual 2.36)")))
+			Expect(err).To(MatchError(ContainSubstring("run REINDEX")))
+		})
+
+		It("reports the mismatched default collations of the databases", func(ctx SpecContext) {
+			postgresMock.ExpectQuery(collationQuery).WillReturnRows(sqlmock.NewRows(collationColumns))
+			appMock.ExpectQuery(collationQuery).WillReturnRows(sqlmock.NewRows(collationColumns).
+				AddRow("en_US", "2.28", "2.36"))
+			mock.ExpectQuery(databaseCollationQuery).WillReturnRows(
+				sqlmock.NewRows([]string{"datname", "datcollversion", "pg_database_collation_actual_version"}).
+					AddRow("app", "2.28", "2.36"))
+
+			err := checkCollationVersions(ctx, db, connect, 15)
+			var mismatchError *CollationMismatchError
+			Expect(errors.As(err, &mismatchError)).To(BeTrue())
+			Expect(err).To(MatchError(ContainSubstring("en_US in database app (recorded 2.28, actual 2.36)")))
+			Expect(err).To(MatchError(ContainSubstring(
+				"the default collation of database app (recorded 2.28, actual 2.36)")))
+			Expect(err).To(MatchError(ContainSubstring("ALTER DATABASE ... REFRESH COLLATION VERSION")))
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("doesn't check the default collations before PostgreSQL 15", func(ctx SpecContext) {
+			postgresMock.ExpectQuery(collationQuery).WillReturnRows(sqlmock.NewRows(collationColumns))
+			appMock.ExpectQuery(collationQuery).WillReturnRows(sqlmock.NewRows(collationColumns))
+
+			Expect(checkCollationVersions(ctx, db, connect, 14)).To(Succeed())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("fails when the versions can't be read", func(ctx SpecContext) {
+			postgresMock.ExpectQuery(collationQuery).WillReturnError(errors.New("connection lost"))
+
+			err := checkCollationVersions(ctx, db, connect, 14)
+			var mismatchError *CollationMismatchError
+			Expect(errors.As(err, &mismatchError)).To(BeFalse())
+			Expect(err).To(MatchError(ContainSubstring("database postgres")))
+		})
+	})
+})
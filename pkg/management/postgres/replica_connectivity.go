@@ -0,0 +1,61 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/cloudnative-pg/cloudnative-pg/pkg/management/postgres/pool"
+)
+
+// replicaConnectivityTimeout is the connect_timeout, in seconds, used
+// while verifying the connectivity of a new replica
+const replicaConnectivityTimeout = 5
+
+// openReplicationConnection opens a physical replication
+// connection using the passed connection string
+func openReplicationConnection(connInfo string) (*sql.DB, error) {
+	return pool.NewDBConnection(connInfo, pool.ConnectionProfilePostgresqlPhysicalReplication)
+}
+
+// verifyReplicaConnectivity checks that a replica can authenticate to the
+// primary with the passed primary_conninfo, opening the same physical
+// replication connection the WAL receiver will use. Such connections can't
+// change any data, and only the system identifier is read
+func verifyReplicaConnectivity(
+	ctx context.Context,
+	connInfo string,
+	open func(connInfo string) (*sql.DB, error),
+) error {
+	db, err := open(fmt.Sprintf("%s dbname=postgres connect_timeout=%d", connInfo, replicaConnectivityTimeout))
+	if err != nil {
+		return fmt.Errorf("while opening the replication connection to the primary: %w", err)
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+
+	rows, err := db.QueryContext(ctx, "IDENTIFY_SYSTEM")
+	if err != nil {
+		return fmt.Errorf("the replica can't connect to the primary with the configured primary_conninfo, "+
+			"check the credentials and the TLS certificates: %w", err)
+	}
+
+	return rows.Close()
+}
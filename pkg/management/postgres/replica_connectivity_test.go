@@ -0,0 +1,77 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("replica connectivity verification", func() {
+	It("succeeds when the primary accepts the replication connection", func(ctx SpecContext) {
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		Expect(err).ToNot(HaveOccurred())
+		mock.ExpectQuery("IDENTIFY_SYSTEM").WillReturnRows(
+			sqlmock.NewRows([]string{"systemid", "timeline", "xlogpos", "dbname"}).
+				AddRow("7380000000000000000", 1, "0/3000060", nil))
+		mock.ExpectClose()
+
+		var openedConnInfo string
+		open := func(connInfo string) (*sql.DB, error) {
+			openedConnInfo = connInfo
+			return db, nil
+		}
+
+		Expect(verifyReplicaConnectivity(ctx, "host=cluster-example-rw user=streaming_replica", open)).
+			To(Succeed())
+		Expect(openedConnInfo).To(Equal(
+			"host=cluster-example-rw user=streaming_replica dbname=postgres connect_timeout=5"))
+		Expect(mock.ExpectationsWereMet()).To(Succeed())
+	})
+
+	It("reports the authentication failures", func(ctx SpecContext) {
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		Expect(err).ToNot(HaveOccurred())
+		mock.ExpectQuery("IDENTIFY_SYSTEM").WillReturnError(
+			errors.New("certificate authentication failed for user \"streaming_replica\""))
+
+		err = verifyReplicaConnectivity(ctx, "host=cluster-example-rw", func(string) (*sql.DB, error) {
+			return db, nil
+		})
+		Expect(err).To(MatchError(ContainSubstring("certificate authentication failed")))
+		Expect(err).To(MatchError(ContainSubstring("check the credentials and the TLS certificates")))
+	})
+
+	It("fails when the primary is unreachable", func(ctx SpecContext) {
+		// Reserve a port and release it, so that nothing is listening there
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+		port := listener.Addr().(*net.TCPAddr).Port
+		Expect(listener.Close()).To(Succeed())
+
+		connInfo := fmt.Sprintf("host=127.0.0.1 port=%d user=streaming_replica sslmode=disable", port)
+		err = verifyReplicaConnectivity(ctx, connInfo, openReplicationConnection)
+		Expect(err).To(MatchError(ContainSubstring("can't connect to the primary")))
+	})
+})
@@ -45,6 +45,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/util/retry"
+	"k8s.io/utils/ptr"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	apiv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
@@ -55,6 +56,7 @@ import (
 	"github.com/cloudnative-pg/cloudnative-pg/pkg/management"
 	"github.com/cloudnative-pg/cloudnative-pg/pkg/management/external"
 	"github.com/cloudnative-pg/cloudnative-pg/pkg/management/postgres/constants"
+	postgresutils "github.com/cloudnative-pg/cloudnative-pg/pkg/management/postgres/utils"
 	postgresSpec "github.com/cloudnative-pg/cloudnative-pg/pkg/postgres"
 	"github.com/cloudnative-pg/cloudnative-pg/pkg/system"
 	"github.com/cloudnative-pg/cloudnative-pg/pkg/utils"
@@ -73,6 +75,10 @@ var (
 		Steps: math.MaxInt32,
 	}
 
+	// recoveryConsistencyPollInterval is the time between two checks
+	// of the recovery status of a restored instance
+	recoveryConsistencyPollInterval = time.Second
+
 	pgControldataSettingsToParamsMap = map[string]string{
 		"max_connections setting":      "max_connections",
 		"max_wal_senders setting":      "max_wal_senders",
@@ -233,20 +239,101 @@ func (info InitInfo) createBackupObjectForSnapshotRestore(
 
 // Restore restores a PostgreSQL cluster from a backup into the object storage
 func (info InitInfo) Restore(ctx context.Context) error {
+	return info.RestoreWithOptions(ctx, RestoreOptions{})
+}
+
+// RestoreBackup restores a PostgreSQL cluster from the backup with the
+// passed ID, or from the latest backup when empty
+func (info InitInfo) RestoreBackup(ctx context.Context, backupID string) error {
+	info.BackupID = backupID
+	return info.Restore(ctx)
+}
+
+// pendingRecovery is the recovery to be run on a restored data directory
+type pendingRecovery struct {
+	info    InitInfo
+	cluster *apiv1.Cluster
+	envs    []string
+}
+
+// RestoreWithOptions restores a PostgreSQL cluster from a backup into the object storage,
+// reporting the progress of the operation as requested in the options
+func (info InitInfo) RestoreWithOptions(ctx context.Context, options RestoreOptions) error {
+	if err := info.validateAtomicRestore(); err != nil {
+		return err
+	}
+
+	var recovery *pendingRecovery
+	restore := func(target InitInfo) (err error) {
+		recovery, err = target.prepareRecovery(ctx, options)
+		return err
+	}
+
+	if info.AtomicRestore {
+		// The recovery runs once the restored data directory is in place,
+		// so that a failure after the promotion never removes it
+		if err := info.restoreAtomically(ctx, restore); err != nil {
+			return err
+		}
+		if recovery != nil {
+			recovery.info.PgData = info.PgData
+		}
+	} else if err := restore(info); err != nil {
+		return err
+	}
+
+	// The recovery of a replica cluster is left to the instance manager
+	if recovery == nil {
+		return nil
+	}
+
+	options.report(RestoreProgress{Phase: RestorePhaseRecovery})
+	if err := recovery.info.ConfigureInstanceAfterRestore(ctx, recovery.cluster, recovery.envs); err != nil {
+		return err
+	}
+
+	options.report(RestoreProgress{Phase: RestorePhaseCompleted})
+	return nil
+}
+
+// prepareRecovery restores the data directory from the backup and writes
+// the configuration needed to recover it, returning the recovery to be run.
+// No recovery is returned for a replica cluster
+func (info InitInfo) prepareRecovery(ctx context.Context, options RestoreOptions) (*pendingRecovery, error) {
 	contextLogger := log.FromContext(ctx)
 	typedClient, err := management.NewControllerRuntimeClient()
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	if err := validateRecoveryEndCommand(info.RecoveryEndCommand, info.AllowUnsafeRecoveryEndCommand); err != nil {
+		return nil, err
+	}
+
+	if err := validateRestoreCommand(info.RestoreCommand); err != nil {
+		return nil, err
+	}
+
+	if err := info.validateLocalBackupPath(); err != nil {
+		return nil, err
 	}
 
 	cluster, err := info.loadCluster(ctx, typedClient)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	if _, err := info.recoveryTarget(cluster); err != nil {
+		return nil, err
+	}
+
+	if err := info.validatePromptPromotion(cluster); err != nil {
+		return nil, err
 	}
 
 	coredumpFilter := cluster.GetCoredumpFilter()
 	if err := system.SetCoredumpFilter(coredumpFilter); err != nil {
-		return err
+		return nil, err
 	}
 
 	if cluster.ShouldRecoveryCreateApplicationDatabase() {
@@ -258,14 +345,22 @@ func (info InitInfo) Restore(ctx context.Context) error {
 	var config string
 
 	// nolint:nestif
-	if pluginConfiguration := cluster.GetRecoverySourcePlugin(); pluginConfiguration != nil {
+	if info.LocalBackupPath != "" {
+		contextLogger.Info("Restore from a local backup path detected, proceeding...",
+			"path", info.LocalBackupPath)
+		conf, err := info.restoreFromLocalPath(ctx)
+		if err != nil {
+			return nil, err
+		}
+		config = conf
+	} else if pluginConfiguration := cluster.GetRecoverySourcePlugin(); pluginConfiguration != nil {
 		contextLogger.Info("Restore through plugin detected, proceeding...")
 		res, err := restoreViaPlugin(ctx, cluster, pluginConfiguration)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		if res == nil {
-			return errors.New("empty response from restoreViaPlugin, programmatic error")
+			return nil, errors.New("empty response from restoreViaPlugin, programmatic error")
 		}
 		envs = res.Envs
 		config = res.RestoreConfig
@@ -274,71 +369,82 @@ func (info InitInfo) Restore(ctx context.Context) error {
 		// otherwise, we stop creating the cluster
 		err = info.checkBackupDestination(ctx, typedClient, cluster)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		// If we need to download data from a backup, we do it
 		backup, env, err := info.loadBackup(ctx, typedClient, cluster)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		if err := info.ensureArchiveContainsLastCheckpointRedoWAL(ctx, cluster, env, backup); err != nil {
-			return err
+			return nil, err
 		}
 
-		if err := info.restoreDataDir(ctx, backup, env); err != nil {
-			return err
+		if err := info.restoreBaseBackup(ctx, backup, env, options); err != nil {
+			return nil, err
 		}
 
 		if _, err := info.restoreCustomWalDir(ctx); err != nil {
-			return err
+			return nil, err
+		}
+
+		if err := info.checkWALSegmentSize(backup); err != nil {
+			return nil, err
+		}
+
+		if err := info.verifyWALContinuity(ctx, cluster, env, backup); err != nil {
+			return nil, err
 		}
 
 		conf, err := getRestoreWalConfig(ctx, backup)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		config = conf
 		envs = env
 	}
 
 	if err := info.WriteInitialPostgresqlConf(ctx, cluster); err != nil {
-		return err
+		return nil, err
 	}
 	// we need a migration here, otherwise the server will not start up if
 	// we recover from a base which has postgresql.auto.conf
 	// the override.conf and include statement is present, what we need to do is to
 	// migrate the content
 	if _, err := info.GetInstance().migratePostgresAutoConfFile(ctx); err != nil {
-		return err
+		return nil, err
+	}
+	if err := info.resetAutoConf(ctx); err != nil {
+		return nil, err
 	}
 	if cluster.IsReplica() {
 		server, ok := cluster.ExternalCluster(cluster.Spec.ReplicaCluster.Source)
 		if !ok {
-			return fmt.Errorf("missing external cluster: %v", cluster.Spec.ReplicaCluster.Source)
+			return nil, fmt.Errorf("missing external cluster: %v", cluster.Spec.ReplicaCluster.Source)
 		}
 
 		connectionString, err := external.ConfigureConnectionToServer(
 			ctx, typedClient, info.Namespace, &server)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		// TODO: Using a replication slot on replica cluster is not supported (yet?)
 		_, err = UpdateReplicaConfiguration(info.PgData, connectionString, "")
-		return err
+		return nil, err
 	}
 
 	if err := info.WriteRestoreHbaConf(ctx); err != nil {
-		return err
+		return nil, err
 	}
 
 	if err := info.writeCustomRestoreWalConfig(cluster, config); err != nil {
-		return err
+		return nil, err
 	}
 
-	return info.ConfigureInstanceAfterRestore(ctx, cluster, envs)
+	return &pendingRecovery{info: info, cluster: cluster, envs: envs}, nil
 }
 
 func (info InitInfo) ensureArchiveContainsLastCheckpointRedoWAL(
@@ -420,25 +526,40 @@ func (info InitInfo) restoreCustomWalDir(ctx context.Context) (bool, error) {
 	return true, os.Symlink(info.PgWal, pgDataWal)
 }
 
+// restoreBaseBackup downloads the base backup inside the data directory.
+// In WAL-only mode, the data directory has already been restored
+// out-of-band and the download is skipped
+func (info InitInfo) restoreBaseBackup(
+	ctx context.Context,
+	backup *apiv1.Backup,
+	env []string,
+	options RestoreOptions,
+) error {
+	if info.WALOnly {
+		log.FromContext(ctx).Info("WAL-only restore requested, skipping the base backup download",
+			"pgdata", info.PgData)
+		return nil
+	}
+
+	if err := info.checkRestoreFreeSpace(ctx, backup, env); err != nil {
+		return err
+	}
+
+	options.report(RestoreProgress{Phase: RestorePhaseBaseBackupDownload})
+	return options.monitorDownloadProgress(ctx, info.PgData, func() error {
+		return info.restoreDataDir(ctx, backup, env)
+	})
+}
+
 // restoreDataDir restores PGDATA from an existing backup
 func (info InitInfo) restoreDataDir(ctx context.Context, backup *apiv1.Backup, env []string) error {
 	contextLogger := log.FromContext(ctx)
-	var options []string
 
-	if backup.Status.EndpointURL != "" {
-		options = append(options, "--endpoint-url", backup.Status.EndpointURL)
-	}
-	options = append(options, backup.Status.DestinationPath)
-	options = append(options, backup.Status.ServerName)
-	options = append(options, backup.Status.BackupID)
-
-	options, err := barmanCommand.AppendCloudProviderOptionsFromBackup(ctx, options, backup.Status.BarmanCredentials)
+	options, err := info.barmanCloudRestoreOptions(ctx, backup)
 	if err != nil {
 		return err
 	}
 
-	options = append(options, info.PgData)
-
 	contextLogger.Info("Starting barman-cloud-restore",
 		"options", options)
 
@@ -458,6 +579,26 @@ func (info InitInfo) restoreDataDir(ctx context.Context, backup *apiv1.Backup, e
 	return nil
 }
 
+// barmanCloudRestoreOptions returns the options of barman-cloud-restore
+// needed to download the passed backup in the data directory
+func (info InitInfo) barmanCloudRestoreOptions(ctx context.Context, backup *apiv1.Backup) ([]string, error) {
+	var options []string
+
+	if backup.Status.EndpointURL != "" {
+		options = append(options, "--endpoint-url", backup.Status.EndpointURL)
+	}
+	options = append(options, backup.Status.DestinationPath)
+	options = append(options, backup.Status.ServerName)
+	options = append(options, backup.Status.BackupID)
+
+	options, err := barmanCommand.AppendCloudProviderOptionsFromBackup(ctx, options, backup.Status.BarmanCredentials)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(options, info.PgData), nil
+}
+
 // loadCluster loads the cluster definition from the API server
 func (info InitInfo) loadCluster(ctx context.Context, typedClient client.Client) (*apiv1.Cluster, error) {
 	var cluster apiv1.Cluster
@@ -492,50 +633,21 @@ func (info InitInfo) loadBackupObjectFromExternalCluster(
 	cluster *apiv1.Cluster,
 ) (*apiv1.Backup, []string, error) {
 	contextLogger := log.FromContext(ctx)
-	sourceName := cluster.Spec.Bootstrap.Recovery.Source
-
-	if sourceName == "" {
-		return nil, nil, fmt.Errorf("recovery source not specified")
-	}
-
-	contextLogger.Info("Recovering from external cluster", "sourceName", sourceName)
-
-	server, found := cluster.ExternalCluster(sourceName)
-	if !found {
-		return nil, nil, fmt.Errorf("missing external cluster: %v", sourceName)
-	}
-	serverName := server.GetServerName()
-
-	env, err := barmanCredentials.EnvSetRestoreCloudCredentials(
-		ctx,
-		typedClient,
-		cluster.Namespace,
-		server.BarmanObjectStore,
-		os.Environ())
-	if err != nil {
-		return nil, nil, err
-	}
 
-	backupCatalog, err := barmanCommand.GetBackupList(ctx, server.BarmanObjectStore, serverName, env)
+	server, backupCatalog, env, err := loadExternalClusterCatalog(ctx, typedClient, cluster)
 	if err != nil {
 		return nil, nil, err
 	}
+	serverName := server.GetServerName()
 
 	// We are now choosing the right backup to restore
-	var targetBackup *barmanCatalog.BarmanBackup
-	if cluster.Spec.Bootstrap.Recovery != nil &&
-		cluster.Spec.Bootstrap.Recovery.RecoveryTarget != nil {
-		targetBackup, err = backupCatalog.FindBackupInfo(
-			cluster.Spec.Bootstrap.Recovery.RecoveryTarget,
-		)
-		if err != nil {
-			return nil, nil, err
-		}
-	} else {
-		targetBackup = backupCatalog.LatestBackupInfo()
+	var recoveryTarget *apiv1.RecoveryTarget
+	if cluster.Spec.Bootstrap.Recovery != nil {
+		recoveryTarget = cluster.Spec.Bootstrap.Recovery.RecoveryTarget
 	}
-	if targetBackup == nil {
-		return nil, nil, fmt.Errorf("no target backup found")
+	targetBackup, err := selectTargetBackup(backupCatalog, recoveryTarget, info.BackupID)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	contextLogger.Info("Target backup found", "backup", targetBackup)
@@ -567,6 +679,88 @@ func (info InitInfo) loadBackupObjectFromExternalCluster(
 	}, env, nil
 }
 
+// loadExternalClusterCatalog reads the catalog of the backups of the
+// external cluster the passed cluster is recovered from, returning it with
+// the external cluster and the environment needed to access its object store
+func loadExternalClusterCatalog(
+	ctx context.Context,
+	typedClient client.Client,
+	cluster *apiv1.Cluster,
+) (*apiv1.ExternalCluster, *barmanCatalog.Catalog, []string, error) {
+	var sourceName string
+	if cluster.Spec.Bootstrap != nil && cluster.Spec.Bootstrap.Recovery != nil {
+		sourceName = cluster.Spec.Bootstrap.Recovery.Source
+	}
+	if sourceName == "" {
+		return nil, nil, nil, fmt.Errorf("recovery source not specified")
+	}
+
+	log.FromContext(ctx).Info("Reading the backup catalog of the external cluster", "sourceName", sourceName)
+
+	server, found := cluster.ExternalCluster(sourceName)
+	if !found {
+		return nil, nil, nil, fmt.Errorf("missing external cluster: %v", sourceName)
+	}
+	if server.BarmanObjectStore == nil {
+		return nil, nil, nil, fmt.Errorf("the external cluster %v has no object store", sourceName)
+	}
+
+	env, err := barmanCredentials.EnvSetRestoreCloudCredentials(
+		ctx,
+		typedClient,
+		cluster.Namespace,
+		server.BarmanObjectStore,
+		os.Environ())
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	backupCatalog, err := barmanCommand.GetBackupList(ctx, server.BarmanObjectStore, server.GetServerName(), env)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return &server, backupCatalog, env, nil
+}
+
+// selectTargetBackup chooses the backup to be restored from the passed
+// catalog. The backup with the passed ID is used when not empty, otherwise
+// the one matching the recovery target, and the latest one as a fallback
+func selectTargetBackup(
+	backupCatalog *barmanCatalog.Catalog,
+	recoveryTarget *apiv1.RecoveryTarget,
+	backupID string,
+) (*barmanCatalog.BarmanBackup, error) {
+	if backupID != "" {
+		target := &apiv1.RecoveryTarget{}
+		if recoveryTarget != nil {
+			if recoveryTarget.BackupID != "" && recoveryTarget.BackupID != backupID {
+				return nil, fmt.Errorf("the backup ID %q conflicts with the one of the recovery target %q",
+					backupID, recoveryTarget.BackupID)
+			}
+			target = recoveryTarget.DeepCopy()
+		}
+		target.BackupID = backupID
+		recoveryTarget = target
+	}
+
+	var targetBackup *barmanCatalog.BarmanBackup
+	if recoveryTarget != nil {
+		var err error
+		targetBackup, err = backupCatalog.FindBackupInfo(recoveryTarget)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		targetBackup = backupCatalog.LatestBackupInfo()
+	}
+	if targetBackup == nil {
+		return nil, fmt.Errorf("no target backup found")
+	}
+
+	return targetBackup, nil
+}
+
 // loadBackupFromReference loads a backup object and the required credentials given the backup object resource
 func (info InitInfo) loadBackupFromReference(
 	ctx context.Context,
@@ -599,6 +793,11 @@ func (info InitInfo) loadBackupFromReference(
 		return nil, nil, err
 	}
 
+	if info.BackupID != "" && info.BackupID != backup.Status.BackupID {
+		return nil, nil, fmt.Errorf("the backup ID %q conflicts with the one of the backup %s, %q",
+			info.BackupID, backup.Name, backup.Status.BackupID)
+	}
+
 	contextLogger.Info("Recovering existing backup", "backup", backup)
 	return &backup, env, nil
 }
@@ -615,28 +814,108 @@ func (info InitInfo) writeRestoreWalConfig(
 	if err != nil {
 		return err
 	}
-	recoveryFileContents := fmt.Sprintf(
-		"%s\n"+
-			"%s",
-		conf,
-		cluster.Spec.Bootstrap.Recovery.RecoveryTarget.BuildPostgresOptions())
 
-	return info.writeRecoveryConfiguration(cluster, recoveryFileContents)
+	return info.writeCustomRestoreWalConfig(cluster, conf)
 }
 
 func (info InitInfo) writeCustomRestoreWalConfig(cluster *apiv1.Cluster, conf string) error {
+	recoveryFileContents, err := info.renderRecoveryConfiguration(cluster, conf)
+	if err != nil {
+		return err
+	}
+
+	return info.writeRecoveryConfiguration(cluster, recoveryFileContents)
+}
+
+// renderRecoveryConfiguration returns the recovery configuration made of
+// the passed WAL restore configuration, the recovery target of the cluster
+// and the restore options
+func (info InitInfo) renderRecoveryConfiguration(cluster *apiv1.Cluster, conf string) (string, error) {
+	recoveryTarget, err := info.recoveryTarget(cluster)
+	if err != nil {
+		return "", err
+	}
+
+	if err := info.validatePromptPromotion(cluster); err != nil {
+		return "", err
+	}
+
 	recoveryFileContents := fmt.Sprintf(
 		"%s\n"+
 			"%s",
 		conf,
-		cluster.Spec.Bootstrap.Recovery.RecoveryTarget.BuildPostgresOptions())
+		recoveryTarget.BuildPostgresOptions())
 
-	return info.writeRecoveryConfiguration(cluster, recoveryFileContents)
+	return info.recoveryConfiguration(recoveryFileContents)
+}
+
+// recoveryTarget returns the recovery target of the cluster. When
+// TargetImmediate is set, the recovery ends as soon as a consistent state
+// is reached, and the target can't be combined with other ones
+func (info InitInfo) recoveryTarget(cluster *apiv1.Cluster) (*apiv1.RecoveryTarget, error) {
+	var target *apiv1.RecoveryTarget
+	if cluster.Spec.Bootstrap != nil && cluster.Spec.Bootstrap.Recovery != nil {
+		target = cluster.Spec.Bootstrap.Recovery.RecoveryTarget
+	}
+
+	if !info.TargetImmediate {
+		return target, nil
+	}
+
+	if target == nil {
+		return &apiv1.RecoveryTarget{TargetImmediate: ptr.To(true)}, nil
+	}
+
+	var conflicts []string
+	if target.TargetXID != "" {
+		conflicts = append(conflicts, "targetXID")
+	}
+	if target.TargetName != "" {
+		conflicts = append(conflicts, "targetName")
+	}
+	if target.TargetLSN != "" {
+		conflicts = append(conflicts, "targetLSN")
+	}
+	if target.TargetTime != "" {
+		conflicts = append(conflicts, "targetTime")
+	}
+	if len(conflicts) > 0 {
+		return nil, fmt.Errorf("the immediate recovery target conflicts with the recovery target of the cluster: %s",
+			strings.Join(conflicts, ", "))
+	}
+
+	result := target.DeepCopy()
+	result.TargetImmediate = ptr.To(true)
+	return result, nil
+}
+
+// validatePromptPromotion ensures the prompt promotion can be honored:
+// a restore being only verified is never promoted, and the timeline to
+// be followed can't be chosen by the recovery target of the cluster
+func (info InitInfo) validatePromptPromotion(cluster *apiv1.Cluster) error {
+	if !info.PromptPromotion {
+		return nil
+	}
+
+	if info.VerifyOnly {
+		return fmt.Errorf("the prompt promotion can't be used while only verifying the restore")
+	}
+
+	if cluster.Spec.Bootstrap != nil && cluster.Spec.Bootstrap.Recovery != nil &&
+		cluster.Spec.Bootstrap.Recovery.RecoveryTarget != nil &&
+		cluster.Spec.Bootstrap.Recovery.RecoveryTarget.TargetTLI != "" {
+		return fmt.Errorf("the prompt promotion conflicts with the targetTLI of the recovery target of the cluster")
+	}
+
+	return nil
 }
 
 // getRestoreWalConfig obtains the content to append to `custom.conf` allowing PostgreSQL
 // to complete the WAL recovery from the object storage and then start
-// as a new primary
+// as a new primary.
+// The restore_command downloads every WAL file only when PostgreSQL requests
+// it, straight into the path PostgreSQL passes, without prefetching the
+// following ones in the spool directory as the wal-restore command does
 func getRestoreWalConfig(ctx context.Context, backup *apiv1.Backup) (string, error) {
 	var err error
 
@@ -664,17 +943,8 @@ func getRestoreWalConfig(ctx context.Context, backup *apiv1.Backup) (string, err
 }
 
 func (info InitInfo) writeRecoveryConfiguration(cluster *apiv1.Cluster, recoveryFileContents string) error {
-	// Ensure restore_command is used to correctly recover WALs
-	// from the object storage
-
 	log.Info("Generated recovery configuration", "configuration", recoveryFileContents)
-	// Temporarily suspend WAL archiving. We set it to `false` (which means failure
-	// of the archiver) in order to defer the decision about archiving to PostgreSQL
-	// itself once the recovery job is completed and the instance is regularly started.
-	err := fileutils.AppendStringToFile(
-		path.Join(info.PgData, constants.PostgresqlCustomConfigurationFile),
-		"archive_command = 'false'\n")
-	if err != nil {
+	if err := info.suspendWALArchiving(); err != nil {
 		return fmt.Errorf("cannot write recovery config: %w", err)
 	}
 
@@ -740,11 +1010,159 @@ func (info InitInfo) writeRecoveryConfiguration(cluster *apiv1.Cluster, recovery
 
 	// Create recovery signal file
 	return os.WriteFile(
-		path.Join(info.PgData, "recovery.signal"),
+		path.Join(info.PgData, info.recoverySignalFile()),
 		[]byte(""),
 		0o600)
 }
 
+// suspendWALArchiving temporarily suspends the WAL archiving of the
+// restored instance, until the instance is regularly started and its
+// configuration is generated again.
+// By default, archive_command is set to `false` (which means failure of
+// the archiver) in order to defer the decision about archiving to
+// PostgreSQL itself, which will then archive the WAL files written by the
+// restore too. When NoArchiveOnRestore is set, archive_mode is disabled
+// instead, so that the WAL files written by the restore are never archived
+func (info InitInfo) suspendWALArchiving() error {
+	settings := "archive_command = 'false'\n"
+	if info.NoArchiveOnRestore {
+		log.Info("Disabling the WAL archiving of the restored instance until it is reconfigured")
+		settings += "archive_mode = 'off'\n"
+	}
+
+	return fileutils.AppendStringToFile(
+		path.Join(info.PgData, constants.PostgresqlCustomConfigurationFile),
+		settings)
+}
+
+// recoveryEndCommandUnsafeCharacters is the list of shell metacharacters
+// not accepted in recovery_end_command unless explicitly allowed
+const recoveryEndCommandUnsafeCharacters = ";&|<>`$\\\n\r"
+
+// validateRecoveryEndCommand ensures the passed recovery_end_command doesn't
+// contain shell metacharacters that could chain or redirect commands
+func validateRecoveryEndCommand(command string, allowUnsafe bool) error {
+	if allowUnsafe {
+		return nil
+	}
+
+	if idx := strings.IndexAny(command, recoveryEndCommandUnsafeCharacters); idx >= 0 {
+		return fmt.Errorf(
+			"recovery_end_command contains the shell metacharacter %q, "+
+				"which is not allowed unless explicitly enabled", command[idx])
+	}
+
+	return nil
+}
+
+// validateRestoreCommand ensures the passed restore_command, when set,
+// contains the placeholders PostgreSQL replaces with the name of the
+// requested WAL file and the path where it must be copied
+func validateRestoreCommand(command string) error {
+	if command == "" {
+		return nil
+	}
+
+	for _, placeholder := range []string{"%f", "%p"} {
+		if !strings.Contains(command, placeholder) {
+			return fmt.Errorf("restore_command must contain the %s placeholder", placeholder)
+		}
+	}
+
+	return nil
+}
+
+// recoverySignalFile returns the name of the signal file used to start
+// the restored instance. When only verifying a restore, the instance
+// is started as a standby, so that it will never be promoted even
+// if no recovery target has been specified
+func (info InitInfo) recoverySignalFile() string {
+	if info.VerifyOnly {
+		return "standby.signal"
+	}
+
+	return "recovery.signal"
+}
+
+// recoveryConfiguration applies the restore options to the
+// passed recovery configuration
+func (info InitInfo) recoveryConfiguration(recoveryFileContents string) (string, error) {
+	var err error
+
+	if info.VerifyOnly {
+		if recoveryFileContents, err = pauseRecoveryConfiguration(recoveryFileContents); err != nil {
+			return "", err
+		}
+	}
+
+	if info.PromptPromotion {
+		if recoveryFileContents, err = promptPromotionConfiguration(recoveryFileContents); err != nil {
+			return "", err
+		}
+	}
+
+	if info.RestoreCommand != "" {
+		recoveryFileContents, err = updateRecoveryConfiguration(
+			recoveryFileContents,
+			map[string]string{
+				"restore_command": info.RestoreCommand,
+			})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if info.RecoveryEndCommand != "" {
+		recoveryFileContents, err = updateRecoveryConfiguration(
+			recoveryFileContents,
+			map[string]string{
+				"recovery_end_command": info.RecoveryEndCommand,
+			})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return recoveryFileContents, nil
+}
+
+// pauseRecoveryConfiguration changes the passed recovery configuration
+// to pause the recovery once the recovery target is reached
+func pauseRecoveryConfiguration(recoveryFileContents string) (string, error) {
+	return updateRecoveryConfiguration(
+		recoveryFileContents,
+		map[string]string{
+			"recovery_target_action": "pause",
+		})
+}
+
+// promptPromotionConfiguration changes the passed recovery configuration
+// to promote the instance once the recovery target is reached, following
+// only the timeline of the backup. This way PostgreSQL doesn't look for
+// the history files of newer timelines, which are usually missing, in the
+// archive while replaying the WAL files
+func promptPromotionConfiguration(recoveryFileContents string) (string, error) {
+	return updateRecoveryConfiguration(
+		recoveryFileContents,
+		map[string]string{
+			"recovery_target_action":   "promote",
+			"recovery_target_timeline": "current",
+		})
+}
+
+// updateRecoveryConfiguration sets the passed options in
+// the recovery configuration, replacing existing values
+func updateRecoveryConfiguration(recoveryFileContents string, options map[string]string) (string, error) {
+	lines, err := configfile.UpdateConfigurationContents(
+		strings.Split(strings.TrimSpace(recoveryFileContents), "\n"),
+		options)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.Join(lines, "\n") + "\n", nil
+}
+
 // LoadEnforcedParametersFromPgControldata will parse the output of pg_controldata in order to get
 // the values of all the hot standby sensible parameters
 func LoadEnforcedParametersFromPgControldata(pgData string) (map[string]int, error) {
@@ -833,7 +1251,7 @@ func (info InitInfo) WriteInitialPostgresqlConf(ctx context.Context, cluster *ap
 		Temporary: true,
 	}
 
-	if err = temporaryInitInfo.CreateDataDirectory(); err != nil {
+	if err = temporaryInitInfo.CreateDataDirectory(ctx); err != nil {
 		return fmt.Errorf("while creating a temporary data directory: %w", err)
 	}
 
@@ -918,25 +1336,90 @@ func (info InitInfo) ConfigureInstanceAfterRestore(ctx context.Context, cluster
 		return err
 	}
 
+	if info.VerifyOnly {
+		// The instance will stay in recovery, and we'll only check it
+		// reached a consistent state. The signal file is kept in place.
+		if err := instance.WithActiveInstance(ctx, func() error {
+			db, err := instance.GetSuperUserDB()
+			if err != nil {
+				return err
+			}
+
+			recoveryCtx, cancel := info.recoveryContext(ctx)
+			defer cancel()
+			return verifyRestoredInstance(recoveryCtx, db)
+		}); err != nil {
+			return err
+		}
+
+		// The instance has been shut down, as required by pg_checksums
+		return verifyDataChecksums(ctx, instance)
+	}
+
 	// This will start the recovery of WALs taken during the backup
 	// and, after that, the server will start in a new timeline
-	if err := instance.WithActiveInstance(func() error {
+	if err := instance.WithActiveInstance(ctx, func() error {
 		db, err := instance.GetSuperUserDB()
 		if err != nil {
 			return err
 		}
 
 		// Wait until we exit from recovery mode
-		err = waitUntilRecoveryFinishes(db)
-		if err != nil {
+		recoveryCtx, cancel := info.recoveryContext(ctx)
+		defer cancel()
+		if _, err := WaitForRecoveryConsistency(recoveryCtx, db); err != nil {
+			return err
+		}
+		if err := waitUntilRecoveryFinishes(recoveryCtx, db); err != nil {
 			return fmt.Errorf("while waiting for PostgreSQL to stop recovery mode: %w", err)
 		}
 
+		// The slots are dropped before pruning the databases, as
+		// DROP DATABASE fails on databases having logical slots
+		if info.DropStaleSlots {
+			if err := dropReplicationSlots(ctx, db); err != nil {
+				return fmt.Errorf("while dropping the stale replication slots: %w", err)
+			}
+		}
+
+		if len(info.KeepDatabases) > 0 {
+			if err := pruneDatabases(ctx, db, info.KeepDatabases); err != nil {
+				return fmt.Errorf("while pruning the restored databases: %w", err)
+			}
+		}
+
+		majorVersion, err := instance.MajorVersion()
+		if err != nil {
+			return fmt.Errorf("while getting the major version of the restored instance: %w", err)
+		}
+		if err := checkCollationVersions(ctx, db, instance.ConnectionPool().Connection, majorVersion); err != nil {
+			var mismatchError *CollationMismatchError
+			if info.FailOnCollationMismatch || !errors.As(err, &mismatchError) {
+				return fmt.Errorf("while checking the collation versions: %w", err)
+			}
+			contextLogger.Warning("Collation version mismatch in the restored instance", "err", err)
+		}
+
+		if info.AnalyzeAfterRestore {
+			if err := analyzeDatabases(ctx, db, instance.LongRunningConnectionPool().Connection,
+				info.VacuumAfterRestore); err != nil {
+				return fmt.Errorf("while refreshing the planner statistics: %w", err)
+			}
+		}
+
 		return nil
 	}); err != nil {
 		return err
 	}
 
+	// The instance has been cleanly shut down, as
+	// required to assign a new system identifier
+	if info.ResetSystemIdentifier {
+		if err := info.resetSystemIdentifier(ctx); err != nil {
+			return fmt.Errorf("while resetting the system identifier: %w", err)
+		}
+	}
+
 	primaryConnInfo := info.GetPrimaryConnInfo()
 	slotName := cluster.GetSlotNameFromInstanceName(info.PodName)
 	if _, err := configurePostgresOverrideConfFile(info.PgData, primaryConnInfo, slotName); err != nil {
@@ -945,22 +1428,56 @@ func (info InitInfo) ConfigureInstanceAfterRestore(ctx context.Context, cluster
 
 	if info.ApplicationUser == "" || info.ApplicationDatabase == "" {
 		log.Debug("configure new instance not ran, cluster is running in replica mode or missing user or database")
+		if info.PostRestoreSQLFile != "" {
+			contextLogger.Warning("Skipping the post-restore SQL file, as there is no application database",
+				"file", info.PostRestoreSQLFile)
+		}
 		return nil
 	}
 
 	// Configure the application database information for restored instance
-	return instance.WithActiveInstance(func() error {
-		if err := info.ConfigureNewInstance(instance); err != nil {
+	return instance.WithActiveInstance(ctx, func() error {
+		if err := info.ConfigureNewInstance(ctx, instance); err != nil {
 			return fmt.Errorf("while configuring restored instance: %w", err)
 		}
 
-		return nil
+		if info.PostRestoreSQLFile == "" {
+			return nil
+		}
+
+		db, err := instance.LongRunningConnectionPool().Connection(info.ApplicationDatabase)
+		if err != nil {
+			return err
+		}
+		return info.executePostRestoreSQLFile(ctx, db)
 	})
 }
 
-// GetPrimaryConnInfo returns the DSN to reach the primary
+// executePostRestoreSQLFile runs the statements of the post-restore
+// SQL file in the passed database
+func (info InitInfo) executePostRestoreSQLFile(ctx context.Context, db *sql.DB) error {
+	statements, err := fileutils.ReadFile(info.PostRestoreSQLFile)
+	if err != nil {
+		return fmt.Errorf("while reading the post-restore SQL file: %w", err)
+	}
+
+	log.FromContext(ctx).Info("Executing the post-restore SQL file", "file", info.PostRestoreSQLFile)
+	if _, err := db.ExecContext(ctx, string(statements)); err != nil {
+		return fmt.Errorf("while executing the post-restore SQL file %s: %w", info.PostRestoreSQLFile, err)
+	}
+
+	return nil
+}
+
+// GetPrimaryConnInfo returns the DSN to reach the primary, on ParentPort
+// or, when not set, on the same port of the local server
 func (info InitInfo) GetPrimaryConnInfo() string {
-	return buildPrimaryConnInfo(info.ClusterName+"-rw", info.PodName)
+	port := info.ParentPort
+	if port == 0 {
+		port = GetServerPort()
+	}
+
+	return buildPrimaryConnInfo(info.ClusterName+"-rw", port, info.PodName)
 }
 
 func (info *InitInfo) checkBackupDestination(
@@ -1017,13 +1534,13 @@ func (info *InitInfo) checkBackupDestination(
 // waitUntilRecoveryFinishes periodically checks the underlying
 // PostgreSQL connection and returns only when the recovery
 // mode is finished
-func waitUntilRecoveryFinishes(db *sql.DB) error {
+func waitUntilRecoveryFinishes(ctx context.Context, db *sql.DB) error {
 	errorIsRetriable := func(err error) bool {
-		return err == ErrInstanceInRecovery
+		return err == ErrInstanceInRecovery && ctx.Err() == nil
 	}
 
 	return retry.OnError(RetryUntilRecoveryDone, errorIsRetriable, func() error {
-		row := db.QueryRow("SELECT pg_is_in_recovery()")
+		row := db.QueryRowContext(ctx, "SELECT pg_is_in_recovery()")
 
 		var status bool
 		if err := row.Scan(&status); err != nil {
@@ -1041,6 +1558,95 @@ func waitUntilRecoveryFinishes(db *sql.DB) error {
 	})
 }
 
+// recoveryContext returns the context bounding the wait for the
+// recovery of the restored instance to the recovery timeout, if any
+func (info InitInfo) recoveryContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if info.RecoveryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, info.RecoveryTimeout)
+}
+
+// WaitForRecoveryConsistency waits until the passed restored instance
+// reached a consistent state, that is when it accepts connections, either
+// as a hot standby or as a promoted primary. It returns whether the
+// instance is still in recovery, and fails when the context is done
+func WaitForRecoveryConsistency(ctx context.Context, db *sql.DB) (bool, error) {
+	contextLogger := log.FromContext(ctx)
+
+	var inRecovery bool
+	var lastErr error
+	err := wait.PollUntilContextCancel(ctx, recoveryConsistencyPollInterval, true,
+		func(ctx context.Context) (bool, error) {
+			row := db.QueryRowContext(ctx, "SELECT pg_is_in_recovery()")
+			if lastErr = row.Scan(&inRecovery); lastErr != nil {
+				contextLogger.Info("The restored instance is not yet consistent, will retry", "err", lastErr)
+				return false, nil
+			}
+
+			return true, nil
+		})
+	if err != nil {
+		if lastErr != nil {
+			err = fmt.Errorf("%w: %w", err, lastErr)
+		}
+		return false, fmt.Errorf("while waiting for the restored instance to be consistent: %w", err)
+	}
+
+	contextLogger.Info("The restored instance reached a consistent state", "recovery", inRecovery)
+	return inRecovery, nil
+}
+
+// verifyRestoredInstance checks that a restored instance, started in
+// verify-only mode, reached a consistent state without being promoted
+func verifyRestoredInstance(ctx context.Context, db *sql.DB) error {
+	contextLogger := log.FromContext(ctx)
+
+	inRecovery, err := WaitForRecoveryConsistency(ctx, db)
+	if err != nil {
+		return err
+	}
+	if !inRecovery {
+		return fmt.Errorf("the restored instance has been promoted while verifying the restore")
+	}
+
+	var databases int
+	row := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM pg_catalog.pg_database WHERE datallowconn")
+	if err := row.Scan(&databases); err != nil {
+		return fmt.Errorf("while reading the list of restored databases: %w", err)
+	}
+
+	contextLogger.Info("Restored instance reached a consistent state",
+		"databases", databases)
+	return nil
+}
+
+// verifyDataChecksums verifies the data checksums of every page of the
+// stopped restored instance, when they are enabled, catching the
+// corruption the consistency check can't detect
+func verifyDataChecksums(ctx context.Context, instance *Instance) error {
+	contextLogger := log.FromContext(ctx)
+
+	controlData, err := instance.GetPgControldata()
+	if err != nil {
+		return err
+	}
+	checksumVersion := utils.ParsePgControldataOutput(controlData)[utils.PgControlDataKeyDataPageChecksumVersion]
+	if checksumVersion == "" || checksumVersion == "0" {
+		contextLogger.Info("Data checksums are disabled, skipping their verification")
+		return nil
+	}
+
+	contextLogger.Info("Verifying the data checksums of the restored instance")
+	options := []string{"--check", "-D", instance.PgData}
+	if _, err := postgresutils.RunCommand(ctx, pgChecksumsName, options, postgresutils.CommandOptions{}); err != nil {
+		return fmt.Errorf("while verifying the data checksums: %w", err)
+	}
+
+	return nil
+}
+
 // restoreViaPlugin tries to restore the cluster using a plugin if available and enabled.
 // Returns true if a restore plugin was found and any error encountered.
 func restoreViaPlugin(
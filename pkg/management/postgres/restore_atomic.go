@@ -0,0 +1,102 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/cloudnative-pg/machinery/pkg/fileutils"
+	"github.com/cloudnative-pg/machinery/pkg/log"
+)
+
+// atomicRestoreStagingSuffix is appended to the data directory to
+// get the name of the directory an atomic restore is staged into
+const atomicRestoreStagingSuffix = "_restoring"
+
+// validateAtomicRestore checks that the atomic restore
+// is compatible with the other restore options
+func (info InitInfo) validateAtomicRestore() error {
+	if !info.AtomicRestore {
+		return nil
+	}
+
+	if info.WALOnly {
+		return errors.New("a WAL-only restore reuses the existing data directory and can't be atomic")
+	}
+
+	// The WAL directory would be shared by the staging
+	// and the existing data directories
+	if info.PgWal != "" {
+		return errors.New("a restore with a separate WAL directory can't be atomic")
+	}
+
+	return nil
+}
+
+// restoreAtomically runs the passed restore into a sibling of the data
+// directory, which replaces the data directory only once the restore
+// succeeds. When the restore fails, the staging directory is removed and
+// the existing data directory, if any, is left untouched. Otherwise, the
+// existing data directory is renamed, as CheckTargetDataDirectory does.
+// The passed restore must not promote the instance, as its data would be
+// removed on failure
+func (info InitInfo) restoreAtomically(ctx context.Context, restore func(staged InitInfo) error) error {
+	contextLogger := log.FromContext(ctx)
+
+	staged := info
+	staged.PgData = info.PgData + atomicRestoreStagingSuffix
+	staged.AtomicRestore = false
+
+	// A leftover of an interrupted restore is never reused
+	if err := os.RemoveAll(staged.PgData); err != nil {
+		return fmt.Errorf("while removing the staging directory of a previous restore: %w", err)
+	}
+
+	contextLogger.Info("Restoring into a staging directory", "stagingDirectory", staged.PgData)
+	if err := restore(staged); err != nil {
+		contextLogger.Info("Restore failed, removing the staging directory",
+			"stagingDirectory", staged.PgData)
+		if cleanupErr := os.RemoveAll(staged.PgData); cleanupErr != nil {
+			contextLogger.Error(cleanupErr, "error while removing the staging directory",
+				"stagingDirectory", staged.PgData)
+		}
+		return err
+	}
+
+	pgDataExists, err := fileutils.FileExists(info.PgData)
+	if err != nil {
+		return fmt.Errorf("while verifying if PGDATA exists: %w", err)
+	}
+	if pgDataExists {
+		renamedDirectoryName := fmt.Sprintf("%s_%s", info.PgData, fileutils.FormatFriendlyTimestamp(time.Now()))
+		contextLogger.Info("Renaming the existing data directory", "newName", renamedDirectoryName)
+		if err := os.Rename(info.PgData, renamedDirectoryName); err != nil {
+			return fmt.Errorf("while renaming existing data directory: %w", err)
+		}
+	}
+
+	if err := os.Rename(staged.PgData, info.PgData); err != nil {
+		return fmt.Errorf("while moving the restored data directory in place: %w", err)
+	}
+
+	contextLogger.Info("Moved the restored data directory in place", "pgdata", info.PgData)
+	return nil
+}
@@ -0,0 +1,97 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("atomic restore", func() {
+	var info InitInfo
+
+	BeforeEach(func() {
+		info = InitInfo{
+			PgData:        filepath.Join(GinkgoT().TempDir(), "pgdata"),
+			AtomicRestore: true,
+		}
+		Expect(os.Mkdir(info.PgData, 0o700)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(info.PgData, "PG_VERSION"), []byte("16\n"), 0o600)).To(Succeed())
+	})
+
+	It("leaves the existing data directory untouched when the restore fails", func(ctx SpecContext) {
+		var stagingDirectory string
+		err := info.restoreAtomically(ctx, func(staged InitInfo) error {
+			stagingDirectory = staged.PgData
+			Expect(os.Mkdir(staged.PgData, 0o700)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(staged.PgData, "PG_VERSION"), []byte("17\n"), 0o600)).To(Succeed())
+			return errors.New("barman-cloud-restore failed")
+		})
+		Expect(err).To(MatchError("barman-cloud-restore failed"))
+
+		Expect(stagingDirectory).To(Equal(info.PgData + "_restoring"))
+		Expect(stagingDirectory).ToNot(BeAnExistingFile())
+		Expect(os.ReadFile(filepath.Join(info.PgData, "PG_VERSION"))).To(BeEquivalentTo("16\n"))
+	})
+
+	It("replaces the data directory once the restore succeeds", func(ctx SpecContext) {
+		Expect(info.restoreAtomically(ctx, func(staged InitInfo) error {
+			Expect(staged.AtomicRestore).To(BeFalse())
+			Expect(os.Mkdir(staged.PgData, 0o700)).To(Succeed())
+			return os.WriteFile(filepath.Join(staged.PgData, "PG_VERSION"), []byte("17\n"), 0o600)
+		})).To(Succeed())
+
+		Expect(os.ReadFile(filepath.Join(info.PgData, "PG_VERSION"))).To(BeEquivalentTo("17\n"))
+		Expect(info.PgData + "_restoring").ToNot(BeAnExistingFile())
+
+		renamed, err := filepath.Glob(info.PgData + "_*")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(renamed).To(HaveLen(1))
+		Expect(os.ReadFile(filepath.Join(renamed[0], "PG_VERSION"))).To(BeEquivalentTo("16\n"))
+	})
+
+	It("discards the leftovers of an interrupted restore", func(ctx SpecContext) {
+		leftover := filepath.Join(info.PgData+"_restoring", "base")
+		Expect(os.MkdirAll(leftover, 0o700)).To(Succeed())
+
+		Expect(info.restoreAtomically(ctx, func(staged InitInfo) error {
+			Expect(staged.PgData).ToNot(BeAnExistingFile())
+			return os.Mkdir(staged.PgData, 0o700)
+		})).To(Succeed())
+	})
+
+	It("can't be used in WAL-only mode", func() {
+		info.WALOnly = true
+		Expect(info.validateAtomicRestore()).To(MatchError(ContainSubstring("can't be atomic")))
+	})
+
+	It("can't be used with a separate WAL directory", func() {
+		info.PgWal = filepath.Join(GinkgoT().TempDir(), "pgwal")
+		Expect(info.validateAtomicRestore()).To(MatchError(ContainSubstring("WAL directory")))
+	})
+
+	It("accepts any option when not requested", func() {
+		info.AtomicRestore = false
+		info.WALOnly = true
+		info.PgWal = filepath.Join(GinkgoT().TempDir(), "pgwal")
+		Expect(info.validateAtomicRestore()).To(Succeed())
+	})
+})
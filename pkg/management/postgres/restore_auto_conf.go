@@ -0,0 +1,89 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/cloudnative-pg/machinery/pkg/fileutils"
+	"github.com/cloudnative-pg/machinery/pkg/log"
+
+	"github.com/cloudnative-pg/cloudnative-pg/pkg/configfile"
+)
+
+// resetAutoConf removes the settings inherited from the source cluster
+// through postgresql.auto.conf, like ALTER SYSTEM RESET would do, before
+// the restored instance is started for the first time. Every setting is
+// removed unless ResetAutoConfSettings selects some of them
+func (info InitInfo) resetAutoConf(ctx context.Context) error {
+	if !info.ResetAutoConf {
+		return nil
+	}
+
+	autoConfFile := path.Join(info.PgData, "postgresql.auto.conf")
+	lines, err := fileutils.ReadFileLines(autoConfFile)
+	if err != nil {
+		return fmt.Errorf("while reading postgresql.auto.conf: %w", err)
+	}
+
+	lines, removed := resetAutoConfContents(lines, info.ResetAutoConfSettings)
+	if len(removed) == 0 {
+		return nil
+	}
+
+	if _, err := fileutils.WriteLinesToFile(autoConfFile, lines); err != nil {
+		return fmt.Errorf("while resetting postgresql.auto.conf: %w", err)
+	}
+
+	log.FromContext(ctx).Info("Reset the settings inherited through postgresql.auto.conf",
+		"settings", removed)
+	return nil
+}
+
+// resetAutoConfContents removes the passed settings, or every setting when
+// none is passed, from the contents of postgresql.auto.conf, keeping the
+// comments. It returns the remaining lines and the removed settings
+func resetAutoConfContents(lines []string, settings []string) ([]string, []string) {
+	var names []string
+	for _, line := range lines {
+		name, _, found := strings.Cut(strings.TrimSpace(line), "=")
+		if !found || strings.HasPrefix(name, "#") {
+			continue
+		}
+		names = append(names, strings.TrimSpace(name))
+	}
+
+	if len(settings) > 0 {
+		selected := make(map[string]bool, len(settings))
+		for _, setting := range settings {
+			selected[strings.ToLower(strings.TrimSpace(setting))] = true
+		}
+		names = slices.DeleteFunc(names, func(name string) bool {
+			return !selected[strings.ToLower(name)]
+		})
+	}
+
+	if len(names) == 0 {
+		return lines, nil
+	}
+
+	return configfile.RemoveOptionsFromConfigurationContents(lines, names...), names
+}
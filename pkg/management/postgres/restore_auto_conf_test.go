@@ -0,0 +1,75 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("reset of postgresql.auto.conf after a restore", func() {
+	const autoConf = "# Do not edit this file manually!\n" +
+		"# It will be overwritten by the ALTER SYSTEM command.\n" +
+		"work_mem = '64MB'\n" +
+		"primary_slot_name = 'source_slot'\n" +
+		"log_directory = '/source/logs'\n"
+
+	var pgData string
+
+	BeforeEach(func() {
+		pgData = GinkgoT().TempDir()
+		Expect(os.WriteFile(filepath.Join(pgData, "postgresql.auto.conf"), []byte(autoConf), 0o600)).To(Succeed())
+	})
+
+	readAutoConf := func() string {
+		content, err := os.ReadFile(filepath.Join(pgData, "postgresql.auto.conf")) // #nosec
+		Expect(err).ToNot(HaveOccurred())
+		return string(content)
+	}
+
+	It("removes every inherited setting, keeping the comments", func(ctx SpecContext) {
+		Expect(InitInfo{PgData: pgData, ResetAutoConf: true}.resetAutoConf(ctx)).To(Succeed())
+		Expect(readAutoConf()).To(Equal("# Do not edit this file manually!\n" +
+			"# It will be overwritten by the ALTER SYSTEM command.\n"))
+	})
+
+	It("removes only the selected settings", func(ctx SpecContext) {
+		info := InitInfo{
+			PgData:                pgData,
+			ResetAutoConf:         true,
+			ResetAutoConfSettings: []string{"Primary_Slot_Name", "log_directory", "not_there"},
+		}
+		Expect(info.resetAutoConf(ctx)).To(Succeed())
+		Expect(readAutoConf()).To(Equal("# Do not edit this file manually!\n" +
+			"# It will be overwritten by the ALTER SYSTEM command.\n" +
+			"work_mem = '64MB'\n"))
+	})
+
+	It("leaves the file untouched when not requested", func(ctx SpecContext) {
+		Expect(InitInfo{PgData: pgData}.resetAutoConf(ctx)).To(Succeed())
+		Expect(readAutoConf()).To(Equal(autoConf))
+	})
+
+	It("does nothing when there is no postgresql.auto.conf", func(ctx SpecContext) {
+		Expect(os.Remove(filepath.Join(pgData, "postgresql.auto.conf"))).To(Succeed())
+		Expect(InitInfo{PgData: pgData, ResetAutoConf: true}.resetAutoConf(ctx)).To(Succeed())
+		Expect(filepath.Join(pgData, "postgresql.auto.conf")).ToNot(BeAnExistingFile())
+	})
+})
@@ -0,0 +1,157 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/cloudnative-pg/machinery/pkg/fileutils"
+	"github.com/cloudnative-pg/machinery/pkg/log"
+)
+
+const (
+	// localBackupBaseDirectory is the directory of a local backup path
+	// containing the base backup, as a copy of the data directory
+	localBackupBaseDirectory = "base"
+
+	// localBackupWALDirectory is the directory of a local backup
+	// path containing the archived WAL files
+	localBackupWALDirectory = "wals"
+)
+
+// localBackupPathRegex matches the local backup paths which can be used
+// in restore_command without being quoted for the shell
+var localBackupPathRegex = regexp.MustCompile(`^/[A-Za-z0-9/._-]+$`)
+
+// validateLocalBackupPath ensures the local backup path, when set, is an
+// absolute path which doesn't need to be quoted, and is not combined
+// with the options selecting a backup in the object storage
+func (info InitInfo) validateLocalBackupPath() error {
+	if info.LocalBackupPath == "" {
+		return nil
+	}
+
+	if !localBackupPathRegex.MatchString(info.LocalBackupPath) {
+		return fmt.Errorf("invalid local backup path %q: it must be an absolute path made of "+
+			"letters, digits, dots, dashes and underscores", info.LocalBackupPath)
+	}
+
+	if info.BackupID != "" || info.VerifyWALContinuity {
+		return fmt.Errorf("the backup ID and the WAL continuity check require a backup in the object storage")
+	}
+
+	return nil
+}
+
+// restoreFromLocalPath restores the base backup staged in the local
+// backup path into the data directory, returning the recovery
+// configuration reading the WAL files staged beside it
+func (info InitInfo) restoreFromLocalPath(ctx context.Context) (string, error) {
+	contextLogger := log.FromContext(ctx)
+
+	baseDirectory := filepath.Join(info.LocalBackupPath, localBackupBaseDirectory)
+	walDirectory := filepath.Join(info.LocalBackupPath, localBackupWALDirectory)
+
+	if stat, err := os.Stat(walDirectory); err != nil || !stat.IsDir() {
+		return "", fmt.Errorf("the local backup path %s has no %s directory", info.LocalBackupPath,
+			localBackupWALDirectory)
+	}
+
+	if info.WALOnly {
+		contextLogger.Info("WAL-only restore requested, skipping the base backup copy",
+			"pgdata", info.PgData)
+	} else {
+		versionFile := filepath.Join(baseDirectory, "PG_VERSION")
+		if exists, err := fileutils.FileExists(versionFile); err != nil || !exists {
+			return "", fmt.Errorf("the local backup path %s has no base backup in the %s directory",
+				info.LocalBackupPath, localBackupBaseDirectory)
+		}
+
+		contextLogger.Info("Copying the base backup from the local backup path",
+			"source", baseDirectory, "pgdata", info.PgData)
+		if err := copyDataDirectory(baseDirectory, info.PgData); err != nil {
+			return "", fmt.Errorf("while copying the base backup: %w", err)
+		}
+	}
+
+	if _, err := info.restoreCustomWalDir(ctx); err != nil {
+		return "", err
+	}
+
+	return localRestoreWalConfig(walDirectory), nil
+}
+
+// localRestoreWalConfig returns the content to append to `custom.conf`
+// allowing PostgreSQL to complete the WAL recovery from the passed
+// directory and then start as a new primary
+func localRestoreWalConfig(walDirectory string) string {
+	return fmt.Sprintf(
+		"recovery_target_action = promote\n"+
+			"restore_command = 'cp %s/%%f %%p'\n",
+		walDirectory)
+}
+
+// copyDataDirectory copies the source directory into the destination one,
+// which must not contain any file, with the permissions PostgreSQL
+// requires on a data directory. Symbolic links are copied as they are
+func copyDataDirectory(source, destination string) error {
+	entries, err := os.ReadDir(destination)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if len(entries) > 0 {
+		return fmt.Errorf("the data directory %s is not empty", destination)
+	}
+
+	return filepath.WalkDir(source, func(sourcePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relativePath, err := filepath.Rel(source, sourcePath)
+		if err != nil {
+			return err
+		}
+		destinationPath := filepath.Join(destination, relativePath)
+
+		switch {
+		case entry.IsDir():
+			if err := os.MkdirAll(destinationPath, 0o700); err != nil {
+				return err
+			}
+			return os.Chmod(destinationPath, 0o700)
+
+		case entry.Type()&fs.ModeSymlink != 0:
+			target, err := os.Readlink(sourcePath)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(target, destinationPath)
+
+		default:
+			if err := fileutils.CopyFile(sourcePath, destinationPath); err != nil {
+				return err
+			}
+			return os.Chmod(destinationPath, 0o600)
+		}
+	})
+}
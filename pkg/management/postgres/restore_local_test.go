@@ -0,0 +1,133 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/cloudnative-pg/machinery/pkg/fileutils"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("restore from a local backup path", func() {
+	var info InitInfo
+
+	BeforeEach(func() {
+		backupPath := GinkgoT().TempDir()
+		for name, content := range map[string]string{
+			"base/PG_VERSION":                   "16\n",
+			"base/global/pg_control":            "control",
+			"base/base/1/1259":                  "relation",
+			"wals/000000010000000000000002":     "segment 2",
+			"wals/000000010000000000000003":     "segment 3",
+			"wals/00000002.history":             "1\t0/3000000\tno recovery target specified\n",
+			"base/pg_wal/archive_status/.empty": "",
+		} {
+			_, err := fileutils.WriteStringToFile(filepath.Join(backupPath, name), content)
+			Expect(err).ToNot(HaveOccurred())
+		}
+		Expect(os.Symlink("/var/lib/tablespaces/data", filepath.Join(backupPath, "base", "pg_tblspc"))).
+			To(Succeed())
+
+		info = InitInfo{
+			PgData:          filepath.Join(GinkgoT().TempDir(), "pgdata"),
+			LocalBackupPath: backupPath,
+		}
+	})
+
+	It("copies the base backup into the data directory", func(ctx SpecContext) {
+		Expect(info.validateLocalBackupPath()).To(Succeed())
+		_, err := info.restoreFromLocalPath(ctx)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(fileutils.ReadFile(filepath.Join(info.PgData, "PG_VERSION"))).To(BeEquivalentTo("16\n"))
+		Expect(fileutils.ReadFile(filepath.Join(info.PgData, "base", "1", "1259"))).To(BeEquivalentTo("relation"))
+		Expect(os.Readlink(filepath.Join(info.PgData, "pg_tblspc"))).To(Equal("/var/lib/tablespaces/data"))
+
+		stat, err := os.Stat(filepath.Join(info.PgData, "global"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stat.Mode().Perm()).To(Equal(os.FileMode(0o700)))
+		stat, err = os.Stat(filepath.Join(info.PgData, "global", "pg_control"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stat.Mode().Perm()).To(Equal(os.FileMode(0o600)))
+	})
+
+	It("configures restore_command to read the local WAL files", func(ctx SpecContext) {
+		config, err := info.restoreFromLocalPath(ctx)
+		Expect(err).ToNot(HaveOccurred())
+
+		walDirectory := filepath.Join(info.LocalBackupPath, "wals")
+		Expect(config).To(Equal("recovery_target_action = promote\n" +
+			"restore_command = 'cp " + walDirectory + "/%f %p'\n"))
+
+		// Run restore_command as PostgreSQL would do
+		restoreCommand := regexp.MustCompile(`restore_command = '(.*)'`).FindStringSubmatch(config)[1]
+		destination := filepath.Join(GinkgoT().TempDir(), "RECOVERYXLOG")
+		command := strings.NewReplacer("%f", "000000010000000000000003", "%p", destination).
+			Replace(restoreCommand)
+		Expect(exec.Command("sh", "-c", command).Run()).To(Succeed()) // #nosec G204
+		Expect(fileutils.ReadFile(destination)).To(BeEquivalentTo("segment 3"))
+
+		command = strings.NewReplacer("%f", "000000010000000000000004", "%p", destination).
+			Replace(restoreCommand)
+		Expect(exec.Command("sh", "-c", command).Run()).ToNot(Succeed()) // #nosec G204
+	})
+
+	It("skips the base backup in WAL-only mode", func(ctx SpecContext) {
+		info.WALOnly = true
+		_, err := info.restoreFromLocalPath(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(info.PgData).ToNot(BeADirectory())
+	})
+
+	It("refuses a data directory which is not empty", func(ctx SpecContext) {
+		_, err := fileutils.WriteStringToFile(filepath.Join(info.PgData, "PG_VERSION"), "15\n")
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = info.restoreFromLocalPath(ctx)
+		Expect(err).To(MatchError(ContainSubstring("is not empty")))
+	})
+
+	It("refuses a local backup path without a base backup", func(ctx SpecContext) {
+		Expect(os.Remove(filepath.Join(info.LocalBackupPath, "base", "PG_VERSION"))).To(Succeed())
+
+		_, err := info.restoreFromLocalPath(ctx)
+		Expect(err).To(MatchError(ContainSubstring("has no base backup")))
+	})
+
+	It("refuses a local backup path without WAL files", func(ctx SpecContext) {
+		Expect(os.RemoveAll(filepath.Join(info.LocalBackupPath, "wals"))).To(Succeed())
+
+		_, err := info.restoreFromLocalPath(ctx)
+		Expect(err).To(MatchError(ContainSubstring("has no wals directory")))
+	})
+
+	DescribeTable("validates the local backup path",
+		func(info InitInfo, message string) {
+			Expect(info.validateLocalBackupPath()).To(MatchError(ContainSubstring(message)))
+		},
+		Entry("relative path", InitInfo{LocalBackupPath: "backups/latest"}, "absolute path"),
+		Entry("path to be quoted", InitInfo{LocalBackupPath: "/backups/it's latest"}, "absolute path"),
+		Entry("backup ID", InitInfo{LocalBackupPath: "/backups", BackupID: "20240101T000000"}, "object storage"),
+	)
+})
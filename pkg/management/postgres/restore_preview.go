@@ -0,0 +1,72 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+
+	"github.com/cloudnative-pg/cloudnative-pg/pkg/management"
+)
+
+// PreviewRecoveryConfiguration returns the recovery configuration a
+// restore would write, without touching the data directory. The backup to
+// be restored is chosen as the restore would do, but nothing is downloaded
+func (info InitInfo) PreviewRecoveryConfiguration(ctx context.Context) (string, error) {
+	typedClient, err := management.NewControllerRuntimeClient()
+	if err != nil {
+		return "", err
+	}
+
+	if err := validateRecoveryEndCommand(info.RecoveryEndCommand, info.AllowUnsafeRecoveryEndCommand); err != nil {
+		return "", err
+	}
+
+	if err := validateRestoreCommand(info.RestoreCommand); err != nil {
+		return "", err
+	}
+
+	if err := info.validateLocalBackupPath(); err != nil {
+		return "", err
+	}
+
+	cluster, err := info.loadCluster(ctx, typedClient)
+	if err != nil {
+		return "", err
+	}
+
+	var conf string
+	switch {
+	case info.LocalBackupPath != "":
+		conf = localRestoreWalConfig(filepath.Join(info.LocalBackupPath, localBackupWALDirectory))
+
+	case cluster.GetRecoverySourcePlugin() != nil:
+		return "", errors.New("the recovery configuration of a restore through a plugin can't be previewed")
+
+	default:
+		backup, _, err := info.loadBackup(ctx, typedClient, cluster)
+		if err != nil {
+			return "", err
+		}
+		if conf, err = getRestoreWalConfig(ctx, backup); err != nil {
+			return "", err
+		}
+	}
+
+	return info.renderRecoveryConfiguration(cluster, conf)
+}
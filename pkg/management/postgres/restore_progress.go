@@ -0,0 +1,153 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+	"time"
+)
+
+// defaultRestoreProgressInterval is the default interval between
+// two progress reports while downloading the base backup
+const defaultRestoreProgressInterval = 30 * time.Second
+
+// RestorePhase is a milestone of the restore process
+type RestorePhase string
+
+const (
+	// RestorePhaseBaseBackupDownload is reported periodically
+	// while the base backup is being downloaded
+	RestorePhaseBaseBackupDownload RestorePhase = "BaseBackupDownload"
+
+	// RestorePhaseBaseBackupDownloaded is reported once the
+	// base backup has been downloaded
+	RestorePhaseBaseBackupDownloaded RestorePhase = "BaseBackupDownloaded"
+
+	// RestorePhaseRecovery is reported when PostgreSQL starts
+	// replaying the archived WAL files
+	RestorePhaseRecovery RestorePhase = "Recovery"
+
+	// RestorePhaseCompleted is reported when the restore is completed
+	RestorePhaseCompleted RestorePhase = "Completed"
+)
+
+// RestoreProgress is the progress of the restore process
+type RestoreProgress struct {
+	// The phase of the restore process
+	Phase RestorePhase
+
+	// The number of bytes of the base backup downloaded so far
+	BytesDownloaded int64
+}
+
+// RestoreProgressFunc is a function receiving the progress of the restore
+type RestoreProgressFunc func(progress RestoreProgress)
+
+// RestoreOptions are the options of the restore process
+type RestoreOptions struct {
+	// Progress, when set, is invoked when the restore reaches a
+	// milestone and periodically while downloading the base backup
+	Progress RestoreProgressFunc
+
+	// ProgressInterval is the interval between two progress reports
+	// while downloading the base backup. Defaults to 30 seconds
+	ProgressInterval time.Duration
+}
+
+// report sends the passed progress to the callback, if any
+func (options RestoreOptions) report(progress RestoreProgress) {
+	if options.Progress != nil {
+		options.Progress(progress)
+	}
+}
+
+// progressInterval gets the interval between two progress reports
+func (options RestoreOptions) progressInterval() time.Duration {
+	if options.ProgressInterval <= 0 {
+		return defaultRestoreProgressInterval
+	}
+
+	return options.ProgressInterval
+}
+
+// monitorDownloadProgress executes the passed download function, periodically
+// reporting the size of the target directory. The reported byte counts never
+// decrease, even if the downloader removes temporary files.
+func (options RestoreOptions) monitorDownloadProgress(
+	ctx context.Context,
+	targetDirectory string,
+	download func() error,
+) error {
+	if options.Progress == nil {
+		return download()
+	}
+
+	var lastReported int64
+	reportSize := func(phase RestorePhase) {
+		if size := directorySize(targetDirectory); size > lastReported {
+			lastReported = size
+		}
+		options.report(RestoreProgress{Phase: phase, BytesDownloaded: lastReported})
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- download()
+	}()
+
+	ticker := time.NewTicker(options.progressInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case err := <-done:
+			if err != nil {
+				return err
+			}
+			reportSize(RestorePhaseBaseBackupDownloaded)
+			return nil
+
+		case <-ticker.C:
+			reportSize(RestorePhaseBaseBackupDownload)
+
+		case <-ctx.Done():
+			// The download is executed by an external process that
+			// we don't control: wait for it to terminate
+			return <-done
+		}
+	}
+}
+
+// directorySize gets the total size of the regular files inside the passed
+// directory. Files disappearing while walking the directory are ignored.
+func directorySize(directory string) int64 {
+	var size int64
+	_ = filepath.WalkDir(directory, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.Type().IsRegular() {
+			return nil
+		}
+
+		if info, err := entry.Info(); err == nil {
+			size += info.Size()
+		}
+		return nil
+	})
+
+	return size
+}
@@ -0,0 +1,113 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("restore progress", func() {
+	var (
+		lock     sync.Mutex
+		reported []RestoreProgress
+		options  RestoreOptions
+	)
+
+	BeforeEach(func() {
+		reported = nil
+		options = RestoreOptions{
+			ProgressInterval: 5 * time.Millisecond,
+			Progress: func(progress RestoreProgress) {
+				lock.Lock()
+				defer lock.Unlock()
+				reported = append(reported, progress)
+			},
+		}
+	})
+
+	It("reports monotonically increasing byte counts while downloading", func(ctx SpecContext) {
+		targetDirectory := GinkgoT().TempDir()
+
+		// The fake downloader writes a set of files, removing a temporary
+		// one half way to simulate a decreasing directory size
+		fakeDownloader := func() error {
+			tempFile := filepath.Join(targetDirectory, "temporary")
+			if err := os.WriteFile(tempFile, make([]byte, 4096), 0o600); err != nil {
+				return err
+			}
+			for i := 0; i < 10; i++ {
+				if i == 5 {
+					if err := os.Remove(tempFile); err != nil {
+						return err
+					}
+				}
+				fileName := filepath.Join(targetDirectory, fmt.Sprintf("file%d", i))
+				if err := os.WriteFile(fileName, make([]byte, 1024), 0o600); err != nil {
+					return err
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			return nil
+		}
+
+		Expect(options.monitorDownloadProgress(ctx, targetDirectory, fakeDownloader)).To(Succeed())
+
+		lock.Lock()
+		defer lock.Unlock()
+		Expect(len(reported)).To(BeNumerically(">", 2))
+		for i := 1; i < len(reported); i++ {
+			Expect(reported[i].BytesDownloaded).To(BeNumerically(">=", reported[i-1].BytesDownloaded))
+		}
+		Expect(reported[0].BytesDownloaded).To(BeNumerically("<", reported[len(reported)-1].BytesDownloaded))
+
+		last := reported[len(reported)-1]
+		Expect(last.Phase).To(Equal(RestorePhaseBaseBackupDownloaded))
+		Expect(last.BytesDownloaded).To(BeEquivalentTo(10 * 1024))
+	})
+
+	It("returns the download error without reporting the completion", func(ctx SpecContext) {
+		downloadErr := errors.New("download failed")
+		err := options.monitorDownloadProgress(ctx, GinkgoT().TempDir(), func() error {
+			return downloadErr
+		})
+		Expect(err).To(MatchError(downloadErr))
+
+		lock.Lock()
+		defer lock.Unlock()
+		for _, progress := range reported {
+			Expect(progress.Phase).ToNot(Equal(RestorePhaseBaseBackupDownloaded))
+		}
+	})
+
+	It("runs the download directly without a callback", func(ctx SpecContext) {
+		called := false
+		err := RestoreOptions{}.monitorDownloadProgress(ctx, GinkgoT().TempDir(), func() error {
+			called = true
+			return nil
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(called).To(BeTrue())
+	})
+})
@@ -0,0 +1,179 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	barmanCapabilities "github.com/cloudnative-pg/barman-cloud/pkg/capabilities"
+	barmanCommand "github.com/cloudnative-pg/barman-cloud/pkg/command"
+	"github.com/cloudnative-pg/machinery/pkg/log"
+
+	apiv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	postgresutils "github.com/cloudnative-pg/cloudnative-pg/pkg/management/postgres/utils"
+)
+
+// ErrInsufficientDiskSpace is raised when the volumes of the instance
+// can't hold the backup that is going to be restored
+var ErrInsufficientDiskSpace = errors.New("insufficient disk space to restore the backup")
+
+// availableDiskSpace returns the number of bytes available to
+// unprivileged users on the filesystem holding the passed path.
+// It is a variable to allow tests to simulate a small filesystem.
+var availableDiskSpace = func(path string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+
+	return uint64(stat.Bavail) * uint64(stat.Bsize), nil //nolint:gosec,unconvert
+}
+
+// barmanBackupSize is the subset of the barman-cloud-backup-show output
+// containing the size of a backup
+type barmanBackupSize struct {
+	Cloud struct {
+		Size uint64 `json:"size"`
+	} `json:"cloud"`
+}
+
+// checkRestoreFreeSpace ensures the data directory and, if present, the
+// WAL directory have enough space to hold the backup that is going to be
+// restored, so that we can fail before starting a long download
+func (info InitInfo) checkRestoreFreeSpace(ctx context.Context, backup *apiv1.Backup, env []string) error {
+	contextLogger := log.FromContext(ctx)
+
+	if info.FreeSpaceMargin < 0 {
+		return fmt.Errorf("invalid free space margin: %d", info.FreeSpaceMargin)
+	}
+
+	backupSize, err := getBarmanBackupSize(ctx, backup, env)
+	if err != nil {
+		return fmt.Errorf("while reading the size of backup %s: %w", backup.Status.BackupID, err)
+	}
+	if backupSize == 0 {
+		contextLogger.Info("The backup catalog doesn't report the backup size, skipping the free space check",
+			"backupID", backup.Status.BackupID)
+		return nil
+	}
+
+	return info.ensureFreeSpaceForBackup(ctx, backupSize)
+}
+
+// ensureFreeSpaceForBackup checks the volumes of the instance against the
+// size of the backup. The data directory needs to hold the backup plus the
+// configured margin, while a dedicated WAL volume needs to hold the margin,
+// which stands for the WAL files required to reach a consistent state
+func (info InitInfo) ensureFreeSpaceForBackup(ctx context.Context, backupSize uint64) error {
+	margin := backupSize * uint64(info.FreeSpaceMargin) / 100 //nolint:gosec
+
+	if err := checkAvailableDiskSpace(ctx, info.PgData, backupSize+margin); err != nil {
+		return err
+	}
+
+	if info.PgWal == "" {
+		return nil
+	}
+
+	return checkAvailableDiskSpace(ctx, info.PgWal, margin)
+}
+
+// checkAvailableDiskSpace returns ErrInsufficientDiskSpace if the filesystem
+// holding the passed path has less than the required number of bytes available
+func checkAvailableDiskSpace(ctx context.Context, path string, required uint64) error {
+	contextLogger := log.FromContext(ctx)
+
+	// The target directory is usually not created yet, so we
+	// look at the nearest existing parent directory instead
+	existingPath := path
+	for {
+		if _, err := os.Stat(existingPath); err == nil {
+			break
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+
+		parent := filepath.Dir(existingPath)
+		if parent == existingPath {
+			return fmt.Errorf("cannot find an existing parent directory for %s", path)
+		}
+		existingPath = parent
+	}
+
+	available, err := availableDiskSpace(existingPath)
+	if err != nil {
+		return fmt.Errorf("while checking the available disk space for %s: %w", path, err)
+	}
+
+	contextLogger.Info("Checking available disk space before restore",
+		"path", path,
+		"requiredBytes", required,
+		"availableBytes", available)
+
+	if available < required {
+		return fmt.Errorf("%w: %s has %d bytes available, %d bytes are required",
+			ErrInsufficientDiskSpace, path, available, required)
+	}
+
+	return nil
+}
+
+// getBarmanBackupSize reads the size of the backup from the barman catalog
+func getBarmanBackupSize(ctx context.Context, backup *apiv1.Backup, env []string) (uint64, error) {
+	contextLogger := log.FromContext(ctx)
+
+	options := []string{"--format", "json"}
+	if backup.Status.EndpointURL != "" {
+		options = append(options, "--endpoint-url", backup.Status.EndpointURL)
+	}
+
+	options, err := barmanCommand.AppendCloudProviderOptionsFromBackup(ctx, options, backup.Status.BarmanCredentials)
+	if err != nil {
+		return 0, err
+	}
+
+	options = append(options,
+		backup.Status.DestinationPath,
+		backup.Status.ServerName,
+		backup.Status.BackupID)
+
+	output, err := postgresutils.RunCommand(ctx, barmanCapabilities.BarmanCloudBackupShow, options,
+		postgresutils.CommandOptions{Env: env, SeparateStderr: true})
+	if err != nil {
+		contextLogger.Error(err, "Can't read the backup information")
+		return 0, err
+	}
+
+	return parseBarmanBackupSize([]byte(output))
+}
+
+// parseBarmanBackupSize extracts the backup size from the output
+// of barman-cloud-backup-show
+func parseBarmanBackupSize(rawJSON []byte) (uint64, error) {
+	var result barmanBackupSize
+	if err := json.Unmarshal(rawJSON, &result); err != nil {
+		return 0, err
+	}
+
+	return result.Cloud.Size, nil
+}
@@ -0,0 +1,86 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("free space check before restore", func() {
+	var originalAvailableDiskSpace func(string) (uint64, error)
+	var available map[string]uint64
+	var tempDir string
+
+	BeforeEach(func() {
+		tempDir = GinkgoT().TempDir()
+		available = make(map[string]uint64)
+		originalAvailableDiskSpace = availableDiskSpace
+		availableDiskSpace = func(path string) (uint64, error) {
+			return available[path], nil
+		}
+	})
+
+	AfterEach(func() {
+		availableDiskSpace = originalAvailableDiskSpace
+	})
+
+	It("extracts the backup size from the barman output", func() {
+		size, err := parseBarmanBackupSize([]byte(`{"cloud":{"backup_id":"20241105T000000","size":1048576}}`))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(size).To(BeEquivalentTo(1048576))
+	})
+
+	It("reports a zero size when the barman output doesn't contain it", func() {
+		size, err := parseBarmanBackupSize([]byte(`{"cloud":{"backup_id":"20241105T000000"}}`))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(size).To(BeZero())
+	})
+
+	It("accepts a backup fitting the data directory", func() {
+		available[tempDir] = 1200
+		info := InitInfo{PgData: filepath.Join(tempDir, "pgdata"), FreeSpaceMargin: 10}
+		Expect(info.ensureFreeSpaceForBackup(context.TODO(), 1000)).To(Succeed())
+	})
+
+	It("refuses a backup not fitting a small filesystem, considering the margin", func() {
+		available[tempDir] = 1050
+		info := InitInfo{PgData: filepath.Join(tempDir, "pgdata"), FreeSpaceMargin: 10}
+		err := info.ensureFreeSpaceForBackup(context.TODO(), 1000)
+		Expect(err).To(MatchError(ErrInsufficientDiskSpace))
+		Expect(err.Error()).To(ContainSubstring("1050 bytes available, 1100 bytes are required"))
+	})
+
+	It("checks the WAL volume when it is separate", func() {
+		pgData := filepath.Join(tempDir, "data")
+		pgWal := filepath.Join(tempDir, "wal")
+		Expect(os.Mkdir(pgData, 0o700)).To(Succeed())
+		Expect(os.Mkdir(pgWal, 0o700)).To(Succeed())
+		available[pgData] = 2000
+		available[pgWal] = 50
+
+		info := InitInfo{PgData: pgData, PgWal: pgWal, FreeSpaceMargin: 10}
+		Expect(info.ensureFreeSpaceForBackup(context.TODO(), 1000)).To(MatchError(ErrInsufficientDiskSpace))
+
+		available[pgWal] = 100
+		Expect(info.ensureFreeSpaceForBackup(context.TODO(), 1000)).To(Succeed())
+	})
+})
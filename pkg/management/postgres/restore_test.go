@@ -17,14 +17,21 @@ limitations under the License.
 package postgres
 
 import (
+	"context"
+	"errors"
 	"os"
 	"path"
+	"time"
 
+	"github.com/DATA-DOG/go-sqlmock"
+	barmanCatalog "github.com/cloudnative-pg/barman-cloud/pkg/catalog"
 	"github.com/cloudnative-pg/machinery/pkg/fileutils"
 	"github.com/thoas/go-funk"
 	"k8s.io/utils/strings/slices"
 
 	apiv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	"github.com/cloudnative-pg/cloudnative-pg/pkg/configfile"
+	"github.com/cloudnative-pg/cloudnative-pg/pkg/management/postgres/constants"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -204,3 +211,449 @@ var _ = Describe("testing restore InitInfo methods", func() {
 		Expect(enforcedParamsInPGData["max_connections"]).To(Equal(200))
 	})
 })
+
+var _ = Describe("verify-only restore", func() {
+	It("starts the restored instance as a standby", func() {
+		Expect(InitInfo{}.recoverySignalFile()).To(Equal("recovery.signal"))
+		Expect(InitInfo{VerifyOnly: true}.recoverySignalFile()).To(Equal("standby.signal"))
+	})
+
+	It("pauses the recovery instead of promoting", func() {
+		conf, err := pauseRecoveryConfiguration(
+			"recovery_target_action = promote\n" +
+				"restore_command = 'barman-cloud-wal-restore s3://bucket server %f %p'\n")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(conf).To(ContainSubstring("recovery_target_action = 'pause'"))
+		Expect(conf).ToNot(ContainSubstring("promote"))
+		Expect(conf).To(ContainSubstring("restore_command = 'barman-cloud-wal-restore s3://bucket server %f %p'"))
+	})
+
+	It("skips the base backup download in WAL-only mode", func(ctx SpecContext) {
+		pgData := path.Join(GinkgoT().TempDir(), "pgdata")
+		info := InitInfo{PgData: pgData, WALOnly: true}
+
+		// No barman-cloud-restore is available in the test environment,
+		// so the download would fail if attempted
+		Expect(info.restoreBaseBackup(ctx, &apiv1.Backup{}, nil, RestoreOptions{})).To(Succeed())
+		Expect(pgData).ToNot(BeADirectory())
+	})
+
+	It("attempts the base backup download when not in WAL-only mode", func(ctx SpecContext) {
+		info := InitInfo{PgData: path.Join(GinkgoT().TempDir(), "pgdata")}
+		Expect(info.restoreBaseBackup(ctx, &apiv1.Backup{}, nil, RestoreOptions{})).ToNot(Succeed())
+	})
+
+	It("writes the recovery_end_command into the recovery configuration", func() {
+		info := InitInfo{RecoveryEndCommand: "/usr/local/bin/cleanup-staging %r"}
+		conf, err := info.recoveryConfiguration(
+			"recovery_target_action = promote\n" +
+				"restore_command = 'barman-cloud-wal-restore s3://bucket server %f %p'\n")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(conf).To(ContainSubstring("recovery_end_command = '/usr/local/bin/cleanup-staging %r'"))
+		Expect(conf).To(ContainSubstring("recovery_target_action = promote"))
+	})
+
+	It("writes the restore_command override verbatim", func() {
+		info := InitInfo{RestoreCommand: "/usr/local/bin/fetch-wal --archive hybrid %f %p"}
+		conf, err := info.recoveryConfiguration(
+			"recovery_target_action = promote\n" +
+				"restore_command = 'barman-cloud-wal-restore s3://bucket server %f %p'\n")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(conf).To(ContainSubstring("restore_command = '/usr/local/bin/fetch-wal --archive hybrid %f %p'"))
+		Expect(conf).ToNot(ContainSubstring("barman-cloud-wal-restore"))
+		Expect(conf).To(ContainSubstring("recovery_target_action = promote"))
+	})
+
+	It("requires the placeholders in the restore_command override", func() {
+		Expect(validateRestoreCommand("")).To(Succeed())
+		Expect(validateRestoreCommand("cp /archive/%f %p")).To(Succeed())
+		Expect(validateRestoreCommand("cp /archive/%f /pg_wal")).To(MatchError(ContainSubstring("%p")))
+		Expect(validateRestoreCommand("cp /archive/next %p")).To(MatchError(ContainSubstring("%f")))
+	})
+
+	It("refuses a recovery_end_command containing shell metacharacters", func() {
+		Expect(validateRecoveryEndCommand("/bin/cleanup %r", false)).To(Succeed())
+		Expect(validateRecoveryEndCommand("/bin/cleanup; rm -rf /", false)).ToNot(Succeed())
+		Expect(validateRecoveryEndCommand("/bin/cleanup $(id)", false)).ToNot(Succeed())
+		Expect(validateRecoveryEndCommand("/bin/cleanup > /tmp/log", false)).ToNot(Succeed())
+		Expect(validateRecoveryEndCommand("/bin/cleanup > /tmp/log", true)).To(Succeed())
+	})
+
+	It("fails when the instance has been promoted", func(ctx SpecContext) {
+		db, mock, err := sqlmock.New()
+		Expect(err).ToNot(HaveOccurred())
+
+		mock.ExpectQuery("SELECT pg_is_in_recovery()").
+			WillReturnRows(sqlmock.NewRows([]string{"pg_is_in_recovery"}).AddRow(false))
+
+		err = verifyRestoredInstance(ctx, db)
+		Expect(err).To(HaveOccurred())
+		Expect(mock.ExpectationsWereMet()).To(Succeed())
+	})
+
+	It("succeeds when the instance is still in recovery", func(ctx SpecContext) {
+		db, mock, err := sqlmock.New()
+		Expect(err).ToNot(HaveOccurred())
+
+		mock.ExpectQuery("SELECT pg_is_in_recovery()").
+			WillReturnRows(sqlmock.NewRows([]string{"pg_is_in_recovery"}).AddRow(true))
+		mock.ExpectQuery("SELECT COUNT").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+
+		Expect(verifyRestoredInstance(ctx, db)).To(Succeed())
+		Expect(mock.ExpectationsWereMet()).To(Succeed())
+	})
+})
+
+var _ = Describe("data checksums verification", func() {
+	var binDir, invocationsFile string
+
+	writeBinary := func(name, script string) {
+		Expect(os.WriteFile(path.Join(binDir, name), []byte("#!/bin/sh\n"+script), 0o700)).To(Succeed()) // #nosec
+	}
+
+	BeforeEach(func() {
+		binDir = GinkgoT().TempDir()
+		invocationsFile = path.Join(binDir, "invocations")
+		writeBinary("pg_checksums", "echo \"$@\" >> "+invocationsFile+"\n")
+		GinkgoT().Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	})
+
+	It("runs pg_checksums on the data directory when the checksums are enabled", func(ctx SpecContext) {
+		writeBinary("pg_controldata", "echo 'Data page checksum version:           1'\n")
+		instance := InitInfo{PgData: "/var/lib/postgresql/data/pgdata"}.GetInstance()
+
+		Expect(verifyDataChecksums(ctx, instance)).To(Succeed())
+		invocations, err := os.ReadFile(invocationsFile) // #nosec
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(invocations)).To(Equal("--check -D /var/lib/postgresql/data/pgdata\n"))
+	})
+
+	It("skips the verification when the checksums are disabled", func(ctx SpecContext) {
+		writeBinary("pg_controldata", "echo 'Data page checksum version:           0'\n")
+
+		Expect(verifyDataChecksums(ctx, InitInfo{PgData: GinkgoT().TempDir()}.GetInstance())).To(Succeed())
+		Expect(invocationsFile).ToNot(BeAnExistingFile())
+	})
+
+	It("fails when a checksum doesn't match", func(ctx SpecContext) {
+		writeBinary("pg_controldata", "echo 'Data page checksum version:           1'\n")
+		writeBinary("pg_checksums", "echo 'checksum verification failed in file \"base/5/16384\"' >&2\nexit 1\n")
+
+		err := verifyDataChecksums(ctx, InitInfo{PgData: GinkgoT().TempDir()}.GetInstance())
+		Expect(err).To(MatchError(ContainSubstring("while verifying the data checksums")))
+		Expect(err).To(MatchError(ContainSubstring("base/5/16384")))
+	})
+})
+
+var _ = Describe("recovery consistency", func() {
+	BeforeEach(func() {
+		previousInterval := recoveryConsistencyPollInterval
+		recoveryConsistencyPollInterval = time.Millisecond
+		DeferCleanup(func() {
+			recoveryConsistencyPollInterval = previousInterval
+		})
+	})
+
+	It("polls until the instance accepts connections", func(ctx SpecContext) {
+		db, mock, err := sqlmock.New()
+		Expect(err).ToNot(HaveOccurred())
+
+		startingUp := errors.New("pq: the database system is starting up")
+		mock.ExpectQuery("SELECT pg_is_in_recovery()").WillReturnError(startingUp)
+		mock.ExpectQuery("SELECT pg_is_in_recovery()").WillReturnError(startingUp)
+		mock.ExpectQuery("SELECT pg_is_in_recovery()").
+			WillReturnRows(sqlmock.NewRows([]string{"pg_is_in_recovery"}).AddRow(true))
+
+		Expect(WaitForRecoveryConsistency(ctx, db)).To(BeTrue())
+		Expect(mock.ExpectationsWereMet()).To(Succeed())
+	})
+
+	It("reports a promoted instance", func(ctx SpecContext) {
+		db, mock, err := sqlmock.New()
+		Expect(err).ToNot(HaveOccurred())
+
+		mock.ExpectQuery("SELECT pg_is_in_recovery()").
+			WillReturnRows(sqlmock.NewRows([]string{"pg_is_in_recovery"}).AddRow(false))
+
+		Expect(WaitForRecoveryConsistency(ctx, db)).To(BeFalse())
+		Expect(mock.ExpectationsWereMet()).To(Succeed())
+	})
+
+	It("gives up when the recovery timeout expires", func(ctx SpecContext) {
+		db, mock, err := sqlmock.New()
+		Expect(err).ToNot(HaveOccurred())
+		mock.MatchExpectationsInOrder(false)
+		for range 1000 {
+			mock.ExpectQuery("SELECT pg_is_in_recovery()").
+				WillReturnError(errors.New("pq: the database system is starting up"))
+		}
+
+		info := InitInfo{RecoveryTimeout: 20 * time.Millisecond}
+		recoveryCtx, cancel := info.recoveryContext(ctx)
+		defer cancel()
+
+		start := time.Now()
+		_, err = WaitForRecoveryConsistency(recoveryCtx, db)
+		Expect(err).To(MatchError(context.DeadlineExceeded))
+		Expect(err).To(MatchError(ContainSubstring("starting up")))
+		Expect(time.Since(start)).To(BeNumerically("<", 5*time.Second))
+	})
+
+	It("doesn't bound the wait without a recovery timeout", func(ctx SpecContext) {
+		recoveryCtx, cancel := InitInfo{}.recoveryContext(ctx)
+		defer cancel()
+
+		_, hasDeadline := recoveryCtx.Deadline()
+		Expect(hasDeadline).To(BeFalse())
+	})
+})
+
+var _ = Describe("post-restore SQL file", func() {
+	var sqlFile string
+
+	BeforeEach(func() {
+		sqlFile = path.Join(GinkgoT().TempDir(), "migrations.sql")
+		_, err := fileutils.WriteStringToFile(sqlFile, "ALTER TABLE orders ADD COLUMN notes text;")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("executes the statements of the file", func(ctx SpecContext) {
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		Expect(err).ToNot(HaveOccurred())
+
+		mock.ExpectExec("ALTER TABLE orders ADD COLUMN notes text;").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		info := InitInfo{PostRestoreSQLFile: sqlFile}
+		Expect(info.executePostRestoreSQLFile(ctx, db)).To(Succeed())
+		Expect(mock.ExpectationsWereMet()).To(Succeed())
+	})
+
+	It("reports the failing statements", func(ctx SpecContext) {
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		Expect(err).ToNot(HaveOccurred())
+
+		mock.ExpectExec("ALTER TABLE orders ADD COLUMN notes text;").
+			WillReturnError(errors.New(`relation "orders" does not exist`))
+
+		info := InitInfo{PostRestoreSQLFile: sqlFile}
+		Expect(info.executePostRestoreSQLFile(ctx, db)).To(MatchError(ContainSubstring("orders")))
+		Expect(mock.ExpectationsWereMet()).To(Succeed())
+	})
+
+	It("is validated before the restore", func() {
+		info := InitInfo{PostRestoreSQLFile: path.Join(GinkgoT().TempDir(), "missing.sql")}
+		Expect(info.VerifyConfiguration()).To(MatchError(ContainSubstring("post-restore SQL file")))
+	})
+})
+
+var _ = Describe("backup selection", func() {
+	now := time.Now()
+	backupCatalog := barmanCatalog.NewCatalog([]barmanCatalog.BarmanBackup{
+		{ID: "20240101T000000", BeginTime: now.Add(-72 * time.Hour), EndTime: now.Add(-71 * time.Hour)},
+		{ID: "20240102T000000", BeginTime: now.Add(-48 * time.Hour), EndTime: now.Add(-47 * time.Hour)},
+		{ID: "20240103T000000", BeginTime: now.Add(-24 * time.Hour), EndTime: now.Add(-23 * time.Hour)},
+	})
+
+	It("uses the latest backup when no backup ID is passed", func() {
+		backup, err := selectTargetBackup(backupCatalog, nil, "")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(backup.ID).To(Equal("20240103T000000"))
+	})
+
+	It("uses the backup with the passed ID", func() {
+		backup, err := selectTargetBackup(backupCatalog, nil, "20240101T000000")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(backup.ID).To(Equal("20240101T000000"))
+
+		backup, err = selectTargetBackup(backupCatalog, &apiv1.RecoveryTarget{TargetTLI: "latest"}, "20240102T000000")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(backup.ID).To(Equal("20240102T000000"))
+	})
+
+	It("refuses a backup ID not in the catalog", func() {
+		_, err := selectTargetBackup(backupCatalog, nil, "20231231T000000")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("refuses a backup ID conflicting with the recovery target", func() {
+		_, err := selectTargetBackup(backupCatalog,
+			&apiv1.RecoveryTarget{BackupID: "20240101T000000"}, "20240102T000000")
+		Expect(err).To(MatchError(ContainSubstring("conflicts")))
+	})
+
+	It("passes the backup ID to barman-cloud-restore", func(ctx SpecContext) {
+		backup := &apiv1.Backup{Status: apiv1.BackupStatus{
+			DestinationPath: "s3://backups/",
+			ServerName:      "cluster-example",
+			BackupID:        "20240102T000000",
+		}}
+
+		options, err := InitInfo{PgData: "/var/lib/postgresql/data/pgdata"}.barmanCloudRestoreOptions(ctx, backup)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(options).To(Equal([]string{
+			"s3://backups/", "cluster-example", "20240102T000000", "/var/lib/postgresql/data/pgdata",
+		}))
+	})
+})
+
+var _ = Describe("immediate recovery target", func() {
+	clusterWithTarget := func(target *apiv1.RecoveryTarget) *apiv1.Cluster {
+		return &apiv1.Cluster{
+			Spec: apiv1.ClusterSpec{
+				Bootstrap: &apiv1.BootstrapConfiguration{
+					Recovery: &apiv1.BootstrapRecovery{RecoveryTarget: target},
+				},
+			},
+		}
+	}
+
+	It("stops the recovery once a consistent state is reached", func() {
+		target, err := InitInfo{TargetImmediate: true}.recoveryTarget(clusterWithTarget(nil))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(target.BuildPostgresOptions()).To(ContainSubstring("recovery_target = immediate\n"))
+	})
+
+	It("keeps the timeline and the backup of the recovery target", func() {
+		cluster := clusterWithTarget(&apiv1.RecoveryTarget{TargetTLI: "latest", BackupID: "20240101T000000"})
+		target, err := InitInfo{TargetImmediate: true}.recoveryTarget(cluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(target.BackupID).To(Equal("20240101T000000"))
+		Expect(target.BuildPostgresOptions()).To(And(
+			ContainSubstring("recovery_target_timeline = 'latest'\n"),
+			ContainSubstring("recovery_target = immediate\n")))
+		Expect(cluster.Spec.Bootstrap.Recovery.RecoveryTarget.TargetImmediate).To(BeNil())
+	})
+
+	It("uses the recovery target of the cluster when not set", func() {
+		cluster := clusterWithTarget(&apiv1.RecoveryTarget{TargetName: "before-migration"})
+		target, err := InitInfo{}.recoveryTarget(cluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(target.BuildPostgresOptions()).ToNot(ContainSubstring("recovery_target = immediate"))
+	})
+
+	DescribeTable("is mutually exclusive with the other recovery targets",
+		func(target *apiv1.RecoveryTarget, conflict string) {
+			_, err := InitInfo{TargetImmediate: true}.recoveryTarget(clusterWithTarget(target))
+			Expect(err).To(MatchError(ContainSubstring(conflict)))
+		},
+		Entry("target XID", &apiv1.RecoveryTarget{TargetXID: "1234"}, "targetXID"),
+		Entry("target name", &apiv1.RecoveryTarget{TargetName: "before-migration"}, "targetName"),
+		Entry("target LSN", &apiv1.RecoveryTarget{TargetLSN: "0/3000000"}, "targetLSN"),
+		Entry("target time", &apiv1.RecoveryTarget{TargetTime: "2024-01-01 00:00:00"}, "targetTime"),
+	)
+})
+
+var _ = Describe("recovery configuration preview", func() {
+	It("renders the restore_command, the recovery target and the restore options", func() {
+		cluster := &apiv1.Cluster{
+			Spec: apiv1.ClusterSpec{
+				Bootstrap: &apiv1.BootstrapConfiguration{
+					Recovery: &apiv1.BootstrapRecovery{
+						RecoveryTarget: &apiv1.RecoveryTarget{TargetTLI: "latest", TargetLSN: "0/3000000"},
+					},
+				},
+			},
+		}
+		info := InitInfo{
+			VerifyOnly:         true,
+			RecoveryEndCommand: "/usr/local/bin/notify",
+		}
+
+		configuration, err := info.renderRecoveryConfiguration(cluster, localRestoreWalConfig("/backup/wals"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(configuration).To(Equal("recovery_target_action = 'pause'\n" +
+			"restore_command = 'cp /backup/wals/%f %p'\n" +
+			"\n" +
+			"recovery_target_timeline = 'latest'\n" +
+			"recovery_target_lsn = '0/3000000'\n" +
+			"recovery_target_inclusive = true\n" +
+			"recovery_end_command = '/usr/local/bin/notify'\n"))
+	})
+
+	It("renders the restore_command override and the immediate target", func() {
+		info := InitInfo{
+			RestoreCommand:  "cp /mnt/archive/%f %p",
+			TargetImmediate: true,
+		}
+
+		configuration, err := info.renderRecoveryConfiguration(&apiv1.Cluster{}, localRestoreWalConfig("/backup/wals"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(configuration).To(And(
+			ContainSubstring("restore_command = 'cp /mnt/archive/%f %p'\n"),
+			ContainSubstring("recovery_target = immediate\n"),
+			Not(ContainSubstring("/backup/wals"))))
+	})
+
+	It("renders the prompt promotion", func() {
+		info := InitInfo{PromptPromotion: true}
+
+		configuration, err := info.renderRecoveryConfiguration(&apiv1.Cluster{}, localRestoreWalConfig("/backup/wals"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(configuration).To(Equal("recovery_target_action = 'promote'\n" +
+			"restore_command = 'cp /backup/wals/%f %p'\n" +
+			"recovery_target_timeline = 'current'\n"))
+	})
+
+	It("refuses the prompt promotion when only verifying the restore", func() {
+		info := InitInfo{PromptPromotion: true, VerifyOnly: true}
+
+		_, err := info.renderRecoveryConfiguration(&apiv1.Cluster{}, localRestoreWalConfig("/backup/wals"))
+		Expect(err).To(MatchError(ContainSubstring("verifying")))
+	})
+
+	It("refuses the prompt promotion with a target timeline", func() {
+		cluster := &apiv1.Cluster{
+			Spec: apiv1.ClusterSpec{
+				Bootstrap: &apiv1.BootstrapConfiguration{
+					Recovery: &apiv1.BootstrapRecovery{
+						RecoveryTarget: &apiv1.RecoveryTarget{TargetTLI: "latest"},
+					},
+				},
+			},
+		}
+		info := InitInfo{PromptPromotion: true}
+
+		_, err := info.renderRecoveryConfiguration(cluster, localRestoreWalConfig("/backup/wals"))
+		Expect(err).To(MatchError(ContainSubstring("targetTLI")))
+	})
+})
+
+var _ = Describe("WAL archiving suspension on restore", func() {
+	var pgData string
+
+	// effectiveArchiveSettings returns the archive settings of the
+	// restored custom.conf, where the last occurrence of each one wins
+	effectiveArchiveSettings := func() []string {
+		lines, err := fileutils.ReadFileLines(path.Join(pgData, constants.PostgresqlCustomConfigurationFile))
+		Expect(err).ToNot(HaveOccurred())
+		return configfile.ReadLinesFromConfigurationContents(lines, "archive_mode", "archive_command")
+	}
+
+	BeforeEach(func() {
+		pgData = GinkgoT().TempDir()
+		Expect(os.WriteFile(path.Join(pgData, constants.PostgresqlCustomConfigurationFile),
+			[]byte("archive_mode = 'on'\narchive_command = '/controller/manager wal-archive %p'\n"),
+			0o600)).To(Succeed())
+	})
+
+	It("makes the archiver fail by default", func() {
+		Expect(InitInfo{PgData: pgData}.suspendWALArchiving()).To(Succeed())
+		Expect(effectiveArchiveSettings()).To(Equal([]string{
+			"archive_mode = 'on'",
+			"archive_command = '/controller/manager wal-archive %p'",
+			"archive_command = 'false'",
+		}))
+	})
+
+	It("disables archive_mode when requested", func() {
+		Expect(InitInfo{PgData: pgData, NoArchiveOnRestore: true}.suspendWALArchiving()).To(Succeed())
+		Expect(effectiveArchiveSettings()).To(Equal([]string{
+			"archive_mode = 'on'",
+			"archive_command = '/controller/manager wal-archive %p'",
+			"archive_command = 'false'",
+			"archive_mode = 'off'",
+		}))
+	})
+})
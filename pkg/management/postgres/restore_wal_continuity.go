@@ -0,0 +1,216 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	barmanCommand "github.com/cloudnative-pg/barman-cloud/pkg/command"
+	barmanRestorer "github.com/cloudnative-pg/barman-cloud/pkg/restorer"
+	"github.com/cloudnative-pg/machinery/pkg/fileutils"
+	"github.com/cloudnative-pg/machinery/pkg/log"
+
+	apiv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	postgresSpec "github.com/cloudnative-pg/cloudnative-pg/pkg/postgres"
+	"github.com/cloudnative-pg/cloudnative-pg/pkg/utils"
+)
+
+// verifyWALContinuity ensures that every WAL segment from the beginning of
+// the backup to the end of the backup, or to the recovery target LSN when
+// set, is in the archive. A gap in the archived WAL would otherwise be
+// detected only by the recovery, possibly stopping it before the target
+func (info InitInfo) verifyWALContinuity(
+	ctx context.Context,
+	cluster *apiv1.Cluster,
+	env []string,
+	backup *apiv1.Backup,
+) error {
+	if !info.VerifyWALContinuity {
+		return nil
+	}
+	contextLogger := log.FromContext(ctx)
+
+	walSegmentSize, err := info.restoredWALSegmentSize()
+	if err != nil {
+		return err
+	}
+
+	var targetLSN string
+	if cluster.Spec.Bootstrap != nil && cluster.Spec.Bootstrap.Recovery != nil &&
+		cluster.Spec.Bootstrap.Recovery.RecoveryTarget != nil {
+		targetLSN = cluster.Spec.Bootstrap.Recovery.RecoveryTarget.TargetLSN
+	}
+
+	segments, err := neededWALSegments(backup.Status.BeginWal, backup.Status.EndWal, targetLSN, walSegmentSize)
+	if err != nil {
+		return fmt.Errorf("while computing the WAL segments needed by the recovery: %w", err)
+	}
+
+	// it's the full path of the file that will temporarily contain each checked segment
+	const checkWALPath = postgresSpec.RecoveryTemporaryDirectory + "/continuity.wal"
+	if err := fileutils.EnsureParentDirectoryExists(checkWALPath); err != nil {
+		return err
+	}
+
+	rest, err := barmanRestorer.New(ctx, env, postgresSpec.SpoolDirectory)
+	if err != nil {
+		return err
+	}
+
+	opts, err := barmanCommand.CloudWalRestoreOptions(ctx, &apiv1.BarmanObjectStoreConfiguration{
+		BarmanCredentials: backup.Status.BarmanCredentials,
+		EndpointCA:        backup.Status.EndpointCA,
+		EndpointURL:       backup.Status.EndpointURL,
+		DestinationPath:   backup.Status.DestinationPath,
+		ServerName:        backup.Status.ServerName,
+	}, cluster.Name)
+	if err != nil {
+		return err
+	}
+
+	contextLogger.Info("Verifying the continuity of the archived WAL",
+		"firstSegment", segments[0],
+		"lastSegment", segments[len(segments)-1],
+		"segments", len(segments))
+
+	return checkWALSegmentsInArchive(segments, func(walName string) error {
+		defer func() {
+			if err := fileutils.RemoveFile(checkWALPath); err != nil {
+				contextLogger.Error(err, "while deleting the temporary wal file")
+			}
+		}()
+		return rest.Restore(walName, checkWALPath, opts)
+	})
+}
+
+// restoredWALSegmentSize returns the WAL segment size of the restored data directory
+func (info InitInfo) restoredWALSegmentSize() (int64, error) {
+	pgControlDataString, err := info.GetInstance().GetPgControldata()
+	if err != nil {
+		return 0, fmt.Errorf("while running pg_controldata to detect WAL segment size: %w", err)
+	}
+
+	walSegmentSizeString, ok := utils.ParsePgControldataOutput(pgControlDataString)["Bytes per WAL segment"]
+	if !ok {
+		return 0, fmt.Errorf("no 'Bytes per WAL segment' section into pg_controldata output")
+	}
+
+	walSegmentSize, err := strconv.ParseInt(walSegmentSizeString, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf(
+			"wrong 'Bytes per WAL segment' pg_controldata value (not an integer): '%s' %w",
+			walSegmentSizeString, err)
+	}
+
+	return walSegmentSize, nil
+}
+
+// checkWALSegmentsInArchive fetches every passed WAL segment, reporting
+// all the segments missing from the archive
+func checkWALSegmentsInArchive(segments []string, fetch func(walName string) error) error {
+	var missingSegments []string
+	for _, segment := range segments {
+		err := fetch(segment)
+		if errors.Is(err, barmanRestorer.ErrWALNotFound) {
+			missingSegments = append(missingSegments, segment)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("while checking the presence of %s in the archive: %w", segment, err)
+		}
+	}
+
+	if len(missingSegments) > 0 {
+		return fmt.Errorf("WAL gap detected in the archive, missing segments: %s",
+			strings.Join(missingSegments, ", "))
+	}
+
+	return nil
+}
+
+// neededWALSegments returns the names of the WAL segments from beginWAL
+// to endWAL, extended up to the segment containing targetLSN when set
+func neededWALSegments(beginWAL, endWAL, targetLSN string, walSegmentSize int64) ([]string, error) {
+	first, err := postgresSpec.SegmentFromName(beginWAL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid begin WAL %q: %w", beginWAL, err)
+	}
+	last, err := postgresSpec.SegmentFromName(endWAL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid end WAL %q: %w", endWAL, err)
+	}
+	if first.Tli != last.Tli {
+		return nil, fmt.Errorf("the backup spans timelines %d and %d", first.Tli, last.Tli)
+	}
+
+	if targetLSN != "" {
+		target, err := segmentFromLSN(first.Tli, targetLSN, walSegmentSize)
+		if err != nil {
+			return nil, err
+		}
+		if segmentBefore(last, target) {
+			last = target
+		}
+	}
+
+	if segmentBefore(last, first) {
+		return nil, fmt.Errorf("the end WAL %s precedes the begin WAL %s", last.Name(), first.Name())
+	}
+
+	var segments []string
+	for current := first; ; current = current.NextSegments(2, nil, &walSegmentSize)[1] {
+		segments = append(segments, current.Name())
+		if current == last {
+			return segments, nil
+		}
+	}
+}
+
+// segmentFromLSN returns the segment of the passed timeline containing
+// the passed LSN, in the X/Y format used by PostgreSQL
+func segmentFromLSN(tli int32, lsn string, walSegmentSize int64) (postgresSpec.Segment, error) {
+	high, low, found := strings.Cut(lsn, "/")
+	if !found {
+		return postgresSpec.Segment{}, fmt.Errorf("invalid LSN %q", lsn)
+	}
+	highValue, err := strconv.ParseUint(high, 16, 32)
+	if err != nil {
+		return postgresSpec.Segment{}, fmt.Errorf("invalid LSN %q: %w", lsn, err)
+	}
+	lowValue, err := strconv.ParseUint(low, 16, 32)
+	if err != nil {
+		return postgresSpec.Segment{}, fmt.Errorf("invalid LSN %q: %w", lsn, err)
+	}
+
+	segmentNumber := (highValue<<32 | lowValue) / uint64(walSegmentSize) //nolint:gosec
+	segmentsPerLog := (uint64(1) << 32) / uint64(walSegmentSize)         //nolint:gosec
+	return postgresSpec.Segment{
+		Tli: tli,
+		Log: int32(segmentNumber / segmentsPerLog), //nolint:gosec
+		Seg: int32(segmentNumber % segmentsPerLog), //nolint:gosec
+	}, nil
+}
+
+// segmentBefore returns true when the segment a precedes the segment b
+// in the same timeline
+func segmentBefore(a, b postgresSpec.Segment) bool {
+	return a.Log < b.Log || (a.Log == b.Log && a.Seg < b.Seg)
+}
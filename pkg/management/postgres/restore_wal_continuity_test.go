@@ -0,0 +1,120 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"errors"
+
+	barmanRestorer "github.com/cloudnative-pg/barman-cloud/pkg/restorer"
+
+	postgresSpec "github.com/cloudnative-pg/cloudnative-pg/pkg/postgres"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("WAL continuity verification", func() {
+	walSegmentSize := postgresSpec.DefaultWALSegmentSize
+
+	Context("needed segments", func() {
+		It("walks the segments of the backup", func() {
+			segments, err := neededWALSegments(
+				"0000000100000000000000FE", "000000010000000100000001", "", walSegmentSize)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(segments).To(Equal([]string{
+				"0000000100000000000000FE",
+				"0000000100000000000000FF",
+				"000000010000000100000000",
+				"000000010000000100000001",
+			}))
+		})
+
+		It("extends the segments up to the recovery target LSN", func() {
+			segments, err := neededWALSegments(
+				"000000020000000000000003", "000000020000000000000003", "0/5000028", walSegmentSize)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(segments).To(Equal([]string{
+				"000000020000000000000003",
+				"000000020000000000000004",
+				"000000020000000000000005",
+			}))
+		})
+
+		It("ignores a recovery target LSN inside the backup", func() {
+			segments, err := neededWALSegments(
+				"000000010000000000000003", "000000010000000000000004", "0/3000100", walSegmentSize)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(segments).To(HaveLen(2))
+		})
+
+		It("uses the segment size of the data directory", func() {
+			segmentSize := int64(1 << 30)
+			segments, err := neededWALSegments(
+				"000000010000000000000003", "000000010000000000000003", "1/40000000", segmentSize)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(segments).To(Equal([]string{"000000010000000000000003", "000000010000000100000000",
+				"000000010000000100000001"}))
+		})
+
+		DescribeTable("refuses invalid ranges",
+			func(beginWAL, endWAL, targetLSN string) {
+				_, err := neededWALSegments(beginWAL, endWAL, targetLSN, walSegmentSize)
+				Expect(err).To(HaveOccurred())
+			},
+			Entry("invalid names", "invalid", "000000010000000000000003", ""),
+			Entry("timeline switches", "000000010000000000000003", "000000020000000000000004", ""),
+			Entry("reversed ranges", "000000010000000000000004", "000000010000000000000003", ""),
+			Entry("invalid LSNs", "000000010000000000000003", "000000010000000000000004", "0-3000100"),
+		)
+	})
+
+	Context("archive check", func() {
+		segments := []string{
+			"000000010000000000000003",
+			"000000010000000000000004",
+			"000000010000000000000005",
+			"000000010000000000000006",
+		}
+
+		It("succeeds when every segment is archived", func() {
+			var fetched []string
+			Expect(checkWALSegmentsInArchive(segments, func(walName string) error {
+				fetched = append(fetched, walName)
+				return nil
+			})).To(Succeed())
+			Expect(fetched).To(Equal(segments))
+		})
+
+		It("reports the gaps in the archive", func() {
+			err := checkWALSegmentsInArchive(segments, func(walName string) error {
+				if walName == "000000010000000000000004" || walName == "000000010000000000000005" {
+					return barmanRestorer.ErrWALNotFound
+				}
+				return nil
+			})
+			Expect(err).To(MatchError("WAL gap detected in the archive, missing segments: " +
+				"000000010000000000000004, 000000010000000000000005"))
+		})
+
+		It("stops on the errors reaching the archive", func() {
+			err := checkWALSegmentsInArchive(segments, func(string) error {
+				return errors.New("connection refused")
+			})
+			Expect(err).To(MatchError(ContainSubstring("connection refused")))
+		})
+	})
+})
@@ -0,0 +1,94 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"fmt"
+
+	apiv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	postgresSpec "github.com/cloudnative-pg/cloudnative-pg/pkg/postgres"
+)
+
+const (
+	// minWALSegmentSize and maxWALSegmentSize are the limits of the WAL
+	// segment size, which is a power of two, supported by PostgreSQL
+	minWALSegmentSize = 1 << 20
+	maxWALSegmentSize = 1 << 30
+)
+
+// checkWALSegmentSize ensures the WAL segment size of the restored data
+// directory is supported and matches the one of the backup, so that the
+// WAL files of the archive can be replayed during the recovery
+func (info InitInfo) checkWALSegmentSize(backup *apiv1.Backup) error {
+	restoredSize, err := info.restoredWALSegmentSize()
+	if err != nil {
+		return err
+	}
+
+	return validateWALSegmentSize(backup, restoredSize)
+}
+
+// validateWALSegmentSize checks the passed WAL segment size of the restored
+// data directory against the one detected from the metadata of the backup
+func validateWALSegmentSize(backup *apiv1.Backup, restoredSize int64) error {
+	if restoredSize < minWALSegmentSize || restoredSize > maxWALSegmentSize ||
+		restoredSize&(restoredSize-1) != 0 {
+		return fmt.Errorf("the restored data directory uses an unsupported WAL segment size of %d bytes",
+			restoredSize)
+	}
+
+	backupSize, detected := backupWALSegmentSize(backup.Status.BeginWal, backup.Status.BeginLSN)
+	if !detected || backupSize == restoredSize {
+		return nil
+	}
+
+	return fmt.Errorf("WAL segment size mismatch: the backup %s was taken with WAL segments of %d bytes, "+
+		"but the restored data directory uses %d bytes", backup.Name, backupSize, restoredSize)
+}
+
+// backupWALSegmentSize detects the WAL segment size of a backup from the
+// name of its first WAL file and its starting LSN, as only one segment
+// size maps that LSN into that WAL file. False is returned when the
+// metadata is missing or any size would map the LSN to the same WAL file,
+// as it happens in the first segment of the WAL
+func backupWALSegmentSize(beginWAL, beginLSN string) (int64, bool) {
+	if beginWAL == "" || beginLSN == "" {
+		return 0, false
+	}
+
+	first, err := postgresSpec.SegmentFromName(beginWAL)
+	if err != nil {
+		return 0, false
+	}
+
+	var detectedSize int64
+	for size := int64(minWALSegmentSize); size <= maxWALSegmentSize; size <<= 1 {
+		segment, err := segmentFromLSN(first.Tli, beginLSN, size)
+		if err != nil {
+			return 0, false
+		}
+		if segment != first {
+			continue
+		}
+		if detectedSize != 0 {
+			return 0, false
+		}
+		detectedSize = size
+	}
+
+	return detectedSize, detectedSize != 0
+}
@@ -0,0 +1,82 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	apiv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("WAL segment size of the restored backup", func() {
+	const (
+		sixteenMegabytes   = 16 << 20
+		sixtyFourMegabytes = 64 << 20
+	)
+
+	backupWith := func(beginWAL, beginLSN string) *apiv1.Backup {
+		return &apiv1.Backup{
+			ObjectMeta: metav1.ObjectMeta{Name: "backup-example"},
+			Status:     apiv1.BackupStatus{BeginWal: beginWAL, BeginLSN: beginLSN},
+		}
+	}
+
+	DescribeTable("detects the segment size from the backup metadata",
+		func(beginWAL, beginLSN string, expectedSize int64) {
+			size, detected := backupWALSegmentSize(beginWAL, beginLSN)
+			Expect(detected).To(BeTrue())
+			Expect(size).To(Equal(expectedSize))
+		},
+		Entry("16MB segments", "000000010000000000000003", "0/3000028", int64(sixteenMegabytes)),
+		Entry("16MB segments, in a later log", "000000020000000100000003", "1/3000028", int64(sixteenMegabytes)),
+		Entry("64MB segments", "000000010000000000000003", "0/C000028", int64(sixtyFourMegabytes)),
+	)
+
+	DescribeTable("can't detect the segment size",
+		func(beginWAL, beginLSN string) {
+			_, detected := backupWALSegmentSize(beginWAL, beginLSN)
+			Expect(detected).To(BeFalse())
+		},
+		Entry("missing metadata", "", ""),
+		Entry("first segment of the WAL", "000000010000000000000000", "0/28"),
+		Entry("inconsistent metadata", "000000010000000000000003", "0/9000028"),
+	)
+
+	It("accepts a restored data directory matching the backup", func() {
+		backup := backupWith("000000010000000000000003", "0/3000028")
+		Expect(validateWALSegmentSize(backup, sixteenMegabytes)).To(Succeed())
+	})
+
+	It("refuses a restored data directory not matching the backup", func() {
+		backup := backupWith("000000010000000000000003", "0/C000028")
+		Expect(validateWALSegmentSize(backup, sixteenMegabytes)).To(MatchError(
+			"WAL segment size mismatch: the backup backup-example was taken with WAL segments of 67108864 bytes, " +
+				"but the restored data directory uses 16777216 bytes"))
+	})
+
+	It("refuses an unsupported segment size", func() {
+		Expect(validateWALSegmentSize(backupWith("", ""), 3<<20)).To(MatchError(ContainSubstring("unsupported")))
+		Expect(validateWALSegmentSize(backupWith("", ""), 2<<30)).To(MatchError(ContainSubstring("unsupported")))
+	})
+
+	It("accepts any supported size when the backup size can't be detected", func() {
+		Expect(validateWALSegmentSize(backupWith("", ""), sixtyFourMegabytes)).To(Succeed())
+	})
+})
@@ -0,0 +1,155 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/cloudnative-pg/machinery/pkg/log"
+
+	apiv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	"github.com/cloudnative-pg/cloudnative-pg/pkg/utils"
+)
+
+// ErrDataDirectoryNotShutDown is raised when pg_rewind is requested on a
+// data directory that has not been cleanly shut down
+var ErrDataDirectoryNotShutDown = errors.New("the data directory has not been cleanly shut down")
+
+// ErrRewindNotPossible is raised when pg_rewind reports that the data
+// directory can't be rewound, and needs to be cloned again
+var ErrRewindNotPossible = errors.New("pg_rewind is not possible")
+
+// rewindNotPossibleMessages are the errors pg_rewind reports when the data
+// directory can't be rewound, whatever the state of the instances is
+var rewindNotPossibleMessages = []string{
+	"source and target clusters are from different systems",
+	"clusters are not compatible with this version of pg_rewind",
+	"target server needs to use either data checksums or \"wal_log_hints = on\"",
+	"could not find common ancestor of the source and target cluster's timelines",
+}
+
+// Rewind aligns the data directory of a former primary with the current
+// primary of the cluster using pg_rewind. If pg_rewind can't be used, the
+// data directory is cloned again from the primary using pg_basebackup
+func (info InitInfo) Rewind(ctx context.Context, cluster *apiv1.Cluster) error {
+	instance := info.GetInstance().
+		WithNamespace(info.Namespace).
+		WithPodName(info.PodName).
+		WithClusterName(info.ClusterName)
+
+	rewind := func() error {
+		return info.rewindInstance(ctx, instance, cluster)
+	}
+
+	clone := func() error {
+		// The existing data directory is moved aside before cloning
+		if err := info.CheckTargetDataDirectory(ctx); err != nil {
+			return err
+		}
+		return info.Join(ctx, cluster)
+	}
+
+	return rewindOrClone(ctx, rewind, clone)
+}
+
+// rewindOrClone executes the passed rewind function, falling back to
+// the clone one only when the former reports that a rewind is not possible.
+// Every other error is returned as is
+func rewindOrClone(ctx context.Context, rewind, clone func() error) error {
+	contextLogger := log.FromContext(ctx)
+
+	err := rewind()
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, ErrRewindNotPossible) {
+		return err
+	}
+
+	contextLogger.Info("pg_rewind is not possible, cloning the data directory from the primary",
+		"err", err)
+	if err := clone(); err != nil {
+		return fmt.Errorf("while cloning the data directory after pg_rewind failure: %w", err)
+	}
+
+	return nil
+}
+
+// rewindInstance checks the data directory was cleanly shut down, and
+// then runs pg_rewind against the current primary, demoting the instance
+func (info InitInfo) rewindInstance(ctx context.Context, instance *Instance, cluster *apiv1.Cluster) error {
+	controldata, err := instance.GetPgControldata()
+	if err != nil {
+		return err
+	}
+
+	if err := checkCleanShutdown(ctx, controldata); err != nil {
+		return err
+	}
+
+	pgVersion, err := cluster.GetPostgresqlVersion()
+	if err != nil {
+		return err
+	}
+
+	if err := instance.Rewind(ctx, pgVersion); err != nil {
+		return err
+	}
+
+	return instance.Demote(ctx, cluster)
+}
+
+// checkCleanShutdown verifies, given the output of pg_controldata, that
+// the data directory has been cleanly shut down
+func checkCleanShutdown(ctx context.Context, controldata string) error {
+	state := utils.PgDataState(
+		utils.ParsePgControldataOutput(controldata)[utils.PgControlDataDatabaseClusterStateKey])
+	if !state.IsShutdown(ctx) {
+		return fmt.Errorf("%w: cluster state is %q", ErrDataDirectoryNotShutDown, state)
+	}
+
+	return nil
+}
+
+// rewindOutput collects the lines pg_rewind writes on its standard error,
+// to detect why it failed
+type rewindOutput struct {
+	lines []string
+}
+
+// Write implements the io.Writer interface, receiving a line at a time
+func (output *rewindOutput) Write(p []byte) (int, error) {
+	output.lines = append(output.lines, string(p))
+	return len(p), nil
+}
+
+// rewindNotPossible checks if pg_rewind reported that the data directory
+// can't be rewound
+func (output *rewindOutput) rewindNotPossible() bool {
+	for _, line := range output.lines {
+		for _, message := range rewindNotPossibleMessages {
+			if strings.Contains(line, message) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
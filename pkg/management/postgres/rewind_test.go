@@ -0,0 +1,111 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"errors"
+	"fmt"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("rewinding a former primary", func() {
+	It("uses pg_rewind when possible", func(ctx SpecContext) {
+		cloned := false
+		err := rewindOrClone(ctx,
+			func() error { return nil },
+			func() error {
+				cloned = true
+				return nil
+			})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cloned).To(BeFalse())
+	})
+
+	It("falls back to pg_basebackup when pg_rewind is not possible", func(ctx SpecContext) {
+		cloned := false
+		err := rewindOrClone(ctx,
+			func() error { return fmt.Errorf("%w: exit status 1", ErrRewindNotPossible) },
+			func() error {
+				cloned = true
+				return nil
+			})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cloned).To(BeTrue())
+	})
+
+	It("reports an error when the fallback fails too", func(ctx SpecContext) {
+		err := rewindOrClone(ctx,
+			func() error { return fmt.Errorf("%w: exit status 1", ErrRewindNotPossible) },
+			func() error { return errors.New("pg_basebackup failed") })
+		Expect(err).To(MatchError(ContainSubstring("pg_basebackup failed")))
+	})
+
+	DescribeTable("doesn't clone the data directory on the other errors",
+		func(ctx SpecContext, rewindErr error) {
+			cloned := false
+			err := rewindOrClone(ctx,
+				func() error { return rewindErr },
+				func() error {
+					cloned = true
+					return nil
+				})
+			Expect(err).To(MatchError(rewindErr))
+			Expect(cloned).To(BeFalse())
+		},
+		Entry("when pg_rewind fails", errors.New("error executing pg_rewind: exit status 1")),
+		Entry("when the data directory was not shut down",
+			fmt.Errorf("%w: cluster state is %q", ErrDataDirectoryNotShutDown, "in production")),
+	)
+
+	DescribeTable("detects when pg_rewind is not possible from its output",
+		func(lines []string, expected bool) {
+			var output rewindOutput
+			for _, line := range lines {
+				_, err := output.Write([]byte(line))
+				Expect(err).ToNot(HaveOccurred())
+			}
+			Expect(output.rewindNotPossible()).To(Equal(expected))
+		},
+		Entry("without output", nil, false),
+		Entry("with a connection failure", []string{
+			"pg_rewind: error: could not connect to server: Connection refused",
+		}, false),
+		Entry("without data checksums or wal_log_hints", []string{
+			"pg_rewind: connected to server",
+			`pg_rewind: error: target server needs to use either data checksums or "wal_log_hints = on"`,
+		}, true),
+		Entry("without a common ancestor", []string{
+			"pg_rewind: fatal: could not find common ancestor of the source and target cluster's timelines",
+		}, true),
+		Entry("with different systems", []string{
+			"pg_rewind: fatal: source and target clusters are from different systems",
+		}, true),
+	)
+
+	It("accepts a cleanly shut down data directory", func(ctx SpecContext) {
+		Expect(checkCleanShutdown(ctx, "Database cluster state:               shut down\n")).To(Succeed())
+		Expect(checkCleanShutdown(ctx, "Database cluster state:               shut down in recovery\n")).
+			To(Succeed())
+	})
+
+	It("rejects a data directory that was not cleanly shut down", func(ctx SpecContext) {
+		err := checkCleanShutdown(ctx, "Database cluster state:               in production\n")
+		Expect(err).To(MatchError(ErrDataDirectoryNotShutDown))
+	})
+})
@@ -0,0 +1,133 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// procDirectory is the directory where the kernel exposes its limits
+var procDirectory = "/proc"
+
+// sharedBuffersDefaultUnit is the unit of shared_buffers
+// when its value is expressed without a unit
+const sharedBuffersDefaultUnit = 8 << 10
+
+// defaultSharedBuffers is the shared_buffers written by initdb
+// when the kernel allows it
+const defaultSharedBuffers = "128MB"
+
+// validHugePages are the values accepted by PostgreSQL for huge_pages
+var validHugePages = []string{"off", "on", "try"}
+
+// checkSharedMemory ensures that, when huge pages are required, enough of
+// them are free for the transient instance to allocate the requested
+// shared_buffers, returning an actionable error instead of letting
+// PostgreSQL fail with a cryptic one at startup
+func (info InitInfo) checkSharedMemory() error {
+	sharedBuffers := info.SharedBuffers
+	if sharedBuffers == "" {
+		sharedBuffers = info.InitDBSettings["shared_buffers"]
+	}
+	hugePages := info.HugePages
+	if hugePages == "" {
+		hugePages = info.InitDBSettings["huge_pages"]
+	}
+	if sharedBuffers == "" {
+		// Without huge pages, the default shared_buffers fits
+		// the limits initdb has already checked
+		if hugePages != "on" {
+			return nil
+		}
+		sharedBuffers = defaultSharedBuffers
+	}
+
+	requested, err := parseMemorySize(sharedBuffers, sharedBuffersDefaultUnit)
+	if err != nil {
+		return fmt.Errorf("invalid shared_buffers: %w", err)
+	}
+
+	if hugePages == "on" {
+		if err := checkHugePages(requested); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkHugePages ensures that enough free huge pages are
+// available to allocate the requested shared memory
+func checkHugePages(requested int64) error {
+	content, err := os.ReadFile(filepath.Join(procDirectory, "meminfo"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("while reading the memory information: %w", err)
+	}
+
+	freePages, pageSize, err := parseHugePagesInfo(content)
+	if err != nil {
+		return err
+	}
+
+	if available := freePages * pageSize; available < requested {
+		return fmt.Errorf("huge_pages is on and shared_buffers requires %d bytes, but only %d bytes of "+
+			"huge pages are available: reserve more huge pages on the node, reduce shared_buffers "+
+			"or set huge_pages to try", requested, available)
+	}
+
+	return nil
+}
+
+// parseHugePagesInfo extracts the number of free huge pages
+// and their size in bytes from the content of /proc/meminfo
+func parseHugePagesInfo(content []byte) (freePages int64, pageSize int64, err error) {
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		name, value, found := strings.Cut(scanner.Text(), ":")
+		if !found {
+			continue
+		}
+		fields := strings.Fields(value)
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch name {
+		case "HugePages_Free":
+			if freePages, err = strconv.ParseInt(fields[0], 10, 64); err != nil {
+				return 0, 0, fmt.Errorf("while parsing HugePages_Free: %w", err)
+			}
+		case "Hugepagesize":
+			if pageSize, err = strconv.ParseInt(fields[0], 10, 64); err != nil {
+				return 0, 0, fmt.Errorf("while parsing Hugepagesize: %w", err)
+			}
+			// The huge page size is expressed in kB
+			pageSize <<= 10
+		}
+	}
+
+	return freePages, pageSize, scanner.Err()
+}
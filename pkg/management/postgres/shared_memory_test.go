@@ -0,0 +1,107 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("shared memory preflight", func() {
+	BeforeEach(func() {
+		originalProcDirectory := procDirectory
+		procDirectory = GinkgoT().TempDir()
+		DeferCleanup(func() {
+			procDirectory = originalProcDirectory
+		})
+
+		Expect(os.WriteFile(filepath.Join(procDirectory, "meminfo"), []byte(
+			"MemTotal:       16318480 kB\n"+
+				"HugePages_Total:     512\n"+
+				"HugePages_Free:      256\n"+
+				"Hugepagesize:       2048 kB\n"), 0o600)).To(Succeed())
+	})
+
+	It("doesn't check anything when shared_buffers is not set", func() {
+		Expect(InitInfo{}.checkSharedMemory()).To(Succeed())
+	})
+
+	It("checks the default shared_buffers when huge pages are required", func() {
+		Expect(InitInfo{InitDBSettings: map[string]string{"huge_pages": "on"}}.checkSharedMemory()).To(Succeed())
+
+		Expect(os.WriteFile(filepath.Join(procDirectory, "meminfo"), []byte(
+			"HugePages_Total:       0\n"+
+				"HugePages_Free:        0\n"+
+				"Hugepagesize:       2048 kB\n"), 0o600)).To(Succeed())
+		Expect(InitInfo{HugePages: "on"}.checkSharedMemory()).To(MatchError(ContainSubstring("huge pages")))
+		Expect(InitInfo{HugePages: "try"}.checkSharedMemory()).To(Succeed())
+	})
+
+	It("checks the free huge pages when huge_pages is set", func() {
+		info := InitInfo{SharedBuffers: "1GB", HugePages: "on"}
+		Expect(info.checkSharedMemory()).To(MatchError(ContainSubstring("huge pages")))
+
+		info.HugePages = "off"
+		Expect(info.checkSharedMemory()).To(Succeed())
+	})
+
+	It("gives precedence to huge_pages over the initdb settings", func() {
+		info := InitInfo{
+			SharedBuffers:  "1GB",
+			HugePages:      "try",
+			InitDBSettings: map[string]string{"huge_pages": "on"},
+		}
+		Expect(info.checkSharedMemory()).To(Succeed())
+	})
+
+	It("doesn't check the huge pages when they are not required", func() {
+		Expect(InitInfo{SharedBuffers: "2GB"}.checkSharedMemory()).To(Succeed())
+	})
+
+	It("checks the free huge pages when they are required", func() {
+		info := InitInfo{
+			SharedBuffers:  "512MB",
+			InitDBSettings: map[string]string{"huge_pages": "on"},
+		}
+		Expect(info.checkSharedMemory()).To(Succeed())
+
+		info.SharedBuffers = "1GB"
+		Expect(info.checkSharedMemory()).To(MatchError(ContainSubstring("huge pages")))
+
+		info.InitDBSettings["huge_pages"] = "try"
+		Expect(info.checkSharedMemory()).To(Succeed())
+	})
+
+	It("uses the shared_buffers in the initdb settings, expressed in pages", func() {
+		info := InitInfo{
+			InitDBSettings: map[string]string{"huge_pages": "on", "shared_buffers": "131072"},
+		}
+		Expect(info.checkSharedMemory()).To(MatchError(ContainSubstring("1073741824 bytes")))
+	})
+
+	It("skips the checks when the kernel doesn't expose its limits", func() {
+		Expect(os.RemoveAll(procDirectory)).To(Succeed())
+		info := InitInfo{
+			SharedBuffers:  "1TB",
+			InitDBSettings: map[string]string{"huge_pages": "on"},
+		}
+		Expect(info.checkSharedMemory()).To(Succeed())
+	})
+})
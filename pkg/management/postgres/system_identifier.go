@@ -0,0 +1,189 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cloudnative-pg/machinery/pkg/fileutils"
+	"github.com/cloudnative-pg/machinery/pkg/log"
+
+	"github.com/cloudnative-pg/cloudnative-pg/pkg/configfile"
+	"github.com/cloudnative-pg/cloudnative-pg/pkg/management/postgres/constants"
+	postgresutils "github.com/cloudnative-pg/cloudnative-pg/pkg/management/postgres/utils"
+	"github.com/cloudnative-pg/cloudnative-pg/pkg/utils"
+)
+
+const (
+	// controlFileName is the path of the control file inside the data directory
+	controlFileName = "global/pg_control"
+
+	// controlFileMaxDataSize is the maximum size of the data stored in
+	// the control file, corresponding to PG_CONTROL_MAX_SAFE_SIZE
+	controlFileMaxDataSize = 512
+
+	// controlFileCRCSize is the size of the CRC-32C terminating the control data
+	controlFileCRCSize = 4
+
+	// systemIdentifierSize is the size of the system identifier,
+	// which is the first field of the control data
+	systemIdentifierSize = 8
+)
+
+// errControlFileCRCNotFound is raised when the control data checksum can't be located
+var errControlFileCRCNotFound = errors.New("cannot find the checksum of the control file")
+
+// crc32cTable is the table of the CRC-32C algorithm used by PostgreSQL
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// ReadSystemIdentifier reads the PostgreSQL system identifier
+// of the data directory using pg_controldata
+func (info InitInfo) ReadSystemIdentifier() (string, error) {
+	out, err := info.GetInstance().GetPgControldata()
+	if err != nil {
+		return "", err
+	}
+
+	return parseSystemIdentifier(out)
+}
+
+// ReportSystemIdentifier logs the PostgreSQL system identifier of the
+// data directory and, when requested, writes it in the status file, so
+// that the operator can persist it for later identity checks
+func (info InitInfo) ReportSystemIdentifier(ctx context.Context) error {
+	contextLogger := log.FromContext(ctx)
+
+	systemID, err := info.ReadSystemIdentifier()
+	if err != nil {
+		return fmt.Errorf("while reading the system identifier: %w", err)
+	}
+
+	contextLogger.Info("Detected the PostgreSQL system identifier", "systemID", systemID)
+
+	if info.SystemIdentifierFile == "" {
+		return nil
+	}
+
+	if _, err := fileutils.WriteStringToFile(info.SystemIdentifierFile, systemID); err != nil {
+		return fmt.Errorf("while writing the system identifier file: %w", err)
+	}
+
+	return nil
+}
+
+// parseSystemIdentifier extracts the system identifier from the output of pg_controldata
+func parseSystemIdentifier(controldataOutput string) (string, error) {
+	systemID := utils.ParsePgControldataOutput(controldataOutput)[utils.PgControlDataKeyDatabaseSystemIdentifier]
+	if systemID == "" {
+		return "", fmt.Errorf("no %q found in the pg_controldata output",
+			utils.PgControlDataKeyDatabaseSystemIdentifier)
+	}
+
+	return systemID, nil
+}
+
+// resetSystemIdentifier assigns a new system identifier to the cleanly shut
+// down data directory, giving the restored cluster a brand-new identity.
+// PostgreSQL has no supported way to do that, so we rewrite the identifier
+// inside the control file and then run pg_resetwal, which replaces the
+// existing WAL files with a new segment carrying the new identifier.
+func (info InitInfo) resetSystemIdentifier(ctx context.Context) error {
+	contextLogger := log.FromContext(ctx)
+
+	oldSystemID, err := info.ReadSystemIdentifier()
+	if err != nil {
+		return err
+	}
+
+	newSystemID := generateSystemIdentifier(time.Now(), os.Getpid())
+	contextLogger.Info("Assigning a new system identifier to the restored cluster",
+		"oldSystemID", oldSystemID,
+		"newSystemID", newSystemID)
+
+	if err := setSystemIdentifier(info.PgData, newSystemID); err != nil {
+		return fmt.Errorf("while writing the new system identifier: %w", err)
+	}
+
+	options := []string{"-D", info.PgData}
+	if _, err := postgresutils.RunCommand(ctx, pgResetWalName, options, postgresutils.CommandOptions{}); err != nil {
+		return fmt.Errorf("error while resetting the WAL files: %w", err)
+	}
+
+	// The new identity is bound to the new cluster
+	if _, err := configfile.UpdatePostgresConfigurationFile(
+		filepath.Join(info.PgData, constants.PostgresqlCustomConfigurationFile),
+		map[string]string{"cluster_name": info.ClusterName},
+	); err != nil {
+		return fmt.Errorf("while updating cluster_name: %w", err)
+	}
+
+	return nil
+}
+
+// generateSystemIdentifier generates a new system identifier
+// using the same algorithm used by initdb
+func generateSystemIdentifier(now time.Time, pid int) uint64 {
+	result := uint64(now.Unix()) << 32            //nolint:gosec
+	result |= uint64(now.Nanosecond()/1000) << 12 //nolint:gosec
+	result |= uint64(pid) & 0xFFF                 //nolint:gosec
+	return result
+}
+
+// setSystemIdentifier changes the system identifier stored in the control
+// file of the passed data directory, updating the control data checksum
+func setSystemIdentifier(pgData string, systemID uint64) error {
+	fileName := filepath.Join(pgData, controlFileName)
+	content, err := os.ReadFile(fileName) // #nosec G304
+	if err != nil {
+		return err
+	}
+
+	crcOffset, err := findControlFileCRCOffset(content)
+	if err != nil {
+		return err
+	}
+
+	binary.LittleEndian.PutUint64(content[:systemIdentifierSize], systemID)
+	binary.LittleEndian.PutUint32(
+		content[crcOffset:crcOffset+controlFileCRCSize],
+		crc32.Checksum(content[:crcOffset], crc32cTable))
+
+	return os.WriteFile(fileName, content, 0o600)
+}
+
+// findControlFileCRCOffset locates the checksum terminating the control data.
+// The size of the control data depends on the PostgreSQL version, so we look
+// for the first position where the stored checksum matches the preceding bytes.
+// The checksum is 4-byte aligned, like every field of the control data.
+func findControlFileCRCOffset(content []byte) (int, error) {
+	limit := min(len(content), controlFileMaxDataSize)
+	for offset := systemIdentifierSize; offset+controlFileCRCSize <= limit; offset += controlFileCRCSize {
+		expected := binary.LittleEndian.Uint32(content[offset : offset+controlFileCRCSize])
+		if crc32.Checksum(content[:offset], crc32cTable) == expected {
+			return offset, nil
+		}
+	}
+
+	return 0, errControlFileCRCNotFound
+}
@@ -0,0 +1,101 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("system identifier", func() {
+	const controldataOutput = `pg_control version number:            1300
+Catalog version number:               202307071
+Database system identifier:           7381644286723183634
+Database cluster state:               shut down
+pg_control last modified:             Tue 12 Nov 2024 10:00:00 AM UTC
+Latest checkpoint location:           0/3000028
+Latest checkpoint's TimeLineID:       1
+`
+
+	It("parses the system identifier from the pg_controldata output", func() {
+		Expect(parseSystemIdentifier(controldataOutput)).To(Equal("7381644286723183634"))
+	})
+
+	It("fails when the pg_controldata output has no system identifier", func() {
+		_, err := parseSystemIdentifier("pg_control version number:            1300\n")
+		Expect(err).To(HaveOccurred())
+	})
+
+	Context("resetting the system identifier", func() {
+		const crcOffset = 296
+
+		writeControlFile := func(pgData string, systemID uint64) string {
+			content := make([]byte, 8192)
+			binary.LittleEndian.PutUint64(content, systemID)
+			for i := systemIdentifierSize; i < crcOffset; i++ {
+				content[i] = byte(i)
+			}
+			binary.LittleEndian.PutUint32(content[crcOffset:], crc32.Checksum(content[:crcOffset], crc32cTable))
+
+			fileName := filepath.Join(pgData, controlFileName)
+			Expect(os.MkdirAll(filepath.Dir(fileName), 0o700)).To(Succeed())
+			Expect(os.WriteFile(fileName, content, 0o600)).To(Succeed())
+			return fileName
+		}
+
+		It("changes the identifier keeping the control file checksum valid", func() {
+			pgData := GinkgoT().TempDir()
+			const oldSystemID = uint64(7381644286723183634)
+			fileName := writeControlFile(pgData, oldSystemID)
+
+			newSystemID := generateSystemIdentifier(time.Now(), os.Getpid())
+			Expect(newSystemID).ToNot(Equal(oldSystemID))
+			Expect(setSystemIdentifier(pgData, newSystemID)).To(Succeed())
+
+			content, err := os.ReadFile(fileName) // nolint:gosec
+			Expect(err).ToNot(HaveOccurred())
+			Expect(binary.LittleEndian.Uint64(content)).To(Equal(newSystemID))
+			Expect(findControlFileCRCOffset(content)).To(Equal(crcOffset))
+			Expect(content[100]).To(BeEquivalentTo(100))
+		})
+
+		It("fails when the control file checksum can't be found", func() {
+			pgData := GinkgoT().TempDir()
+			fileName := writeControlFile(pgData, 1)
+
+			// Corrupt the control data
+			content, err := os.ReadFile(fileName) // nolint:gosec
+			Expect(err).ToNot(HaveOccurred())
+			content[crcOffset]++
+			Expect(os.WriteFile(fileName, content, 0o600)).To(Succeed())
+
+			Expect(setSystemIdentifier(pgData, 42)).To(MatchError(errControlFileCRCNotFound))
+		})
+
+		It("generates identifiers embedding the current time", func() {
+			now := time.Unix(1700000000, 123456000)
+			Expect(generateSystemIdentifier(now, 4097) >> 32).To(BeEquivalentTo(1700000000))
+			Expect(generateSystemIdentifier(now, 4097) & 0xFFF).To(BeEquivalentTo(1))
+		})
+	})
+})
@@ -0,0 +1,55 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the name of the tracer of the instance bootstrap
+const tracerName = "github.com/cloudnative-pg/cloudnative-pg/pkg/management/postgres"
+
+// The attributes of the bootstrap spans
+const (
+	clusterNameAttribute  = attribute.Key("cnpg.cluster.name")
+	podNameAttribute      = attribute.Key("cnpg.pod.name")
+	majorVersionAttribute = attribute.Key("postgresql.major_version")
+)
+
+// startSpan starts a span of the bootstrap, as a child of the span in
+// the passed context and with the tracer provider it was created with.
+// When the context has no span, the returned one is a no-op
+func (info InitInfo) startSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	tracer := trace.SpanFromContext(ctx).TracerProvider().Tracer(tracerName)
+	return tracer.Start(ctx, name, trace.WithAttributes(
+		clusterNameAttribute.String(info.ClusterName),
+		podNameAttribute.String(info.PodName),
+	))
+}
+
+// endSpan ends the passed span, recording the error, if any
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
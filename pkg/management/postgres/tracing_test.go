@@ -0,0 +1,107 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("bootstrap tracing", func() {
+	var exporter *tracetest.InMemoryExporter
+	var provider *sdktrace.TracerProvider
+
+	BeforeEach(func(ctx SpecContext) {
+		exporter = tracetest.NewInMemoryExporter()
+		provider = sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+		DeferCleanup(func(ctx SpecContext) {
+			Expect(provider.Shutdown(ctx)).To(Succeed())
+		})
+	})
+
+	spanNamed := func(name string) tracetest.SpanStub {
+		for _, span := range exporter.GetSpans() {
+			if span.Name == name {
+				return span
+			}
+		}
+		Fail("missing span " + name)
+		return tracetest.SpanStub{}
+	}
+
+	It("nests the bootstrap spans under the span of the context", func(ctx SpecContext) {
+		info := InitInfo{
+			ClusterName:          "cluster-example",
+			PodName:              "cluster-example-1",
+			SkipApplicationSetup: true,
+			MaxConnections:       -1,
+			SocketDirectory:      GinkgoT().TempDir(),
+		}
+
+		rootCtx, root := provider.Tracer("test").Start(ctx, "root")
+		bootstrapCtx, bootstrapSpan := info.startSpan(rootCtx, "Bootstrap")
+		Expect(info.CreateDataDirectory(bootstrapCtx)).ToNot(Succeed())
+		Expect(info.ConfigureNewInstance(bootstrapCtx, info.GetInstance())).To(Succeed())
+		endSpan(bootstrapSpan, nil)
+		root.End()
+
+		Expect(exporter.GetSpans()).To(HaveLen(4))
+		rootSpan := spanNamed("root")
+		bootstrap := spanNamed("Bootstrap")
+		createDataDirectory := spanNamed("CreateDataDirectory")
+		configureNewInstance := spanNamed("ConfigureNewInstance")
+
+		Expect(bootstrap.Parent.SpanID()).To(Equal(rootSpan.SpanContext.SpanID()))
+		Expect(createDataDirectory.Parent.SpanID()).To(Equal(bootstrap.SpanContext.SpanID()))
+		Expect(configureNewInstance.Parent.SpanID()).To(Equal(bootstrap.SpanContext.SpanID()))
+		Expect(createDataDirectory.SpanContext.TraceID()).To(Equal(rootSpan.SpanContext.TraceID()))
+
+		Expect(bootstrap.Attributes).To(ContainElements(
+			attribute.String("cnpg.cluster.name", "cluster-example"),
+			attribute.String("cnpg.pod.name", "cluster-example-1"),
+		))
+		Expect(bootstrap.EndTime).To(BeTemporally(">=", createDataDirectory.EndTime))
+	})
+
+	It("records the errors in the spans", func(ctx SpecContext) {
+		info := InitInfo{MaxConnections: -1}
+
+		rootCtx, root := provider.Tracer("test").Start(ctx, "root")
+		err := info.CreateDataDirectory(rootCtx)
+		root.End()
+
+		Expect(err).To(HaveOccurred())
+		span := spanNamed("CreateDataDirectory")
+		Expect(span.Status.Code).To(Equal(codes.Error))
+		Expect(span.Status.Description).To(ContainSubstring("max_connections"))
+		Expect(span.Events).To(ContainElement(HaveField("Name", "exception")))
+		Expect(spanNamed("root").Status.Code).To(Equal(codes.Unset))
+	})
+
+	It("doesn't trace anything without a span in the context", func() {
+		_, span := InitInfo{}.startSpan(context.Background(), "Bootstrap")
+		Expect(span.IsRecording()).To(BeFalse())
+		Expect(span.SpanContext().IsValid()).To(BeFalse())
+	})
+})
@@ -0,0 +1,101 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/cloudnative-pg/machinery/pkg/fileutils"
+	"github.com/cloudnative-pg/machinery/pkg/log"
+
+	postgresutils "github.com/cloudnative-pg/cloudnative-pg/pkg/management/postgres/utils"
+)
+
+const (
+	// minInitialTransactionID is the first normal transaction ID,
+	// the lowest value accepted by `pg_resetwal -x`
+	minInitialTransactionID = 3
+
+	// clogTransactionsPerSegment is the number of transactions whose status
+	// is stored in a single pg_xact segment: 32 pages of 8kB, with 4
+	// transactions per byte
+	clogTransactionsPerSegment = 32 * 8192 * 4
+
+	// clogSegmentSize is the size of a pg_xact segment
+	clogSegmentSize = 32 * 8192
+)
+
+// validateInitialTransactionID checks that the initial transaction ID,
+// if requested, is accepted by pg_resetwal
+func validateInitialTransactionID(xid uint32) error {
+	if xid != 0 && xid < minInitialTransactionID {
+		return fmt.Errorf("the initial transaction ID must be at least %d, got %d",
+			minInitialTransactionID, xid)
+	}
+
+	return nil
+}
+
+// resetTransactionID sets the next transaction ID of the freshly created
+// data directory. This is meant to be used only for testing purposes,
+// i.e. to create a cluster close to the transaction ID wraparound.
+func (info InitInfo) resetTransactionID(ctx context.Context) error {
+	log.Warning("Setting the next transaction ID of the new data directory, "+
+		"this is supposed to be used only for testing purposes",
+		"pgdata", info.PgData,
+		"transactionID", info.InitialTransactionID)
+
+	// pg_resetwal doesn't create the transaction status file
+	// for the new transaction ID, and PostgreSQL wouldn't start
+	// without it
+	if err := createClogSegment(info.PgData, info.InitialTransactionID); err != nil {
+		return err
+	}
+
+	options := resetWalTransactionIDOptions(info.PgData, info.InitialTransactionID)
+	if _, err := postgresutils.RunCommand(ctx, pgResetWalName, options, postgresutils.CommandOptions{}); err != nil {
+		return fmt.Errorf("error while setting the initial transaction ID: %w", err)
+	}
+
+	return nil
+}
+
+// resetWalTransactionIDOptions returns the pg_resetwal options
+// setting the next transaction ID of a data directory
+func resetWalTransactionIDOptions(pgData string, xid uint32) []string {
+	return []string{
+		"-x", strconv.FormatUint(uint64(xid), 10),
+		"-D", pgData,
+	}
+}
+
+// createClogSegment creates the zero-filled pg_xact segment
+// storing the status of the passed transaction ID
+func createClogSegment(pgData string, xid uint32) error {
+	segmentName := filepath.Join(pgData, "pg_xact", fmt.Sprintf("%04X", xid/clogTransactionsPerSegment))
+
+	exists, err := fileutils.FileExists(segmentName)
+	if err != nil || exists {
+		return err
+	}
+
+	return os.WriteFile(segmentName, make([]byte, clogSegmentSize), 0o600)
+}
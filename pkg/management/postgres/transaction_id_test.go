@@ -0,0 +1,57 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("initial transaction ID", func() {
+	It("invokes pg_resetwal with the requested transaction ID", func() {
+		options := resetWalTransactionIDOptions("/var/lib/postgresql/data/pgdata", 2147483000)
+		Expect(options).To(Equal([]string{
+			"-x", "2147483000", "-D", "/var/lib/postgresql/data/pgdata",
+		}))
+	})
+
+	It("creates the transaction status segment for the new transaction ID", func() {
+		pgData := GinkgoT().TempDir()
+		Expect(os.Mkdir(filepath.Join(pgData, "pg_xact"), 0o700)).To(Succeed())
+
+		Expect(createClogSegment(pgData, 2147483000)).To(Succeed())
+
+		stat, err := os.Stat(filepath.Join(pgData, "pg_xact", "07FF"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stat.Size()).To(BeEquivalentTo(clogSegmentSize))
+	})
+
+	It("validates the range of the transaction ID", func() {
+		Expect(validateInitialTransactionID(0)).To(Succeed())
+		Expect(validateInitialTransactionID(2)).ToNot(Succeed())
+		Expect(validateInitialTransactionID(3)).To(Succeed())
+		Expect(validateInitialTransactionID(4294967295)).To(Succeed())
+	})
+
+	It("refuses an invalid transaction ID while verifying the configuration", func() {
+		info := InitInfo{InitialTransactionID: 1}
+		Expect(info.VerifyConfiguration()).ToNot(Succeed())
+	})
+})
@@ -0,0 +1,167 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cloudnative-pg/machinery/pkg/log"
+)
+
+// secretFlags are the options whose following argument is a secret
+var secretFlags = []string{"--password"}
+
+// CommandOptions are the options of the external commands run by RunCommand
+type CommandOptions struct {
+	// Env is the environment of the command. When nil,
+	// the one of the current process is used
+	Env []string
+
+	// Stdin, when set, is the standard input of the command
+	Stdin io.Reader
+
+	// GracePeriod, when set, makes the command interrupted with SIGINT
+	// when the context is canceled, and killed once it expires. Otherwise,
+	// the command is killed as soon as the context is canceled
+	GracePeriod time.Duration
+
+	// SeparateStderr makes RunCommand return only the standard output,
+	// i.e. when it is to be parsed, while the standard error is only
+	// reported in the error
+	SeparateStderr bool
+}
+
+// CommandError is returned when an external command fails,
+// reporting its exit code and its output
+type CommandError struct {
+	// The name of the command
+	Name string
+
+	// The exit code of the command, or -1 when it didn't exit normally
+	ExitCode int
+
+	// The combined standard output and error of the command, or only the
+	// standard error when SeparateStderr is set, with the secrets redacted
+	Output string
+
+	err error
+}
+
+// Error implements the error interface
+func (e *CommandError) Error() string {
+	message := fmt.Sprintf("%s failed with exit code %d: %v", e.Name, e.ExitCode, e.err)
+	if output := strings.TrimSpace(e.Output); output != "" {
+		message += ", output: " + output
+	}
+	return message
+}
+
+// Unwrap returns the underlying error, which includes the error
+// of the context when the command was canceled
+func (e *CommandError) Unwrap() error {
+	return e.err
+}
+
+// RunCommand runs an external command, logging its command line with the
+// secrets redacted, and returns its combined output. The command is
+// stopped when the context is canceled
+func RunCommand(ctx context.Context, name string, args []string, options CommandOptions) (string, error) {
+	var cmd *exec.Cmd
+	if options.GracePeriod > 0 {
+		cmd = GracefulCommandContext(ctx, options.GracePeriod, name, args...)
+	} else {
+		cmd = exec.CommandContext(ctx, name, args...) // #nosec
+	}
+	cmd.Env = options.Env
+	cmd.Stdin = options.Stdin
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stdout
+	if options.SeparateStderr {
+		cmd.Stderr = &stderr
+	}
+
+	log.FromContext(ctx).Info("Running command", "command", name, "args", redactArguments(args))
+	err := cmd.Run()
+	if err == nil {
+		return stdout.String(), nil
+	}
+
+	exitCode := -1
+	var exitError *exec.ExitError
+	if errors.As(err, &exitError) {
+		exitCode = exitError.ExitCode()
+	}
+	if ctx.Err() != nil {
+		err = errors.Join(err, ctx.Err())
+	}
+
+	errorOutput := stdout.String()
+	if options.SeparateStderr {
+		errorOutput = stderr.String()
+	}
+
+	return stdout.String(), &CommandError{
+		Name:     name,
+		ExitCode: exitCode,
+		Output:   RedactSecrets(errorOutput),
+		err:      err,
+	}
+}
+
+// GracefulCommandContext creates a command which is interrupted with SIGINT
+// when the passed context is canceled, and killed if it is still running
+// after the grace period. This allows initdb to remove the data directory
+// it was creating instead of leaving a corrupted one behind
+func GracefulCommandContext(
+	ctx context.Context,
+	gracePeriod time.Duration,
+	name string,
+	args ...string,
+) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...) // #nosec
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(os.Interrupt)
+	}
+	cmd.WaitDelay = gracePeriod
+	return cmd
+}
+
+// redactArguments returns a copy of the passed command
+// line arguments with the secrets redacted
+func redactArguments(args []string) []string {
+	result := make([]string, len(args))
+	for index, arg := range args {
+		if index > 0 && slices.Contains(secretFlags, args[index-1]) {
+			result[index] = RedactedValue
+			continue
+		}
+		result[index] = RedactSecrets(arg)
+	}
+
+	return result
+}
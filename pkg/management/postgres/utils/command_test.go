@@ -0,0 +1,159 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RunCommand", func() {
+	It("returns the combined output of a successful command", func(ctx SpecContext) {
+		output, err := RunCommand(ctx, "sh", []string{"-c", "echo out; echo err >&2"}, CommandOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(output).To(ContainSubstring("out\n"))
+		Expect(output).To(ContainSubstring("err\n"))
+	})
+
+	It("uses the passed environment", func(ctx SpecContext) {
+		output, err := RunCommand(ctx, "sh", []string{"-c", "echo $TEST_VALUE"},
+			CommandOptions{Env: []string{"TEST_VALUE=value"}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(output).To(Equal("value\n"))
+	})
+
+	It("reports the exit code and the output of a failed command", func(ctx SpecContext) {
+		output, err := RunCommand(ctx, "sh", []string{"-c", "echo 'password=secret failed'; exit 3"},
+			CommandOptions{})
+		Expect(output).To(Equal("password=secret failed\n"))
+
+		var commandError *CommandError
+		Expect(errors.As(err, &commandError)).To(BeTrue())
+		Expect(commandError.Name).To(Equal("sh"))
+		Expect(commandError.ExitCode).To(Equal(3))
+		Expect(commandError.Output).To(Equal("password=******** failed\n"))
+		Expect(err.Error()).To(ContainSubstring("exit code 3"))
+		Expect(err.Error()).ToNot(ContainSubstring("secret"))
+	})
+
+	It("reports a command which cannot be started", func(ctx SpecContext) {
+		_, err := RunCommand(ctx, "/nonexistent/command", nil, CommandOptions{})
+
+		var commandError *CommandError
+		Expect(errors.As(err, &commandError)).To(BeTrue())
+		Expect(commandError.ExitCode).To(Equal(-1))
+	})
+
+	It("passes the standard input to the command", func(ctx SpecContext) {
+		output, err := RunCommand(ctx, "cat", nil, CommandOptions{Stdin: strings.NewReader("input\n")})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(output).To(Equal("input\n"))
+	})
+
+	It("returns only the standard output when requested", func(ctx SpecContext) {
+		output, err := RunCommand(ctx, "sh", []string{"-c", "echo out; echo err >&2"},
+			CommandOptions{SeparateStderr: true})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(output).To(Equal("out\n"))
+
+		output, err = RunCommand(ctx, "sh", []string{"-c", "echo out; echo 'password=secret' >&2; exit 1"},
+			CommandOptions{SeparateStderr: true})
+		Expect(output).To(Equal("out\n"))
+
+		var commandError *CommandError
+		Expect(errors.As(err, &commandError)).To(BeTrue())
+		Expect(commandError.Output).To(Equal("password=********\n"))
+	})
+
+	It("stops the command when the context is canceled", func(ctx SpecContext) {
+		timeoutCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+		defer cancel()
+
+		_, err := RunCommand(timeoutCtx, "sleep", []string{"30"}, CommandOptions{GracePeriod: time.Second})
+		Expect(errors.Is(err, context.DeadlineExceeded)).To(BeTrue())
+	})
+})
+
+var _ = DescribeTable("redactArguments",
+	func(args []string, expected []string) {
+		Expect(redactArguments(args)).To(Equal(expected))
+	},
+	Entry("without secrets",
+		[]string{"-D", "/pgdata", "--username", "postgres"},
+		[]string{"-D", "/pgdata", "--username", "postgres"}),
+	Entry("with a password in a connection string",
+		[]string{"-d", "host=pg user=app password=secret dbname=app"},
+		[]string{"-d", "host=pg user=app password=******** dbname=app"}),
+	Entry("with a quoted password in a connection string",
+		[]string{"-d", "host=pg password = 'my secret' dbname=app"},
+		[]string{"-d", "host=pg password = ******** dbname=app"}),
+	Entry("with a password option",
+		[]string{"--password=secret", "--password", "secret"},
+		[]string{"--password=********", "--password", "********"}),
+)
+
+var _ = Describe("GracefulCommandContext", func() {
+	It("interrupts the command and waits for it to clean up", func(ctx SpecContext) {
+		dir := GinkgoT().TempDir()
+		startedFile := filepath.Join(dir, "started")
+		cleanedUpFile := filepath.Join(dir, "cleaned-up")
+		fakeInitdb := filepath.Join(dir, "initdb")
+		script := "#!/bin/sh\n" +
+			"trap 'touch " + cleanedUpFile + "; exit 1' INT\n" +
+			"touch " + startedFile + "\n" +
+			"while true; do sleep 0.1; done\n"
+		Expect(os.WriteFile(fakeInitdb, []byte(script), 0o700)).To(Succeed()) // #nosec
+
+		commandCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		cmd := GracefulCommandContext(commandCtx, 10*time.Second, fakeInitdb)
+		Expect(cmd.Start()).To(Succeed())
+		Eventually(startedFile).WithContext(ctx).Should(BeARegularFile())
+
+		cancel()
+		Expect(cmd.Wait()).ToNot(Succeed())
+		Expect(cleanedUpFile).To(BeARegularFile())
+	})
+
+	It("kills the command once the grace period has expired", func(ctx SpecContext) {
+		dir := GinkgoT().TempDir()
+		startedFile := filepath.Join(dir, "started")
+		fakeInitdb := filepath.Join(dir, "initdb")
+		script := "#!/bin/sh\n" +
+			"trap '' INT\n" +
+			"touch " + startedFile + "\n" +
+			"while true; do sleep 0.1; done\n"
+		Expect(os.WriteFile(fakeInitdb, []byte(script), 0o700)).To(Succeed()) // #nosec
+
+		commandCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		cmd := GracefulCommandContext(commandCtx, 100*time.Millisecond, fakeInitdb)
+		Expect(cmd.Start()).To(Succeed())
+		Eventually(startedFile).WithContext(ctx).Should(BeARegularFile())
+
+		cancel()
+		Expect(cmd.Wait()).ToNot(Succeed())
+		Expect(cmd.ProcessState.ExitCode()).To(Equal(-1))
+	})
+})
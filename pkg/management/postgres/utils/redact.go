@@ -0,0 +1,40 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import "regexp"
+
+// RedactedValue replaces the secrets in the logs and in the errors
+const RedactedValue = "********"
+
+var (
+	// passwordLiteralRegex matches the password literals of the role
+	// statements, as quoted by pq.QuoteLiteral
+	passwordLiteralRegex = regexp.MustCompile(`(?i)(\bPASSWORD\s+)(E?'(?:[^'\\]|''|\\.)*')`)
+
+	// passwordParameterRegex matches the passwords contained in connection
+	// strings and in the `--password=value` options
+	passwordParameterRegex = regexp.MustCompile(`(?i)(password\s*=\s*)('(?:[^'\\]|\\.)*'|[^\s']+)`)
+)
+
+// RedactSecrets replaces the passwords contained in the passed text, be
+// they password literals of SQL statements or parameters of connection
+// strings, making it safe to be logged
+func RedactSecrets(text string) string {
+	text = passwordLiteralRegex.ReplaceAllString(text, "${1}"+RedactedValue)
+	return passwordParameterRegex.ReplaceAllString(text, "${1}"+RedactedValue)
+}
@@ -0,0 +1,55 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = DescribeTable("RedactSecrets",
+	func(text, expected string) {
+		Expect(RedactSecrets(text)).To(Equal(expected))
+	},
+	Entry("without passwords",
+		"GRANT CONNECT ON DATABASE app TO reader",
+		"GRANT CONNECT ON DATABASE app TO reader"),
+	Entry("with a NULL password",
+		"ALTER ROLE postgres WITH PASSWORD NULL",
+		"ALTER ROLE postgres WITH PASSWORD NULL"),
+	Entry("with a plain password",
+		"ALTER USER app PASSWORD 'secret'",
+		"ALTER USER app PASSWORD ********"),
+	Entry("with an escaped quote",
+		"CREATE ROLE app LOGIN PASSWORD 'se''cret' CONNECTION LIMIT 10",
+		"CREATE ROLE app LOGIN PASSWORD ******** CONNECTION LIMIT 10"),
+	Entry("with a backslash",
+		`ALTER ROLE app WITH ENCRYPTED password  E'se\\cr\'et'`,
+		"ALTER ROLE app WITH ENCRYPTED password  ********"),
+	Entry("with multiple statements",
+		"ALTER USER a PASSWORD 'one'; ALTER USER b PASSWORD 'two'",
+		"ALTER USER a PASSWORD ********; ALTER USER b PASSWORD ********"),
+	Entry("with a connection string",
+		"host=pg user=app password=secret dbname=app",
+		"host=pg user=app password=******** dbname=app"),
+	Entry("with a quoted password in a connection string",
+		"host=pg password = 'se cret' dbname=app",
+		"host=pg password = ******** dbname=app"),
+	Entry("with a password option",
+		"--password=secret",
+		"--password=********"),
+)
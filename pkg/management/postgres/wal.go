@@ -20,6 +20,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/cloudnative-pg/machinery/pkg/log"
 	"k8s.io/apimachinery/pkg/util/wait"
@@ -30,6 +31,17 @@ import (
 
 var errNoWalArchivePresent = errors.New("no wal-archive present")
 
+// retryUntilBootstrapWalArchived is the retry configuration used to wait
+// for the first WAL file of a new instance to be archived. Being bounded
+// to seven attempts, about 90 seconds, a broken archive_command fails
+// the bootstrap instead of hanging it
+var retryUntilBootstrapWalArchived = wait.Backoff{
+	Duration: time.Second,
+	Factor:   2,
+	Steps:    7,
+	Cap:      30 * time.Second,
+}
+
 // ensureWalArchiveIsWorking behaves slightly differently when executed on primary or a standby.
 // On primary, it could run even before the first WAL has completed. For this reason it
 // could require a WAL switch, to quicken the check.
@@ -189,3 +201,42 @@ func (w *walArchiveBootstrapper) shipWalFile(db *sql.DB) error {
 
 	return nil
 }
+
+// verifyArchiving forces the first WAL file of a new primary instance to be
+// archived, failing when archive_mode is disabled or the WAL file is not
+// archived within the passed backoff. This detects a misconfigured
+// archive_command while bootstrapping rather than on the first WAL switch
+func (w *walArchiveBootstrapper) verifyArchiving(backoff wait.Backoff) error {
+	if err := w.checkArchiveMode(); err != nil {
+		return err
+	}
+
+	if err := w.ensureFirstWalArchived(backoff); err != nil {
+		return fmt.Errorf("WAL archiving is not working: %w", err)
+	}
+
+	return nil
+}
+
+// checkArchiveMode ensures that the WAL archiving is enabled
+func (w *walArchiveBootstrapper) checkArchiveMode() error {
+	db, err := w.dbFactory()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := db.Close(); closeErr != nil {
+			log.Debug("Error while closing connection", "err", closeErr.Error())
+		}
+	}()
+
+	var archiveMode string
+	if err := db.QueryRow("SHOW archive_mode").Scan(&archiveMode); err != nil {
+		return fmt.Errorf("while reading archive_mode: %w", err)
+	}
+	if archiveMode == "off" {
+		return errors.New("the WAL archiving can't be verified: archive_mode is off")
+	}
+
+	return nil
+}
@@ -0,0 +1,123 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/cloudnative-pg/machinery/pkg/log"
+
+	postgresSpec "github.com/cloudnative-pg/cloudnative-pg/pkg/postgres"
+)
+
+// defaultMinWalSize is the default value of min_wal_size
+const defaultMinWalSize = 80 << 20
+
+// preallocateWAL creates the WAL segments PostgreSQL will write after the
+// ones created by initdb, up to min_wal_size. PostgreSQL reuses the existing
+// segments instead of allocating them, reducing the latency of the first
+// checkpoints on slow storage
+func (info InitInfo) preallocateWAL() error {
+	minWalSize := int64(defaultMinWalSize)
+	if info.MinWalSize != "" {
+		var err error
+		if minWalSize, err = parseMemorySize(info.MinWalSize, walSizeDefaultUnit); err != nil {
+			return fmt.Errorf("invalid min_wal_size: %w", err)
+		}
+	}
+
+	segmentSize, err := info.restoredWALSegmentSize()
+	if err != nil {
+		return err
+	}
+
+	created, err := preallocateWALSegments(filepath.Join(info.PgData, "pg_wal"), segmentSize, minWalSize)
+	if err != nil {
+		return fmt.Errorf("while preallocating the WAL segments: %w", err)
+	}
+
+	log.Info("Preallocated the WAL segments", "segments", created, "segmentSize", segmentSize)
+	return nil
+}
+
+// preallocateWALSegments writes zeroed segments, following the latest one
+// contained in walDir, until the segments of the directory fill minWalSize.
+// The number of segments created is returned
+func preallocateWALSegments(walDir string, segmentSize, minWalSize int64) (int, error) {
+	entries, err := os.ReadDir(walDir)
+	if err != nil {
+		return 0, err
+	}
+
+	var segmentNames []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && postgresSpec.IsWALFile(entry.Name()) {
+			segmentNames = append(segmentNames, entry.Name())
+		}
+	}
+	if len(segmentNames) == 0 {
+		return 0, fmt.Errorf("no WAL segment found in %s", walDir)
+	}
+
+	missing := int((minWalSize+segmentSize-1)/segmentSize) - len(segmentNames)
+	if missing <= 0 {
+		return 0, nil
+	}
+
+	latest, err := postgresSpec.SegmentFromName(slices.Max(segmentNames))
+	if err != nil {
+		return 0, err
+	}
+
+	// The first segment returned is the latest one, which already exists
+	segments := latest.NextSegments(missing+1, nil, &segmentSize)[1:]
+	for _, segment := range segments {
+		if err := writeZeroedSegment(filepath.Join(walDir, segment.Name()), segmentSize); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(segments), nil
+}
+
+// writeZeroedSegment creates a WAL segment filled with zeroes. The zeroes
+// are written instead of truncating the file, which would only create a
+// sparse file whose blocks are allocated when PostgreSQL writes them
+func writeZeroedSegment(fileName string, segmentSize int64) error {
+	file, err := os.OpenFile(fileName, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600) // #nosec
+	if err != nil {
+		return err
+	}
+
+	zeroes := make([]byte, min(segmentSize, 1<<20))
+	for written := int64(0); written < segmentSize; written += int64(len(zeroes)) {
+		if _, err := file.Write(zeroes); err != nil {
+			_ = file.Close()
+			return err
+		}
+	}
+
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	return file.Close()
+}
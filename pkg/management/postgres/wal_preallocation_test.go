@@ -0,0 +1,74 @@
+/*
+Copyright The CloudNativePG Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgres
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("WAL preallocation", func() {
+	const segmentSize = 1 << 20
+
+	var walDir string
+
+	BeforeEach(func() {
+		walDir = GinkgoT().TempDir()
+		Expect(os.Mkdir(filepath.Join(walDir, "archive_status"), 0o700)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(walDir, "000000010000000000000001"),
+			make([]byte, segmentSize), 0o600)).To(Succeed())
+	})
+
+	It("creates the segments up to min_wal_size", func() {
+		created, err := preallocateWALSegments(walDir, segmentSize, 5*segmentSize)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(created).To(Equal(4))
+
+		for _, name := range []string{
+			"000000010000000000000002",
+			"000000010000000000000003",
+			"000000010000000000000004",
+			"000000010000000000000005",
+		} {
+			stat, err := os.Stat(filepath.Join(walDir, name))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stat.Size()).To(BeEquivalentTo(segmentSize))
+		}
+		Expect(filepath.Join(walDir, "000000010000000000000006")).ToNot(BeAnExistingFile())
+	})
+
+	It("rounds min_wal_size up to a whole segment", func() {
+		created, err := preallocateWALSegments(walDir, segmentSize, 2*segmentSize+1)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(created).To(Equal(2))
+	})
+
+	It("doesn't create segments when min_wal_size is already covered", func() {
+		created, err := preallocateWALSegments(walDir, segmentSize, segmentSize)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(created).To(BeZero())
+	})
+
+	It("fails when there is no segment to start from", func() {
+		Expect(os.Remove(filepath.Join(walDir, "000000010000000000000001"))).To(Succeed())
+		_, err := preallocateWALSegments(walDir, segmentSize, 5*segmentSize)
+		Expect(err).To(MatchError(ContainSubstring("no WAL segment found")))
+	})
+})
@@ -19,8 +19,10 @@ package postgres
 import (
 	"database/sql"
 	"errors"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"k8s.io/apimachinery/pkg/util/wait"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -87,3 +89,97 @@ var _ = Describe("ensure isWalArchiveWorking works correctly", func() {
 		Expect(mock.ExpectationsWereMet()).To(Succeed())
 	})
 })
+
+var _ = Describe("verifyArchiving", func() {
+	const flexibleCoalescenceQuery = "SELECT COALESCE.*FROM pg_stat_archiver"
+	var (
+		mocks        []sqlmock.Sqlmock
+		dbs          []*sql.DB
+		bootstrapper walArchiveBootstrapper
+		fakeResult   = sqlmock.NewResult(0, 1)
+		backoff      = wait.Backoff{Duration: time.Millisecond, Steps: 2}
+	)
+
+	// newMock prepares the connection returned by the next call
+	// to the connection factory
+	newMock := func() sqlmock.Sqlmock {
+		db, mock, err := sqlmock.New()
+		Expect(err).ToNot(HaveOccurred())
+		dbs = append(dbs, db)
+		mocks = append(mocks, mock)
+		return mock
+	}
+
+	BeforeEach(func() {
+		mocks = nil
+		dbs = nil
+		bootstrapper = walArchiveBootstrapper{
+			walArchiveAnalyzer: walArchiveAnalyzer{
+				dbFactory: func() (*sql.DB, error) {
+					Expect(dbs).ToNot(BeEmpty())
+					db := dbs[0]
+					dbs = dbs[1:]
+					return db, nil
+				},
+			},
+		}
+	})
+
+	AfterEach(func() {
+		for _, mock := range mocks {
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		}
+	})
+
+	It("fails when archive_mode is off", func() {
+		newMock().ExpectQuery("SHOW archive_mode").
+			WillReturnRows(sqlmock.NewRows([]string{"archive_mode"}).AddRow("off"))
+
+		err := bootstrapper.verifyArchiving(backoff)
+		Expect(err).To(MatchError(ContainSubstring("archive_mode is off")))
+	})
+
+	It("succeeds when the switched WAL file is archived", func() {
+		newMock().ExpectQuery("SHOW archive_mode").
+			WillReturnRows(sqlmock.NewRows([]string{"archive_mode"}).AddRow("on"))
+		mock := newMock()
+		mock.ExpectQuery(flexibleCoalescenceQuery).
+			WillReturnRows(sqlmock.NewRows([]string{"is_archiving", "last_failed_time_present"}).AddRow(false, false))
+		mock.ExpectExec("CHECKPOINT").WillReturnResult(fakeResult)
+		mock.ExpectExec("SELECT pg_switch_wal()").WillReturnResult(fakeResult)
+		newMock().ExpectQuery(flexibleCoalescenceQuery).
+			WillReturnRows(sqlmock.NewRows([]string{"is_archiving", "last_failed_time_present"}).AddRow(true, false))
+
+		Expect(bootstrapper.verifyArchiving(backoff)).To(Succeed())
+	})
+
+	It("fails when the archive_command can't archive the switched WAL file", func() {
+		newMock().ExpectQuery("SHOW archive_mode").
+			WillReturnRows(sqlmock.NewRows([]string{"archive_mode"}).AddRow("on"))
+		mock := newMock()
+		mock.ExpectQuery(flexibleCoalescenceQuery).
+			WillReturnRows(sqlmock.NewRows([]string{"is_archiving", "last_failed_time_present"}).AddRow(false, false))
+		mock.ExpectExec("CHECKPOINT").WillReturnResult(fakeResult)
+		mock.ExpectExec("SELECT pg_switch_wal()").WillReturnResult(fakeResult)
+		newMock().ExpectQuery(flexibleCoalescenceQuery).
+			WillReturnRows(sqlmock.NewRows([]string{"is_archiving", "last_failed_time_present"}).AddRow(false, true))
+
+		err := bootstrapper.verifyArchiving(backoff)
+		Expect(err).To(MatchError(ContainSubstring("WAL archiving is not working")))
+	})
+
+	It("gives up when the WAL file is never archived", func() {
+		newMock().ExpectQuery("SHOW archive_mode").
+			WillReturnRows(sqlmock.NewRows([]string{"archive_mode"}).AddRow("on"))
+		mock := newMock()
+		mock.ExpectQuery(flexibleCoalescenceQuery).
+			WillReturnRows(sqlmock.NewRows([]string{"is_archiving", "last_failed_time_present"}).AddRow(false, false))
+		mock.ExpectExec("CHECKPOINT").WillReturnResult(fakeResult)
+		mock.ExpectExec("SELECT pg_switch_wal()").WillReturnResult(fakeResult)
+		newMock().ExpectQuery(flexibleCoalescenceQuery).
+			WillReturnRows(sqlmock.NewRows([]string{"is_archiving", "last_failed_time_present"}).AddRow(false, false))
+
+		err := bootstrapper.verifyArchiving(backoff)
+		Expect(err).To(MatchError(ContainSubstring("waiting for first wal-archive")))
+	})
+})
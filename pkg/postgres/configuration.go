@@ -238,6 +238,10 @@ local {{.Username}} postgres
 
 	// SynchronousStandbyNames is the postgresql parameter key for synchronous standbys
 	SynchronousStandbyNames = "synchronous_standby_names"
+
+	// pluginArchiveCommandPlaceholder is the archive_command used when the
+	// WAL archiving is handled by a plugin
+	pluginArchiveCommandPlaceholder = "/bin/true"
 )
 
 // hbaTemplate is the template used to create the HBA configuration
@@ -325,6 +329,10 @@ type ConfigurationInfo struct {
 	// IsWalArchivingDisabled is true when user requested to disable WAL archiving
 	IsWalArchivingDisabled bool
 
+	// IsWalArchivingViaPlugin is true when the WAL archiving is handled by a
+	// plugin, and archive_command is a no-op placeholder
+	IsWalArchivingViaPlugin bool
+
 	// IsAlterSystemEnabled is true when 'allow_alter_system' should be set to on
 	IsAlterSystemEnabled bool
 
@@ -654,6 +662,10 @@ func CreatePostgresqlConfiguration(info ConfigurationInfo) *PgConfiguration {
 		if info.Version.Major() >= 17 {
 			configuration.OverwriteConfig("allow_alter_system", info.getAlterSystemEnabledValue())
 		}
+
+		if info.IsWalArchivingViaPlugin {
+			configuration.OverwriteConfig("archive_command", pluginArchiveCommandPlaceholder)
+		}
 	}
 
 	// Apply the correct archive_mode
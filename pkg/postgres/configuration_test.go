@@ -136,6 +136,33 @@ var _ = Describe("PostgreSQL configuration creation", func() {
 		})
 	})
 
+	When("the WAL archiving is handled by a plugin", func() {
+		It("will set a no-op archive_command", func() {
+			info := ConfigurationInfo{
+				Settings:                CnpgConfigurationSettings,
+				Version:                 version.New(13, 0),
+				UserSettings:            settings,
+				IncludingMandatory:      true,
+				IsWalArchivingViaPlugin: true,
+			}
+			config := CreatePostgresqlConfiguration(info)
+			Expect(config.GetConfig("archive_mode")).To(Equal("on"))
+			Expect(config.GetConfig("archive_command")).To(Equal("/bin/true"))
+		})
+
+		It("will still disable archive_mode when requested", func() {
+			info := ConfigurationInfo{
+				Settings:                CnpgConfigurationSettings,
+				Version:                 version.New(13, 0),
+				IncludingMandatory:      true,
+				IsWalArchivingViaPlugin: true,
+				IsWalArchivingDisabled:  true,
+			}
+			config := CreatePostgresqlConfiguration(info)
+			Expect(config.GetConfig("archive_mode")).To(Equal("off"))
+		})
+	})
+
 	It("adds shared_preload_library correctly", func() {
 		info := ConfigurationInfo{
 			Settings:                         CnpgConfigurationSettings,
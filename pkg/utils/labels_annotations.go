@@ -144,6 +144,10 @@ const (
 	// SkipWalArchiving is the name of the annotation which turns off WAL archiving
 	SkipWalArchiving = MetadataNamespace + "/skipWalArchiving"
 
+	// WalArchivingViaPlugin is the name of the annotation which marks the WAL
+	// archiving as handled by a plugin
+	WalArchivingViaPlugin = MetadataNamespace + "/walArchivingViaPlugin"
+
 	// skipEmptyWalArchiveCheck is the name of the annotation which turns off the checks that ensure that the WAL
 	// archive is empty before writing data
 	skipEmptyWalArchiveCheck = MetadataNamespace + "/skipEmptyWalArchiveCheck"
@@ -433,6 +437,12 @@ func IsWalArchivingDisabled(object *metav1.ObjectMeta) bool {
 	return object.Annotations[SkipWalArchiving] == string(annotationStatusEnabled)
 }
 
+// IsWalArchivingViaPlugin returns a boolean indicating if the WAL files
+// are archived by a plugin instead of the instance manager
+func IsWalArchivingViaPlugin(object *metav1.ObjectMeta) bool {
+	return object.Annotations[WalArchivingViaPlugin] == string(annotationStatusEnabled)
+}
+
 func mergeMap(receiver, giver map[string]string) map[string]string {
 	for key, value := range giver {
 		receiver[key] = value
@@ -54,6 +54,10 @@ const (
 	// PgControlDataDatabaseClusterStateKey is the status
 	// of the latest primary that run on this data directory.
 	PgControlDataDatabaseClusterStateKey pgControlDataKey = "Database cluster state"
+
+	// PgControlDataKeyDataPageChecksumVersion is the data page
+	// checksum version pg_controldata entry, zero when disabled
+	PgControlDataKeyDataPageChecksumVersion pgControlDataKey = "Data page checksum version"
 )
 
 // PgDataState represents the "Database cluster state" field of pg_controldata